@@ -6,10 +6,17 @@ import (
 	"net/http"
 
 	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/adapters/fxrate"
 	"auth-payment-backend/internal/adapters/handler"
+	"auth-payment-backend/internal/adapters/mailer"
 	"auth-payment-backend/internal/adapters/middleware"
+	"auth-payment-backend/internal/adapters/oauth"
+	"auth-payment-backend/internal/adapters/payment"
 	sys_payment "auth-payment-backend/internal/adapters/payment/stripe"
+	"auth-payment-backend/internal/adapters/pdf"
 	"auth-payment-backend/internal/adapters/repository"
+	"auth-payment-backend/internal/adapters/tax"
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/services"
 
 	"github.com/gin-contrib/cors"
@@ -31,13 +38,28 @@ func main() {
 			repository.NewMongoAffiliateRepository,
 			repository.NewMongoInvoiceRepository,
 			repository.NewMongoCouponRepository, // Added
+			repository.NewMongoAuditRepository,
+			repository.NewLoginAttemptStore,
+			repository.NewMongoOAuthStateRepository,
+			repository.NewMongoAPIKeyRepository,
+			repository.NewMongoSubscriptionRepository,
+			repository.NewMongoPaymentRepository,
+			mailer.NewLogMailer,
+			oauth.NewOAuthProviders,
 
 			// Payment Deps
 			sys_payment.NewStripeAdapter,
+			sys_payment.NewStripeConnectAdapter,
+			payment.NewCheckoutGateways,
 			services.NewPaymentService,
 			services.NewWalletService,
 			services.NewAffiliateService,
+			services.NewCommissionScheduler,
+			services.NewPaymentExpiryScheduler,
 			services.NewInvoiceService,
+			pdf.NewInvoiceRenderer,
+			tax.NewRuleTableCalculator,
+			fxrate.NewStaticRateProvider,
 
 			handler.NewPaymentHandler,
 			handler.NewWalletHandler,
@@ -49,11 +71,18 @@ func main() {
 			handler.NewCouponHandler, // Added
 
 			services.NewTokenService,
+			services.NewLoginGuard,
+			services.NewSocialAuthService,
+			services.NewAPIKeyService,
+			services.NewAccountService,
 			services.NewAuthService,
 			services.NewPricingService,
 			services.NewCouponService,  // Added
 			services.NewConnectService, // Added
 			handler.NewAuthHandler,
+			handler.NewOAuthHandler,
+			handler.NewAPIKeyHandler,
+			handler.NewAccountHandler,
 			handler.NewPricingHandler,
 			middleware.NewAuthMiddleware,
 			NewGinRouter,
@@ -61,33 +90,44 @@ func main() {
 		fx.Invoke(
 			RegisterRoutes,
 			StartServer,
+			StartCommissionScheduler,
+			StartPaymentExpiryScheduler,
 		),
 	)
 
 	app.Run()
 }
 
-func NewGinRouter() *gin.Engine {
+func NewGinRouter(cfg *config.Config) (*gin.Engine, error) {
 	r := gin.Default()
+	// ClientIP feeds the login throttle, so only configured proxies may override it
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
 
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:5173"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"}
 	config.AllowCredentials = true
 
 	r.Use(cors.New(config))
 
-	return r
+	return r, nil
 }
 
-func RegisterRoutes(router *gin.Engine, authHandler *handler.AuthHandler, pricingHandler *handler.PricingHandler, paymentHandler *handler.PaymentHandler, walletHandler *handler.WalletHandler, affiliateHandler *handler.AffiliateHandler, invoiceHandler *handler.InvoiceHandler, couponHandler *handler.CouponHandler, connectHandler *handler.ConnectHandler, authMiddleware *middleware.AuthMiddleware) {
-	authHandler.RegisterRoutes(router, authMiddleware.Protect())
-	pricingHandler.RegisterRoutes(router, authMiddleware.Protect())
-	paymentHandler.RegisterRoutes(router, authMiddleware.Protect())
-	walletHandler.RegisterRoutes(router, authMiddleware.Protect())
+func RegisterRoutes(router *gin.Engine, authHandler *handler.AuthHandler, oauthHandler *handler.OAuthHandler, apiKeyHandler *handler.APIKeyHandler, accountHandler *handler.AccountHandler, pricingHandler *handler.PricingHandler, paymentHandler *handler.PaymentHandler, walletHandler *handler.WalletHandler, affiliateHandler *handler.AffiliateHandler, invoiceHandler *handler.InvoiceHandler, couponHandler *handler.CouponHandler, connectHandler *handler.ConnectHandler, authMiddleware *middleware.AuthMiddleware) {
+	authHandler.RegisterRoutes(router, authMiddleware.Protect(), authMiddleware.RequireRole(domain.RoleAdmin))
+	oauthHandler.RegisterRoutes(router)
+	accountHandler.RegisterRoutes(router, authMiddleware.Protect())
+	apiKeyHandler.RegisterRoutes(router, authMiddleware.Protect(), authMiddleware.RequireRole(domain.RoleCreator, domain.RoleAdmin))
+
+	// Server-to-server callers may use an API key instead of a JWT on these groups
+	pricingHandler.RegisterRoutes(router, authMiddleware.ProtectWithAPIKey(domain.ScopePricingRead, domain.ScopePricingWrite))
+	paymentHandler.RegisterRoutes(router, authMiddleware.ProtectWithAPIKey(domain.ScopePaymentsRead, domain.ScopePaymentsWrite), authMiddleware.RequireRole(domain.RoleAdmin))
+	walletHandler.RegisterRoutes(router, authMiddleware.ProtectWithAPIKey(domain.ScopeWalletRead, domain.ScopeWalletWrite))
 	affiliateHandler.RegisterRoutes(router, authMiddleware.Protect())
-	invoiceHandler.RegisterRoutes(router, authMiddleware.Protect())
+	invoiceHandler.RegisterRoutes(router, authMiddleware.Protect(), authMiddleware.RequireRole(domain.RoleAdmin))
 
 	// Coupon Routes
 	couponGroup := router.Group("/coupons")
@@ -95,21 +135,33 @@ func RegisterRoutes(router *gin.Engine, authHandler *handler.AuthHandler, pricin
 	{
 		couponGroup.POST("", couponHandler.CreateCoupon)
 		couponGroup.GET("", couponHandler.ListCoupons)
+		couponGroup.PUT("/:id", couponHandler.UpdateCoupon)
+		couponGroup.DELETE("/:id", couponHandler.DeleteCoupon)
+		couponGroup.GET("/:id/redemptions", couponHandler.ListRedemptions)
+
+		// Campaigns: bulk-generated single-use codes
+		couponGroup.POST("/campaigns", couponHandler.CreateCampaign)
+		couponGroup.GET("/campaigns", couponHandler.ListCampaigns)
+		couponGroup.GET("/campaigns/:id", couponHandler.GetCampaign)
+		couponGroup.GET("/campaigns/:id/stats", couponHandler.GetCampaignStats)
+		couponGroup.GET("/campaigns/:id/export", couponHandler.ExportCampaign)
+		couponGroup.POST("/campaigns/:id/deactivate", couponHandler.DeactivateCampaign)
 		couponGroup.POST("/validate", couponHandler.ValidateCoupon) // Public? Maybe allow without auth if guest checkout? For now protected.
 	}
 
 	// Stripe Connect Routes
 	connectGroup := router.Group("/stripe/connect")
-	// callback is public or authenticated?
-	// The callback comes from Stripe browser redirect. It doesn't have the Bearer token in header usually!
-	// But we are using `state` = userID.
-	// We can't rely on AuthMiddleware for callback unless we pass token in state/cookie.
-	// So HandleCallback must be PUBLIC (no middleware), but secure via state check.
+	// The callback comes from a Stripe browser redirect without our Bearer token,
+	// so it is PUBLIC. It is secured by the single-use, server-stored `state` nonce
+	// that binds the flow to the user who started it.
 	connectGroup.GET("/callback", connectHandler.HandleCallback)
+	// Events of connected accounts, verified by their Stripe signature
+	connectGroup.POST("/webhook", connectHandler.Webhook)
 
 	// Protected routes
 	protectedConnect := connectGroup.Use(authMiddleware.Protect())
 	{
+		protectedConnect.POST("/onboard", connectHandler.StartOnboarding)
 		protectedConnect.GET("/oauth", connectHandler.GenerateOAuthURL)
 		protectedConnect.GET("/status", connectHandler.GetStatus)
 		protectedConnect.POST("/dashboard", connectHandler.GetDashboardLink)
@@ -139,3 +191,27 @@ func StartServer(lc fx.Lifecycle, cfg *config.Config, router *gin.Engine) {
 		},
 	})
 }
+
+func StartCommissionScheduler(lc fx.Lifecycle, scheduler *services.CommissionScheduler) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			scheduler.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return scheduler.Stop(ctx)
+		},
+	})
+}
+
+func StartPaymentExpiryScheduler(lc fx.Lifecycle, scheduler *services.PaymentExpiryScheduler) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			scheduler.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return scheduler.Stop(ctx)
+		},
+	})
+}
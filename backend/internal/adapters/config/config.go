@@ -1,6 +1,9 @@
 package config
 
 import (
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,9 +14,65 @@ type Config struct {
 	DBName                string  `mapstructure:"DB_NAME"`
 	JWTSecret             string  `mapstructure:"JWT_SECRET"`
 	FrontendURL           string  `mapstructure:"FRONTEND_URL"`
+	BackendURL            string  `mapstructure:"BACKEND_URL"` // Public base URL used for OAuth callbacks and affiliate links
 	StripeSecretKey       string  `mapstructure:"STRIPE_SECRET_KEY"`
+	StripeWebhookSecret   string  `mapstructure:"STRIPE_WEBHOOK_SECRET"` // Signing secret of the /webhooks/stripe endpoint
 	StripeConnectClientID string  `mapstructure:"STRIPE_CONNECT_CLIENT_ID"`
+	StripeConnectRedirect string  `mapstructure:"STRIPE_CONNECT_REDIRECT_URL"` // Defaults to {BACKEND_URL}/stripe/connect/callback
 	PlatformFeePercent    float64 `mapstructure:"PLATFORM_FEE_PERCENT"`
+	PlatformName          string  `mapstructure:"PLATFORM_NAME"`      // Seller name on invoices of platform sales
+	TaxOriginCountry      string  `mapstructure:"TAX_ORIGIN_COUNTRY"` // Where the platform is established; no reverse charge for buyers there
+	FXRatesFile           string  `mapstructure:"FX_RATES_FILE"`      // JSON exchange rates for derived prices and payouts
+
+	// Stripe Connect onboarding (Account Links) and the Connect webhook endpoint (/stripe/connect/webhook)
+	StripeConnectRefreshURL    string `mapstructure:"STRIPE_CONNECT_REFRESH_URL"` // Where an expired onboarding link sends the seller
+	StripeConnectReturnURL     string `mapstructure:"STRIPE_CONNECT_RETURN_URL"`  // Where the seller lands after onboarding
+	StripeConnectWebhookSecret string `mapstructure:"STRIPE_CONNECT_WEBHOOK_SECRET"`
+
+	// PayPal checkout (Orders v2). Enabled when the client ID is set.
+	PaypalClientID     string `mapstructure:"PAYPAL_CLIENT_ID"`
+	PaypalClientSecret string `mapstructure:"PAYPAL_CLIENT_SECRET"`
+	PaypalAPIURL       string `mapstructure:"PAYPAL_API_URL"`    // Sandbox unless set to https://api-m.paypal.com
+	PaypalWebhookID    string `mapstructure:"PAYPAL_WEBHOOK_ID"` // ID of the /webhooks/paypal endpoint, used to verify deliveries
+
+	// Manual payments (bank transfer). Enabled when instructions are set; {reference}, {amount}
+	// and {currency} in them are filled in per order.
+	ManualPaymentInstructions string        `mapstructure:"MANUAL_PAYMENT_INSTRUCTIONS"`
+	ManualPaymentExpiry       time.Duration `mapstructure:"MANUAL_PAYMENT_EXPIRY"`   // Unpaid orders expire after this
+	PaymentExpiryInterval     time.Duration `mapstructure:"PAYMENT_EXPIRY_INTERVAL"` // How often expired orders are checked
+
+	// Mock webhook endpoints (/payment/webhook/mock/*) settle, fail, refund and renew orders without
+	// a gateway. Off unless set; even then only admins can call them.
+	AllowMockWebhooks bool `mapstructure:"ALLOW_MOCK_WEBHOOKS"`
+
+	// Affiliate
+	CommissionMaturationInterval time.Duration `mapstructure:"COMMISSION_MATURATION_INTERVAL"` // How often pending commissions are checked
+
+	// Login throttling
+	LoginAttemptStore  string        `mapstructure:"LOGIN_ATTEMPT_STORE"`   // "memory" or "mongo"
+	LoginMaxAttempts   int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`    // per account before lockout
+	LoginIPMaxAttempts int           `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"` // per client IP before lockout
+	LoginAttemptWindow time.Duration `mapstructure:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutBase   time.Duration `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax    time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX"`
+
+	// Proxies allowed to set X-Forwarded-For. Comma-separated IPs or CIDRs; empty trusts
+	// none, so the client IP used for login throttling and click tracking is the peer address.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// Social login. A provider is enabled when its client ID is set.
+	// Endpoint overrides exist so tests can point at a local fake server.
+	GoogleClientID     string `mapstructure:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `mapstructure:"GOOGLE_CLIENT_SECRET"`
+	GoogleIssuer       string `mapstructure:"GOOGLE_ISSUER"`
+	GoogleAuthURL      string `mapstructure:"GOOGLE_AUTH_URL"`
+	GoogleTokenURL     string `mapstructure:"GOOGLE_TOKEN_URL"`
+	GoogleJWKSURL      string `mapstructure:"GOOGLE_JWKS_URL"`
+	GithubClientID     string `mapstructure:"GITHUB_CLIENT_ID"`
+	GithubClientSecret string `mapstructure:"GITHUB_CLIENT_SECRET"`
+	GithubAuthURL      string `mapstructure:"GITHUB_AUTH_URL"`
+	GithubTokenURL     string `mapstructure:"GITHUB_TOKEN_URL"`
+	GithubAPIURL       string `mapstructure:"GITHUB_API_URL"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -41,6 +100,81 @@ func LoadConfig() (*Config, error) {
 	if config.AppEnv == "" {
 		config.AppEnv = "development"
 	}
+	if config.FrontendURL == "" {
+		config.FrontendURL = "http://localhost:5173"
+	}
+	if config.BackendURL == "" {
+		config.BackendURL = "http://localhost:" + config.ServerPort
+	}
+	if config.StripeConnectRedirect == "" {
+		config.StripeConnectRedirect = config.BackendURL + "/stripe/connect/callback"
+	}
+	settingsURL := strings.TrimRight(config.FrontendURL, "/") + "/settings/stripe-connect"
+	if config.StripeConnectRefreshURL == "" {
+		config.StripeConnectRefreshURL = settingsURL + "?onboarding=refresh"
+	}
+	if config.StripeConnectReturnURL == "" {
+		config.StripeConnectReturnURL = settingsURL + "?onboarding=return"
+	}
+	if config.LoginAttemptStore == "" {
+		config.LoginAttemptStore = "memory"
+	}
+	if config.LoginMaxAttempts <= 0 {
+		config.LoginMaxAttempts = 5
+	}
+	if config.LoginIPMaxAttempts <= 0 {
+		config.LoginIPMaxAttempts = 20
+	}
+	if config.LoginAttemptWindow <= 0 {
+		config.LoginAttemptWindow = 15 * time.Minute
+	}
+	if config.LoginLockoutBase <= 0 {
+		config.LoginLockoutBase = time.Minute
+	}
+	if config.LoginLockoutMax <= 0 {
+		config.LoginLockoutMax = time.Hour
+	}
+
+	if config.PlatformName == "" {
+		config.PlatformName = "Platform"
+	}
+
+	if config.ManualPaymentExpiry <= 0 {
+		config.ManualPaymentExpiry = 7 * 24 * time.Hour
+	}
+	if config.PaymentExpiryInterval <= 0 {
+		config.PaymentExpiryInterval = time.Hour
+	}
+
+	if config.CommissionMaturationInterval <= 0 {
+		config.CommissionMaturationInterval = time.Hour
+	}
+
+	if config.PaypalAPIURL == "" {
+		config.PaypalAPIURL = "https://api-m.sandbox.paypal.com"
+	}
+
+	if config.GoogleIssuer == "" {
+		config.GoogleIssuer = "https://accounts.google.com"
+	}
+	if config.GoogleAuthURL == "" {
+		config.GoogleAuthURL = "https://accounts.google.com/o/oauth2/v2/auth"
+	}
+	if config.GoogleTokenURL == "" {
+		config.GoogleTokenURL = "https://oauth2.googleapis.com/token"
+	}
+	if config.GoogleJWKSURL == "" {
+		config.GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+	}
+	if config.GithubAuthURL == "" {
+		config.GithubAuthURL = "https://github.com/login/oauth/authorize"
+	}
+	if config.GithubTokenURL == "" {
+		config.GithubTokenURL = "https://github.com/login/oauth/access_token"
+	}
+	if config.GithubAPIURL == "" {
+		config.GithubAPIURL = "https://api.github.com"
+	}
 
 	return config, nil
 }
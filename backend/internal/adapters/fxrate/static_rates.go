@@ -0,0 +1,68 @@
+package fxrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// rateFile is the format of FX_RATES_FILE:
+//
+//	{"base": "USD", "rates": {"EUR": 0.92, "GBP": 0.79, "JPY": 151.2}}
+type rateFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"` // Units of each currency per unit of base
+}
+
+// StaticRateProvider serves fixed rates from a JSON file. Cross rates go through the base currency.
+type StaticRateProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewStaticRateProvider loads cfg.FXRatesFile. Without a file only same-currency conversions succeed.
+func NewStaticRateProvider(cfg *config.Config) (ports.FXRateProvider, error) {
+	p := &StaticRateProvider{base: domain.DefaultCurrency, rates: map[string]float64{}}
+	if cfg.FXRatesFile == "" {
+		return p, nil
+	}
+
+	raw, err := os.ReadFile(cfg.FXRatesFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading FX rates: %w", err)
+	}
+	var file rateFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing FX rates: %w", err)
+	}
+	if file.Base != "" {
+		p.base = domain.NormalizeCurrency(file.Base)
+	}
+	for currency, rate := range file.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("FX rate for %s must be positive", currency)
+		}
+		p.rates[domain.NormalizeCurrency(currency)] = rate
+	}
+	p.rates[p.base] = 1
+	return p, nil
+}
+
+func (p *StaticRateProvider) Rate(ctx context.Context, from string, to string) (float64, error) {
+	from, to = domain.NormalizeCurrency(from), domain.NormalizeCurrency(to)
+	if from == to {
+		return 1, nil
+	}
+	fromRate, okFrom := p.rates[from]
+	toRate, okTo := p.rates[to]
+	if !okFrom || !okTo {
+		return 0, errors.New("no exchange rate from " + from + " to " + to)
+	}
+	return toRate / fromRate, nil
+}
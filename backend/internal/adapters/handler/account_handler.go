@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"net/http"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	service ports.AccountService
+}
+
+func NewAccountHandler(service ports.AccountService) *AccountHandler {
+	return &AccountHandler{service: service}
+}
+
+type updateProfileRequest struct {
+	FullName string `json:"full_name" binding:"required"`
+}
+
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	var req updateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	user, err := h.service.UpdateProfile(c.Request.Context(), userID, req.FullName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateBillingAddress sets the address tax is charged by; a business buyer adds its VAT ID
+func (h *AccountHandler) UpdateBillingAddress(c *gin.Context) {
+	var req domain.BillingAddress
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	user, err := h.service.UpdateBillingAddress(c.Request.Context(), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AccountHandler) DeleteBillingAddress(c *gin.Context) {
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	user, err := h.service.UpdateBillingAddress(c.Request.Context(), userID, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+type preferredCurrencyRequest struct {
+	Currency string `json:"currency"` // Empty = the billing country's currency
+}
+
+// UpdatePreferredCurrency sets the currency prices are shown and charged in
+func (h *AccountHandler) UpdatePreferredCurrency(c *gin.Context) {
+	var req preferredCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	user, err := h.service.UpdatePreferredCurrency(c.Request.Context(), userID, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+type changeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password"`
+}
+
+func (h *AccountHandler) RequestEmailChange(c *gin.Context) {
+	var req changeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	if err := h.service.RequestEmailChange(c.Request.Context(), userID, req.Email, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation sent to the new email address"})
+}
+
+type confirmEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (h *AccountHandler) ConfirmEmailChange(c *gin.Context) {
+	var req confirmEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	user, err := h.service.ConfirmEmailChange(c.Request.Context(), userID, req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	accessToken, refreshToken, err := h.service.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Other sessions are now revoked; hand this one fresh tokens
+	c.SetCookie("refresh_token", refreshToken, 3600*24*7, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	var req deleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	if err := h.service.DeleteAccount(c.Request.Context(), userID, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func (h *AccountHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
+	me := router.Group("/users/me")
+	me.Use(middleware)
+	{
+		me.PATCH("", h.UpdateProfile)
+		me.PUT("/billing-address", h.UpdateBillingAddress)
+		me.DELETE("/billing-address", h.DeleteBillingAddress)
+		me.PUT("/currency", h.UpdatePreferredCurrency)
+		me.POST("/email", h.RequestEmailChange)
+		me.POST("/email/confirm", h.ConfirmEmailChange)
+		me.POST("/password", h.ChangePassword)
+		me.DELETE("", h.DeleteAccount)
+	}
+}
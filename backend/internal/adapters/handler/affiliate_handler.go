@@ -1,24 +1,64 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AffiliateHandler struct {
-	service ports.AffiliateService
+	service     ports.AffiliateService
+	cookie      *referralCookie
+	frontendURL string
 }
 
-func NewAffiliateHandler(service ports.AffiliateService) *AffiliateHandler {
-	return &AffiliateHandler{service: service}
+func NewAffiliateHandler(service ports.AffiliateService, cfg *config.Config) *AffiliateHandler {
+	return &AffiliateHandler{
+		service:     service,
+		cookie:      newReferralCookie(cfg.JWTSecret),
+		frontendURL: strings.TrimRight(cfg.FrontendURL, "/"),
+	}
+}
+
+// Redirect records a referral click, stores it in the signed attribution cookie
+// and sends the visitor on to the frontend. `to` may name a path on the frontend.
+func (h *AffiliateHandler) Redirect(c *gin.Context) {
+	target := h.frontendURL + "/"
+	if to := c.Query("to"); strings.HasPrefix(to, "/") && !strings.HasPrefix(to, "//") {
+		target = h.frontendURL + to
+	}
+
+	code := c.Param("code")
+	_, program, err := h.service.TrackClick(c.Request.Context(), code, c.ClientIP(), c.Request.UserAgent(), c.Request.Referer())
+	if err != nil {
+		// Unknown codes still land the visitor somewhere useful
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	attr := h.cookie.Read(c)
+	if attr == nil {
+		attr = &domain.AffiliateAttribution{}
+	}
+	attr.Record(domain.AffiliateTouch{Code: code, ClickedAt: time.Now()})
+	_ = h.cookie.Write(c, attr, int(program.Window().Seconds()))
+
+	c.Redirect(http.StatusFound, target)
 }
 
 type createLinkRequest struct {
-	ProgramID string `json:"program_id" binding:"required"`
-	Code      string `json:"code" binding:"required"`
+	ProgramID     string `json:"program_id" binding:"required"`
+	Code          string `json:"code" binding:"required"`
+	RecruiterCode string `json:"recruiter_code"` // Optional: code of the affiliate who recruited you
 }
 
 func (h *AffiliateHandler) CreateLink(c *gin.Context) {
@@ -28,9 +68,9 @@ func (h *AffiliateHandler) CreateLink(c *gin.Context) {
 		return
 	}
 
-	userID := "650000000000000000000000" // Mock
+	user := c.MustGet("user").(*domain.User)
 
-	link, err := h.service.GenerateLink(c.Request.Context(), userID, req.ProgramID, req.Code)
+	link, err := h.service.GenerateLink(c.Request.Context(), user.ID.Hex(), req.ProgramID, req.Code, req.RecruiterCode)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -39,10 +79,47 @@ func (h *AffiliateHandler) CreateLink(c *gin.Context) {
 	c.JSON(http.StatusCreated, link)
 }
 
+// statsRange reads ?from= and ?to= (YYYY-MM-DD, inclusive), defaulting to the last 30 days
+func statsRange(c *gin.Context) (time.Time, time.Time, error) {
+	const layout = "2006-01-02"
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	to := today
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be YYYY-MM-DD")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be YYYY-MM-DD")
+		}
+		from = t
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	if to.Sub(from) > 366*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("date range cannot exceed one year")
+	}
+	// Make the end exclusive
+	return from, to.AddDate(0, 0, 1), nil
+}
+
 func (h *AffiliateHandler) GetStats(c *gin.Context) {
-	userID := "650000000000000000000000" // Mock
+	user := c.MustGet("user").(*domain.User)
 
-	stats, err := h.service.GetMyStats(c.Request.Context(), userID)
+	from, to, err := statsRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.service.GetMyStats(c.Request.Context(), user.ID.Hex(), from, to)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -51,33 +128,278 @@ func (h *AffiliateHandler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// Temporary for testing: Create Program (usually Admin only)
-type createProgramRequest struct {
-	Rate float64 `json:"rate"`
+type programRequest struct {
+	Name                  string                  `json:"name" binding:"required"`
+	Terms                 string                  `json:"terms"`
+	ProductID             string                  `json:"product_id"` // Empty = all of the creator's products
+	Rate                  float64                 `json:"rate"`
+	EnrollmentMode        domain.EnrollmentMode   `json:"enrollment_mode"`         // open (default) or approval
+	AttributionModel      domain.AttributionModel `json:"attribution_model"`       // first_click or last_click
+	AttributionWindowDays int                     `json:"attribution_window_days"` // Cookie duration, 0 = default (30)
+	RefundWindowDays      int                     `json:"refund_window_days"`      // 0 = default (30)
+	Tier2Rate             float64                 `json:"tier2_rate"`              // Recruiter's share, 0 = disabled
+	Tier2MaxAmount        float64                 `json:"tier2_max_amount"`        // 0 = uncapped
+}
+
+func (r *programRequest) toProgram() (*domain.AffiliateProgram, error) {
+	program := &domain.AffiliateProgram{
+		Name:                  r.Name,
+		Terms:                 r.Terms,
+		CommissionRate:        r.Rate,
+		EnrollmentMode:        r.EnrollmentMode,
+		AttributionModel:      r.AttributionModel,
+		AttributionWindowDays: r.AttributionWindowDays,
+		RefundWindowDays:      r.RefundWindowDays,
+		Tier2Rate:             r.Tier2Rate,
+		Tier2MaxAmount:        r.Tier2MaxAmount,
+	}
+	if r.ProductID != "" {
+		oid, err := primitive.ObjectIDFromHex(r.ProductID)
+		if err != nil {
+			return nil, errors.New("invalid product ID")
+		}
+		program.ProductID = &oid
+	}
+	return program, nil
 }
 
 func (h *AffiliateHandler) CreateProgram(c *gin.Context) {
-	var req createProgramRequest
+	user := c.MustGet("user").(*domain.User)
+
+	var req programRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	program, err := req.toProgram()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prog, err := h.service.CreateProgram(c.Request.Context(), user.ID.Hex(), program)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, prog)
+}
 
-	creatorID := "650000000000000000000000"
+// ListPrograms lists the current creator's programs
+func (h *AffiliateHandler) ListPrograms(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
 
-	prog, err := h.service.CreateProgram(c.Request.Context(), creatorID, nil, req.Rate)
+	programs, err := h.service.ListPrograms(c.Request.Context(), user.ID.Hex())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, prog)
+	c.JSON(http.StatusOK, programs)
+}
+
+// GetProgram shows a program's terms to prospective affiliates
+func (h *AffiliateHandler) GetProgram(c *gin.Context) {
+	program, err := h.service.GetProgram(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, program)
+}
+
+func (h *AffiliateHandler) UpdateProgram(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	var req programRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	terms, err := req.toProgram()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	program, err := h.service.UpdateProgram(c.Request.Context(), user.ID.Hex(), c.Param("id"), terms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, program)
+}
+
+// setProgramStatus returns a handler that moves the program to status (pause, resume, close)
+func (h *AffiliateHandler) setProgramStatus(status domain.ProgramStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*domain.User)
+
+		program, err := h.service.SetProgramStatus(c.Request.Context(), user.ID.Hex(), c.Param("id"), status)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, program)
+	}
+}
+
+type enrollRequest struct {
+	Message string `json:"message"`
+}
+
+// Enroll applies the current user to a program as an affiliate
+func (h *AffiliateHandler) Enroll(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	var req enrollRequest
+	// The message is optional
+	_ = c.ShouldBindJSON(&req)
+
+	enrollment, err := h.service.Enroll(c.Request.Context(), user.ID.Hex(), c.Param("id"), req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+func (h *AffiliateHandler) ListMyEnrollments(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	enrollments, err := h.service.ListMyEnrollments(c.Request.Context(), user.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, enrollments)
+}
+
+// ListEnrollments lists applicants to one of the current creator's programs, optionally filtered by ?status=
+func (h *AffiliateHandler) ListEnrollments(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	status := domain.EnrollmentStatus(c.Query("status"))
+	enrollments, err := h.service.ListEnrollments(c.Request.Context(), user.ID.Hex(), c.Param("id"), status)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, enrollments)
+}
+
+type decideEnrollmentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// decideEnrollment returns a handler that approves or rejects an application
+func (h *AffiliateHandler) decideEnrollment(approve bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*domain.User)
+
+		var req decideEnrollmentRequest
+		// The reason is optional
+		_ = c.ShouldBindJSON(&req)
+
+		enrollment, err := h.service.DecideEnrollment(c.Request.Context(), user.ID.Hex(), c.Param("id"), approve, req.Reason)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// GetLeaderboard ranks the affiliates of one of the current creator's programs by earnings
+func (h *AffiliateHandler) GetLeaderboard(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	from, to, err := statsRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	entries, err := h.service.GetLeaderboard(c.Request.Context(), user.ID.Hex(), c.Param("id"), from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ListCommissions lists commissions on the current creator's programs, optionally filtered by ?status=
+func (h *AffiliateHandler) ListCommissions(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	status := domain.CommissionStatus(c.Query("status"))
+	comms, err := h.service.ListCreatorCommissions(c.Request.Context(), user.ID.Hex(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comms)
+}
+
+func (h *AffiliateHandler) ApproveCommission(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	comm, err := h.service.ApproveCommission(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comm)
+}
+
+type rejectCommissionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *AffiliateHandler) RejectCommission(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	var req rejectCommissionRequest
+	// The reason is optional
+	_ = c.ShouldBindJSON(&req)
+
+	comm, err := h.service.RejectCommission(c.Request.Context(), user.ID.Hex(), c.Param("id"), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, comm)
 }
 
 func (h *AffiliateHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
+	// Public referral entry point
+	router.GET("/ref/:code", h.Redirect)
+
 	aff := router.Group("/affiliate")
+	aff.Use(middleware)
 	{
+		// Affiliates
+		aff.GET("/programs/:id", h.GetProgram)
+		aff.POST("/programs/:id/enroll", h.Enroll)
+		aff.GET("/enrollments", h.ListMyEnrollments)
 		aff.POST("/links", h.CreateLink)
 		aff.GET("/stats", h.GetStats)
-		aff.POST("/programs", h.CreateProgram) // Testing only
+
+		// Creators managing their own programs
+		aff.POST("/programs", h.CreateProgram)
+		aff.GET("/programs", h.ListPrograms)
+		aff.PUT("/programs/:id", h.UpdateProgram)
+		aff.POST("/programs/:id/pause", h.setProgramStatus(domain.ProgramStatusPaused))
+		aff.POST("/programs/:id/resume", h.setProgramStatus(domain.ProgramStatusActive))
+		aff.DELETE("/programs/:id", h.setProgramStatus(domain.ProgramStatusClosed)) // Closes; history is kept
+		aff.GET("/programs/:id/leaderboard", h.GetLeaderboard)
+		aff.GET("/programs/:id/enrollments", h.ListEnrollments)
+		aff.POST("/enrollments/:id/approve", h.decideEnrollment(true))
+		aff.POST("/enrollments/:id/reject", h.decideEnrollment(false))
+
+		// Creator review of commissions earned on their programs
+		aff.GET("/commissions", h.ListCommissions)
+		aff.POST("/commissions/:id/approve", h.ApproveCommission)
+		aff.POST("/commissions/:id/reject", h.RejectCommission)
 	}
 }
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	service ports.APIKeyService
+}
+
+func NewAPIKeyHandler(service ports.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+type createAPIKeyRequest struct {
+	Name      string               `json:"name" binding:"required"`
+	Scopes    []domain.APIKeyScope `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time           `json:"expires_at"`
+}
+
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*domain.User)
+
+	key, rawKey, err := h.service.CreateKey(c.Request.Context(), user.ID.Hex(), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The plaintext key is only ever returned here
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": key,
+		"key":     rawKey,
+	})
+}
+
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	keys, err := h.service.ListKeys(c.Request.Context(), user.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	if err := h.service.RevokeKey(c.Request.Context(), user.ID.Hex(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
+
+// RegisterRoutes expects a JWT-only middleware: API keys must not be able to mint more keys
+func (h *APIKeyHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc, creatorOnly gin.HandlerFunc) {
+	keys := router.Group("/api-keys")
+	keys.Use(middleware, creatorOnly)
+	{
+		keys.POST("", h.CreateKey)
+		keys.GET("", h.ListKeys)
+		keys.DELETE("/:id", h.RevokeKey)
+	}
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
+	"auth-payment-backend/internal/core/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,7 +42,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Auto-login: Generate tokens
-	accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), req.Email, req.Password)
+	accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
 	if err != nil {
 		// If login fails after register (unlikely), just return user created 201
 		c.JSON(http.StatusCreated, user)
@@ -67,8 +71,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), req.Email, req.Password)
+	accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
 	if err != nil {
+		var lockErr *services.LockoutError
+		if errors.As(err, &lockErr) {
+			c.Header("Retry-After", strconv.Itoa(int(lockErr.RetryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -135,7 +145,19 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func (h *AuthHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
+// UnlockUser clears a login lockout (admin only)
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	admin := c.MustGet("user").(*domain.User)
+
+	if err := h.authService.UnlockAccount(c.Request.Context(), admin.ID.Hex(), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "unlocked"})
+}
+
+func (h *AuthHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc, adminOnly gin.HandlerFunc) {
 	auth := router.Group("/auth")
 	{
 		auth.POST("/register", h.Register)
@@ -150,4 +172,10 @@ func (h *AuthHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerF
 	{
 		users.GET("/me", h.Me)
 	}
+
+	admin := router.Group("/admin/users")
+	admin.Use(middleware, adminOnly)
+	{
+		admin.POST("/:id/unlock", h.UnlockUser)
+	}
 }
@@ -1,80 +1,118 @@
 package handler
 
 import (
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"auth-payment-backend/internal/adapters/config"
 	"auth-payment-backend/internal/core/domain"
-	"auth-payment-backend/internal/core/services"
+	"auth-payment-backend/internal/core/ports"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ConnectHandler struct {
-	connectService *services.ConnectService
-	configDomain   string // Frontend URL for redirect
+	connectService ports.ConnectService
+	settingsURL    string // Frontend page to land on after the OAuth callback
 }
 
-func NewConnectHandler(connectService *services.ConnectService) *ConnectHandler {
-	// Ideally we inject config to get frontend URL, but for now we can infer or pass it.
-	// Let's modify NewConnectHandler signature in main.go later if needed,
-	// or just assume we can get it from service or context.
-	// Service has config.
+func NewConnectHandler(connectService ports.ConnectService, cfg *config.Config) *ConnectHandler {
 	return &ConnectHandler{
 		connectService: connectService,
+		settingsURL:    strings.TrimRight(cfg.FrontendURL, "/") + "/settings/stripe-connect",
 	}
 }
 
+type onboardingRequest struct {
+	Type string `json:"type"` // "express" (default) or "standard"
+}
+
+// StartOnboarding returns the Stripe onboarding link of the user's connected account
+func (h *ConnectHandler) StartOnboarding(c *gin.Context) {
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	var req onboardingRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	onboardingURL, err := h.connectService.StartOnboarding(c.Request.Context(), userID, req.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": onboardingURL})
+}
+
 // GenerateOAuthURL returns the Stripe OAuth URL
 func (h *ConnectHandler) GenerateOAuthURL(c *gin.Context) {
 	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	url := h.connectService.GenerateOAuthURL(userID)
-	c.JSON(http.StatusOK, gin.H{"url": url})
+	authURL, err := h.connectService.GenerateOAuthURL(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
 }
 
 // HandleCallback handles the Stripe redirect with auth code
 func (h *ConnectHandler) HandleCallback(c *gin.Context) {
 	code := c.Query("code")
-	state := c.Query("state") // userID
-	// error := c.Query("error")
+	state := c.Query("state")
+
+	if stripeErr := c.Query("error"); stripeErr != "" {
+		h.redirectWithError(c, stripeErr)
+		return
+	}
 
 	if code == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code missing"})
 		return
 	}
 
-	// In a real app, verify state matches current user or session.
-	// Here `state` IS the userID passed to Stripe.
-	userID := state
+	// Resolve the initiating user from the server-stored state before touching Stripe,
+	// so a forged or replayed callback can't attach an account to someone else.
+	userID, err := h.connectService.ConsumeOAuthState(c.Request.Context(), state)
+	if err != nil {
+		h.redirectWithError(c, err.Error())
+		return
+	}
 
 	// Exchange code for connected account ID
 	connectID, err := h.connectService.HandleOAuthCallback(c.Request.Context(), code)
 	if err != nil {
-		// Redirect to frontend with error
-		// We need the frontend URL. It's in config, but service has it.
-		// Let's hardcode for now matching config or get from service if I exposed it. I didn't verify if service exposes config.
-		// It does: `type ConnectService struct { config *config.Config ... }` but it's private.
-		// Just hardcode for dev/demo or assume standard env.
-		c.Redirect(http.StatusTemporaryRedirect, "http://localhost:5173/settings/stripe-connect?error="+err.Error())
+		h.redirectWithError(c, err.Error())
 		return
 	}
 
 	// Update user
 	err = h.connectService.ConnectUser(c.Request.Context(), userID, connectID)
 	if err != nil {
-		c.Redirect(http.StatusTemporaryRedirect, "http://localhost:5173/settings/stripe-connect?error="+err.Error())
+		h.redirectWithError(c, err.Error())
 		return
 	}
 
 	// Success redirect
-	c.Redirect(http.StatusTemporaryRedirect, "http://localhost:5173/settings/stripe-connect?success=true")
+	c.Redirect(http.StatusTemporaryRedirect, h.settingsURL+"?success=true")
+}
+
+func (h *ConnectHandler) redirectWithError(c *gin.Context, msg string) {
+	c.Redirect(http.StatusTemporaryRedirect, h.settingsURL+"?error="+url.QueryEscape(msg))
 }
 
 // GetStatus returns the current user's connection status
 func (h *ConnectHandler) GetStatus(c *gin.Context) {
 	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	status, err := h.connectService.GetConnectionStatus(c.Request.Context(), userID)
+	refresh := c.Query("refresh") == "true"
+	status, err := h.connectService.GetConnectionStatus(c.Request.Context(), userID, refresh)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -111,3 +149,20 @@ func (h *ConnectHandler) Disconnect(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"status": "disconnected"})
 }
+
+// Webhook receives events of connected accounts (Stripe Connect webhook endpoint)
+func (h *ConnectHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.connectService.HandleWebhook(c.Request.Context(), payload, c.Request.Header); err != nil {
+		log.Printf("Connect Webhook Error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
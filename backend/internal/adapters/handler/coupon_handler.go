@@ -3,7 +3,10 @@ package handler
 import (
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
+	"encoding/csv"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,6 +25,7 @@ func (h *CouponHandler) CreateCoupon(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	coupon.CreatorID = c.MustGet("user").(*domain.User).ID
 
 	if err := h.service.CreateCoupon(c.Request.Context(), &coupon); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -32,7 +36,8 @@ func (h *CouponHandler) CreateCoupon(c *gin.Context) {
 }
 
 func (h *CouponHandler) ListCoupons(c *gin.Context) {
-	coupons, err := h.service.ListCoupons(c.Request.Context())
+	user := c.MustGet("user").(*domain.User)
+	coupons, err := h.service.ListCoupons(c.Request.Context(), user.ID.Hex())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -42,16 +47,26 @@ func (h *CouponHandler) ListCoupons(c *gin.Context) {
 
 func (h *CouponHandler) ValidateCoupon(c *gin.Context) {
 	var req struct {
-		Code   string `json:"code"`
-		PlanID string `json:"plan_id"`
+		Code        string   `json:"code"`
+		PlanID      string   `json:"plan_id"`
+		OrderAmount float64  `json:"order_amount"` // Optional, defaults to the plan price
+		EarlyBird   bool     `json:"early_bird"`   // The early-bird price is applied
+		OtherCodes  []string `json:"other_codes"`  // Coupons already on the order
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user := c.MustGet("user").(*domain.User)
 
-	coupon, discount, err := h.service.ValidateCoupon(c.Request.Context(), req.Code, req.PlanID)
+	coupon, discount, err := h.service.ValidateCoupon(c.Request.Context(), req.Code, &domain.CouponCheck{
+		PlanID:      req.PlanID,
+		UserID:      user.ID.Hex(),
+		OrderAmount: req.OrderAmount,
+		EarlyBird:   req.EarlyBird,
+		OtherCodes:  req.OtherCodes,
+	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -62,3 +77,128 @@ func (h *CouponHandler) ValidateCoupon(c *gin.Context) {
 		"discount": discount,
 	})
 }
+
+// ListRedemptions reports every use of a coupon with its customer, payment and discount
+func (h *CouponHandler) ListRedemptions(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	redemptions, err := h.service.ListRedemptions(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, redemptions)
+}
+
+// UpdateCoupon replaces the rules of a coupon owned by the user
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	var update domain.Coupon
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := c.MustGet("user").(*domain.User)
+
+	coupon, err := h.service.UpdateCoupon(c.Request.Context(), user.ID.Hex(), c.Param("id"), &update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, coupon)
+}
+
+func (h *CouponHandler) DeleteCoupon(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	if err := h.service.DeleteCoupon(c.Request.Context(), user.ID.Hex(), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// CreateCampaign generates `code_count` unique codes from `pattern`, all sharing `rules`
+func (h *CouponHandler) CreateCampaign(c *gin.Context) {
+	var campaign domain.CouponCampaign
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := c.MustGet("user").(*domain.User)
+
+	if err := h.service.CreateCampaign(c.Request.Context(), user.ID.Hex(), &campaign); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, campaign)
+}
+
+func (h *CouponHandler) ListCampaigns(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	campaigns, err := h.service.ListCampaigns(c.Request.Context(), user.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, campaigns)
+}
+
+func (h *CouponHandler) GetCampaign(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	campaign, err := h.service.GetCampaign(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, campaign)
+}
+
+// GetCampaignStats reports redemptions across every code of the campaign
+func (h *CouponHandler) GetCampaignStats(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	stats, err := h.service.GetCampaignStats(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ExportCampaign downloads the campaign's codes as CSV
+func (h *CouponHandler) ExportCampaign(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	coupons, err := h.service.GetCampaignCodes(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=campaign-%s.csv", c.Param("id")))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"code", "used_count", "max_uses", "is_active", "expiry_date"})
+	for _, coupon := range coupons {
+		expiry := ""
+		if coupon.ExpiryDate != nil {
+			expiry = coupon.ExpiryDate.Format("2006-01-02")
+		}
+		_ = w.Write([]string{
+			coupon.Code,
+			strconv.Itoa(coupon.UsedCount),
+			strconv.Itoa(coupon.MaxUses),
+			strconv.FormatBool(coupon.IsActive),
+			expiry,
+		})
+	}
+	w.Flush()
+}
+
+func (h *CouponHandler) DeactivateCampaign(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	campaign, err := h.service.DeactivateCampaign(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, campaign)
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +20,7 @@ func NewInvoiceHandler(service ports.InvoiceService) *InvoiceHandler {
 	return &InvoiceHandler{service: service}
 }
 
+// Generate issues the invoice of a payment by hand, e.g. if automatic generation failed (admins only)
 func (h *InvoiceHandler) Generate(c *gin.Context) {
 	txID := c.Param("txId")
 	inv, err := h.service.GenerateInvoiceForTransaction(c.Request.Context(), txID)
@@ -27,23 +31,182 @@ func (h *InvoiceHandler) Generate(c *gin.Context) {
 	c.JSON(http.StatusCreated, inv)
 }
 
+// List returns the user's invoices: ?view=purchases (default), sales, or all for admins.
+// Optional filters: status, from and to (YYYY-MM-DD, inclusive).
+func (h *InvoiceHandler) List(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+
+	filter, err := invoiceFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoices, err := h.service.ListInvoices(c.Request.Context(), user.ID.Hex(), domain.InvoiceView(c.Query("view")), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invoices)
+}
+
+func invoiceFilter(c *gin.Context) (domain.InvoiceFilter, error) {
+	const layout = "2006-01-02"
+	var filter domain.InvoiceFilter
+
+	switch status := domain.InvoiceStatus(c.Query("status")); status {
+	case "", domain.InvoiceStatusPaid, domain.InvoiceStatusPending, domain.InvoiceStatusVoid:
+		filter.Status = status
+	default:
+		return filter, errors.New("invalid status")
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return filter, errors.New("from must be YYYY-MM-DD")
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			return filter, errors.New("to must be YYYY-MM-DD")
+		}
+		// Make the end exclusive
+		t = t.AddDate(0, 0, 1)
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errors.New("from must not be after to")
+	}
+	return filter, nil
+}
+
+func (h *InvoiceHandler) Get(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	inv, err := h.service.GetInvoice(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, inv)
+}
+
 func (h *InvoiceHandler) Download(c *gin.Context) {
 	id := c.Param("id")
-	pdfBytes, err := h.service.GetInvoicePDF(c.Request.Context(), id)
+	user := c.MustGet("user").(*domain.User)
+	pdfBytes, err := h.service.GetInvoicePDF(c.Request.Context(), user.ID.Hex(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
 		return
 	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=invoice-%s.txt", id))
-	c.Data(http.StatusOK, "text/plain", pdfBytes)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=invoice-%s.pdf", id))
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
 }
 
-func (h *InvoiceHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
+func (h *InvoiceHandler) Void(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := c.MustGet("user").(*domain.User)
+
+	inv, err := h.service.VoidInvoice(c.Request.Context(), user.ID.Hex(), c.Param("id"), req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, inv)
+}
+
+// Reissue voids the invoice and returns its replacement
+func (h *InvoiceHandler) Reissue(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	inv, err := h.service.ReissueInvoice(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, inv)
+}
+
+type creditNoteRequest struct {
+	Note  string               `json:"note" binding:"required"`
+	Items []domain.InvoiceItem `json:"items" binding:"required"` // Net amounts; tax is added at the invoice's rate
+}
+
+// IssueCreditNote credits part of an invoice as a goodwill adjustment (admins only)
+func (h *InvoiceHandler) IssueCreditNote(c *gin.Context) {
+	var req creditNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user := c.MustGet("user").(*domain.User)
+
+	cn, err := h.service.IssueAdjustmentCreditNote(c.Request.Context(), user.ID.Hex(), c.Param("id"), req.Note, req.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, cn)
+}
+
+func (h *InvoiceHandler) ListCreditNotes(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	notes, err := h.service.ListCreditNotes(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, notes)
+}
+
+func (h *InvoiceHandler) GetCreditNote(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	cn, err := h.service.GetCreditNote(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cn)
+}
+
+func (h *InvoiceHandler) DownloadCreditNote(c *gin.Context) {
+	id := c.Param("id")
+	user := c.MustGet("user").(*domain.User)
+	pdfBytes, err := h.service.GetCreditNotePDF(c.Request.Context(), user.ID.Hex(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credit note not found"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=credit-note-%s.pdf", id))
+	c.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+func (h *InvoiceHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc, adminOnly gin.HandlerFunc) {
 	inv := router.Group("/invoices")
-	// inv.Use(middleware)
+	inv.Use(middleware)
 	{
-		inv.POST("/generate/:txId", h.Generate)
+		inv.GET("", h.List)
+		inv.GET("/:id", h.Get)
 		inv.GET("/:id/download", h.Download)
+		inv.POST("/:id/void", h.Void)
+		inv.POST("/:id/reissue", h.Reissue)
+		inv.POST("/generate/:txId", adminOnly, h.Generate)
+		inv.GET("/:id/credit-notes", h.ListCreditNotes)
+		inv.POST("/:id/credit-notes", adminOnly, h.IssueCreditNote)
+	}
+
+	credit := router.Group("/credit-notes")
+	credit.Use(middleware)
+	{
+		credit.GET("/:id", h.GetCreditNote)
+		credit.GET("/:id/download", h.DownloadCreditNote)
 	}
 }
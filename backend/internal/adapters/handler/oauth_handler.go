@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/ports"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthStateCookie binds a login's state to the browser that started it, so an
+// attacker can't complete their own login in a victim's browser
+const oauthStateCookie = "oauth_state"
+
+type OAuthHandler struct {
+	service     ports.SocialAuthService
+	frontendURL string
+}
+
+func NewOAuthHandler(service ports.SocialAuthService, cfg *config.Config) *OAuthHandler {
+	return &OAuthHandler{
+		service:     service,
+		frontendURL: strings.TrimRight(cfg.FrontendURL, "/"),
+	}
+}
+
+// Start redirects the browser to the provider's consent screen
+func (h *OAuthHandler) Start(c *gin.Context) {
+	authURL, state, err := h.service.StartLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Lax still sends the cookie on the provider's top-level redirect back to us
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, 600, "/auth/oauth", "", false, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes the login and hands the tokens to the frontend
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		h.redirectWithError(c, providerErr)
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, "/auth/oauth", "", false, true)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		h.redirectWithError(c, "login was not started in this browser")
+		return
+	}
+
+	accessToken, refreshToken, err := h.service.CompleteLogin(c.Request.Context(), c.Param("provider"), c.Query("code"), state)
+	if err != nil {
+		h.redirectWithError(c, err.Error())
+		return
+	}
+
+	c.SetCookie("refresh_token", refreshToken, 3600*24*7, "/", "", false, true)
+
+	// Fragment so the access token never reaches server logs or Referer headers
+	fragment := url.Values{}
+	fragment.Set("access_token", accessToken)
+	c.Redirect(http.StatusFound, h.frontendURL+"/oauth/callback#"+fragment.Encode())
+}
+
+func (h *OAuthHandler) redirectWithError(c *gin.Context, msg string) {
+	c.Redirect(http.StatusFound, h.frontendURL+"/login?error="+url.QueryEscape(msg))
+}
+
+func (h *OAuthHandler) RegisterRoutes(router *gin.Engine) {
+	oauth := router.Group("/auth/oauth")
+	{
+		oauth.GET("/:provider", h.Start)
+		oauth.GET("/:provider/callback", h.Callback)
+	}
+}
@@ -2,29 +2,58 @@ package handler
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PaymentHandler struct {
-	service *services.PaymentServiceImpl
+	service   *services.PaymentServiceImpl
+	referral  *referralCookie
+	allowMock bool // Register the mock webhook endpoints
 }
 
-func NewPaymentHandler(service *services.PaymentServiceImpl) *PaymentHandler {
-	return &PaymentHandler{service: service}
+func NewPaymentHandler(service *services.PaymentServiceImpl, cfg *config.Config) *PaymentHandler {
+	return &PaymentHandler{
+		service:   service,
+		referral:  newReferralCookie(cfg.JWTSecret),
+		allowMock: cfg.AllowMockWebhooks,
+	}
 }
 
 type checkoutRequest struct {
-	PlanID        string  `json:"plan_id" binding:"required"`
-	AffiliateCode string  `json:"affiliate_code"`
-	CouponCode    string  `json:"coupon_code"` // Added
-	Amount        float64 `json:"amount"`      // For Donation
-	Quantity      int     `json:"quantity"`    // For Tiered
-	TierIndex     int     `json:"tier_index"`  // For Tiered
+	PlanID        string   `json:"plan_id" binding:"required"`
+	AffiliateCode string   `json:"affiliate_code"`
+	CouponCode    string   `json:"coupon_code"`  // Added
+	CouponCodes   []string `json:"coupon_codes"` // Stacked coupons, in the order they apply
+	Amount        float64  `json:"amount"`       // For Donation
+	Quantity      int      `json:"quantity"`     // For Tiered
+	TierIndex     int      `json:"tier_index"`   // For Tiered
+	Currency      string   `json:"currency"`     // Empty = the buyer's preferred or local currency
+	Gateway       string   `json:"gateway"`      // "stripe", "paypal" or "manual"; empty = stripe
+}
+
+// couponCodes merges coupon_code and coupon_codes, dropping blanks and duplicates
+func (r *checkoutRequest) couponCodes() []string {
+	var codes []string
+	seen := map[string]bool{}
+	for _, code := range append([]string{r.CouponCode}, r.CouponCodes...) {
+		code = strings.TrimSpace(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes
 }
 
 func (h *PaymentHandler) InitiateCheckout(c *gin.Context) {
@@ -34,20 +63,127 @@ func (h *PaymentHandler) InitiateCheckout(c *gin.Context) {
 		return
 	}
 
-	// Get User from Auth Middleware
-	// metadata, _ := c.Get("user")
-	// userID := metadata.(*domain.User).ID.Hex()
-	userID := "650000000000000000000000" // Mock for now until Auth is fully verified in this context
+	// Get User from Auth Middleware (JWT or API key)
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	// Pass dynamic args to service
-	clientSecret, err := h.service.InitiateCheckout(c.Request.Context(), userID, req.PlanID, req.AffiliateCode, req.CouponCode, req.Amount, req.Quantity)
+	// Pass dynamic args to service, including any referral picked up via /ref/:code
+	checkout, err := h.service.InitiateCheckout(c.Request.Context(), userID, req.PlanID, req.AffiliateCode, h.referral.Read(c), req.couponCodes(), req.Amount, req.Quantity, req.Currency, req.Gateway)
 	if err != nil {
 		log.Printf("Checkout Error: %v", err) // DEBUG LOG
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to initiate checkout: %v", err)})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"client_secret": clientSecret})
+	c.JSON(http.StatusOK, checkout)
+}
+
+// CapturePayment collects an order the buyer approved at the gateway, e.g. on return from PayPal
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	payment, err := h.service.CapturePayment(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// GetPayment returns one of the buyer's payments, including the instructions of a manual payment
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	payment, err := h.service.GetPayment(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// ListManualPayments lists bank transfers and other offline payments (admin)
+func (h *PaymentHandler) ListManualPayments(c *gin.Context) {
+	payments, err := h.service.ListManualPayments(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+type confirmPaymentRequest struct {
+	Notes string `json:"notes" binding:"required"` // Proof of payment, e.g. the bank statement line
+}
+
+// ConfirmManualPayment marks an offline payment as received (admin)
+func (h *PaymentHandler) ConfirmManualPayment(c *gin.Context) {
+	var req confirmPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin := c.MustGet("user").(*domain.User)
+
+	payment, err := h.service.ConfirmManualPayment(c.Request.Context(), admin.ID.Hex(), c.Param("id"), req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+type refundRequest struct {
+	Amount float64 `json:"amount"` // 0 = refund the rest of the payment
+	Reason string  `json:"reason"`
+}
+
+// RefundPayment refunds a payment at the gateway it was paid with (admin)
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.service.RefundPayment(c.Request.Context(), c.Param("id"), req.Amount, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// Webhook receives signed event deliveries from a gateway; the gateway retries until it gets a 2xx
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.HandleWebhook(c.Request.Context(), c.Param("gateway"), payload, c.Request.Header); err != nil {
+		log.Printf("Webhook Error (%s): %v", c.Param("gateway"), err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// Quote prices a checkout with the buyer's tax, without reserving coupons or charging anything
+func (h *PaymentHandler) Quote(c *gin.Context) {
+	var req checkoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
+
+	quote, err := h.service.QuoteCheckout(c.Request.Context(), userID, req.PlanID, req.couponCodes(), req.Amount, req.Quantity, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, quote)
 }
 
 // MockWebhookRequest for testing E2E without real Stripe
@@ -73,11 +209,116 @@ func (h *PaymentHandler) MockWebhookSuccess(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "processed"})
 }
 
-func (h *PaymentHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
+func (h *PaymentHandler) MockWebhookFailure(c *gin.Context) {
+	var req MockWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ProcessPaymentFailure(c.Request.Context(), req.Metadata); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// MockRefundWebhookRequest for testing refunds without real Stripe
+type MockRefundWebhookRequest struct {
+	OrderID  string  `json:"order_id" binding:"required"`
+	RefundID string  `json:"refund_id"` // Gateway refund ID, repeated deliveries are ignored
+	Amount   float64 `json:"amount"`    // 0 = refund the rest of the payment
+	Reason   string  `json:"reason"`
+}
+
+func (h *PaymentHandler) MockWebhookRefund(c *gin.Context) {
+	var req MockRefundWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ProcessPaymentCancellation(c.Request.Context(), req.OrderID, req.RefundID, req.Amount, req.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// MockRenewalWebhookRequest for testing subscription invoices without real Stripe
+type MockRenewalWebhookRequest struct {
+	SubscriptionID string    `json:"subscription_id" binding:"required"` // Gateway subscription ID
+	InvoiceID      string    `json:"invoice_id" binding:"required"`
+	Amount         float64   `json:"amount"` // Amount paid after the gateway applied the coupon
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+}
+
+func (h *PaymentHandler) MockWebhookRenewal(c *gin.Context) {
+	var req MockRenewalWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cycle, err := h.service.ProcessSubscriptionInvoice(c.Request.Context(), req.SubscriptionID, req.InvoiceID, req.Amount, req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cycle)
+}
+
+func (h *PaymentHandler) ListSubscriptions(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	subs, err := h.service.ListSubscriptions(c.Request.Context(), user.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// ListSubscriptionCycles reports each billing cycle with the discount it received
+func (h *PaymentHandler) ListSubscriptionCycles(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	cycles, err := h.service.ListSubscriptionCycles(c.Request.Context(), user.ID.Hex(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cycles)
+}
+
+func (h *PaymentHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc, adminOnly gin.HandlerFunc) {
+	// Gateways call this without our credentials; deliveries are verified by their signature
+	router.POST("/webhooks/:gateway", h.Webhook)
+
 	payment := router.Group("/payment")
 	payment.Use(middleware)
 	{
 		payment.POST("/checkout", h.InitiateCheckout)
-		payment.POST("/webhook/mock", h.MockWebhookSuccess) // Test endpoint
+		payment.POST("/quote", h.Quote)
+		payment.GET("/orders/:id", h.GetPayment)
+		payment.POST("/orders/:id/capture", h.CapturePayment)
+		payment.POST("/orders/:id/refund", adminOnly, h.RefundPayment)
+		payment.POST("/orders/:id/confirm", adminOnly, h.ConfirmManualPayment)
+		payment.GET("/manual", adminOnly, h.ListManualPayments)
+		payment.GET("/subscriptions", h.ListSubscriptions)
+		payment.GET("/subscriptions/:id/cycles", h.ListSubscriptionCycles)
+	}
+
+	// Test endpoints: they bypass gateway signatures, so they are opt-in and admin only
+	if h.allowMock {
+		mock := payment.Group("/webhook/mock", adminOnly)
+		{
+			mock.POST("", h.MockWebhookSuccess)
+			mock.POST("/failed", h.MockWebhookFailure)
+			mock.POST("/refund", h.MockWebhookRefund)
+			mock.POST("/renewal", h.MockWebhookRenewal)
+		}
 	}
 }
@@ -82,15 +82,62 @@ func (h *PricingHandler) GetPlan(c *gin.Context) {
 	c.JSON(http.StatusOK, plan)
 }
 
+// GetPlanPrice shows the plan's price in ?currency=, converted if the creator set none
+func (h *PricingHandler) GetPlanPrice(c *gin.Context) {
+	plan, err := h.service.GetPlan(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return
+	}
+
+	price, err := h.service.PriceIn(c.Request.Context(), plan, c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, price)
+}
+
+// SetCurrencyPrice sets the plan's price in one currency by hand
+func (h *PricingHandler) SetCurrencyPrice(c *gin.Context) {
+	var req struct {
+		Amount float64 `json:"amount" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*domain.User)
+	plan, err := h.service.SetCurrencyPrice(c.Request.Context(), user.ID.Hex(), c.Param("id"), c.Param("currency"), &req.Amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeleteCurrencyPrice removes a hand-set price; the currency is then converted from the base price
+func (h *PricingHandler) DeleteCurrencyPrice(c *gin.Context) {
+	user := c.MustGet("user").(*domain.User)
+	plan, err := h.service.SetCurrencyPrice(c.Request.Context(), user.ID.Hex(), c.Param("id"), c.Param("currency"), nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
 func (h *PricingHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
 	pricing := router.Group("/pricing")
 	{
 		// Public or Protected? Let's make List public, Create protected
 		pricing.GET("/plans", h.ListPlans)
 		pricing.GET("/plans/:id", h.GetPlan)
+		pricing.GET("/plans/:id/price", h.GetPlanPrice)
 
 		// Protected
-		pricing.POST("/plans", h.CreatePlan)
+		pricing.POST("/plans", middleware, h.CreatePlan)
 	}
 
 	// Admin routes
@@ -100,6 +147,8 @@ func (h *PricingHandler) RegisterRoutes(router *gin.Engine, middleware gin.Handl
 		admin.POST("/plans", h.CreatePlan)
 		admin.PUT("/plans/:id", h.UpdatePlan)
 		admin.DELETE("/plans/:id", h.DeletePlan)
+		admin.PUT("/plans/:id/prices/:currency", h.SetCurrencyPrice)
+		admin.DELETE("/plans/:id/prices/:currency", h.DeleteCurrencyPrice)
 	}
 }
 
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const referralCookieName = "aff_ref"
+
+// referralCookie signs the visitor's attribution state with HMAC-SHA256 so it
+// can be trusted when it comes back at checkout.
+type referralCookie struct {
+	secret []byte
+}
+
+func newReferralCookie(secret string) *referralCookie {
+	return &referralCookie{secret: []byte("affiliate-attribution:" + secret)}
+}
+
+func (rc *referralCookie) sign(payload string) string {
+	mac := hmac.New(sha256.New, rc.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (rc *referralCookie) encode(attr *domain.AffiliateAttribution) (string, error) {
+	raw, err := json.Marshal(attr)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + rc.sign(payload), nil
+}
+
+func (rc *referralCookie) decode(value string) (*domain.AffiliateAttribution, error) {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(rc.sign(payload))) {
+		return nil, errors.New("invalid referral cookie signature")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	var attr domain.AffiliateAttribution
+	if err := json.Unmarshal(raw, &attr); err != nil {
+		return nil, err
+	}
+	return &attr, nil
+}
+
+// Read returns the visitor's attribution, or nil if there is no valid cookie
+func (rc *referralCookie) Read(c *gin.Context) *domain.AffiliateAttribution {
+	value, err := c.Cookie(referralCookieName)
+	if err != nil || value == "" {
+		return nil
+	}
+	attr, err := rc.decode(value)
+	if err != nil {
+		return nil
+	}
+	return attr
+}
+
+// Write stores the attribution for maxAge seconds
+func (rc *referralCookie) Write(c *gin.Context, attr *domain.AffiliateAttribution, maxAge int) error {
+	value, err := rc.encode(attr)
+	if err != nil {
+		return err
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(referralCookieName, value, maxAge, "/", "", false, true)
+	return nil
+}
@@ -21,21 +21,21 @@ func (h *WalletHandler) GetBalance(c *gin.Context) {
 	// In real app, get from middleware
 	// user := c.MustGet("user").(*domain.User)
 	// userID := user.ID.Hex()
-	userID := "650000000000000000000000" // Mock
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	wallet, err := h.service.GetBalance(c.Request.Context(), userID)
+	wallets, err := h.service.GetBalance(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, wallet)
+	c.JSON(http.StatusOK, wallets)
 }
 
 func (h *WalletHandler) GetTransactions(c *gin.Context) {
-	userID := "650000000000000000000000" // Mock
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	txs, err := h.service.GetTransactions(c.Request.Context(), userID)
+	txs, err := h.service.GetTransactions(c.Request.Context(), userID, c.Query("currency"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -45,8 +45,10 @@ func (h *WalletHandler) GetTransactions(c *gin.Context) {
 }
 
 type payoutRequestBody struct {
-	Amount float64 `json:"amount" binding:"required,gt=0"`
-	Method string  `json:"method" binding:"required"`
+	Amount         float64 `json:"amount" binding:"required,gt=0"`
+	Currency       string  `json:"currency"`        // Wallet to debit; defaults to USD
+	PayoutCurrency string  `json:"payout_currency"` // Currency to pay out in; defaults to the wallet's
+	Method         string  `json:"method" binding:"required"`
 }
 
 func (h *WalletHandler) RequestPayout(c *gin.Context) {
@@ -56,20 +58,20 @@ func (h *WalletHandler) RequestPayout(c *gin.Context) {
 		return
 	}
 
-	userID := "650000000000000000000000" // Mock
+	userID := c.MustGet("user").(*domain.User).ID.Hex()
 
-	err := h.service.RequestPayout(c.Request.Context(), userID, req.Amount, domain.PayoutMethod(req.Method))
+	payout, err := h.service.RequestPayout(c.Request.Context(), userID, req.Amount, req.Currency, req.PayoutCurrency, domain.PayoutMethod(req.Method))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Payout requested successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Payout requested successfully", "payout": payout})
 }
 
 func (h *WalletHandler) RegisterRoutes(router *gin.Engine, middleware gin.HandlerFunc) {
 	wallet := router.Group("/wallet")
-	// wallet.Use(middleware) // Enable auth
+	wallet.Use(middleware)
 	{
 		wallet.GET("/balance", h.GetBalance)
 		wallet.GET("/transactions", h.GetTransactions)
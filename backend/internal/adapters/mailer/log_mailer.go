@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"context"
+	"log"
+
+	"auth-payment-backend/internal/core/ports"
+)
+
+// LogMailer writes outgoing mail to the server log. Swap for an SMTP/API
+// adapter in production.
+type LogMailer struct{}
+
+func NewLogMailer() ports.Mailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	log.Printf("📧 Mail to %s | %s\n%s", to, subject, body)
+	return nil
+}
@@ -4,18 +4,23 @@ import (
 	"net/http"
 	"strings"
 
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
 	"github.com/gin-gonic/gin"
 )
 
+const apiKeyHeader = "X-API-Key"
+
 type AuthMiddleware struct {
-	authService ports.AuthService
+	authService   ports.AuthService
+	apiKeyService ports.APIKeyService
 }
 
-func NewAuthMiddleware(authService ports.AuthService) *AuthMiddleware {
+func NewAuthMiddleware(authService ports.AuthService, apiKeyService ports.APIKeyService) *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: authService,
+		authService:   authService,
+		apiKeyService: apiKeyService,
 	}
 }
 
@@ -48,3 +53,71 @@ func (m *AuthMiddleware) Protect() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// ProtectWithAPIKey accepts either a user JWT (same as Protect) or an API key sent as
+// "X-API-Key: apk_..." or "Authorization: Bearer apk_...". API key requests act as the
+// issuing user but are limited to readScope for GET/HEAD and writeScope otherwise.
+func (m *AuthMiddleware) ProtectWithAPIKey(readScope, writeScope domain.APIKeyScope) gin.HandlerFunc {
+	protect := m.Protect()
+
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader(apiKeyHeader)
+		if rawKey == "" {
+			if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && strings.HasPrefix(bearer, "apk_") {
+				rawKey = bearer
+			}
+		}
+		if rawKey == "" {
+			protect(c)
+			return
+		}
+
+		user, key, err := m.apiKeyService.Authenticate(c.Request.Context(), rawKey)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired api key"})
+			c.Abort()
+			return
+		}
+
+		required := writeScope
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			required = readScope
+		}
+		if !key.HasScope(required) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + string(required)})
+			c.Abort()
+			return
+		}
+
+		c.Set("user", user)
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
+
+// RequireRole must run after Protect. It rejects users that have none of the given roles.
+// API keys are limited to their scopes and never carry the issuer's role.
+func (m *AuthMiddleware) RequireRole(roles ...domain.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		if _, viaKey := c.Get("api_key"); viaKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "api keys cannot access this endpoint"})
+			c.Abort()
+			return
+		}
+
+		if !user.(*domain.User).HasRole(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// NewOAuthProviders registers every provider that has a client ID configured
+func NewOAuthProviders(cfg *config.Config) ports.OAuthProviders {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	providers := ports.OAuthProviders{}
+
+	if cfg.GoogleClientID != "" {
+		providers["google"] = NewOIDCProvider(httpClient, OIDCConfig{
+			Name:         "google",
+			ClientID:     cfg.GoogleClientID,
+			ClientSecret: cfg.GoogleClientSecret,
+			Issuer:       cfg.GoogleIssuer,
+			AuthURL:      cfg.GoogleAuthURL,
+			TokenURL:     cfg.GoogleTokenURL,
+			JWKSURL:      cfg.GoogleJWKSURL,
+			RedirectURL:  callbackURL(cfg, "google"),
+		})
+	}
+	if cfg.GithubClientID != "" {
+		providers["github"] = NewGithubProvider(httpClient, GithubConfig{
+			ClientID:     cfg.GithubClientID,
+			ClientSecret: cfg.GithubClientSecret,
+			AuthURL:      cfg.GithubAuthURL,
+			TokenURL:     cfg.GithubTokenURL,
+			APIURL:       cfg.GithubAPIURL,
+			RedirectURL:  callbackURL(cfg, "github"),
+		})
+	}
+
+	return providers
+}
+
+func callbackURL(cfg *config.Config, provider string) string {
+	return fmt.Sprintf("%s/auth/oauth/%s/callback", strings.TrimRight(cfg.BackendURL, "/"), provider)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchangeCode performs the authorization_code grant against tokenURL
+func exchangeCode(ctx context.Context, client *http.Client, tokenURL string, form url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var tok tokenResponse
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", tok.Error, tok.ErrorDescription)
+	}
+	if resp.StatusCode >= 400 || tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+	return &tok, nil
+}
+
+// getJSON performs an authenticated GET and decodes the JSON body into dest
+func getJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dest)
+}
@@ -0,0 +1,103 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+type GithubConfig struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	APIURL       string
+	RedirectURL  string
+}
+
+// GithubProvider uses plain OAuth2 (GitHub has no ID token), so the identity
+// comes from the REST API and the verified primary email from /user/emails.
+type GithubProvider struct {
+	cfg    GithubConfig
+	client *http.Client
+}
+
+func NewGithubProvider(client *http.Client, cfg GithubConfig) *GithubProvider {
+	return &GithubProvider{cfg: cfg, client: client}
+}
+
+func (p *GithubProvider) Name() string {
+	return "github"
+}
+
+func (p *GithubProvider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{}
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", "read:user user:email")
+	params.Set("state", state)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+	params.Set("allow_signup", "false")
+	return p.cfg.AuthURL + "?" + params.Encode()
+}
+
+func (p *GithubProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.ExternalIdentity, error) {
+	form := url.Values{}
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	tok, err := exchangeCode(ctx, p.client, p.cfg.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := strings.TrimRight(p.cfg.APIURL, "/")
+
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, p.client, apiURL+"/user", tok.AccessToken, &profile); err != nil {
+		return nil, err
+	}
+	if profile.ID == 0 {
+		return nil, errors.New("github did not return a user id")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.client, apiURL+"/user/emails", tok.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &domain.ExternalIdentity{
+		Provider: "github",
+		Subject:  strconv.FormatInt(profile.ID, 10),
+		Name:     profile.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
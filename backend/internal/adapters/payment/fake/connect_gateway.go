@@ -0,0 +1,321 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// ConnectAccount is a connected account held by FakeConnectGateway
+type ConnectAccount struct {
+	ID           string
+	Email        string
+	State        domain.ConnectAccount
+	Metadata     map[string]string
+	OAuth        bool // Linked with OAuth rather than created by the platform
+	Deauthorized bool
+}
+
+// FakeConnectGateway is an in-memory ports.ConnectGateway for tests. Accounts start with
+// onboarding pending; CompleteOnboarding, RequireInformation, Reject and LeavePlatform
+// change them as Stripe would and queue the matching signed webhooks.
+type FakeConnectGateway struct {
+	secret []byte
+
+	mu         sync.Mutex
+	seq        int
+	accounts   map[string]*ConnectAccount
+	oauthCodes map[string]string // authorization code -> account ID
+	links      []string          // Onboarding and login links handed out
+	outbox     []Webhook
+}
+
+func NewFakeConnectGateway() *FakeConnectGateway {
+	return &FakeConnectGateway{
+		secret:     []byte("whsec_fake_connect"),
+		accounts:   map[string]*ConnectAccount{},
+		oauthCodes: map[string]string{},
+	}
+}
+
+func (g *FakeConnectGateway) newID(prefix string) string {
+	g.seq++
+	return fmt.Sprintf("%s_fake_%d", prefix, g.seq)
+}
+
+func (g *FakeConnectGateway) account(id string) (*ConnectAccount, error) {
+	acc, ok := g.accounts[id]
+	if !ok || acc.Deauthorized {
+		return nil, fmt.Errorf("no such account: %s", id)
+	}
+	return acc, nil
+}
+
+func (g *FakeConnectGateway) CreateAccount(ctx context.Context, accountType string, email string, metadata map[string]string) (string, *domain.ConnectAccount, error) {
+	if accountType != domain.ConnectAccountExpress && accountType != domain.ConnectAccountStandard {
+		return "", nil, fmt.Errorf("invalid account type: %s", accountType)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc := &ConnectAccount{
+		ID:       g.newID("acct"),
+		Email:    email,
+		Metadata: copyMetadata(metadata),
+		State: domain.ConnectAccount{
+			Type:         accountType,
+			CurrentlyDue: []string{"business_type", "external_account", "tos_acceptance.date"},
+		},
+	}
+	g.accounts[acc.ID] = acc
+	return acc.ID, g.snapshot(acc), nil
+}
+
+func (g *FakeConnectGateway) GetAccount(ctx context.Context, accountID string) (*domain.ConnectAccount, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, err := g.account(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return g.snapshot(acc), nil
+}
+
+func (g *FakeConnectGateway) CreateOnboardingLink(ctx context.Context, accountID string, refreshURL string, returnURL string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, err := g.account(accountID); err != nil {
+		return "", err
+	}
+	if refreshURL == "" || returnURL == "" {
+		return "", errors.New("refresh and return URLs are required")
+	}
+	link := fmt.Sprintf("https://connect.fake/setup/%s/%s?%s", accountID, g.newID("link"), url.Values{
+		"refresh_url": {refreshURL},
+		"return_url":  {returnURL},
+	}.Encode())
+	g.links = append(g.links, link)
+	return link, nil
+}
+
+func (g *FakeConnectGateway) CreateLoginLink(ctx context.Context, accountID string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, err := g.account(accountID)
+	if err != nil {
+		return "", err
+	}
+	if acc.State.Type != domain.ConnectAccountExpress {
+		return "", errors.New("login links are only for express accounts")
+	}
+	if !acc.State.DetailsSubmitted {
+		return "", errors.New("the account has not completed onboarding")
+	}
+	link := fmt.Sprintf("https://connect.fake/express/%s/%s", accountID, g.newID("login"))
+	g.links = append(g.links, link)
+	return link, nil
+}
+
+func (g *FakeConnectGateway) OAuthURL(state string, redirectURI string) string {
+	return "https://connect.fake/oauth/authorize?" + url.Values{
+		"state":        {state},
+		"redirect_uri": {redirectURI},
+	}.Encode()
+}
+
+func (g *FakeConnectGateway) ExchangeOAuthCode(ctx context.Context, code string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id, ok := g.oauthCodes[code]
+	if !ok {
+		return "", errors.New("invalid authorization code")
+	}
+	delete(g.oauthCodes, code) // Single use
+	return id, nil
+}
+
+func (g *FakeConnectGateway) Deauthorize(ctx context.Context, accountID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, err := g.account(accountID)
+	if err != nil {
+		return err
+	}
+	if !acc.OAuth {
+		return errors.New("only accounts linked with OAuth can be deauthorized")
+	}
+	acc.Deauthorized = true
+	return nil
+}
+
+// ParseWebhook reads deliveries made by this fake, checking their signature
+func (g *FakeConnectGateway) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.ConnectEvent, error) {
+	if err := verify(g.secret, payload, headers); err != nil {
+		return nil, err
+	}
+	var event domain.ConnectEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ---- Simulation ----
+
+// AuthorizeOAuth simulates a seller granting access to their existing, fully onboarded
+// Standard account on the OAuth consent page. It returns the authorization code.
+func (g *FakeConnectGateway) AuthorizeOAuth(email string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc := &ConnectAccount{
+		ID:    g.newID("acct"),
+		Email: email,
+		OAuth: true,
+		State: domain.ConnectAccount{
+			Type:             domain.ConnectAccountStandard,
+			ChargesEnabled:   true,
+			PayoutsEnabled:   true,
+			DetailsSubmitted: true,
+		},
+	}
+	g.accounts[acc.ID] = acc
+	code := g.newID("ac")
+	g.oauthCodes[code] = acc.ID
+	return code
+}
+
+// CompleteOnboarding simulates the seller submitting every required detail
+func (g *FakeConnectGateway) CompleteOnboarding(accountID string) error {
+	return g.update(accountID, func(s *domain.ConnectAccount) {
+		s.DetailsSubmitted = true
+		s.ChargesEnabled = true
+		s.PayoutsEnabled = true
+		s.CurrentlyDue = nil
+		s.PastDue = nil
+		s.DisabledReason = ""
+		s.Deadline = nil
+	})
+}
+
+// RequireInformation simulates Stripe asking for more fields. Past due ones disable charges and payouts.
+func (g *FakeConnectGateway) RequireInformation(accountID string, pastDue bool, fields ...string) error {
+	return g.update(accountID, func(s *domain.ConnectAccount) {
+		s.CurrentlyDue = append(s.CurrentlyDue, fields...)
+		if pastDue {
+			s.PastDue = append(s.PastDue, fields...)
+			s.ChargesEnabled = false
+			s.PayoutsEnabled = false
+			s.DisabledReason = "requirements.past_due"
+			s.Deadline = nil
+		} else {
+			deadline := time.Now().AddDate(0, 0, 14)
+			s.Deadline = &deadline
+		}
+	})
+}
+
+// Reject simulates Stripe closing the account, e.g. for fraud
+func (g *FakeConnectGateway) Reject(accountID string) error {
+	return g.update(accountID, func(s *domain.ConnectAccount) {
+		s.ChargesEnabled = false
+		s.PayoutsEnabled = false
+		s.DisabledReason = "rejected.fraud"
+	})
+}
+
+// LeavePlatform simulates the seller revoking the platform's access from their Stripe dashboard
+func (g *FakeConnectGateway) LeavePlatform(accountID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, err := g.account(accountID)
+	if err != nil {
+		return err
+	}
+	acc.Deauthorized = true
+	return g.emit(&domain.ConnectEvent{Type: domain.ConnectEventDeauthorized, AccountID: acc.ID})
+}
+
+func (g *FakeConnectGateway) update(accountID string, change func(*domain.ConnectAccount)) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, err := g.account(accountID)
+	if err != nil {
+		return err
+	}
+	change(&acc.State)
+	return g.emit(&domain.ConnectEvent{Type: domain.ConnectEventAccountUpdated, AccountID: acc.ID, Account: g.snapshot(acc)})
+}
+
+// Webhooks returns the deliveries made since the last call, oldest first
+func (g *FakeConnectGateway) Webhooks() []Webhook {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	out := g.outbox
+	g.outbox = nil
+	return out
+}
+
+// Account returns a snapshot of an account
+func (g *FakeConnectGateway) Account(id string) (ConnectAccount, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	acc, ok := g.accounts[id]
+	if !ok {
+		return ConnectAccount{}, false
+	}
+	out := *acc
+	out.State = *g.snapshot(acc)
+	return out, true
+}
+
+// Accounts is the number of accounts created or linked
+func (g *FakeConnectGateway) Accounts() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.accounts)
+}
+
+// Links returns the onboarding and login links handed out, oldest first
+func (g *FakeConnectGateway) Links() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]string(nil), g.links...)
+}
+
+func (g *FakeConnectGateway) emit(event *domain.ConnectEvent) error {
+	event.ID = g.newID("evt")
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	g.outbox = append(g.outbox, signed(g.secret, payload))
+	return nil
+}
+
+// snapshot copies an account's state, as the gateway would report it
+func (g *FakeConnectGateway) snapshot(acc *ConnectAccount) *domain.ConnectAccount {
+	state := acc.State
+	state.CurrentlyDue = append([]string(nil), acc.State.CurrentlyDue...)
+	state.PastDue = append([]string(nil), acc.State.PastDue...)
+	state.SyncedAt = time.Now()
+	return &state
+}
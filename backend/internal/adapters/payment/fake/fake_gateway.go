@@ -0,0 +1,624 @@
+package fake
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// Test cards, after Stripe's: the outcome of Confirm depends on the card used
+const (
+	CardSucceeds    = "4242424242424242"
+	CardDeclined    = "4000000000000002"
+	CardRequires3DS = "4000002500003155"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook payload
+const SignatureHeader = "Fake-Signature"
+
+type OrderStatus string
+
+const (
+	StatusRequiresPayment OrderStatus = "requires_payment_method"
+	StatusRequiresAction  OrderStatus = "requires_action" // Waiting for 3-D Secure
+	StatusSucceeded       OrderStatus = "succeeded"
+)
+
+// Order is a charge, like a Stripe PaymentIntent
+type Order struct {
+	Ref                  string
+	OrderID              string
+	Amount               float64
+	Currency             string
+	Metadata             map[string]string
+	DestinationAccountID string
+	ApplicationFee       float64
+	SubscriptionRef      string // Set on the first charge of a subscription
+	Status               OrderStatus
+	Refunded             float64
+}
+
+type Subscription struct {
+	Ref              string
+	CustomerRef      string
+	PriceRef         string
+	PromotionCodeRef string
+	Tax              *domain.TaxResult
+	Metadata         map[string]string
+	Status           string // incomplete, active, canceled
+	Cycles           int
+	StartedAt        time.Time
+	PeriodStart      time.Time
+	PeriodEnd        time.Time
+}
+
+type price struct {
+	amount   float64
+	currency string
+	interval string
+	active   bool
+}
+
+type coupon struct {
+	percentOff float64
+	amountOff  float64
+	duration   domain.CouponDuration
+	months     int
+}
+
+// Webhook is a signed delivery, ready to be passed to the webhook endpoint
+type Webhook struct {
+	Payload []byte
+	Headers http.Header
+}
+
+// FakeGateway is an in-memory ports.PaymentGateway for tests. It keeps every object it is
+// asked to create, hands out unique IDs, and reports outcomes as signed webhooks in the
+// same shape ParseWebhook reads, so services run the same code paths as with a real gateway.
+type FakeGateway struct {
+	name   domain.PaymentGateway
+	secret []byte
+
+	mu             sync.Mutex
+	seq            int
+	orders         map[string]*Order
+	subscriptions  map[string]*Subscription
+	prices         map[string]*price
+	products       map[string]bool // active
+	customers      map[string]bool // exists
+	coupons        map[string]*coupon
+	promotionCodes map[string]string // promotion code ref -> coupon ref
+	outbox         []Webhook
+}
+
+// NewFakeGateway returns a fake answering to name, e.g. domain.GatewayStripe to stand in for the primary gateway
+func NewFakeGateway(name domain.PaymentGateway) *FakeGateway {
+	return &FakeGateway{
+		name:           name,
+		secret:         []byte("whsec_fake"),
+		orders:         map[string]*Order{},
+		subscriptions:  map[string]*Subscription{},
+		prices:         map[string]*price{},
+		products:       map[string]bool{},
+		customers:      map[string]bool{},
+		coupons:        map[string]*coupon{},
+		promotionCodes: map[string]string{},
+	}
+}
+
+func (g *FakeGateway) Name() domain.PaymentGateway {
+	return g.name
+}
+
+func (g *FakeGateway) newID(prefix string) string {
+	g.seq++
+	return fmt.Sprintf("%s_fake_%d", prefix, g.seq)
+}
+
+// ---- Checkout ----
+
+func (g *FakeGateway) CreateOrder(ctx context.Context, o domain.GatewayOrder) (*domain.GatewayCheckout, error) {
+	if o.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	metadata := copyMetadata(o.Metadata)
+	metadata["order_id"] = o.OrderID
+	order := &Order{
+		Ref:                  g.newID("pi"),
+		OrderID:              o.OrderID,
+		Amount:               domain.RoundAmount(o.Amount, o.Currency),
+		Currency:             domain.NormalizeCurrency(o.Currency),
+		Metadata:             metadata,
+		DestinationAccountID: o.DestinationAccountID,
+		ApplicationFee:       o.ApplicationFee,
+		Status:               StatusRequiresPayment,
+	}
+	g.orders[order.Ref] = order
+
+	return &domain.GatewayCheckout{
+		Gateway:      g.name,
+		OrderID:      o.OrderID,
+		Ref:          order.Ref,
+		ClientSecret: order.Ref + "_secret",
+	}, nil
+}
+
+func (g *FakeGateway) CaptureOrder(ctx context.Context, orderRef string) (*domain.GatewayCapture, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderRef]
+	if !ok {
+		return nil, fmt.Errorf("no such order: %s", orderRef)
+	}
+	return &domain.GatewayCapture{
+		Ref:       order.Ref,
+		OrderRef:  order.Ref,
+		OrderID:   order.OrderID,
+		Completed: order.Status == StatusSucceeded,
+		Amount:    order.Amount,
+		Currency:  order.Currency,
+	}, nil
+}
+
+func (g *FakeGateway) RefundCapture(ctx context.Context, captureRef string, amount float64, currency string, metadata map[string]string) (*domain.GatewayRefund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[captureRef]
+	if !ok {
+		return nil, fmt.Errorf("no such charge: %s", captureRef)
+	}
+	if order.Status != StatusSucceeded {
+		return nil, errors.New("charge has not succeeded")
+	}
+	left := domain.RoundAmount(order.Amount-order.Refunded, order.Currency)
+	if amount <= 0 || amount > left {
+		return nil, fmt.Errorf("refund amount must be between 0 and %.2f", left)
+	}
+	order.Refunded = domain.RoundAmount(order.Refunded+amount, order.Currency)
+
+	refundRef := g.newID("re")
+	event := domain.GatewayEvent{
+		Type:      domain.GatewayEventRefunded,
+		OrderID:   order.OrderID,
+		Ref:       order.Ref,
+		RefundRef: refundRef,
+		Amount:    amount,
+		Currency:  order.Currency,
+		Metadata:  copyMetadata(metadata),
+	}
+	if err := g.emit(&event); err != nil {
+		return nil, err
+	}
+	return &domain.GatewayRefund{Ref: refundRef, Amount: amount, Status: "succeeded"}, nil
+}
+
+// ParseWebhook reads deliveries made by this fake, checking their signature
+func (g *FakeGateway) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.GatewayEvent, error) {
+	if err := verify(g.secret, payload, headers); err != nil {
+		return nil, err
+	}
+	var event domain.GatewayEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ---- Simulation ----
+
+// Confirm pays an order with a test card. Declines report a failed payment and leave the
+// order open for another card; CardRequires3DS waits for Authenticate. It returns the
+// emitted event, or nil while authentication is pending.
+func (g *FakeGateway) Confirm(orderRef string, card string) (*domain.GatewayEvent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderRef]
+	if !ok {
+		return nil, fmt.Errorf("no such order: %s", orderRef)
+	}
+	if order.Status != StatusRequiresPayment {
+		return nil, fmt.Errorf("order %s is %s", orderRef, order.Status)
+	}
+
+	switch card {
+	case CardSucceeds:
+		return g.succeed(order)
+	case CardDeclined:
+		return g.decline(order)
+	case CardRequires3DS:
+		order.Status = StatusRequiresAction
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown test card %s", card)
+	}
+}
+
+// Authenticate completes (or fails) the 3-D Secure challenge of an order
+func (g *FakeGateway) Authenticate(orderRef string, approve bool) (*domain.GatewayEvent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderRef]
+	if !ok {
+		return nil, fmt.Errorf("no such order: %s", orderRef)
+	}
+	if order.Status != StatusRequiresAction {
+		return nil, fmt.Errorf("order %s is not waiting for authentication", orderRef)
+	}
+	if !approve {
+		return g.decline(order)
+	}
+	return g.succeed(order)
+}
+
+func (g *FakeGateway) succeed(order *Order) (*domain.GatewayEvent, error) {
+	order.Status = StatusSucceeded
+	if sub, ok := g.subscriptions[order.SubscriptionRef]; ok && sub.Status == "incomplete" {
+		g.startPeriod(sub, time.Now())
+		sub.Status = "active"
+	}
+	event := &domain.GatewayEvent{
+		Type:     domain.GatewayEventSucceeded,
+		OrderID:  order.OrderID,
+		Ref:      order.Ref,
+		Amount:   order.Amount,
+		Currency: order.Currency,
+		Metadata: copyMetadata(order.Metadata),
+	}
+	return event, g.emit(event)
+}
+
+func (g *FakeGateway) decline(order *Order) (*domain.GatewayEvent, error) {
+	order.Status = StatusRequiresPayment
+	event := &domain.GatewayEvent{
+		Type:     domain.GatewayEventFailed,
+		OrderID:  order.OrderID,
+		Ref:      order.Ref,
+		Currency: order.Currency,
+		Metadata: copyMetadata(order.Metadata),
+	}
+	return event, g.emit(event)
+}
+
+// Renew bills the next period of an active subscription and reports the paid invoice
+func (g *FakeGateway) Renew(subRef string) (*domain.GatewayEvent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sub, ok := g.subscriptions[subRef]
+	if !ok {
+		return nil, fmt.Errorf("no such subscription: %s", subRef)
+	}
+	if sub.Status != "active" {
+		return nil, fmt.Errorf("subscription %s is %s", subRef, sub.Status)
+	}
+
+	g.startPeriod(sub, sub.PeriodEnd)
+	p := g.prices[sub.PriceRef]
+	event := &domain.GatewayEvent{
+		Type:            domain.GatewayEventRenewed,
+		Ref:             g.newID("in"),
+		SubscriptionRef: sub.Ref,
+		Amount:          g.invoiceAmount(sub, p),
+		Currency:        domain.NormalizeCurrency(p.currency),
+		PeriodStart:     sub.PeriodStart,
+		PeriodEnd:       sub.PeriodEnd,
+	}
+	return event, g.emit(event)
+}
+
+// Webhooks returns the deliveries made since the last call, oldest first
+func (g *FakeGateway) Webhooks() []Webhook {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	out := g.outbox
+	g.outbox = nil
+	return out
+}
+
+// Order returns a snapshot of an order
+func (g *FakeGateway) Order(ref string) (Order, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[ref]
+	if !ok {
+		return Order{}, false
+	}
+	return *order, true
+}
+
+// Subscription returns a snapshot of a subscription
+func (g *FakeGateway) Subscription(ref string) (Subscription, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sub, ok := g.subscriptions[ref]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
+func (g *FakeGateway) emit(event *domain.GatewayEvent) error {
+	event.ID = g.newID("evt")
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	g.outbox = append(g.outbox, signed(g.secret, payload))
+	return nil
+}
+
+func sign(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signed wraps a payload as a webhook delivery
+func signed(secret []byte, payload []byte) Webhook {
+	headers := http.Header{}
+	headers.Set(SignatureHeader, sign(secret, payload))
+	return Webhook{Payload: payload, Headers: headers}
+}
+
+// verify checks the signature of a delivery
+func verify(secret []byte, payload []byte, headers http.Header) error {
+	if !hmac.Equal([]byte(headers.Get(SignatureHeader)), []byte(sign(secret, payload))) {
+		return errors.New("webhook signature is invalid")
+	}
+	return nil
+}
+
+// ---- Catalog ----
+
+func (g *FakeGateway) CreateProduct(ctx context.Context, name string, description string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ref := g.newID("prod")
+	g.products[ref] = true
+	return ref, nil
+}
+
+func (g *FakeGateway) UpdateProduct(ctx context.Context, productID string, name string, description string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.products[productID]; !ok {
+		return fmt.Errorf("no such product: %s", productID)
+	}
+	return nil
+}
+
+func (g *FakeGateway) ArchiveProduct(ctx context.Context, productID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.products[productID]; !ok {
+		return fmt.Errorf("no such product: %s", productID)
+	}
+	g.products[productID] = false
+	return nil
+}
+
+func (g *FakeGateway) CreatePrice(ctx context.Context, productID string, amount float64, currency string, interval string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.products[productID]; !ok {
+		return "", fmt.Errorf("no such product: %s", productID)
+	}
+	ref := g.newID("price")
+	g.prices[ref] = &price{amount: amount, currency: currency, interval: interval, active: true}
+	return ref, nil
+}
+
+func (g *FakeGateway) ArchivePrice(ctx context.Context, priceID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.prices[priceID]
+	if !ok {
+		return fmt.Errorf("no such price: %s", priceID)
+	}
+	p.active = false
+	return nil
+}
+
+// ---- Customers & subscriptions ----
+
+func (g *FakeGateway) CreateCustomer(ctx context.Context, email string, name string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ref := g.newID("cus")
+	g.customers[ref] = true
+	return ref, nil
+}
+
+func (g *FakeGateway) DeleteCustomer(ctx context.Context, customerID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.customers[customerID] {
+		return fmt.Errorf("no such customer: %s", customerID)
+	}
+	g.customers[customerID] = false
+	return nil
+}
+
+// CreateSubscription opens the subscription with its first charge; it becomes active once that is paid
+func (g *FakeGateway) CreateSubscription(ctx context.Context, customerID string, priceID string, promotionCodeID string, tax *domain.TaxResult, metadata map[string]string, destinationAccountID string, applicationFeePercent float64) (*domain.GatewaySubscription, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.customers[customerID] {
+		return nil, fmt.Errorf("no such customer: %s", customerID)
+	}
+	p, ok := g.prices[priceID]
+	if !ok || !p.active {
+		return nil, fmt.Errorf("no such price: %s", priceID)
+	}
+	if p.interval == "" {
+		return nil, errors.New("price is not recurring")
+	}
+	if promotionCodeID != "" {
+		if _, ok := g.promotionCodes[promotionCodeID]; !ok {
+			return nil, fmt.Errorf("no such promotion code: %s", promotionCodeID)
+		}
+	}
+
+	sub := &Subscription{
+		Ref:              g.newID("sub"),
+		CustomerRef:      customerID,
+		PriceRef:         priceID,
+		PromotionCodeRef: promotionCodeID,
+		Tax:              tax,
+		Metadata:         copyMetadata(metadata),
+		Status:           "incomplete",
+		StartedAt:        time.Now(),
+	}
+	g.subscriptions[sub.Ref] = sub
+
+	amount := g.invoiceAmount(sub, p)
+	if amount <= 0 {
+		// Nothing to pay now, e.g. a fully discounted first invoice
+		g.startPeriod(sub, sub.StartedAt)
+		sub.Status = "active"
+		return &domain.GatewaySubscription{Ref: sub.Ref}, nil
+	}
+
+	// Like Stripe, the invoice's payment does not carry the subscription's metadata
+	order := &Order{
+		Ref:                  g.newID("pi"),
+		Amount:               amount,
+		Currency:             domain.NormalizeCurrency(p.currency),
+		Metadata:             map[string]string{},
+		DestinationAccountID: destinationAccountID,
+		ApplicationFee:       domain.RoundAmount(amount*applicationFeePercent/100, p.currency),
+		SubscriptionRef:      sub.Ref,
+		Status:               StatusRequiresPayment,
+	}
+	g.orders[order.Ref] = order
+
+	return &domain.GatewaySubscription{Ref: sub.Ref, PaymentRef: order.Ref, ClientSecret: order.Ref + "_secret"}, nil
+}
+
+func (g *FakeGateway) CancelSubscription(ctx context.Context, subID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sub, ok := g.subscriptions[subID]
+	if !ok {
+		return fmt.Errorf("no such subscription: %s", subID)
+	}
+	sub.Status = "canceled"
+	return nil
+}
+
+// startPeriod moves the subscription to its next billing period, starting at start
+func (g *FakeGateway) startPeriod(sub *Subscription, start time.Time) {
+	sub.Cycles++
+	sub.PeriodStart = start
+	switch g.prices[sub.PriceRef].interval {
+	case "day":
+		sub.PeriodEnd = start.AddDate(0, 0, 1)
+	case "week":
+		sub.PeriodEnd = start.AddDate(0, 0, 7)
+	case "year":
+		sub.PeriodEnd = start.AddDate(1, 0, 0)
+	default:
+		sub.PeriodEnd = start.AddDate(0, 1, 0)
+	}
+}
+
+// invoiceAmount is what the next invoice charges: the price, less the coupon for the
+// cycles its duration covers, plus tax unless it is included or reverse charged
+func (g *FakeGateway) invoiceAmount(sub *Subscription, p *price) float64 {
+	amount := p.amount
+	if c, ok := g.coupons[g.promotionCodes[sub.PromotionCodeRef]]; ok && g.couponApplies(sub, c) {
+		discount := c.amountOff
+		if c.percentOff > 0 {
+			discount = amount * c.percentOff / 100
+		}
+		amount -= discount
+		if amount < 0 {
+			amount = 0
+		}
+	}
+	if sub.Tax != nil && !sub.Tax.Inclusive && !sub.Tax.ReverseCharge {
+		amount += amount * sub.Tax.Rate
+	}
+	return domain.RoundAmount(amount, p.currency)
+}
+
+// couponApplies reports whether the invoice opening the next period is discounted
+func (g *FakeGateway) couponApplies(sub *Subscription, c *coupon) bool {
+	switch c.duration {
+	case domain.CouponDurationForever:
+		return true
+	case domain.CouponDurationRepeating:
+		start := sub.StartedAt
+		if sub.Cycles > 0 {
+			start = sub.PeriodStart
+		}
+		return start.Before(sub.StartedAt.AddDate(0, c.months, 0))
+	default:
+		return sub.Cycles == 0
+	}
+}
+
+// ---- Coupons ----
+
+func (g *FakeGateway) CreateCoupon(ctx context.Context, c *domain.Coupon, currency string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ref := g.newID("coupon")
+	fc := &coupon{duration: c.EffectiveDuration(), months: c.DurationInMonths}
+	if c.DiscountType == domain.DiscountTypePercent {
+		fc.percentOff = c.DiscountAmount
+	} else {
+		fc.amountOff = c.DiscountAmount
+	}
+	g.coupons[ref] = fc
+	return ref, nil
+}
+
+func (g *FakeGateway) CreatePromotionCode(ctx context.Context, couponID string, code string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.coupons[couponID]; !ok {
+		return "", fmt.Errorf("no such coupon: %s", couponID)
+	}
+	ref := g.newID("promo")
+	g.promotionCodes[ref] = couponID
+	return ref, nil
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package payment
+
+import (
+	"net/http"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/adapters/payment/manual"
+	"auth-payment-backend/internal/adapters/payment/paypal"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// NewCheckoutGateways registers the primary gateway and every other gateway that is configured
+func NewCheckoutGateways(cfg *config.Config, primary ports.PaymentGateway) ports.CheckoutGateways {
+	gateways := ports.CheckoutGateways{
+		primary.Name(): primary,
+	}
+
+	if cfg.PaypalClientID != "" {
+		gateways[domain.GatewayPaypal] = paypal.NewPaypalAdapter(&http.Client{Timeout: 10 * time.Second}, paypal.Config{
+			ClientID:     cfg.PaypalClientID,
+			ClientSecret: cfg.PaypalClientSecret,
+			APIURL:       cfg.PaypalAPIURL,
+			WebhookID:    cfg.PaypalWebhookID,
+		})
+	}
+
+	if cfg.ManualPaymentInstructions != "" {
+		gateways[domain.GatewayManual] = manual.NewManualGateway(manual.Config{
+			Instructions: cfg.ManualPaymentInstructions,
+			ExpiresIn:    cfg.ManualPaymentExpiry,
+		})
+	}
+
+	return gateways
+}
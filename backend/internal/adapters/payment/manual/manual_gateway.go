@@ -0,0 +1,103 @@
+package manual
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// referenceAlphabet leaves out characters that are easily misread on a bank statement (0/O, 1/I)
+const referenceAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type Config struct {
+	// Instructions shown to the buyer. {reference}, {amount} and {currency} are filled in.
+	Instructions string
+	ExpiresIn    time.Duration
+}
+
+// ManualGateway handles payments made outside any gateway, e.g. bank transfers. The order is
+// a reference code the buyer quotes with their transfer; an admin confirms receipt.
+type ManualGateway struct {
+	cfg Config
+}
+
+func NewManualGateway(cfg Config) *ManualGateway {
+	return &ManualGateway{cfg: cfg}
+}
+
+func (g *ManualGateway) Name() domain.PaymentGateway {
+	return domain.GatewayManual
+}
+
+func (g *ManualGateway) CreateOrder(ctx context.Context, order domain.GatewayOrder) (*domain.GatewayCheckout, error) {
+	reference, err := newReference()
+	if err != nil {
+		return nil, err
+	}
+
+	amount := fmt.Sprintf("%.2f", order.Amount)
+	if domain.IsZeroDecimal(order.Currency) {
+		amount = fmt.Sprintf("%.0f", order.Amount)
+	}
+	instructions := strings.NewReplacer(
+		"{reference}", reference,
+		"{amount}", amount,
+		"{currency}", domain.NormalizeCurrency(order.Currency),
+	).Replace(g.cfg.Instructions)
+
+	checkout := &domain.GatewayCheckout{
+		Gateway:      domain.GatewayManual,
+		OrderID:      order.OrderID,
+		Ref:          reference,
+		Reference:    reference,
+		Instructions: instructions,
+	}
+	if g.cfg.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(g.cfg.ExpiresIn)
+		checkout.ExpiresAt = &expiresAt
+	}
+	return checkout, nil
+}
+
+// CaptureOrder never completes: the money arrives outside the platform and an admin confirms it
+func (g *ManualGateway) CaptureOrder(ctx context.Context, orderRef string) (*domain.GatewayCapture, error) {
+	return &domain.GatewayCapture{OrderRef: orderRef}, nil
+}
+
+// RefundCapture records the refund; the money is sent back outside the platform
+func (g *ManualGateway) RefundCapture(ctx context.Context, captureRef string, amount float64, currency string, metadata map[string]string) (*domain.GatewayRefund, error) {
+	reference, err := newReference()
+	if err != nil {
+		return nil, err
+	}
+	return &domain.GatewayRefund{Ref: "RF-" + reference[len("PAY-"):], Amount: amount, Status: "pending"}, nil
+}
+
+func (g *ManualGateway) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.GatewayEvent, error) {
+	return nil, errors.New("manual payments are confirmed by an admin, not by webhooks")
+}
+
+// newReference returns a code such as PAY-7KQ2-XM9D
+func newReference() (string, error) {
+	var b strings.Builder
+	b.WriteString("PAY-")
+	max := big.NewInt(int64(len(referenceAlphabet)))
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(referenceAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,412 @@
+package paypal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	APIURL       string // https://api-m.sandbox.paypal.com or https://api-m.paypal.com
+	WebhookID    string
+}
+
+// PaypalAdapter collects one-off payments through the Orders v2 API. The buyer approves
+// the order on PayPal and is sent back to the return URL, then the order is captured.
+// Sales are collected by the platform; PayPal has no equivalent of Connect destination charges.
+type PaypalAdapter struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func NewPaypalAdapter(client *http.Client, cfg Config) *PaypalAdapter {
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	return &PaypalAdapter{cfg: cfg, client: client}
+}
+
+func (p *PaypalAdapter) Name() domain.PaymentGateway {
+	return domain.GatewayPaypal
+}
+
+type money struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type link struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+type capture struct {
+	ID                string `json:"id"`
+	Status            string `json:"status"`
+	CustomID          string `json:"custom_id"`
+	Amount            money  `json:"amount"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+}
+
+type order struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	Links         []link `json:"links"`
+	PurchaseUnits []struct {
+		CustomID string `json:"custom_id"`
+		Amount   money  `json:"amount"`
+		Payments struct {
+			Captures []capture `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+}
+
+type refund struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	CustomID string `json:"custom_id"`
+	Amount   money  `json:"amount"`
+	Links    []link `json:"links"`
+}
+
+// apiError is PayPal's error body, e.g. {"name":"UNPROCESSABLE_ENTITY","details":[{"issue":"ORDER_ALREADY_CAPTURED"}]}
+type apiError struct {
+	Status  int
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Details []struct {
+		Issue       string `json:"issue"`
+		Description string `json:"description"`
+	} `json:"details"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("paypal returned %d", e.Status)
+	if e.Name != "" {
+		msg += ": " + e.Name
+	}
+	if len(e.Details) > 0 {
+		msg += " " + e.Details[0].Issue
+	} else if e.Message != "" {
+		msg += " " + e.Message
+	}
+	return msg
+}
+
+func (e *apiError) hasIssue(issue string) bool {
+	for _, d := range e.Details {
+		if d.Issue == issue {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PaypalAdapter) CreateOrder(ctx context.Context, o domain.GatewayOrder) (*domain.GatewayCheckout, error) {
+	unit := map[string]interface{}{
+		"reference_id": o.OrderID,
+		"custom_id":    o.OrderID, // Carried into captures, so webhooks can be matched to the payment
+		"amount":       formatMoney(o.Amount, o.Currency),
+	}
+	if o.Description != "" {
+		unit["description"] = truncate(o.Description, 127)
+	}
+	body := map[string]interface{}{
+		"intent":         "CAPTURE",
+		"purchase_units": []interface{}{unit},
+		"application_context": map[string]string{
+			"return_url":  o.ReturnURL,
+			"cancel_url":  o.CancelURL,
+			"user_action": "PAY_NOW",
+		},
+	}
+
+	var created order
+	if err := p.call(ctx, http.MethodPost, "/v2/checkout/orders", o.OrderID, body, &created); err != nil {
+		return nil, err
+	}
+
+	checkout := &domain.GatewayCheckout{
+		Gateway: domain.GatewayPaypal,
+		OrderID: o.OrderID,
+		Ref:     created.ID,
+	}
+	for _, l := range created.Links {
+		if l.Rel == "approve" || l.Rel == "payer-action" {
+			checkout.ApprovalURL = l.Href
+		}
+	}
+	if checkout.ApprovalURL == "" {
+		return nil, errors.New("paypal did not return an approval link")
+	}
+	return checkout, nil
+}
+
+func (p *PaypalAdapter) CaptureOrder(ctx context.Context, orderRef string) (*domain.GatewayCapture, error) {
+	var captured order
+	err := p.call(ctx, http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(orderRef)+"/capture", "capture-"+orderRef, struct{}{}, &captured)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.hasIssue("ORDER_ALREADY_CAPTURED") {
+		// Captured by the webhook or a retried return; report the existing capture
+		err = p.call(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(orderRef), "", nil, &captured)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.GatewayCapture{OrderRef: captured.ID}
+	for _, unit := range captured.PurchaseUnits {
+		result.OrderID = unit.CustomID
+		for _, c := range unit.Payments.Captures {
+			result.Ref = c.ID
+			result.Completed = c.Status == "COMPLETED"
+			result.Currency = domain.NormalizeCurrency(c.Amount.CurrencyCode)
+			result.Amount, _ = strconv.ParseFloat(c.Amount.Value, 64)
+			if c.CustomID != "" {
+				result.OrderID = c.CustomID
+			}
+		}
+	}
+	if result.Ref == "" {
+		return nil, fmt.Errorf("paypal order %s has no capture (status %s)", orderRef, captured.Status)
+	}
+	return result, nil
+}
+
+func (p *PaypalAdapter) RefundCapture(ctx context.Context, captureRef string, amount float64, currency string, metadata map[string]string) (*domain.GatewayRefund, error) {
+	body := map[string]interface{}{
+		"amount": formatMoney(amount, currency),
+	}
+	if orderID := metadata["order_id"]; orderID != "" {
+		body["custom_id"] = orderID
+	}
+	if reason := metadata["reason"]; reason != "" {
+		body["note_to_payer"] = truncate(reason, 255)
+	}
+
+	requestID := fmt.Sprintf("refund-%s-%d", captureRef, time.Now().UnixNano())
+	var r refund
+	if err := p.call(ctx, http.MethodPost, "/v2/payments/captures/"+url.PathEscape(captureRef)+"/refund", requestID, body, &r); err != nil {
+		return nil, err
+	}
+
+	refunded, _ := strconv.ParseFloat(r.Amount.Value, 64)
+	if r.Amount.Value == "" {
+		refunded = amount
+	}
+	return &domain.GatewayRefund{Ref: r.ID, Amount: refunded, Status: r.Status}, nil
+}
+
+// ParseWebhook verifies the delivery with PayPal's verify-webhook-signature API
+func (p *PaypalAdapter) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.GatewayEvent, error) {
+	if p.cfg.WebhookID == "" {
+		return nil, errors.New("paypal webhook id is not configured")
+	}
+
+	var event struct {
+		ID        string          `json:"id"`
+		EventType string          `json:"event_type"`
+		Resource  json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	verify := map[string]interface{}{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        p.cfg.WebhookID,
+		"webhook_event":     json.RawMessage(payload),
+	}
+	var verified struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := p.call(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", "", verify, &verified); err != nil {
+		return nil, err
+	}
+	if verified.VerificationStatus != "SUCCESS" {
+		return nil, errors.New("paypal webhook signature is invalid")
+	}
+
+	switch event.EventType {
+	case "CHECKOUT.ORDER.APPROVED":
+		var o order
+		if err := json.Unmarshal(event.Resource, &o); err != nil {
+			return nil, err
+		}
+		result := &domain.GatewayEvent{ID: event.ID, Type: domain.GatewayEventApproved, Ref: o.ID}
+		if len(o.PurchaseUnits) > 0 {
+			result.OrderID = o.PurchaseUnits[0].CustomID
+			result.Currency = domain.NormalizeCurrency(o.PurchaseUnits[0].Amount.CurrencyCode)
+			result.Amount, _ = strconv.ParseFloat(o.PurchaseUnits[0].Amount.Value, 64)
+		}
+		return result, nil
+
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.DENIED":
+		var c capture
+		if err := json.Unmarshal(event.Resource, &c); err != nil {
+			return nil, err
+		}
+		result := &domain.GatewayEvent{
+			ID:       event.ID,
+			Type:     domain.GatewayEventSucceeded,
+			OrderID:  c.CustomID,
+			Ref:      c.ID,
+			Currency: domain.NormalizeCurrency(c.Amount.CurrencyCode),
+		}
+		result.Amount, _ = strconv.ParseFloat(c.Amount.Value, 64)
+		if event.EventType == "PAYMENT.CAPTURE.DENIED" {
+			result.Type = domain.GatewayEventFailed
+			if result.OrderID == "" {
+				result.Ref = c.SupplementaryData.RelatedIDs.OrderID // No capture was recorded for the payment
+			}
+		}
+		return result, nil
+
+	case "PAYMENT.CAPTURE.REFUNDED":
+		var r refund
+		if err := json.Unmarshal(event.Resource, &r); err != nil {
+			return nil, err
+		}
+		result := &domain.GatewayEvent{
+			ID:        event.ID,
+			Type:      domain.GatewayEventRefunded,
+			OrderID:   r.CustomID,
+			RefundRef: r.ID,
+			Currency:  domain.NormalizeCurrency(r.Amount.CurrencyCode),
+		}
+		result.Amount, _ = strconv.ParseFloat(r.Amount.Value, 64)
+		// The refunded capture is the "up" link, e.g. .../v2/payments/captures/{id}
+		for _, l := range r.Links {
+			if l.Rel == "up" {
+				result.Ref = l.Href[strings.LastIndex(l.Href, "/")+1:]
+			}
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
+// call performs an authenticated JSON request. requestID makes POSTs idempotent across retries.
+func (p *PaypalAdapter) call(ctx context.Context, method, path, requestID string, body, dest interface{}) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		_ = json.Unmarshal(raw, apiErr)
+		return apiErr
+	}
+	if dest == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+// token returns a client-credentials access token, reusing it until shortly before it expires
+func (p *PaypalAdapter) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.APIURL+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.cfg.ClientID, p.cfg.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil || resp.StatusCode >= 400 || tok.AccessToken == "" {
+		return "", fmt.Errorf("paypal token endpoint returned %d", resp.StatusCode)
+	}
+
+	p.accessToken = tok.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+// formatMoney renders an amount the way PayPal expects it, e.g. "10.50" USD or "500" JPY
+func formatMoney(amount float64, currency string) money {
+	value := strconv.FormatFloat(domain.RoundAmount(amount, currency), 'f', 2, 64)
+	if domain.IsZeroDecimal(currency) {
+		value = strconv.FormatFloat(domain.RoundAmount(amount, currency), 'f', 0, 64)
+	}
+	return money{CurrencyCode: domain.NormalizeCurrency(currency), Value: value}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
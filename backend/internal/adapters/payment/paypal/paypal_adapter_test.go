@@ -0,0 +1,345 @@
+package paypal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// fakePaypal serves the subset of the PayPal REST API the adapter uses
+type fakePaypal struct {
+	t *testing.T
+
+	mu             sync.Mutex
+	tokenRequests  int
+	captured       map[string]bool
+	lastOrder      map[string]interface{}
+	lastRefund     map[string]interface{}
+	lastRequestID  string
+	signatureValid bool
+}
+
+func newFakePaypal(t *testing.T) (*fakePaypal, *PaypalAdapter) {
+	f := &fakePaypal{t: t, captured: map[string]bool{}, signatureValid: true}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	adapter := NewPaypalAdapter(srv.Client(), Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		APIURL:       srv.URL + "/",
+		WebhookID:    "WH-1",
+	})
+	return f, adapter
+}
+
+func (f *fakePaypal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/v1/oauth2/token" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.tokenRequests++
+		writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "token-1", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token-1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.lastRequestID = r.Header.Get("PayPal-Request-Id")
+
+	var body map[string]interface{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("%s: invalid JSON body: %v", r.URL.Path, err)
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/checkout/orders":
+		f.lastOrder = body
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"id":     "ORDER-1",
+			"status": "CREATED",
+			"links": []map[string]string{
+				{"rel": "self", "href": "https://api.paypal.test/v2/checkout/orders/ORDER-1"},
+				{"rel": "approve", "href": "https://www.paypal.test/checkoutnow?token=ORDER-1"},
+			},
+		})
+
+	case r.URL.Path == "/v2/checkout/orders/ORDER-1/capture":
+		if f.captured["ORDER-1"] {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"name":    "UNPROCESSABLE_ENTITY",
+				"details": []map[string]string{{"issue": "ORDER_ALREADY_CAPTURED"}},
+			})
+			return
+		}
+		f.captured["ORDER-1"] = true
+		writeJSON(w, http.StatusCreated, capturedOrder())
+
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/checkout/orders/ORDER-1":
+		writeJSON(w, http.StatusOK, capturedOrder())
+
+	case r.URL.Path == "/v2/payments/captures/CAPTURE-1/refund":
+		f.lastRefund = body
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"id":     "REFUND-1",
+			"status": "COMPLETED",
+			"amount": body["amount"],
+		})
+
+	case r.URL.Path == "/v1/notifications/verify-webhook-signature":
+		status := "FAILURE"
+		if f.signatureValid && body["webhook_id"] == "WH-1" && body["transmission_sig"] == "sig" {
+			status = "SUCCESS"
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"verification_status": status})
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"name": "RESOURCE_NOT_FOUND"})
+	}
+}
+
+func capturedOrder() map[string]interface{} {
+	return map[string]interface{}{
+		"id":     "ORDER-1",
+		"status": "COMPLETED",
+		"purchase_units": []map[string]interface{}{{
+			"reference_id": "order-abc",
+			"payments": map[string]interface{}{
+				"captures": []map[string]interface{}{{
+					"id":        "CAPTURE-1",
+					"status":    "COMPLETED",
+					"custom_id": "order-abc",
+					"amount":    map[string]string{"currency_code": "EUR", "value": "19.99"},
+				}},
+			},
+		}},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestCreateOrder(t *testing.T) {
+	f, adapter := newFakePaypal(t)
+
+	checkout, err := adapter.CreateOrder(context.Background(), domain.GatewayOrder{
+		OrderID:     "order-abc",
+		Amount:      19.99,
+		Currency:    "eur",
+		Description: "Pro Plan",
+		ReturnURL:   "https://shop.test/return",
+		CancelURL:   "https://shop.test/cancel",
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if checkout.Gateway != domain.GatewayPaypal || checkout.Ref != "ORDER-1" || checkout.OrderID != "order-abc" {
+		t.Errorf("unexpected checkout %+v", checkout)
+	}
+	if checkout.ApprovalURL != "https://www.paypal.test/checkoutnow?token=ORDER-1" {
+		t.Errorf("approval URL = %q", checkout.ApprovalURL)
+	}
+
+	if f.lastOrder["intent"] != "CAPTURE" {
+		t.Errorf("intent = %v", f.lastOrder["intent"])
+	}
+	unit := f.lastOrder["purchase_units"].([]interface{})[0].(map[string]interface{})
+	if unit["custom_id"] != "order-abc" {
+		t.Errorf("custom_id = %v", unit["custom_id"])
+	}
+	amount := unit["amount"].(map[string]interface{})
+	if amount["currency_code"] != "EUR" || amount["value"] != "19.99" {
+		t.Errorf("amount = %v", amount)
+	}
+	if f.lastRequestID != "order-abc" {
+		t.Errorf("PayPal-Request-Id = %q", f.lastRequestID)
+	}
+}
+
+func TestCreateOrderZeroDecimalCurrency(t *testing.T) {
+	f, adapter := newFakePaypal(t)
+
+	if _, err := adapter.CreateOrder(context.Background(), domain.GatewayOrder{OrderID: "order-jpy", Amount: 1500.4, Currency: "JPY"}); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	unit := f.lastOrder["purchase_units"].([]interface{})[0].(map[string]interface{})
+	if value := unit["amount"].(map[string]interface{})["value"]; value != "1500" {
+		t.Errorf("value = %v, want 1500", value)
+	}
+}
+
+func TestAccessTokenIsReused(t *testing.T) {
+	f, adapter := newFakePaypal(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := adapter.CreateOrder(ctx, domain.GatewayOrder{OrderID: "order-abc", Amount: 5, Currency: "USD"}); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+	if f.tokenRequests != 1 {
+		t.Errorf("token requested %d times, want 1", f.tokenRequests)
+	}
+}
+
+func TestCaptureOrder(t *testing.T) {
+	_, adapter := newFakePaypal(t)
+	ctx := context.Background()
+
+	capture, err := adapter.CaptureOrder(ctx, "ORDER-1")
+	if err != nil {
+		t.Fatalf("CaptureOrder: %v", err)
+	}
+	if !capture.Completed || capture.Ref != "CAPTURE-1" || capture.OrderID != "order-abc" {
+		t.Errorf("unexpected capture %+v", capture)
+	}
+	if capture.Amount != 19.99 || capture.Currency != "EUR" {
+		t.Errorf("captured %v %s", capture.Amount, capture.Currency)
+	}
+
+	// A second capture (e.g. webhook and buyer return racing) reports the existing capture
+	again, err := adapter.CaptureOrder(ctx, "ORDER-1")
+	if err != nil {
+		t.Fatalf("second CaptureOrder: %v", err)
+	}
+	if again.Ref != "CAPTURE-1" || !again.Completed {
+		t.Errorf("unexpected second capture %+v", again)
+	}
+}
+
+func TestCaptureUnknownOrder(t *testing.T) {
+	_, adapter := newFakePaypal(t)
+
+	if _, err := adapter.CaptureOrder(context.Background(), "ORDER-404"); err == nil {
+		t.Fatal("expected an error for an unknown order")
+	}
+}
+
+func TestRefundCapture(t *testing.T) {
+	f, adapter := newFakePaypal(t)
+
+	refund, err := adapter.RefundCapture(context.Background(), "CAPTURE-1", 5, "EUR", map[string]string{"order_id": "order-abc", "reason": "duplicate"})
+	if err != nil {
+		t.Fatalf("RefundCapture: %v", err)
+	}
+	if refund.Ref != "REFUND-1" || refund.Amount != 5 || refund.Status != "COMPLETED" {
+		t.Errorf("unexpected refund %+v", refund)
+	}
+	if f.lastRefund["custom_id"] != "order-abc" || f.lastRefund["note_to_payer"] != "duplicate" {
+		t.Errorf("unexpected refund request %v", f.lastRefund)
+	}
+	if amount := f.lastRefund["amount"].(map[string]interface{}); amount["value"] != "5.00" {
+		t.Errorf("refund amount = %v", amount)
+	}
+}
+
+func webhookHeaders(sig string) http.Header {
+	h := http.Header{}
+	h.Set("PAYPAL-AUTH-ALGO", "SHA256withRSA")
+	h.Set("PAYPAL-CERT-URL", "https://api.paypal.test/cert")
+	h.Set("PAYPAL-TRANSMISSION-ID", "tx-1")
+	h.Set("PAYPAL-TRANSMISSION-SIG", sig)
+	h.Set("PAYPAL-TRANSMISSION-TIME", "2026-01-01T00:00:00Z")
+	return h
+}
+
+func TestParseWebhookEvents(t *testing.T) {
+	_, adapter := newFakePaypal(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    domain.GatewayEvent
+	}{
+		{
+			name:    "order approved",
+			payload: `{"id":"WH-EV-1","event_type":"CHECKOUT.ORDER.APPROVED","resource":{"id":"ORDER-1","purchase_units":[{"custom_id":"order-abc","amount":{"currency_code":"EUR","value":"19.99"}}]}}`,
+			want:    domain.GatewayEvent{ID: "WH-EV-1", Type: domain.GatewayEventApproved, OrderID: "order-abc", Ref: "ORDER-1", Amount: 19.99, Currency: "EUR"},
+		},
+		{
+			name:    "capture completed",
+			payload: `{"id":"WH-EV-2","event_type":"PAYMENT.CAPTURE.COMPLETED","resource":{"id":"CAPTURE-1","status":"COMPLETED","custom_id":"order-abc","amount":{"currency_code":"EUR","value":"19.99"}}}`,
+			want:    domain.GatewayEvent{ID: "WH-EV-2", Type: domain.GatewayEventSucceeded, OrderID: "order-abc", Ref: "CAPTURE-1", Amount: 19.99, Currency: "EUR"},
+		},
+		{
+			name:    "capture denied",
+			payload: `{"id":"WH-EV-3","event_type":"PAYMENT.CAPTURE.DENIED","resource":{"id":"CAPTURE-2","status":"DECLINED","custom_id":"order-abc","amount":{"currency_code":"EUR","value":"19.99"}}}`,
+			want:    domain.GatewayEvent{ID: "WH-EV-3", Type: domain.GatewayEventFailed, OrderID: "order-abc", Ref: "CAPTURE-2", Amount: 19.99, Currency: "EUR"},
+		},
+		{
+			name:    "capture refunded",
+			payload: `{"id":"WH-EV-4","event_type":"PAYMENT.CAPTURE.REFUNDED","resource":{"id":"REFUND-1","custom_id":"order-abc","amount":{"currency_code":"EUR","value":"5.00"},"links":[{"rel":"self","href":"https://api.paypal.test/v2/payments/refunds/REFUND-1"},{"rel":"up","href":"https://api.paypal.test/v2/payments/captures/CAPTURE-1"}]}}`,
+			want:    domain.GatewayEvent{ID: "WH-EV-4", Type: domain.GatewayEventRefunded, OrderID: "order-abc", Ref: "CAPTURE-1", RefundRef: "REFUND-1", Amount: 5, Currency: "EUR"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := adapter.ParseWebhook(ctx, []byte(tt.payload), webhookHeaders("sig"))
+			if err != nil {
+				t.Fatalf("ParseWebhook: %v", err)
+			}
+			if event == nil {
+				t.Fatal("event was ignored")
+			}
+			if event.ID != tt.want.ID || event.Type != tt.want.Type || event.OrderID != tt.want.OrderID || event.Ref != tt.want.Ref ||
+				event.RefundRef != tt.want.RefundRef || event.Amount != tt.want.Amount || event.Currency != tt.want.Currency {
+				t.Errorf("got %+v, want %+v", *event, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWebhookIgnoresOtherEvents(t *testing.T) {
+	_, adapter := newFakePaypal(t)
+
+	event, err := adapter.ParseWebhook(context.Background(), []byte(`{"id":"WH-EV-9","event_type":"CUSTOMER.DISPUTE.CREATED","resource":{}}`), webhookHeaders("sig"))
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	if event != nil {
+		t.Errorf("expected the event to be ignored, got %+v", *event)
+	}
+}
+
+func TestParseWebhookRejectsInvalidSignature(t *testing.T) {
+	f, adapter := newFakePaypal(t)
+	payload := []byte(`{"id":"WH-EV-2","event_type":"PAYMENT.CAPTURE.COMPLETED","resource":{"id":"CAPTURE-1"}}`)
+
+	if _, err := adapter.ParseWebhook(context.Background(), payload, webhookHeaders("forged")); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("expected an invalid signature error, got %v", err)
+	}
+
+	f.mu.Lock()
+	f.signatureValid = false
+	f.mu.Unlock()
+	if _, err := adapter.ParseWebhook(context.Background(), payload, webhookHeaders("sig")); err == nil {
+		t.Error("expected an error when PayPal fails the verification")
+	}
+}
+
+func TestParseWebhookRequiresWebhookID(t *testing.T) {
+	adapter := NewPaypalAdapter(http.DefaultClient, Config{APIURL: "http://127.0.0.1:0"})
+
+	if _, err := adapter.ParseWebhook(context.Background(), []byte(`{}`), http.Header{}); err == nil {
+		t.Fatal("expected an error without a webhook id")
+	}
+}
@@ -0,0 +1,177 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/account"
+	"github.com/stripe/stripe-go/v76/accountlink"
+	"github.com/stripe/stripe-go/v76/loginlink"
+	"github.com/stripe/stripe-go/v76/oauth"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// StripeConnectAdapter manages Stripe Connect accounts with the platform's API key
+type StripeConnectAdapter struct {
+	clientID      string // OAuth client ID of the platform
+	webhookSecret string // Signing secret of the Connect webhook endpoint
+}
+
+func NewStripeConnectAdapter(cfg *config.Config) ports.ConnectGateway {
+	if cfg.StripeSecretKey != "" {
+		stripe.Key = cfg.StripeSecretKey
+	}
+	return &StripeConnectAdapter{
+		clientID:      cfg.StripeConnectClientID,
+		webhookSecret: cfg.StripeConnectWebhookSecret,
+	}
+}
+
+func (s *StripeConnectAdapter) CreateAccount(ctx context.Context, accountType string, email string, metadata map[string]string) (string, *domain.ConnectAccount, error) {
+	params := &stripe.AccountParams{
+		Type:  stripe.String(accountType),
+		Email: stripe.String(email),
+	}
+	// Express accounts get the capabilities destination charges need; Standard accounts choose their own
+	if accountType == domain.ConnectAccountExpress {
+		params.Capabilities = &stripe.AccountCapabilitiesParams{
+			CardPayments: &stripe.AccountCapabilitiesCardPaymentsParams{Requested: stripe.Bool(true)},
+			Transfers:    &stripe.AccountCapabilitiesTransfersParams{Requested: stripe.Bool(true)},
+		}
+	}
+	for k, v := range metadata {
+		params.AddMetadata(k, v)
+	}
+
+	acc, err := account.New(params)
+	if err != nil {
+		return "", nil, err
+	}
+	return acc.ID, connectAccountState(acc), nil
+}
+
+func (s *StripeConnectAdapter) GetAccount(ctx context.Context, accountID string) (*domain.ConnectAccount, error) {
+	acc, err := account.GetByID(accountID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return connectAccountState(acc), nil
+}
+
+func (s *StripeConnectAdapter) CreateOnboardingLink(ctx context.Context, accountID string, refreshURL string, returnURL string) (string, error) {
+	link, err := accountlink.New(&stripe.AccountLinkParams{
+		Account:    stripe.String(accountID),
+		RefreshURL: stripe.String(refreshURL),
+		ReturnURL:  stripe.String(returnURL),
+		Type:       stripe.String("account_onboarding"),
+	})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+func (s *StripeConnectAdapter) CreateLoginLink(ctx context.Context, accountID string) (string, error) {
+	link, err := loginlink.New(&stripe.LoginLinkParams{
+		Account: stripe.String(accountID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return link.URL, nil
+}
+
+func (s *StripeConnectAdapter) OAuthURL(state string, redirectURI string) string {
+	params := url.Values{}
+	params.Add("response_type", "code")
+	params.Add("client_id", s.clientID)
+	params.Add("scope", "read_write")
+	params.Add("state", state)
+	params.Add("redirect_uri", redirectURI)
+
+	return fmt.Sprintf("https://connect.stripe.com/oauth/authorize?%s", params.Encode())
+}
+
+func (s *StripeConnectAdapter) ExchangeOAuthCode(ctx context.Context, code string) (string, error) {
+	token, err := oauth.New(&stripe.OAuthTokenParams{
+		GrantType: stripe.String("authorization_code"),
+		Code:      stripe.String(code),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token.StripeUserID, nil
+}
+
+func (s *StripeConnectAdapter) Deauthorize(ctx context.Context, accountID string) error {
+	_, err := oauth.Del(&stripe.DeauthorizeParams{
+		ClientID:     stripe.String(s.clientID),
+		StripeUserID: stripe.String(accountID),
+	})
+	return err
+}
+
+func (s *StripeConnectAdapter) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.ConnectEvent, error) {
+	if s.webhookSecret == "" {
+		return nil, errors.New("stripe connect webhook secret is not configured")
+	}
+	event, err := webhook.ConstructEventWithOptions(payload, headers.Get("Stripe-Signature"), s.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case stripe.EventTypeAccountUpdated:
+		var acc stripe.Account
+		if err := json.Unmarshal(event.Data.Raw, &acc); err != nil {
+			return nil, err
+		}
+		return &domain.ConnectEvent{
+			ID:        event.ID,
+			Type:      domain.ConnectEventAccountUpdated,
+			AccountID: acc.ID,
+			Account:   connectAccountState(&acc),
+		}, nil
+
+	case stripe.EventTypeAccountApplicationDeauthorized:
+		return &domain.ConnectEvent{
+			ID:        event.ID,
+			Type:      domain.ConnectEventDeauthorized,
+			AccountID: event.Account,
+		}, nil
+	}
+	return nil, nil
+}
+
+// connectAccountState reads what Stripe reports about a connected account
+func connectAccountState(acc *stripe.Account) *domain.ConnectAccount {
+	state := &domain.ConnectAccount{
+		Type:             string(acc.Type),
+		ChargesEnabled:   acc.ChargesEnabled,
+		PayoutsEnabled:   acc.PayoutsEnabled,
+		DetailsSubmitted: acc.DetailsSubmitted,
+		SyncedAt:         time.Now(),
+	}
+	if req := acc.Requirements; req != nil {
+		state.CurrentlyDue = req.CurrentlyDue
+		state.PastDue = req.PastDue
+		state.DisabledReason = string(req.DisabledReason)
+		if req.CurrentDeadline > 0 {
+			deadline := time.Unix(req.CurrentDeadline, 0)
+			state.Deadline = &deadline
+		}
+	}
+	return state
+}
@@ -2,21 +2,37 @@ package stripe
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt" // Added
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
 	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/coupon"
 	"github.com/stripe/stripe-go/v76/customer"
 	"github.com/stripe/stripe-go/v76/paymentintent"
 	"github.com/stripe/stripe-go/v76/price"
 	"github.com/stripe/stripe-go/v76/product"
+	"github.com/stripe/stripe-go/v76/promotioncode"
+	"github.com/stripe/stripe-go/v76/refund"
 	"github.com/stripe/stripe-go/v76/subscription"
+	"github.com/stripe/stripe-go/v76/taxrate"
+	"github.com/stripe/stripe-go/v76/webhook"
 )
 
 type StripeAdapter struct {
 	AllowMock bool
+
+	webhookSecret string
+
+	taxRates sync.Map // Stripe tax rate IDs by taxRateKey, created on first use
 }
 
 func NewStripeAdapter(cfg *config.Config) ports.PaymentGateway {
@@ -24,49 +40,200 @@ func NewStripeAdapter(cfg *config.Config) ports.PaymentGateway {
 	if key == "" {
 		fmt.Println("⚠️ StripeAdapter: Key is missing! Using Mock Mode.")
 		stripe.Key = "sk_test_mock_key"
-		return &StripeAdapter{AllowMock: true}
+		return &StripeAdapter{AllowMock: true, webhookSecret: cfg.StripeWebhookSecret}
 	}
 	if len(key) > 8 {
 		fmt.Printf("✅ StripeAdapter: Loaded Key starting with %s...\n", key[:8])
 	}
 	stripe.Key = key
-	return &StripeAdapter{AllowMock: false}
+	return &StripeAdapter{AllowMock: false, webhookSecret: cfg.StripeWebhookSecret}
+}
+
+func (s *StripeAdapter) Name() domain.PaymentGateway {
+	return domain.GatewayStripe
 }
 
-func (s *StripeAdapter) CreatePaymentIntent(ctx context.Context, amount float64, currency string, metadata map[string]string, destinationAccountID string, applicationFeeAmount int64) (string, error) {
+// CreateOrder creates a PaymentIntent; the buyer confirms it in the browser with its client secret
+func (s *StripeAdapter) CreateOrder(ctx context.Context, order domain.GatewayOrder) (*domain.GatewayCheckout, error) {
 	if s.AllowMock {
-		return "pi_mock_1234567890", nil
+		return &domain.GatewayCheckout{
+			Gateway:      domain.GatewayStripe,
+			OrderID:      order.OrderID,
+			Ref:          "pi_mock_" + order.OrderID,
+			ClientSecret: "pi_mock_1234567890",
+		}, nil
 	}
 
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(int64(amount * 100)), // Convert to cents
-		Currency: stripe.String(currency),
+		Amount:   stripe.Int64(domain.MinorUnits(order.Amount, order.Currency)), // Convert to cents (or yen)
+		Currency: stripe.String(strings.ToLower(order.Currency)),
 		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
 			Enabled: stripe.Bool(true),
 		},
 	}
+	if order.Description != "" {
+		params.Description = stripe.String(order.Description)
+	}
 
 	// Handle Connect Destination Charge
-	if destinationAccountID != "" {
+	if order.DestinationAccountID != "" {
 		params.TransferData = &stripe.PaymentIntentTransferDataParams{
-			Destination: stripe.String(destinationAccountID),
+			Destination: stripe.String(order.DestinationAccountID),
 		}
-		if applicationFeeAmount > 0 {
-			params.ApplicationFeeAmount = stripe.Int64(applicationFeeAmount)
+		if fee := domain.MinorUnits(order.ApplicationFee, order.Currency); fee > 0 {
+			params.ApplicationFeeAmount = stripe.Int64(fee)
 		}
 	}
 
 	// Convert and attach metadata
-	for k, v := range metadata {
+	for k, v := range order.Metadata {
 		params.AddMetadata(k, v)
 	}
+	params.AddMetadata("order_id", order.OrderID)
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return &domain.GatewayCheckout{
+		Gateway:      domain.GatewayStripe,
+		OrderID:      order.OrderID,
+		Ref:          pi.ID,
+		ClientSecret: pi.ClientSecret,
+	}, nil
+}
+
+// CaptureOrder reports the PaymentIntent's outcome. PaymentIntents capture automatically
+// once confirmed; only those created for manual capture are captured here.
+func (s *StripeAdapter) CaptureOrder(ctx context.Context, orderRef string) (*domain.GatewayCapture, error) {
+	if s.AllowMock {
+		return &domain.GatewayCapture{Ref: orderRef, OrderRef: orderRef, Completed: true}, nil
+	}
+
+	pi, err := paymentintent.Get(orderRef, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pi.Status == stripe.PaymentIntentStatusRequiresCapture {
+		if pi, err = paymentintent.Capture(orderRef, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	currency := domain.NormalizeCurrency(string(pi.Currency))
+	return &domain.GatewayCapture{
+		Ref:       pi.ID,
+		OrderRef:  pi.ID,
+		OrderID:   pi.Metadata["order_id"],
+		Completed: pi.Status == stripe.PaymentIntentStatusSucceeded,
+		Amount:    domain.FromMinorUnits(pi.AmountReceived, currency),
+		Currency:  currency,
+	}, nil
+}
+
+func (s *StripeAdapter) RefundCapture(ctx context.Context, captureRef string, amount float64, currency string, metadata map[string]string) (*domain.GatewayRefund, error) {
+	if s.AllowMock {
+		return &domain.GatewayRefund{Ref: fmt.Sprintf("re_mock_%d", time.Now().UnixNano()), Amount: amount, Status: "succeeded"}, nil
 	}
 
-	return pi.ClientSecret, nil
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(captureRef),
+		Amount:        stripe.Int64(domain.MinorUnits(amount, currency)),
+	}
+	for k, v := range metadata {
+		params.AddMetadata(k, v)
+	}
+
+	r, err := refund.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.GatewayRefund{
+		Ref:    r.ID,
+		Amount: domain.FromMinorUnits(r.Amount, string(r.Currency)),
+		Status: string(r.Status),
+	}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header against STRIPE_WEBHOOK_SECRET
+func (s *StripeAdapter) ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.GatewayEvent, error) {
+	if s.webhookSecret == "" {
+		return nil, errors.New("stripe webhook secret is not configured")
+	}
+	event, err := webhook.ConstructEventWithOptions(payload, headers.Get("Stripe-Signature"), s.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case stripe.EventTypePaymentIntentSucceeded, stripe.EventTypePaymentIntentPaymentFailed:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return nil, err
+		}
+		currency := domain.NormalizeCurrency(string(pi.Currency))
+		result := &domain.GatewayEvent{
+			ID:       event.ID,
+			Type:     domain.GatewayEventFailed,
+			OrderID:  pi.Metadata["order_id"],
+			Ref:      pi.ID,
+			Amount:   domain.FromMinorUnits(pi.AmountReceived, currency),
+			Currency: currency,
+			Metadata: pi.Metadata,
+		}
+		if event.Type == stripe.EventTypePaymentIntentSucceeded {
+			result.Type = domain.GatewayEventSucceeded
+		}
+		return result, nil
+
+	case stripe.EventTypeRefundCreated:
+		var r stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &r); err != nil {
+			return nil, err
+		}
+		if r.PaymentIntent == nil {
+			return nil, nil // Refund of a charge made outside checkout
+		}
+		currency := domain.NormalizeCurrency(string(r.Currency))
+		return &domain.GatewayEvent{
+			ID:        event.ID,
+			Type:      domain.GatewayEventRefunded,
+			OrderID:   r.Metadata["order_id"],
+			Ref:       r.PaymentIntent.ID,
+			RefundRef: r.ID,
+			Amount:    domain.FromMinorUnits(r.Amount, currency),
+			Currency:  currency,
+			Metadata:  r.Metadata,
+		}, nil
+
+	case stripe.EventTypeInvoicePaid:
+		var inv stripe.Invoice
+		if err := json.Unmarshal(event.Data.Raw, &inv); err != nil {
+			return nil, err
+		}
+		// The first invoice is settled through the checkout's PaymentIntent
+		if inv.BillingReason != stripe.InvoiceBillingReasonSubscriptionCycle || inv.Subscription == nil {
+			return nil, nil
+		}
+		currency := domain.NormalizeCurrency(string(inv.Currency))
+		result := &domain.GatewayEvent{
+			ID:              event.ID,
+			Type:            domain.GatewayEventRenewed,
+			Ref:             inv.ID,
+			SubscriptionRef: inv.Subscription.ID,
+			Amount:          domain.FromMinorUnits(inv.AmountPaid, currency),
+			Currency:        currency,
+		}
+		if inv.Lines != nil && len(inv.Lines.Data) > 0 && inv.Lines.Data[0].Period != nil {
+			result.PeriodStart = time.Unix(inv.Lines.Data[0].Period.Start, 0)
+			result.PeriodEnd = time.Unix(inv.Lines.Data[0].Period.End, 0)
+		}
+		return result, nil
+	}
+	return nil, nil
 }
 
 // ... CreateProduct, UpdateProduct, etc ... (omitted for brevity in replacement, but need to be careful not to overwrite unless using chunks)
@@ -74,9 +241,13 @@ func (s *StripeAdapter) CreatePaymentIntent(ctx context.Context, amount float64,
 // Wait, I should use multireplace if the file is large or just be careful with ReplaceFileContent.
 // The file is small enough (200 lines) so I can target specific method blocks.
 
-func (s *StripeAdapter) CreateSubscription(ctx context.Context, customerID string, priceID string, metadata map[string]string, destinationAccountID string, applicationFeePercent float64) (string, error) {
+func (s *StripeAdapter) CreateSubscription(ctx context.Context, customerID string, priceID string, promotionCodeID string, tax *domain.TaxResult, metadata map[string]string, destinationAccountID string, applicationFeePercent float64) (*domain.GatewaySubscription, error) {
 	if s.AllowMock {
-		return "sub_mock_123", nil
+		return &domain.GatewaySubscription{
+			Ref:          fmt.Sprintf("sub_mock_%d", time.Now().UnixNano()),
+			PaymentRef:   fmt.Sprintf("pi_mock_%d", time.Now().UnixNano()),
+			ClientSecret: "pi_mock_1234567890",
+		}, nil
 	}
 
 	params := &stripe.SubscriptionParams{
@@ -90,6 +261,20 @@ func (s *StripeAdapter) CreateSubscription(ctx context.Context, customerID strin
 	}
 	params.AddExpand("latest_invoice.payment_intent")
 
+	// Coupon discount, applied by the gateway to every invoice its duration covers
+	if promotionCodeID != "" {
+		params.PromotionCode = stripe.String(promotionCodeID)
+	}
+
+	// Tax decided at checkout, charged on every invoice
+	if tax != nil && tax.Name != "" {
+		taxRateID, err := s.taxRate(tax)
+		if err != nil {
+			return nil, err
+		}
+		params.DefaultTaxRates = []*string{stripe.String(taxRateID)}
+	}
+
 	// Handle Connect Destination Charge
 	if destinationAccountID != "" {
 		params.TransferData = &stripe.SubscriptionTransferDataParams{
@@ -107,14 +292,44 @@ func (s *StripeAdapter) CreateSubscription(ctx context.Context, customerID strin
 
 	sub, err := subscription.New(params)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if sub.LatestInvoice.PaymentIntent == nil {
-		return "", nil // No payment needed or error?
+	if sub.LatestInvoice == nil || sub.LatestInvoice.PaymentIntent == nil {
+		return &domain.GatewaySubscription{Ref: sub.ID}, nil // Nothing to pay now, e.g. fully discounted first invoice
 	}
 
-	return sub.LatestInvoice.PaymentIntent.ClientSecret, nil
+	// Stripe does not copy the subscription's metadata onto the invoice's PaymentIntent,
+	// so its webhooks are matched to the order by the PaymentIntent ID
+	pi := sub.LatestInvoice.PaymentIntent
+	return &domain.GatewaySubscription{Ref: sub.ID, PaymentRef: pi.ID, ClientSecret: pi.ClientSecret}, nil
+}
+
+// taxRate returns the Stripe tax rate matching the result, creating it once per adapter
+func (s *StripeAdapter) taxRate(tax *domain.TaxResult) (string, error) {
+	percentage := tax.Rate * 100
+	displayName := tax.Name
+	if tax.ReverseCharge {
+		percentage = 0
+		displayName = tax.Name + " (reverse charge)"
+	}
+	key := fmt.Sprintf("%s|%s|%.3f|%t", tax.Country, displayName, percentage, tax.Inclusive)
+	if id, ok := s.taxRates.Load(key); ok {
+		return id.(string), nil
+	}
+
+	rate, err := taxrate.New(&stripe.TaxRateParams{
+		DisplayName:  stripe.String(displayName),
+		Country:      stripe.String(tax.Country),
+		Jurisdiction: stripe.String(tax.Country),
+		Percentage:   stripe.Float64(percentage),
+		Inclusive:    stripe.Bool(tax.Inclusive),
+	})
+	if err != nil {
+		return "", err
+	}
+	s.taxRates.Store(key, rate.ID)
+	return rate.ID, nil
 }
 
 func (s *StripeAdapter) CreateProduct(ctx context.Context, name string, description string) (string, error) {
@@ -183,8 +398,8 @@ func (s *StripeAdapter) CreatePrice(ctx context.Context, productID string, amoun
 
 	params := &stripe.PriceParams{
 		Product:    stripe.String(productID),
-		UnitAmount: stripe.Int64(int64(amount * 100)),
-		Currency:   stripe.String(currency),
+		UnitAmount: stripe.Int64(domain.MinorUnits(amount, currency)),
+		Currency:   stripe.String(strings.ToLower(currency)),
 	}
 
 	if interval != "" {
@@ -216,8 +431,12 @@ func (s *StripeAdapter) CreateCustomer(ctx context.Context, email string, name s
 	return c.ID, nil
 }
 
-func (s *StripeAdapter) ConfirmPayment(ctx context.Context, paymentID string) error {
-	return nil // Usually handled via Webhook or Client SDK
+func (s *StripeAdapter) DeleteCustomer(ctx context.Context, customerID string) error {
+	if s.AllowMock {
+		return nil
+	}
+	_, err := customer.Del(customerID, nil)
+	return err
 }
 
 func (s *StripeAdapter) CancelSubscription(ctx context.Context, subID string) error {
@@ -227,3 +446,46 @@ func (s *StripeAdapter) CancelSubscription(ctx context.Context, subID string) er
 	_, err := subscription.Cancel(subID, nil)
 	return err
 }
+
+func (s *StripeAdapter) CreateCoupon(ctx context.Context, c *domain.Coupon, currency string) (string, error) {
+	if s.AllowMock {
+		return "coupon_mock_" + c.Code, nil
+	}
+
+	params := &stripe.CouponParams{
+		Name:     stripe.String(c.Code),
+		Duration: stripe.String(string(c.EffectiveDuration())),
+	}
+	if c.EffectiveDuration() == domain.CouponDurationRepeating {
+		params.DurationInMonths = stripe.Int64(int64(c.DurationInMonths))
+	}
+	if c.DiscountType == domain.DiscountTypePercent {
+		params.PercentOff = stripe.Float64(c.DiscountAmount)
+	} else {
+		params.AmountOff = stripe.Int64(domain.MinorUnits(c.DiscountAmount, currency)) // Convert to cents (or yen)
+		params.Currency = stripe.String(strings.ToLower(currency))
+	}
+	params.AddMetadata("coupon_id", c.ID.Hex())
+
+	cp, err := coupon.New(params)
+	if err != nil {
+		return "", err
+	}
+	return cp.ID, nil
+}
+
+func (s *StripeAdapter) CreatePromotionCode(ctx context.Context, couponID string, code string) (string, error) {
+	if s.AllowMock {
+		return "promo_mock_" + code, nil
+	}
+
+	params := &stripe.PromotionCodeParams{
+		Coupon: stripe.String(couponID),
+		Code:   stripe.String(code),
+	}
+	pc, err := promotioncode.New(params)
+	if err != nil {
+		return "", err
+	}
+	return pc.ID, nil
+}
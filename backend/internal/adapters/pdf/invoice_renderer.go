@@ -0,0 +1,200 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// Column positions of the item table
+const (
+	marginLeft  = 50.0
+	marginRight = 545.0
+	colQty      = 340.0
+	colUnit     = 450.0
+	bottomLimit = 90.0
+)
+
+type InvoiceRenderer struct{}
+
+func NewInvoiceRenderer() ports.InvoiceRenderer {
+	return &InvoiceRenderer{}
+}
+
+func (r *InvoiceRenderer) RenderPDF(inv *domain.Invoice) ([]byte, error) {
+	d := &document{}
+	d.addPage()
+
+	// Header
+	d.text(marginLeft, 780, bold, 22, "INVOICE")
+	d.textRight(marginRight, 786, regular, 10, "Invoice No: "+inv.InvoiceNumber)
+	d.textRight(marginRight, 772, regular, 10, "Date: "+inv.CreatedAt.Format("2006-01-02"))
+	d.textRight(marginRight, 758, regular, 10, "Status: "+strings.ToUpper(string(inv.Status)))
+
+	if inv.Status == domain.InvoiceStatusVoid {
+		d.text(marginLeft, 756, bold, 12, "VOID - this invoice is no longer valid")
+	}
+
+	// Parties
+	y := math.Min(party(d, marginLeft, 720, "From", inv.Seller), party(d, 300, 720, "Bill To", inv.Buyer))
+
+	// Items
+	y = tableHeader(d, math.Min(640, y-25))
+	for _, item := range inv.Items {
+		if y < bottomLimit {
+			d.addPage()
+			y = tableHeader(d, 780)
+		}
+		d.text(marginLeft, y, regular, 10, truncate(item.Description, 55))
+		d.textRight(colQty+20, y, regular, 10, fmt.Sprintf("%d", item.Quantity))
+		d.textRight(colUnit+40, y, regular, 10, money(item.UnitPrice))
+		d.textRight(marginRight, y, regular, 10, money(item.Total))
+		y -= 18
+	}
+	d.line(marginLeft, marginRight, y+8)
+
+	// Totals
+	if y < bottomLimit+80 {
+		d.addPage()
+		y = 780
+	}
+	y -= 10
+	total := func(label string, amount string, f font) {
+		d.textRight(colUnit+40, y, f, 10, label)
+		d.textRight(marginRight, y, f, 10, amount)
+		y -= 16
+	}
+	total("Subtotal", money(inv.SubTotal), regular)
+	for _, discount := range inv.Discounts {
+		total(truncate(discount.Description, 30), "-"+money(discount.Amount), regular)
+	}
+	for _, line := range inv.TaxLines {
+		label := fmt.Sprintf("%s %s%% (%s)", line.Name, percent(line.Rate), line.Country)
+		switch {
+		case inv.ReverseCharge:
+			label = line.Name + " reverse charge"
+		case inv.TaxInclusive:
+			label = "incl. " + label
+		}
+		total(label, money(line.Amount), regular)
+	}
+	if len(inv.TaxLines) == 0 && inv.TaxAmount > 0 {
+		total("Tax", money(inv.TaxAmount), regular)
+	}
+	total("Total ("+strings.ToUpper(inv.Currency)+")", money(inv.TotalAmount), bold)
+
+	// Footer
+	if inv.ReverseCharge {
+		d.text(marginLeft, 88, regular, 9, "Reverse charge: the recipient is liable to account for the tax.")
+	}
+	if inv.Status == domain.InvoiceStatusVoid && inv.VoidReason != "" {
+		d.text(marginLeft, 74, regular, 9, "Void reason: "+truncate(inv.VoidReason, 80))
+	}
+	if inv.PaidAt != nil {
+		d.text(marginLeft, 60, regular, 9, "Paid on "+inv.PaidAt.Format("2006-01-02")+". Thank you for your purchase.")
+	}
+
+	return d.bytes()
+}
+
+func (r *InvoiceRenderer) RenderCreditNotePDF(cn *domain.CreditNote) ([]byte, error) {
+	d := &document{}
+	d.addPage()
+
+	// Header
+	d.text(marginLeft, 780, bold, 22, "CREDIT NOTE")
+	d.textRight(marginRight, 786, regular, 10, "Credit Note No: "+cn.CreditNoteNumber)
+	d.textRight(marginRight, 772, regular, 10, "Date: "+cn.CreatedAt.Format("2006-01-02"))
+	d.textRight(marginRight, 758, regular, 10, "For Invoice: "+cn.InvoiceNumber)
+
+	// Parties
+	y := math.Min(party(d, marginLeft, 720, "From", cn.Seller), party(d, 300, 720, "Credit To", cn.Buyer))
+
+	// Items
+	y = tableHeader(d, math.Min(640, y-25))
+	for _, item := range cn.Items {
+		if y < bottomLimit {
+			d.addPage()
+			y = tableHeader(d, 780)
+		}
+		d.text(marginLeft, y, regular, 10, truncate(item.Description, 55))
+		d.textRight(colQty+20, y, regular, 10, fmt.Sprintf("%d", item.Quantity))
+		d.textRight(colUnit+40, y, regular, 10, money(item.UnitPrice))
+		d.textRight(marginRight, y, regular, 10, money(item.Total))
+		y -= 18
+	}
+	d.line(marginLeft, marginRight, y+8)
+
+	// Totals
+	if y < bottomLimit+60 {
+		d.addPage()
+		y = 780
+	}
+	y -= 10
+	total := func(label string, amount string, f font) {
+		d.textRight(colUnit+40, y, f, 10, label)
+		d.textRight(marginRight, y, f, 10, amount)
+		y -= 16
+	}
+	total("Subtotal", money(cn.SubTotal), regular)
+	if cn.TaxAmount > 0 {
+		total("Tax reversed", money(cn.TaxAmount), regular)
+	}
+	total("Total credited ("+strings.ToUpper(cn.Currency)+")", money(cn.TotalAmount), bold)
+
+	// Footer
+	if cn.Note != "" {
+		d.text(marginLeft, 74, regular, 9, "Note: "+truncate(cn.Note, 90))
+	}
+	d.text(marginLeft, 60, regular, 9, "This credit note reduces the amount of invoice "+cn.InvoiceNumber+".")
+
+	return d.bytes()
+}
+
+// party writes a party block and returns the baseline of its last line
+func party(d *document, x, y float64, title string, p domain.InvoiceParty) float64 {
+	d.text(x, y, bold, 11, title)
+	y -= 15
+	d.text(x, y, regular, 10, truncate(p.Name, 40))
+	lines := p.Address
+	if p.Email != "" {
+		lines = append([]string{p.Email}, lines...)
+	}
+	if p.TaxID != "" {
+		lines = append(lines, "Tax ID: "+p.TaxID)
+	}
+	for _, line := range lines {
+		y -= 14
+		d.text(x, y, regular, 10, truncate(line, 40))
+	}
+	return y
+}
+
+func tableHeader(d *document, y float64) float64 {
+	d.text(marginLeft, y, bold, 10, "Description")
+	d.textRight(colQty+20, y, bold, 10, "Qty")
+	d.textRight(colUnit+40, y, bold, 10, "Unit Price")
+	d.textRight(marginRight, y, bold, 10, "Amount")
+	d.line(marginLeft, marginRight, y-6)
+	return y - 22
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// percent formats a rate fraction without trailing zeros, e.g. 0.255 as "25.5"
+func percent(rate float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", rate*100), "0"), ".")
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
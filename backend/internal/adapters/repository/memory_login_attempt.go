@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// pruneInterval bounds how often a write sweeps expired entries
+const pruneInterval = time.Minute
+
+// MemoryLoginAttemptStore keeps login counters in process memory.
+// Suitable for single-instance deployments and local development.
+type MemoryLoginAttemptStore struct {
+	mu         sync.Mutex
+	attempts   map[string]*domain.LoginAttempt
+	lastPruned time.Time
+}
+
+func NewMemoryLoginAttemptStore() ports.LoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]*domain.LoginAttempt),
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (s *MemoryLoginAttemptStore) IncrementFailure(ctx context.Context, key string, at time.Time, windowStart time.Time) (*domain.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(at, windowStart)
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &domain.LoginAttempt{Key: key}
+		s.attempts[key] = a
+	}
+	if a.LastFailureAt.Before(windowStart) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = at
+
+	cp := *a
+	return &cp, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &domain.LoginAttempt{Key: key}
+		s.attempts[key] = a
+	}
+	a.LockedUntil = &until
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// prune drops entries whose failure window and lockout have both passed, so
+// keys that stop failing (e.g. sprayed emails or IPs) don't pile up forever.
+// Callers must hold s.mu.
+func (s *MemoryLoginAttemptStore) prune(now time.Time, windowStart time.Time) {
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	for key, a := range s.attempts {
+		if a.LastFailureAt.Before(windowStart) && !a.IsLocked(now) {
+			delete(s.attempts, key)
+		}
+	}
+}
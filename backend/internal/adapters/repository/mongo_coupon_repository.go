@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
@@ -10,15 +11,20 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoCouponRepository struct {
-	collection *mongo.Collection
+	collection  *mongo.Collection
+	redemptions *mongo.Collection
+	campaigns   *mongo.Collection
 }
 
 func NewMongoCouponRepository(db *mongo.Database) ports.CouponRepository {
 	return &MongoCouponRepository{
-		collection: db.Collection("coupons"),
+		collection:  db.Collection("coupons"),
+		redemptions: db.Collection("coupon_redemptions"),
+		campaigns:   db.Collection("coupon_campaigns"),
 	}
 }
 
@@ -75,13 +81,151 @@ func (r *MongoCouponRepository) ListCoupons(ctx context.Context) ([]*domain.Coup
 	return coupons, nil
 }
 
+func (r *MongoCouponRepository) ListCouponsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.Coupon, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"creator_id": creatorID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var coupons []*domain.Coupon
+	if err = cursor.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
 func (r *MongoCouponRepository) IncrementUsage(ctx context.Context, code string) error {
 	_, err := r.collection.UpdateOne(ctx, bson.M{"code": code}, bson.M{"$inc": bson.M{"used_count": 1}})
 	return err
 }
 
+func (r *MongoCouponRepository) ReserveUsage(ctx context.Context, couponID primitive.ObjectID) (bool, error) {
+	// The limit check and the increment happen in one conditional update, so
+	// concurrent checkouts can never push used_count past max_uses
+	filter := bson.M{
+		"_id": couponID,
+		"$or": bson.A{
+			bson.M{"max_uses": bson.M{"$lte": 0}},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$used_count", "$max_uses"}}},
+		},
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"used_count": 1}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoCouponRepository) ReleaseUsage(ctx context.Context, couponID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": couponID, "used_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"used_count": -1}},
+	)
+	return err
+}
+
+func (r *MongoCouponRepository) CreateRedemption(ctx context.Context, redemption *domain.CouponRedemption) error {
+	redemption.ID = primitive.NewObjectID()
+	_, err := r.redemptions.InsertOne(ctx, redemption)
+	return err
+}
+
+func (r *MongoCouponRepository) GetRedemption(ctx context.Context, id primitive.ObjectID) (*domain.CouponRedemption, error) {
+	var redemption domain.CouponRedemption
+	err := r.redemptions.FindOne(ctx, bson.M{"_id": id}).Decode(&redemption)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &redemption, nil
+}
+
+func (r *MongoCouponRepository) GetRedemptionsByCoupon(ctx context.Context, couponID primitive.ObjectID) ([]*domain.CouponRedemption, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return r.findRedemptions(ctx, bson.M{"coupon_id": couponID}, opts)
+}
+
+func (r *MongoCouponRepository) GetExpiredReservations(ctx context.Context, couponID primitive.ObjectID, now time.Time) ([]*domain.CouponRedemption, error) {
+	return r.findRedemptions(ctx, bson.M{
+		"coupon_id":  couponID,
+		"status":     domain.RedemptionReserved,
+		"expires_at": bson.M{"$lte": now},
+	})
+}
+
+func (r *MongoCouponRepository) TransitionRedemption(ctx context.Context, redemption *domain.CouponRedemption, from domain.RedemptionStatus) (bool, error) {
+	res, err := r.redemptions.UpdateOne(ctx,
+		bson.M{"_id": redemption.ID, "status": from},
+		bson.M{"$set": bson.M{
+			"status":         redemption.Status,
+			"payment_ref":    redemption.PaymentRef,
+			"release_reason": redemption.ReleaseReason,
+			"confirmed_at":   redemption.ConfirmedAt,
+			"released_at":    redemption.ReleasedAt,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoCouponRepository) CountUserRedemptions(ctx context.Context, couponID primitive.ObjectID, userID primitive.ObjectID) (int64, error) {
+	return r.redemptions.CountDocuments(ctx, bson.M{
+		"coupon_id": couponID,
+		"user_id":   userID,
+		"status":    bson.M{"$ne": domain.RedemptionReleased},
+	})
+}
+
+func (r *MongoCouponRepository) findRedemptions(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*domain.CouponRedemption, error) {
+	cursor, err := r.redemptions.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var redemptions []*domain.CouponRedemption
+	if err = cursor.All(ctx, &redemptions); err != nil {
+		return nil, err
+	}
+	return redemptions, nil
+}
+
+// UpdateCoupon saves the coupon's rules. Usage counters and gateway IDs have their own updates
+// so a stale copy can never roll them back.
 func (r *MongoCouponRepository) UpdateCoupon(ctx context.Context, coupon *domain.Coupon) error {
-	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": coupon.ID}, bson.M{"$set": coupon})
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": coupon.ID}, bson.M{"$set": bson.M{
+		"discount_type":          coupon.DiscountType,
+		"discount_amount":        coupon.DiscountAmount,
+		"applicable_plan_ids":    coupon.ApplicablePlanIDs,
+		"max_uses":               coupon.MaxUses,
+		"expiry_date":            coupon.ExpiryDate,
+		"is_active":              coupon.IsActive,
+		"start_date":             coupon.StartDate,
+		"max_uses_per_user":      coupon.MaxUsesPerUser,
+		"min_order_amount":       coupon.MinOrderAmount,
+		"max_discount_amount":    coupon.MaxDiscountAmount,
+		"first_purchase_only":    coupon.FirstPurchaseOnly,
+		"allowed_user_ids":       coupon.AllowedUserIDs,
+		"allowed_email_domains":  coupon.AllowedEmailDomains,
+		"stacks_with_early_bird": coupon.StacksWithEarlyBird,
+		"stacks_with_coupons":    coupon.StacksWithCoupons,
+		"duration":               coupon.Duration,
+		"duration_in_months":     coupon.DurationInMonths,
+	}})
+	return err
+}
+
+func (r *MongoCouponRepository) SetGatewayIDs(ctx context.Context, coupon *domain.Coupon) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": coupon.ID}, bson.M{"$set": bson.M{
+		"stripe_coupon_id":         coupon.StripeCouponID,
+		"stripe_promotion_code_id": coupon.StripePromotionCodeID,
+		"stripe_currency":          coupon.StripeCurrency,
+	}})
 	return err
 }
 
@@ -89,3 +233,156 @@ func (r *MongoCouponRepository) DeleteCoupon(ctx context.Context, id primitive.O
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+func (r *MongoCouponRepository) CreateCoupons(ctx context.Context, coupons []*domain.Coupon) error {
+	docs := make([]interface{}, len(coupons))
+	for i, coupon := range coupons {
+		coupon.ID = primitive.NewObjectID()
+		docs[i] = coupon
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *MongoCouponRepository) ExistingCodes(ctx context.Context, codes []string) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"code": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"code": bson.M{"$in": codes}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []struct {
+		Code string `bson:"code"`
+	}
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	existing := make([]string, len(found))
+	for i, f := range found {
+		existing[i] = f.Code
+	}
+	return existing, nil
+}
+
+func (r *MongoCouponRepository) GetCouponsByCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]*domain.Coupon, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "code", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"campaign_id": campaignID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var coupons []*domain.Coupon
+	if err = cursor.All(ctx, &coupons); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+func (r *MongoCouponRepository) DeactivateCampaignCoupons(ctx context.Context, campaignID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"campaign_id": campaignID}, bson.M{"$set": bson.M{"is_active": false}})
+	return err
+}
+
+// Campaigns
+
+func (r *MongoCouponRepository) CreateCampaign(ctx context.Context, campaign *domain.CouponCampaign) error {
+	campaign.ID = primitive.NewObjectID()
+	_, err := r.campaigns.InsertOne(ctx, campaign)
+	return err
+}
+
+func (r *MongoCouponRepository) GetCampaign(ctx context.Context, id primitive.ObjectID) (*domain.CouponCampaign, error) {
+	var campaign domain.CouponCampaign
+	err := r.campaigns.FindOne(ctx, bson.M{"_id": id}).Decode(&campaign)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *MongoCouponRepository) GetCampaignsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.CouponCampaign, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.campaigns.Find(ctx, bson.M{"creator_id": creatorID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var campaigns []*domain.CouponCampaign
+	if err = cursor.All(ctx, &campaigns); err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+func (r *MongoCouponRepository) UpdateCampaign(ctx context.Context, campaign *domain.CouponCampaign) error {
+	_, err := r.campaigns.ReplaceOne(ctx, bson.M{"_id": campaign.ID}, campaign)
+	return err
+}
+
+func (r *MongoCouponRepository) DeleteCampaign(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := r.collection.DeleteMany(ctx, bson.M{"campaign_id": id}); err != nil {
+		return err
+	}
+	_, err := r.campaigns.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *MongoCouponRepository) GetCampaignStats(ctx context.Context, campaignID primitive.ObjectID) (*domain.CouponCampaignStats, error) {
+	stats := &domain.CouponCampaignStats{CampaignID: campaignID}
+
+	var err error
+	if stats.CodeCount, err = r.collection.CountDocuments(ctx, bson.M{"campaign_id": campaignID}); err != nil {
+		return nil, err
+	}
+	if stats.CodesUsed, err = r.collection.CountDocuments(ctx, bson.M{"campaign_id": campaignID, "used_count": bson.M{"$gt": 0}}); err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.redemptions.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"campaign_id": campaignID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"status": "$status", "currency": bson.M{"$ifNull": bson.A{"$currency", domain.DefaultCurrency}}},
+			"count":    bson.M{"$sum": 1},
+			"discount": bson.M{"$sum": "$discount_amount"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			Status   domain.RedemptionStatus `bson:"status"`
+			Currency string                  `bson:"currency"`
+		} `bson:"_id"`
+		Count    int64   `bson:"count"`
+		Discount float64 `bson:"discount"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	// TotalDiscount is left to the service, which converts DiscountByCurrency
+	stats.DiscountByCurrency = map[string]float64{}
+	for _, g := range groups {
+		switch g.ID.Status {
+		case domain.RedemptionReserved:
+			stats.Reserved += g.Count
+		case domain.RedemptionConfirmed:
+			stats.Confirmed += g.Count
+			stats.DiscountByCurrency[g.ID.Currency] += g.Discount
+		case domain.RedemptionReleased:
+			stats.Released += g.Count
+		}
+	}
+	if stats.CodeCount > 0 {
+		stats.RedemptionRate = float64(stats.CodesUsed) / float64(stats.CodeCount) * 100
+	}
+	return stats, nil
+}
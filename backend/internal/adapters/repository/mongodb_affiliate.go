@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
@@ -9,12 +11,16 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoAffiliateRepository struct {
 	programs    *mongo.Collection
 	links       *mongo.Collection
 	commissions *mongo.Collection
+	clicks      *mongo.Collection
+	conversions *mongo.Collection
+	enrollments *mongo.Collection
 }
 
 func NewMongoAffiliateRepository(db *mongo.Database) ports.AffiliateRepository {
@@ -22,6 +28,9 @@ func NewMongoAffiliateRepository(db *mongo.Database) ports.AffiliateRepository {
 		programs:    db.Collection("affiliate_programs"),
 		links:       db.Collection("affiliate_links"),
 		commissions: db.Collection("affiliate_commissions"),
+		clicks:      db.Collection("affiliate_clicks"),
+		conversions: db.Collection("affiliate_conversions"),
+		enrollments: db.Collection("affiliate_enrollments"),
 	}
 }
 
@@ -38,11 +47,103 @@ func (r *MongoAffiliateRepository) GetProgram(ctx context.Context, id primitive.
 	return &p, err
 }
 
+func (r *MongoAffiliateRepository) GetProgramsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.AffiliateProgram, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.programs.Find(ctx, bson.M{"creator_id": creatorID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var programs []*domain.AffiliateProgram
+	if err = cursor.All(ctx, &programs); err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+func (r *MongoAffiliateRepository) UpdateProgram(ctx context.Context, program *domain.AffiliateProgram) error {
+	_, err := r.programs.ReplaceOne(ctx, bson.M{"_id": program.ID}, program)
+	return err
+}
+
 func (r *MongoAffiliateRepository) GetGlobalProgram(ctx context.Context, creatorID primitive.ObjectID) (*domain.AffiliateProgram, error) {
+	// product_id nil (or missing) means global
+	return r.findActiveProgram(ctx, bson.M{"creator_id": creatorID, "product_id": nil})
+}
+
+func (r *MongoAffiliateRepository) GetProductProgram(ctx context.Context, creatorID primitive.ObjectID, productID primitive.ObjectID) (*domain.AffiliateProgram, error) {
+	return r.findActiveProgram(ctx, bson.M{"creator_id": creatorID, "product_id": productID})
+}
+
+func (r *MongoAffiliateRepository) findActiveProgram(ctx context.Context, filter bson.M) (*domain.AffiliateProgram, error) {
+	filter["is_active"] = true
 	var p domain.AffiliateProgram
-	// Assuming product_id nil means global
-	err := r.programs.FindOne(ctx, bson.M{"creator_id": creatorID, "product_id": nil}).Decode(&p)
-	return &p, err
+	// Newest first, in case a creator has configured more than one
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := r.programs.FindOne(ctx, filter, opts).Decode(&p)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// --- Enrollments ---
+func (r *MongoAffiliateRepository) CreateEnrollment(ctx context.Context, enrollment *domain.AffiliateEnrollment) error {
+	enrollment.ID = primitive.NewObjectID()
+	_, err := r.enrollments.InsertOne(ctx, enrollment)
+	return err
+}
+
+func (r *MongoAffiliateRepository) GetEnrollment(ctx context.Context, id primitive.ObjectID) (*domain.AffiliateEnrollment, error) {
+	return r.findEnrollment(ctx, bson.M{"_id": id})
+}
+
+func (r *MongoAffiliateRepository) GetUserEnrollment(ctx context.Context, programID primitive.ObjectID, userID primitive.ObjectID) (*domain.AffiliateEnrollment, error) {
+	return r.findEnrollment(ctx, bson.M{"program_id": programID, "user_id": userID})
+}
+
+func (r *MongoAffiliateRepository) GetEnrollmentsByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AffiliateEnrollment, error) {
+	return r.findEnrollments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *MongoAffiliateRepository) GetEnrollmentsByProgram(ctx context.Context, programID primitive.ObjectID, status domain.EnrollmentStatus) ([]*domain.AffiliateEnrollment, error) {
+	filter := bson.M{"program_id": programID}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.findEnrollments(ctx, filter)
+}
+
+func (r *MongoAffiliateRepository) UpdateEnrollment(ctx context.Context, enrollment *domain.AffiliateEnrollment) error {
+	_, err := r.enrollments.ReplaceOne(ctx, bson.M{"_id": enrollment.ID}, enrollment)
+	return err
+}
+
+func (r *MongoAffiliateRepository) findEnrollment(ctx context.Context, filter bson.M) (*domain.AffiliateEnrollment, error) {
+	var e domain.AffiliateEnrollment
+	err := r.enrollments.FindOne(ctx, filter).Decode(&e)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *MongoAffiliateRepository) findEnrollments(ctx context.Context, filter bson.M) ([]*domain.AffiliateEnrollment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.enrollments.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var enrollments []*domain.AffiliateEnrollment
+	if err = cursor.All(ctx, &enrollments); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
 }
 
 // --- Links ---
@@ -75,6 +176,32 @@ func (r *MongoAffiliateRepository) RecordClick(ctx context.Context, linkID primi
 	return err
 }
 
+func (r *MongoAffiliateRepository) DisableLinksByUser(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	_, err := r.links.UpdateMany(ctx,
+		bson.M{"user_id": userID, "disabled_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"disabled_at": at}},
+	)
+	return err
+}
+
+func (r *MongoAffiliateRepository) RecordConversion(ctx context.Context, linkID primitive.ObjectID) error {
+	_, err := r.links.UpdateOne(ctx, bson.M{"_id": linkID}, bson.M{"$inc": bson.M{"conversions": 1}})
+	return err
+}
+
+// --- Click & Conversion Events ---
+func (r *MongoAffiliateRepository) CreateClick(ctx context.Context, click *domain.AffiliateClick) error {
+	click.ID = primitive.NewObjectID()
+	_, err := r.clicks.InsertOne(ctx, click)
+	return err
+}
+
+func (r *MongoAffiliateRepository) CreateConversion(ctx context.Context, conv *domain.AffiliateConversion) error {
+	conv.ID = primitive.NewObjectID()
+	_, err := r.conversions.InsertOne(ctx, conv)
+	return err
+}
+
 // --- Commissions ---
 func (r *MongoAffiliateRepository) CreateCommission(ctx context.Context, comm *domain.Commission) error {
 	comm.ID = primitive.NewObjectID()
@@ -82,8 +209,68 @@ func (r *MongoAffiliateRepository) CreateCommission(ctx context.Context, comm *d
 	return err
 }
 
+func (r *MongoAffiliateRepository) GetCommission(ctx context.Context, id primitive.ObjectID) (*domain.Commission, error) {
+	var comm domain.Commission
+	err := r.commissions.FindOne(ctx, bson.M{"_id": id}).Decode(&comm)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &comm, nil
+}
+
 func (r *MongoAffiliateRepository) GetCommissionsByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Commission, error) {
-	cursor, err := r.commissions.Find(ctx, bson.M{"affiliate_user_id": userID})
+	return r.findCommissions(ctx, bson.M{"affiliate_user_id": userID})
+}
+
+func (r *MongoAffiliateRepository) GetCommissionsByOrder(ctx context.Context, orderID primitive.ObjectID) ([]*domain.Commission, error) {
+	return r.findCommissions(ctx, bson.M{"order_id": orderID})
+}
+
+func (r *MongoAffiliateRepository) GetChildCommissions(ctx context.Context, parentID primitive.ObjectID) ([]*domain.Commission, error) {
+	return r.findCommissions(ctx, bson.M{"parent_commission_id": parentID})
+}
+
+func (r *MongoAffiliateRepository) GetCommissionsByCreator(ctx context.Context, creatorID primitive.ObjectID, status domain.CommissionStatus) ([]*domain.Commission, error) {
+	filter := bson.M{"creator_id": creatorID}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.findCommissions(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+func (r *MongoAffiliateRepository) GetMaturedCommissions(ctx context.Context, now time.Time, limit int64) ([]*domain.Commission, error) {
+	filter := bson.M{
+		"status":     domain.CommissionStatusPending,
+		"matures_at": bson.M{"$lte": now},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "matures_at", Value: 1}}).SetLimit(limit)
+	return r.findCommissions(ctx, filter, opts)
+}
+
+func (r *MongoAffiliateRepository) TransitionCommission(ctx context.Context, comm *domain.Commission, from domain.CommissionStatus) (bool, error) {
+	// Filtering on the current status makes concurrent transitions (scheduler vs. creator) safe
+	res, err := r.commissions.UpdateOne(ctx,
+		bson.M{"_id": comm.ID, "status": from},
+		bson.M{"$set": bson.M{
+			"status":        comm.Status,
+			"approved_at":   comm.ApprovedAt,
+			"rejected_at":   comm.RejectedAt,
+			"cancelled_at":  comm.CancelledAt,
+			"status_reason": comm.StatusReason,
+			"updated_at":    comm.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoAffiliateRepository) findCommissions(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*domain.Commission, error) {
+	cursor, err := r.commissions.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Commissions that no longer pay out don't count towards earnings
+var countedCommissionStatuses = bson.M{"$nin": bson.A{domain.CommissionStatusRejected, domain.CommissionStatusCancelled}}
+
+func createdBetween(from, to time.Time) bson.M {
+	return bson.M{"$gte": from, "$lt": to}
+}
+
+// dayOf buckets created_at into a UTC calendar day
+var dayOf = bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}}
+
+// currencyOf groups amounts by currency; documents from before currencies were stored are in DefaultCurrency
+var currencyOf = bson.M{"$ifNull": bson.A{"$currency", domain.DefaultCurrency}}
+
+// addTo adds amount to m[currency], creating the map if needed
+func addTo(m map[string]float64, currency string, amount float64) map[string]float64 {
+	if m == nil {
+		m = map[string]float64{}
+	}
+	m[currency] += amount
+	return m
+}
+
+func (r *MongoAffiliateRepository) GetAffiliateActivity(ctx context.Context, affiliateID primitive.ObjectID, from, to time.Time) ([]*domain.AffiliateActivityRow, error) {
+	match := bson.M{"affiliate_user_id": affiliateID, "created_at": createdBetween(from, to)}
+	key := bson.M{"link_id": "$link_id", "day": dayOf}
+
+	type bucket struct {
+		ID struct {
+			LinkID   primitive.ObjectID `bson:"link_id"`
+			Day      string             `bson:"day"`
+			Currency string             `bson:"currency"`
+		} `bson:"_id"`
+		Count    int     `bson:"count"`
+		Earnings float64 `bson:"earnings"`
+		Tier2    float64 `bson:"tier2"`
+	}
+
+	merged := map[string]*domain.AffiliateActivityRow{}
+	row := func(b *bucket) *domain.AffiliateActivityRow {
+		k := b.ID.LinkID.Hex() + "|" + b.ID.Day
+		if merged[k] == nil {
+			merged[k] = &domain.AffiliateActivityRow{LinkID: b.ID.LinkID, Day: b.ID.Day}
+		}
+		return merged[k]
+	}
+
+	var clicks []*bucket
+	if err := aggregate(ctx, r.clicks, &clicks,
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": key, "count": bson.M{"$sum": 1}}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range clicks {
+		row(b).Clicks = b.Count
+	}
+
+	var conversions []*bucket
+	if err := aggregate(ctx, r.conversions, &conversions,
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": key, "count": bson.M{"$sum": 1}}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range conversions {
+		row(b).Conversions = b.Count
+	}
+
+	commMatch := bson.M{"affiliate_user_id": affiliateID, "created_at": createdBetween(from, to), "status": countedCommissionStatuses}
+	isTier2 := bson.M{"$eq": bson.A{"$tier", 2}}
+	var earnings []*bucket
+	if err := aggregate(ctx, r.commissions, &earnings,
+		bson.M{"$match": commMatch},
+		bson.M{"$group": bson.M{
+			"_id":      bson.M{"link_id": "$link_id", "day": dayOf, "currency": currencyOf},
+			"earnings": bson.M{"$sum": bson.M{"$cond": bson.A{isTier2, 0, "$earned_amount"}}},
+			"tier2":    bson.M{"$sum": bson.M{"$cond": bson.A{isTier2, "$earned_amount", 0}}},
+		}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range earnings {
+		rw := row(b)
+		rw.Earnings = addTo(rw.Earnings, b.ID.Currency, b.Earnings)
+		rw.Tier2Earnings = addTo(rw.Tier2Earnings, b.ID.Currency, b.Tier2)
+	}
+
+	result := make([]*domain.AffiliateActivityRow, 0, len(merged))
+	for _, rw := range merged {
+		result = append(result, rw)
+	}
+	return result, nil
+}
+
+func (r *MongoAffiliateRepository) GetProgramLeaderboard(ctx context.Context, programID primitive.ObjectID, from, to time.Time) ([]*domain.AffiliateLeaderboardEntry, error) {
+	// Scope everything by the program's links so sales credited under a
+	// product-specific program still count for the link's own program
+	linkIDs, err := r.links.Distinct(ctx, "_id", bson.M{"program_id": programID})
+	if err != nil {
+		return nil, err
+	}
+	if len(linkIDs) == 0 {
+		return []*domain.AffiliateLeaderboardEntry{}, nil
+	}
+	match := bson.M{"link_id": bson.M{"$in": linkIDs}, "created_at": createdBetween(from, to)}
+
+	type bucket struct {
+		ID struct {
+			AffiliateID primitive.ObjectID `bson:"affiliate"`
+			Currency    string             `bson:"currency"`
+		} `bson:"_id"`
+		Count int     `bson:"count"`
+		Sum   float64 `bson:"sum"`
+	}
+	byAffiliate := bson.M{"affiliate": "$affiliate_user_id"}
+	byAffiliateCurrency := bson.M{"affiliate": "$affiliate_user_id", "currency": currencyOf}
+
+	entries := map[primitive.ObjectID]*domain.AffiliateLeaderboardEntry{}
+	entry := func(id primitive.ObjectID) *domain.AffiliateLeaderboardEntry {
+		if entries[id] == nil {
+			entries[id] = &domain.AffiliateLeaderboardEntry{AffiliateID: id}
+		}
+		return entries[id]
+	}
+
+	var clicks []*bucket
+	if err := aggregate(ctx, r.clicks, &clicks,
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": byAffiliate, "count": bson.M{"$sum": 1}}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range clicks {
+		entry(b.ID.AffiliateID).Clicks = b.Count
+	}
+
+	var conversions []*bucket
+	if err := aggregate(ctx, r.conversions, &conversions,
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": byAffiliateCurrency, "count": bson.M{"$sum": 1}, "sum": bson.M{"$sum": "$sale_amount"}}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range conversions {
+		e := entry(b.ID.AffiliateID)
+		e.Conversions += b.Count
+		e.SalesByCurrency = addTo(e.SalesByCurrency, b.ID.Currency, b.Sum)
+	}
+
+	// Only the referring affiliate's (tier-1) earnings rank on the leaderboard
+	commMatch := bson.M{
+		"link_id":    bson.M{"$in": linkIDs},
+		"created_at": createdBetween(from, to),
+		"status":     countedCommissionStatuses,
+		"tier":       bson.M{"$ne": 2},
+	}
+	var earnings []*bucket
+	if err := aggregate(ctx, r.commissions, &earnings,
+		bson.M{"$match": commMatch},
+		bson.M{"$group": bson.M{"_id": byAffiliateCurrency, "sum": bson.M{"$sum": "$earned_amount"}}},
+	); err != nil {
+		return nil, err
+	}
+	for _, b := range earnings {
+		e := entry(b.ID.AffiliateID)
+		e.EarningsByCurrency = addTo(e.EarningsByCurrency, b.ID.Currency, b.Sum)
+	}
+
+	result := make([]*domain.AffiliateLeaderboardEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func aggregate(ctx context.Context, coll *mongo.Collection, dest interface{}, stages ...bson.M) error {
+	pipeline := make(bson.A, len(stages))
+	for i, stage := range stages {
+		pipeline[i] = stage
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, dest)
+}
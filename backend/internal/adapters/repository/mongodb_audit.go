@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAuditRepository struct {
+	events *mongo.Collection
+}
+
+func NewMongoAuditRepository(db *mongo.Database) ports.AuditRepository {
+	return &MongoAuditRepository{
+		events: db.Collection("audit_events"),
+	}
+}
+
+func (r *MongoAuditRepository) Record(ctx context.Context, event *domain.AuditEvent) error {
+	event.ID = primitive.NewObjectID()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	_, err := r.events.InsertOne(ctx, event)
+	return err
+}
+
+func (r *MongoAuditRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AuditEvent, error) {
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := r.events.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var events []*domain.AuditEvent
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"log"
+	"time"
 
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
@@ -9,28 +11,58 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoInvoiceRepository struct {
 	invoices *mongo.Collection
+	counters *mongo.Collection
+	credits  *mongo.Collection
 }
 
 func NewMongoInvoiceRepository(db *mongo.Database) ports.InvoiceRepository {
+	invoices := db.Collection("invoices")
+
+	// One live invoice per payment, even when automatic and manual generation race.
+	// Void invoices are left out so a reissued payment can get its replacement.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := invoices.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "transaction_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"status": bson.M{"$in": bson.A{domain.InvoiceStatusPaid, domain.InvoiceStatusPending}},
+		}),
+	})
+	if err != nil {
+		log.Printf("Failed to create invoices transaction index: %v", err)
+	}
+
 	return &MongoInvoiceRepository{
-		invoices: db.Collection("invoices"),
+		invoices: invoices,
+		counters: db.Collection("invoice_counters"),
+		credits:  db.Collection("credit_notes"),
 	}
 }
 
 func (r *MongoInvoiceRepository) CreateInvoice(ctx context.Context, invoice *domain.Invoice) error {
 	invoice.ID = primitive.NewObjectID()
 	_, err := r.invoices.InsertOne(ctx, invoice)
+	if mongo.IsDuplicateKeyError(err) {
+		return ports.ErrInvoiceExists
+	}
 	return err
 }
 
 func (r *MongoInvoiceRepository) GetInvoice(ctx context.Context, invoiceID primitive.ObjectID) (*domain.Invoice, error) {
 	var inv domain.Invoice
 	err := r.invoices.FindOne(ctx, bson.M{"_id": invoiceID}).Decode(&inv)
-	return &inv, err
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inv, nil
 }
 
 func (r *MongoInvoiceRepository) GetInvoicesByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Invoice, error) {
@@ -44,3 +76,121 @@ func (r *MongoInvoiceRepository) GetInvoicesByUser(ctx context.Context, userID p
 	}
 	return invs, nil
 }
+
+func (r *MongoInvoiceRepository) GetInvoiceByTransaction(ctx context.Context, txID primitive.ObjectID) (*domain.Invoice, error) {
+	var inv domain.Invoice
+	filter := bson.M{"transaction_id": txID, "status": bson.M{"$ne": domain.InvoiceStatusVoid}}
+	err := r.invoices.FindOne(ctx, filter).Decode(&inv)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *MongoInvoiceRepository) ListInvoices(ctx context.Context, filter domain.InvoiceFilter) ([]*domain.Invoice, error) {
+	query := bson.M{}
+	if filter.UserID != nil {
+		query["user_id"] = *filter.UserID
+	}
+	if filter.SellerID != nil {
+		query["seller_id"] = *filter.SellerID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	created := bson.M{}
+	if filter.From != nil {
+		created["$gte"] = *filter.From
+	}
+	if filter.To != nil {
+		created["$lt"] = *filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.invoices.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var invs []*domain.Invoice
+	if err = cursor.All(ctx, &invs); err != nil {
+		return nil, err
+	}
+	return invs, nil
+}
+
+func (r *MongoInvoiceRepository) TransitionInvoice(ctx context.Context, invoice *domain.Invoice, from domain.InvoiceStatus) (bool, error) {
+	res, err := r.invoices.ReplaceOne(ctx, bson.M{"_id": invoice.ID, "status": from}, invoice)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoInvoiceRepository) NextInvoiceSequence(ctx context.Context, sellerKey string) (int64, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOneAndUpdate(ctx, bson.M{"_id": sellerKey}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+func (r *MongoInvoiceRepository) ReleaseInvoiceSequence(ctx context.Context, sellerKey string, seq int64) (bool, error) {
+	res, err := r.counters.UpdateOne(ctx, bson.M{"_id": sellerKey, "seq": seq}, bson.M{"$inc": bson.M{"seq": -1}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoInvoiceRepository) CreateCreditNote(ctx context.Context, note *domain.CreditNote) error {
+	note.ID = primitive.NewObjectID()
+	_, err := r.credits.InsertOne(ctx, note)
+	return err
+}
+
+func (r *MongoInvoiceRepository) GetCreditNote(ctx context.Context, id primitive.ObjectID) (*domain.CreditNote, error) {
+	return r.findCreditNote(ctx, bson.M{"_id": id})
+}
+
+func (r *MongoInvoiceRepository) GetCreditNoteByRefund(ctx context.Context, refundRef string) (*domain.CreditNote, error) {
+	return r.findCreditNote(ctx, bson.M{"refund_ref": refundRef})
+}
+
+func (r *MongoInvoiceRepository) GetCreditNotesByInvoice(ctx context.Context, invoiceID primitive.ObjectID) ([]*domain.CreditNote, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.credits.Find(ctx, bson.M{"invoice_id": invoiceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*domain.CreditNote
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (r *MongoInvoiceRepository) findCreditNote(ctx context.Context, filter bson.M) (*domain.CreditNote, error) {
+	var note domain.CreditNote
+	err := r.credits.FindOne(ctx, filter).Decode(&note)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &note, nil
+}
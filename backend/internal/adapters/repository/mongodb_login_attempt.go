@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLoginAttemptStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptStore(db *mongo.Database) ports.LoginAttemptStore {
+	collection := db.Collection("login_attempts")
+
+	// Let MongoDB delete counters once their failure window and lockout have passed
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create login_attempts TTL index: %v", err)
+	}
+
+	return &MongoLoginAttemptStore{collection: collection}
+}
+
+// NewLoginAttemptStore picks the store implementation from LOGIN_ATTEMPT_STORE ("memory" or "mongo")
+func NewLoginAttemptStore(cfg *config.Config, db *mongo.Database) ports.LoginAttemptStore {
+	if cfg.LoginAttemptStore == "mongo" {
+		return NewMongoLoginAttemptStore(db)
+	}
+	return NewMemoryLoginAttemptStore()
+}
+
+func (s *MongoLoginAttemptStore) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	var a domain.LoginAttempt
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&a)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *MongoLoginAttemptStore) IncrementFailure(ctx context.Context, key string, at time.Time, windowStart time.Time) (*domain.LoginAttempt, error) {
+	// Pipeline update so the window reset and increment happen in one atomic write.
+	// The document expires one window after this failure, or when its lockout ends if later.
+	window := at.Sub(windowStart)
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$last_failure_at", time.Time{}}}, windowStart}},
+				1,
+				bson.M{"$add": bson.A{"$failures", 1}},
+			}},
+			"last_failure_at": at,
+			"expires_at":      bson.M{"$max": bson.A{at.Add(window), "$locked_until"}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var a domain.LoginAttempt
+	if err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *MongoLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"locked_until": until,
+			"expires_at":   bson.M{"$max": bson.A{"$expires_at", until}},
+		}}},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": key}, update, opts)
+	return err
+}
+
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
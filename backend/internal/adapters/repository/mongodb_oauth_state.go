@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoOAuthStateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOAuthStateRepository(db *mongo.Database) ports.OAuthStateRepository {
+	return &MongoOAuthStateRepository{
+		collection: db.Collection("oauth_states"),
+	}
+}
+
+func (r *MongoOAuthStateRepository) Save(ctx context.Context, state *domain.OAuthState) error {
+	_, err := r.collection.InsertOne(ctx, state)
+	return err
+}
+
+func (r *MongoOAuthStateRepository) Consume(ctx context.Context, state string) (*domain.OAuthState, error) {
+	// FindOneAndDelete makes the state single-use even under concurrent callbacks
+	var s domain.OAuthState
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"_id":        state,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&s)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
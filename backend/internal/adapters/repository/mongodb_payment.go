@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoPaymentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPaymentRepository(db *mongo.Database) ports.PaymentRepository {
+	return &MongoPaymentRepository{
+		collection: db.Collection("payments"),
+	}
+}
+
+func (r *MongoPaymentRepository) CreatePayment(ctx context.Context, payment *domain.Payment) error {
+	if payment.ID.IsZero() {
+		payment.ID = primitive.NewObjectID()
+	}
+	payment.CreatedAt = time.Now()
+	payment.UpdatedAt = payment.CreatedAt
+	_, err := r.collection.InsertOne(ctx, payment)
+	return err
+}
+
+func (r *MongoPaymentRepository) GetPayment(ctx context.Context, id primitive.ObjectID) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&payment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *MongoPaymentRepository) GetPaymentByGatewayRef(ctx context.Context, gateway domain.PaymentGateway, ref string) (*domain.Payment, error) {
+	filter := bson.M{
+		"gateway": gateway,
+		"$or": []bson.M{
+			{"gateway_order_ref": ref},
+			{"transaction_id": ref},
+		},
+	}
+	var payment domain.Payment
+	err := r.collection.FindOne(ctx, filter).Decode(&payment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *MongoPaymentRepository) ListPaymentsByGateway(ctx context.Context, gateway domain.PaymentGateway, status domain.PaymentStatus) ([]*domain.Payment, error) {
+	filter := bson.M{"gateway": gateway}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+func (r *MongoPaymentRepository) ListExpiredPayments(ctx context.Context, now time.Time) ([]*domain.Payment, error) {
+	filter := bson.M{
+		"status":     domain.PaymentStatusPending,
+		"expires_at": bson.M{"$lte": now},
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *MongoPaymentRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*domain.Payment, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payments []*domain.Payment
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *MongoPaymentRepository) UpdatePayment(ctx context.Context, payment *domain.Payment) error {
+	payment.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": payment.ID}, payment)
+	return err
+}
+
+func (r *MongoPaymentRepository) SetInvoice(ctx context.Context, id primitive.ObjectID, invoiceID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"invoice_id": invoiceID,
+		"updated_at": time.Now(),
+	}})
+	return err
+}
+
+func (r *MongoPaymentRepository) SetCommission(ctx context.Context, id primitive.ObjectID, affiliateID primitive.ObjectID, commission float64) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"affiliate_id": affiliateID,
+		"commission":   commission,
+		"updated_at":   time.Now(),
+	}})
+	return err
+}
+
+func (r *MongoPaymentRepository) TransitionPayment(ctx context.Context, payment *domain.Payment, from domain.PaymentStatus) (bool, error) {
+	payment.UpdatedAt = time.Now()
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": payment.ID, "status": from}, payment)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
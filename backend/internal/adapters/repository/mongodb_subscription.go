@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoSubscriptionRepository struct {
+	collection *mongo.Collection
+	cycles     *mongo.Collection
+}
+
+func NewMongoSubscriptionRepository(db *mongo.Database) ports.SubscriptionRepository {
+	return &MongoSubscriptionRepository{
+		collection: db.Collection("subscriptions"),
+		cycles:     db.Collection("subscription_cycles"),
+	}
+}
+
+func (r *MongoSubscriptionRepository) CreateSubscription(ctx context.Context, sub *domain.Subscription) error {
+	sub.ID = primitive.NewObjectID()
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = time.Now()
+	_, err := r.collection.InsertOne(ctx, sub)
+	return err
+}
+
+func (r *MongoSubscriptionRepository) GetByStripeID(ctx context.Context, stripeSubID string) (*domain.Subscription, error) {
+	var sub domain.Subscription
+	err := r.collection.FindOne(ctx, bson.M{"stripe_subscription_id": stripeSubID}).Decode(&sub)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *MongoSubscriptionRepository) GetByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Subscription, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var subs []*domain.Subscription
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (r *MongoSubscriptionRepository) UpdateSubscription(ctx context.Context, sub *domain.Subscription) error {
+	sub.UpdatedAt = time.Now()
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": sub.ID}, sub)
+	return err
+}
+
+func (r *MongoSubscriptionRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Subscription, error) {
+	var sub domain.Subscription
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *MongoSubscriptionRepository) CreateCycle(ctx context.Context, cycle *domain.SubscriptionCycle) error {
+	cycle.ID = primitive.NewObjectID()
+	cycle.CreatedAt = time.Now()
+	_, err := r.cycles.InsertOne(ctx, cycle)
+	return err
+}
+
+func (r *MongoSubscriptionRepository) GetCycleByInvoice(ctx context.Context, subscriptionID primitive.ObjectID, invoiceRef string) (*domain.SubscriptionCycle, error) {
+	var cycle domain.SubscriptionCycle
+	err := r.cycles.FindOne(ctx, bson.M{"subscription_id": subscriptionID, "invoice_ref": invoiceRef}).Decode(&cycle)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cycle, nil
+}
+
+func (r *MongoSubscriptionRepository) GetCycles(ctx context.Context, subscriptionID primitive.ObjectID) ([]*domain.SubscriptionCycle, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "cycle", Value: 1}})
+	cursor, err := r.cycles.Find(ctx, bson.M{"subscription_id": subscriptionID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var cycles []*domain.SubscriptionCycle
+	if err = cursor.All(ctx, &cycles); err != nil {
+		return nil, err
+	}
+	return cycles, nil
+}
@@ -11,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoUserRepository struct {
@@ -41,7 +42,9 @@ func (r *MongoUserRepository) Create(ctx context.Context, user *domain.User) err
 
 func (r *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	// Case-insensitive so accounts stored before emails were lowercased still match
+	opts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	err := r.collection.FindOne(ctx, bson.M{"email": email}, opts).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, nil // Return nil if not found, let service handle logic
@@ -65,24 +68,53 @@ func (r *MongoUserRepository) GetByID(ctx context.Context, id string) (*domain.U
 	return &user, nil
 }
 
+func (r *MongoUserRepository) GetByProviderIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	var user domain.User
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *MongoUserRepository) Update(ctx context.Context, user *domain.User) error {
 	user.UpdatedAt = time.Now()
 	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
 	return err
 }
 
-func (r *MongoUserRepository) UpdateStripeConnect(ctx context.Context, userID string, connectID string, status string) error {
+func (r *MongoUserRepository) GetByStripeConnectID(ctx context.Context, connectID string) (*domain.User, error) {
+	var user domain.User
+	err := r.collection.FindOne(ctx, bson.M{"stripe_connect_id": connectID}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) UpdateStripeConnect(ctx context.Context, userID string, connectID string, status string, account *domain.ConnectAccount) error {
 	oid, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return err
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"stripe_connect_id":     connectID,
-			"stripe_connect_status": status,
-			"updated_at":            time.Now(),
-		},
+	set := bson.M{
+		"stripe_connect_id":     connectID,
+		"stripe_connect_status": status,
+		"updated_at":            time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if account != nil {
+		set["stripe_connect_account"] = account
+	} else {
+		update["$unset"] = bson.M{"stripe_connect_account": ""}
 	}
 
 	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": oid}, update)
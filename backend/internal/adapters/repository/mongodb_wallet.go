@@ -34,9 +34,9 @@ func (r *MongoWalletRepository) CreateWallet(ctx context.Context, wallet *domain
 	return err
 }
 
-func (r *MongoWalletRepository) GetWalletByUserID(ctx context.Context, userID primitive.ObjectID) (*domain.Wallet, error) {
+func (r *MongoWalletRepository) GetWallet(ctx context.Context, userID primitive.ObjectID, currency string) (*domain.Wallet, error) {
 	var wallet domain.Wallet
-	err := r.wallets.FindOne(ctx, bson.M{"user_id": userID}).Decode(&wallet)
+	err := r.wallets.FindOne(ctx, bson.M{"user_id": userID, "currency": currency}).Decode(&wallet)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil // Caller handles creation if needed
@@ -46,6 +46,19 @@ func (r *MongoWalletRepository) GetWalletByUserID(ctx context.Context, userID pr
 	return &wallet, nil
 }
 
+func (r *MongoWalletRepository) GetWalletsByUserID(ctx context.Context, userID primitive.ObjectID) ([]*domain.Wallet, error) {
+	opts := options.Find().SetSort(bson.M{"currency": 1})
+	cursor, err := r.wallets.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var wallets []*domain.Wallet
+	if err = cursor.All(ctx, &wallets); err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
 func (r *MongoWalletRepository) GetWalletByID(ctx context.Context, walletID primitive.ObjectID) (*domain.Wallet, error) {
 	var wallet domain.Wallet
 	err := r.wallets.FindOne(ctx, bson.M{"_id": walletID}).Decode(&wallet)
@@ -70,9 +83,9 @@ func (r *MongoWalletRepository) CreateTransaction(ctx context.Context, tx *domai
 	return err
 }
 
-func (r *MongoWalletRepository) GetTransactions(ctx context.Context, walletID primitive.ObjectID) ([]*domain.WalletTransaction, error) {
+func (r *MongoWalletRepository) GetTransactions(ctx context.Context, walletIDs []primitive.ObjectID) ([]*domain.WalletTransaction, error) {
 	opts := options.Find().SetSort(bson.M{"created_at": -1})
-	cursor, err := r.transactions.Find(ctx, bson.M{"wallet_id": walletID}, opts)
+	cursor, err := r.transactions.Find(ctx, bson.M{"wallet_id": bson.M{"$in": walletIDs}}, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,145 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// rule: The standard rate of a jurisdiction
+type rule struct {
+	name          string
+	rate          float64
+	reverseCharge bool           // Foreign B2B buyers with a valid tax ID self-account for the tax
+	taxID         *regexp.Regexp // Format of the tax ID, after normalization
+}
+
+func vat(rate float64, taxID string) rule {
+	return rule{name: "VAT", rate: rate, reverseCharge: true, taxID: regexp.MustCompile(`^(?:` + taxID + `)$`)}
+}
+
+func gst(rate float64, reverseCharge bool, taxID string) rule {
+	return rule{name: "GST", rate: rate, reverseCharge: reverseCharge, taxID: regexp.MustCompile(`^(?:` + taxID + `)$`)}
+}
+
+// rules: Standard VAT/GST rates by country. Countries without a rule are not taxed.
+var rules = map[string]rule{
+	// European Union
+	"AT": vat(0.20, `ATU\d{8}`),
+	"BE": vat(0.21, `BE[01]\d{9}`),
+	"BG": vat(0.20, `BG\d{9,10}`),
+	"HR": vat(0.25, `HR\d{11}`),
+	"CY": vat(0.19, `CY\d{8}[A-Z]`),
+	"CZ": vat(0.21, `CZ\d{8,10}`),
+	"DK": vat(0.25, `DK\d{8}`),
+	"EE": vat(0.24, `EE\d{9}`),
+	"FI": vat(0.255, `FI\d{8}`),
+	"FR": vat(0.20, `FR[0-9A-Z]{2}\d{9}`),
+	"DE": vat(0.19, `DE\d{9}`),
+	"GR": vat(0.24, `EL\d{9}`),
+	"HU": vat(0.27, `HU\d{8}`),
+	"IE": vat(0.23, `IE\d{7}[A-W][A-I]?|IE\d[A-Z+*]\d{5}[A-W]`),
+	"IT": vat(0.22, `IT\d{11}`),
+	"LV": vat(0.21, `LV\d{11}`),
+	"LT": vat(0.21, `LT\d{9}|LT\d{12}`),
+	"LU": vat(0.17, `LU\d{8}`),
+	"MT": vat(0.18, `MT\d{8}`),
+	"NL": vat(0.21, `NL\d{9}B\d{2}`),
+	"PL": vat(0.23, `PL\d{10}`),
+	"PT": vat(0.23, `PT\d{9}`),
+	"RO": vat(0.21, `RO\d{2,10}`),
+	"SK": vat(0.23, `SK\d{10}`),
+	"SI": vat(0.22, `SI\d{8}`),
+	"ES": vat(0.21, `ES[0-9A-Z]\d{7}[0-9A-Z]`),
+	"SE": vat(0.25, `SE\d{12}`),
+
+	// Rest of Europe
+	"GB": vat(0.20, `GB\d{9}|GB\d{12}|GBGD\d{3}|GBHA\d{3}`),
+	"NO": vat(0.25, `NO\d{9}MVA`),
+	"CH": vat(0.081, `CHE\d{9}(?:MWST|TVA|IVA)?`),
+
+	// Asia-Pacific and Americas
+	"AU": gst(0.10, true, `\d{11}`),                                    // ABN
+	"NZ": gst(0.15, true, `\d{8,9}`),                                   // IRD number
+	"SG": gst(0.09, true, `M\d{8}[A-Z]|\d{8,9}[A-Z]`),                  // UEN / GST reg. no.
+	"IN": gst(0.18, false, `\d{2}[A-Z]{5}\d{4}[A-Z][1-9A-Z]Z[0-9A-Z]`), // GSTIN
+	"CA": gst(0.05, false, `\d{9}RT\d{4}`),                             // Business number
+	"JP": {name: "JCT", rate: 0.10, reverseCharge: true, taxID: regexp.MustCompile(`^T\d{13}$`)},
+}
+
+// RuleTableCalculator applies the standard rate of the buyer's country. B2B buyers in
+// another country than the seller are reverse charged if their tax ID is well-formed.
+type RuleTableCalculator struct {
+	origin string // Country the platform is established in
+}
+
+func NewRuleTableCalculator(cfg *config.Config) ports.TaxCalculator {
+	return &RuleTableCalculator{origin: strings.ToUpper(cfg.TaxOriginCountry)}
+}
+
+func (c *RuleTableCalculator) Calculate(ctx context.Context, req domain.TaxRequest) (*domain.TaxResult, error) {
+	amount := round(req.Amount, req.Currency)
+	result := &domain.TaxResult{Inclusive: req.Inclusive, NetAmount: amount, GrossAmount: amount}
+	if req.Buyer == nil || req.Buyer.Country == "" {
+		return result, nil
+	}
+
+	country := strings.ToUpper(req.Buyer.Country)
+	r, ok := rules[country]
+	if !ok {
+		result.Country = country
+		return result, nil
+	}
+	result.Country = country
+	result.Name = r.name
+	result.Rate = r.rate
+
+	if req.Inclusive {
+		result.NetAmount = round(amount/(1+r.rate), req.Currency)
+		result.TaxAmount = round(amount-result.NetAmount, req.Currency)
+	} else {
+		result.TaxAmount = round(amount*r.rate, req.Currency)
+		result.GrossAmount = round(amount+result.TaxAmount, req.Currency)
+	}
+
+	if req.Buyer.IsBusiness() && r.reverseCharge && country != c.origin {
+		taxID, err := c.NormalizeTaxID(country, req.Buyer.TaxID)
+		if err != nil {
+			return nil, err
+		}
+		// The buyer pays the net price and accounts for the tax themselves
+		result.ReverseCharge = true
+		result.BuyerTaxID = taxID
+		result.TaxAmount = 0
+		result.GrossAmount = result.NetAmount
+	}
+	return result, nil
+}
+
+func (c *RuleTableCalculator) NormalizeTaxID(country string, taxID string) (string, error) {
+	country = strings.ToUpper(country)
+	id := strings.ToUpper(taxID)
+	id = strings.NewReplacer(" ", "", ".", "", "-", "", "/", "").Replace(id)
+	if id == "" {
+		return "", errors.New("tax ID is required")
+	}
+	r, ok := rules[country]
+	if !ok {
+		// No format known; keep what the buyer entered
+		return id, nil
+	}
+	if !r.taxID.MatchString(id) {
+		return "", errors.New("invalid " + r.name + " number for " + country)
+	}
+	return id, nil
+}
+
+// round rounds to the minor unit of the currency
+func round(amount float64, currency string) float64 {
+	return domain.RoundAmount(amount, currency)
+}
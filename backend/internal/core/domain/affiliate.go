@@ -6,26 +6,170 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+type AttributionModel string
+
+const (
+	AttributionFirstClick AttributionModel = "first_click"
+	AttributionLastClick  AttributionModel = "last_click"
+)
+
+const DefaultAttributionWindowDays = 30
+
+// MaxAffiliateChainDepth bounds how far recruiter chains are walked when checking for cycles
+const MaxAffiliateChainDepth = 20
+
+// DefaultRefundWindowDays is how long commissions stay pending before they mature
+const DefaultRefundWindowDays = 30
+
+type CommissionStatus string
+
+const (
+	CommissionStatusPending   CommissionStatus = "pending"   // Waiting out the refund window
+	CommissionStatusApproved  CommissionStatus = "approved"  // Credited to the affiliate's wallet
+	CommissionStatusRejected  CommissionStatus = "rejected"  // Declined by the creator
+	CommissionStatusCancelled CommissionStatus = "cancelled" // Purchase refunded or cancelled
+)
+
+type ProgramStatus string
+
+const (
+	ProgramStatusActive ProgramStatus = "active"
+	ProgramStatusPaused ProgramStatus = "paused" // No new links or commissions, can be resumed
+	ProgramStatusClosed ProgramStatus = "closed" // Permanently ended
+)
+
+type EnrollmentMode string
+
+const (
+	EnrollmentOpen     EnrollmentMode = "open"     // Applicants are approved automatically
+	EnrollmentApproval EnrollmentMode = "approval" // The creator reviews each applicant
+)
+
 // AffiliateProgram: Global or Product-specific settings
 type AffiliateProgram struct {
 	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
 	CreatorID      primitive.ObjectID  `bson:"creator_id" json:"creator_id"`
 	ProductID      *primitive.ObjectID `bson:"product_id,omitempty" json:"product_id,omitempty"` // If nil, global for creator
-	CommissionRate float64             `bson:"commission_rate" json:"commission_rate"`           // Percentage (e.g. 10.0 for 10%)
-	IsActive       bool                `bson:"is_active" json:"is_active"`
-	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+	Name           string              `bson:"name" json:"name"`
+	Terms          string              `bson:"terms,omitempty" json:"terms,omitempty"` // Shown to applicants
+	CommissionRate float64             `bson:"commission_rate" json:"commission_rate"` // Percentage (e.g. 10.0 for 10%)
+	Status         ProgramStatus       `bson:"status" json:"status"`
+	IsActive       bool                `bson:"is_active" json:"is_active"` // Mirrors Status == active, used by lookups
+	EnrollmentMode EnrollmentMode      `bson:"enrollment_mode" json:"enrollment_mode"`
+
+	// Attribution
+	AttributionModel      AttributionModel `bson:"attribution_model,omitempty" json:"attribution_model,omitempty"`             // Default last_click
+	AttributionWindowDays int              `bson:"attribution_window_days,omitempty" json:"attribution_window_days,omitempty"` // Default 30
+
+	// Tier 2: the recruiter of the referring affiliate earns a share of the sale as well
+	Tier2Rate      float64 `bson:"tier2_rate,omitempty" json:"tier2_rate,omitempty"`             // Percentage of the sale, 0 = disabled
+	Tier2MaxAmount float64 `bson:"tier2_max_amount,omitempty" json:"tier2_max_amount,omitempty"` // Cap per commission, 0 = uncapped
+
+	// Commissions mature once the refund window has passed
+	RefundWindowDays int `bson:"refund_window_days,omitempty" json:"refund_window_days,omitempty"` // Default 30
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// SetStatus changes the lifecycle status and keeps IsActive in sync
+func (p *AffiliateProgram) SetStatus(status ProgramStatus) {
+	p.Status = status
+	p.IsActive = status == ProgramStatusActive
+}
+
+// RequiresApproval reports whether applicants must be approved by the creator
+func (p *AffiliateProgram) RequiresApproval() bool {
+	return p.EnrollmentMode == EnrollmentApproval
+}
+
+// Model returns the attribution model, defaulting to last click
+func (p *AffiliateProgram) Model() AttributionModel {
+	if p.AttributionModel == "" {
+		return AttributionLastClick
+	}
+	return p.AttributionModel
+}
+
+// Window returns how long after a click a purchase is still attributed
+func (p *AffiliateProgram) Window() time.Duration {
+	days := p.AttributionWindowDays
+	if days <= 0 {
+		days = DefaultAttributionWindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Tier2Amount returns the tier-2 commission for a sale, after applying the cap
+func (p *AffiliateProgram) Tier2Amount(saleAmount float64) float64 {
+	amount := saleAmount * p.Tier2Rate / 100.0
+	if p.Tier2MaxAmount > 0 && amount > p.Tier2MaxAmount {
+		amount = p.Tier2MaxAmount
+	}
+	return amount
+}
+
+// MaturationPeriod returns how long a commission stays pending before it is approved
+func (p *AffiliateProgram) MaturationPeriod() time.Duration {
+	days := p.RefundWindowDays
+	if days <= 0 {
+		days = DefaultRefundWindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Covers reports whether a purchase of the plan can earn commission under this program
+func (p *AffiliateProgram) Covers(plan *PricingPlan) bool {
+	if !p.IsActive || plan == nil || p.CreatorID != plan.CreatorID {
+		return false
+	}
+	return p.ProductID == nil || *p.ProductID == plan.ProductID
+}
+
+type EnrollmentStatus string
+
+const (
+	EnrollmentPending   EnrollmentStatus = "pending"
+	EnrollmentApproved  EnrollmentStatus = "approved"
+	EnrollmentRejected  EnrollmentStatus = "rejected"
+	EnrollmentWithdrawn EnrollmentStatus = "withdrawn" // The affiliate deleted their account
+)
+
+// AffiliateEnrollment: A user's membership of a program as an affiliate
+type AffiliateEnrollment struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProgramID      primitive.ObjectID `bson:"program_id" json:"program_id"`
+	CreatorID      primitive.ObjectID `bson:"creator_id" json:"creator_id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Status         EnrollmentStatus   `bson:"status" json:"status"`
+	Message        string             `bson:"message,omitempty" json:"message,omitempty"`                 // From the applicant
+	DecisionReason string             `bson:"decision_reason,omitempty" json:"decision_reason,omitempty"` // From the creator
+	DecidedAt      *time.Time         `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 // AffiliateLink: Usage specific link for a user
 type AffiliateLink struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"` // Who owns this link
-	ProgramID   primitive.ObjectID `bson:"program_id" json:"program_id"`
-	Code        string             `bson:"code" json:"code"` // Unique slug, e.g. "AHMED20"
-	Url         string             `bson:"url" json:"url"`
-	Clicks      int                `bson:"clicks" json:"clicks"`
-	Conversions int                `bson:"conversions" json:"conversions"` // Number of sales
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"` // Who owns this link
+	ProgramID primitive.ObjectID `bson:"program_id" json:"program_id"`
+	Code      string             `bson:"code" json:"code"` // Unique slug, e.g. "AHMED20"
+	Url       string             `bson:"url" json:"url"`
+	// Recruiting affiliate credited at tier 2 for sales through this link.
+	// Falls back to the owner's AffiliateParentID when unset.
+	ParentID    *primitive.ObjectID `bson:"parent_user_id,omitempty" json:"parent_user_id,omitempty"`
+	Clicks      int                 `bson:"clicks" json:"clicks"`
+	Conversions int                 `bson:"conversions" json:"conversions"` // Number of sales
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	// Set when the owner deletes their account. The code stays reserved but no longer
+	// records clicks or earns commissions.
+	DisabledAt *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+}
+
+// IsActive reports whether the link still attributes sales
+func (l *AffiliateLink) IsActive() bool {
+	return l.DisabledAt == nil
 }
 
 // Commission: Log of earnings
@@ -33,9 +177,166 @@ type Commission struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	AffiliateID  primitive.ObjectID `bson:"affiliate_user_id" json:"affiliate_user_id"` // User who gets money
 	LinkID       primitive.ObjectID `bson:"link_id" json:"link_id"`
+	ProgramID    primitive.ObjectID `bson:"program_id" json:"program_id"`
+	CreatorID    primitive.ObjectID `bson:"creator_id" json:"creator_id"`       // Who reviews it
 	OrderID      primitive.ObjectID `bson:"order_id" json:"order_id"`           // Source Transaction
 	TotalAmount  float64            `bson:"total_amount" json:"total_amount"`   // Sale Price
 	EarnedAmount float64            `bson:"earned_amount" json:"earned_amount"` // Calculated Commission
-	Status       string             `bson:"status" json:"status"`               // pending, paid, cancelled
+	Status       CommissionStatus   `bson:"status" json:"status"`
+
+	// Currency of the sale; the commission is credited to the affiliate's wallet in it
+	Currency string `bson:"currency,omitempty" json:"currency,omitempty"`
+
+	// Multi-tier: tier 1 is the referring affiliate, tier 2 their recruiter
+	Tier               int                 `bson:"tier" json:"tier"`
+	ParentCommissionID *primitive.ObjectID `bson:"parent_commission_id,omitempty" json:"parent_commission_id,omitempty"` // Tier-1 commission a tier-2 one derives from
+
+	MaturesAt    time.Time  `bson:"matures_at" json:"matures_at"` // Auto-approved after this
+	ApprovedAt   *time.Time `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+	RejectedAt   *time.Time `bson:"rejected_at,omitempty" json:"rejected_at,omitempty"`
+	CancelledAt  *time.Time `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	StatusReason string     `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// AffiliateClick: One visit through a referral link
+type AffiliateClick struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LinkID      primitive.ObjectID `bson:"link_id" json:"link_id"`
+	ProgramID   primitive.ObjectID `bson:"program_id" json:"program_id"`
+	AffiliateID primitive.ObjectID `bson:"affiliate_user_id" json:"affiliate_user_id"`
+	IP          string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent   string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Referrer    string             `bson:"referrer,omitempty" json:"referrer,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AffiliateTouch: A referral click that may earn attribution
+type AffiliateTouch struct {
+	Code      string    `bson:"code" json:"code"`
+	ClickedAt time.Time `bson:"clicked_at" json:"clicked_at"`
+}
+
+// AffiliateAttribution: First and most recent referral touches for a visitor or user
+type AffiliateAttribution struct {
+	First *AffiliateTouch `bson:"first,omitempty" json:"first,omitempty"`
+	Last  *AffiliateTouch `bson:"last,omitempty" json:"last,omitempty"`
+}
+
+// Record adds a touch, keeping the earliest as First and the latest as Last
+func (a *AffiliateAttribution) Record(t AffiliateTouch) {
+	if a.First == nil || t.ClickedAt.Before(a.First.ClickedAt) {
+		touch := t
+		a.First = &touch
+	}
+	if a.Last == nil || t.ClickedAt.After(a.Last.ClickedAt) {
+		touch := t
+		a.Last = &touch
+	}
+}
+
+// Merge folds another attribution (e.g. from a cookie) into this one
+func (a *AffiliateAttribution) Merge(o *AffiliateAttribution) {
+	if o == nil {
+		return
+	}
+	if o.First != nil {
+		a.Record(*o.First)
+	}
+	if o.Last != nil {
+		a.Record(*o.Last)
+	}
+}
+
+// IsEmpty reports whether no touch has been recorded
+func (a *AffiliateAttribution) IsEmpty() bool {
+	return a == nil || (a.First == nil && a.Last == nil)
+}
+
+// AffiliateConversion: One attributed sale through a referral link
+type AffiliateConversion struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LinkID       primitive.ObjectID `bson:"link_id" json:"link_id"`
+	ProgramID    primitive.ObjectID `bson:"program_id" json:"program_id"`
+	AffiliateID  primitive.ObjectID `bson:"affiliate_user_id" json:"affiliate_user_id"`
+	OrderID      primitive.ObjectID `bson:"order_id" json:"order_id"`
+	CommissionID primitive.ObjectID `bson:"commission_id" json:"commission_id"`
+	SaleAmount   float64            `bson:"sale_amount" json:"sale_amount"`
+	Currency     string             `bson:"currency,omitempty" json:"currency,omitempty"` // Of the sale; unset on conversions recorded in DefaultCurrency
 	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
 }
+
+// AffiliateActivityRow: Aggregated activity for one link on one (UTC) day
+type AffiliateActivityRow struct {
+	LinkID        primitive.ObjectID `bson:"link_id"`
+	Day           string             `bson:"day"` // YYYY-MM-DD
+	Clicks        int                `bson:"clicks"`
+	Conversions   int                `bson:"conversions"`
+	Earnings      map[string]float64 `bson:"earnings"`       // Tier-1 commissions per currency
+	Tier2Earnings map[string]float64 `bson:"tier2_earnings"` // Commissions as a recruiter per currency
+}
+
+// AffiliateMetrics: Counters shared by every stats breakdown
+type AffiliateMetrics struct {
+	Clicks         int     `json:"clicks"`
+	Conversions    int     `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"` // Conversions per click, as a percentage
+	Earnings       float64 `json:"earnings"`
+	EPC            float64 `json:"epc"` // Earnings per click
+}
+
+// Add accumulates a row's counters
+func (m *AffiliateMetrics) Add(clicks, conversions int, earnings float64) {
+	m.Clicks += clicks
+	m.Conversions += conversions
+	m.Earnings += earnings
+}
+
+// Finalize derives the ratios from the counters
+func (m *AffiliateMetrics) Finalize() {
+	m.ConversionRate, m.EPC = 0, 0
+	if m.Clicks > 0 {
+		m.ConversionRate = float64(m.Conversions) / float64(m.Clicks) * 100
+		m.EPC = m.Earnings / float64(m.Clicks)
+	}
+}
+
+type AffiliateLinkStats struct {
+	LinkID primitive.ObjectID `json:"link_id"`
+	Code   string             `json:"code"`
+	AffiliateMetrics
+}
+
+type AffiliateDailyStats struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	AffiliateMetrics
+}
+
+// AffiliateStats: An affiliate's performance over a date range
+type AffiliateStats struct {
+	From          time.Time             `json:"from"`
+	To            time.Time             `json:"to"`
+	Totals        AffiliateMetrics      `json:"totals"`
+	Tier2Earnings float64               `json:"tier2_earnings"` // Included in Totals.Earnings
+	Links         []AffiliateLinkStats  `json:"links"`
+	Daily         []AffiliateDailyStats `json:"daily"`
+
+	// Earnings are converted to Currency; EarningsByCurrency has the amounts as earned
+	Currency           string             `json:"currency"`
+	EarningsByCurrency map[string]float64 `json:"earnings_by_currency"`
+}
+
+// AffiliateLeaderboardEntry: One affiliate's results within a program
+type AffiliateLeaderboardEntry struct {
+	AffiliateID primitive.ObjectID `json:"affiliate_user_id"`
+	Name        string             `json:"name"`
+	Sales       float64            `json:"sales"` // Attributed revenue
+	AffiliateMetrics
+
+	// Sales and earnings are converted to Currency; the maps have the amounts as earned
+	Currency           string             `json:"currency"`
+	SalesByCurrency    map[string]float64 `json:"sales_by_currency"`
+	EarningsByCurrency map[string]float64 `json:"earnings_by_currency"`
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyScope grants access to one area of the API. Scopes are "<resource>:read"
+// or "<resource>:write"; write implies read for the same resource.
+type APIKeyScope string
+
+const (
+	ScopePricingRead   APIKeyScope = "pricing:read"
+	ScopePricingWrite  APIKeyScope = "pricing:write"
+	ScopePaymentsRead  APIKeyScope = "payments:read"
+	ScopePaymentsWrite APIKeyScope = "payments:write"
+	ScopeWalletRead    APIKeyScope = "wallet:read"
+	ScopeWalletWrite   APIKeyScope = "wallet:write"
+)
+
+// ValidAPIKeyScopes lists every scope a key may be issued with
+var ValidAPIKeyScopes = []APIKeyScope{
+	ScopePricingRead, ScopePricingWrite,
+	ScopePaymentsRead, ScopePaymentsWrite,
+	ScopeWalletRead, ScopeWalletWrite,
+}
+
+// APIKey is a long-lived credential for server-to-server calls.
+// Only the SHA-256 hash of the secret is stored; the plaintext is shown once at creation.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"` // Issuer; requests act as this user
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // First characters of the key, for identification in UIs
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	Scopes     []APIKeyScope      `bson:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // nil = never
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// IsUsable reports whether the key is neither revoked nor expired
+func (k *APIKey) IsUsable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(now)
+}
+
+// HasScope reports whether the key grants the scope (write implies read)
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	var implied APIKeyScope
+	if resource, ok := strings.CutSuffix(string(scope), ":read"); ok {
+		implied = APIKeyScope(resource + ":write")
+	}
+	for _, s := range k.Scopes {
+		if s == scope || (implied != "" && s == implied) {
+			return true
+		}
+	}
+	return false
+}
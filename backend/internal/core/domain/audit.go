@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditEventType string
+
+const (
+	AuditEventAccountLocked   AuditEventType = "account_locked"
+	AuditEventIPLocked        AuditEventType = "ip_locked"
+	AuditEventAccountUnlocked AuditEventType = "account_unlocked"
+)
+
+// AuditEvent records a security-relevant action for later review
+type AuditEvent struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Type      AuditEventType      `bson:"type" json:"type"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`   // Subject of the event
+	ActorID   *primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // Who triggered it (nil = system)
+	IP        string              `bson:"ip,omitempty" json:"ip,omitempty"`
+	Details   map[string]string   `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+}
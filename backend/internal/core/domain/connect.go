@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Stripe Connect account types a seller can onboard with
+const (
+	ConnectAccountExpress  = "express"
+	ConnectAccountStandard = "standard"
+)
+
+// StripeConnectStatus values, derived from the account's capabilities and requirements
+const (
+	ConnectStatusPending      = "pending"      // Onboarding not finished
+	ConnectStatusRestricted   = "restricted"   // Information is missing; charges or payouts are off
+	ConnectStatusActive       = "active"       // Can accept charges and receive payouts
+	ConnectStatusDisabled     = "disabled"     // Rejected by Stripe
+	ConnectStatusDisconnected = "disconnected" // Unlinked from the platform
+)
+
+// ConnectAccount is the last known state of a seller's connected account, as reported by Stripe
+type ConnectAccount struct {
+	Type             string `bson:"type" json:"type"`       // "express", "standard"
+	Managed          bool   `bson:"managed" json:"managed"` // Created by the platform, rather than linked with OAuth
+	ChargesEnabled   bool   `bson:"charges_enabled" json:"charges_enabled"`
+	PayoutsEnabled   bool   `bson:"payouts_enabled" json:"payouts_enabled"`
+	DetailsSubmitted bool   `bson:"details_submitted" json:"details_submitted"`
+
+	// Requirements: fields Stripe needs now, and those whose deadline passed
+	CurrentlyDue   []string   `bson:"currently_due,omitempty" json:"currently_due,omitempty"`
+	PastDue        []string   `bson:"past_due,omitempty" json:"past_due,omitempty"`
+	DisabledReason string     `bson:"disabled_reason,omitempty" json:"disabled_reason,omitempty"`
+	Deadline       *time.Time `bson:"deadline,omitempty" json:"deadline,omitempty"` // When currently due fields become past due
+
+	SyncedAt time.Time `bson:"synced_at" json:"synced_at"`
+}
+
+type ConnectEventType string
+
+const (
+	ConnectEventAccountUpdated ConnectEventType = "account.updated"
+	ConnectEventDeauthorized   ConnectEventType = "account.deauthorized" // The seller removed the platform's access
+)
+
+// ConnectEvent is a verified webhook about a connected account
+type ConnectEvent struct {
+	ID        string
+	Type      ConnectEventType
+	AccountID string
+	Account   *ConnectAccount // Latest state, on account.updated
+}
+
+// Status derives the StripeConnectStatus of the account
+func (a *ConnectAccount) Status() string {
+	switch {
+	case strings.HasPrefix(a.DisabledReason, "rejected"):
+		return ConnectStatusDisabled
+	case !a.DetailsSubmitted:
+		return ConnectStatusPending
+	case a.ChargesEnabled && a.PayoutsEnabled && len(a.PastDue) == 0:
+		return ConnectStatusActive
+	default:
+		return ConnectStatusRestricted
+	}
+}
+
+// CanReceiveDestinationCharges reports whether checkout may route charges to the user's connected account
+func (u *User) CanReceiveDestinationCharges() bool {
+	return u.StripeConnectID != "" &&
+		u.StripeConnectStatus == ConnectStatusActive &&
+		u.StripeConnectAccount != nil &&
+		u.StripeConnectAccount.ChargesEnabled
+}
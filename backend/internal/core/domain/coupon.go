@@ -13,6 +13,15 @@ const (
 	DiscountTypePercent DiscountType = "percent"
 )
 
+// CouponDuration: How many billing cycles of a subscription a coupon discounts
+type CouponDuration string
+
+const (
+	CouponDurationOnce      CouponDuration = "once"      // First invoice only
+	CouponDurationRepeating CouponDuration = "repeating" // Invoices in the first DurationInMonths months
+	CouponDurationForever   CouponDuration = "forever"   // Every invoice
+)
+
 type Coupon struct {
 	ID                primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	Code              string               `bson:"code" json:"code"`
@@ -23,5 +32,118 @@ type Coupon struct {
 	UsedCount         int                  `bson:"used_count" json:"used_count"`
 	ExpiryDate        *time.Time           `bson:"expiry_date,omitempty" json:"expiry_date,omitempty"` // null = no expiry
 	IsActive          bool                 `bson:"is_active" json:"is_active"`
-	CreatedAt         time.Time            `bson:"created_at" json:"created_at"`
+
+	// Ownership
+	CreatorID  primitive.ObjectID  `bson:"creator_id,omitempty" json:"creator_id,omitempty"`   // empty = platform coupon (admins only)
+	CampaignID *primitive.ObjectID `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"` // Set on codes generated by a campaign
+
+	// Eligibility
+	StartDate           *time.Time           `bson:"start_date,omitempty" json:"start_date,omitempty"`                       // null = valid immediately
+	MaxUsesPerUser      int                  `bson:"max_uses_per_user,omitempty" json:"max_uses_per_user,omitempty"`         // 0 = unlimited
+	MinOrderAmount      float64              `bson:"min_order_amount,omitempty" json:"min_order_amount,omitempty"`           // Checked before this coupon's discount
+	MaxDiscountAmount   float64              `bson:"max_discount_amount,omitempty" json:"max_discount_amount,omitempty"`     // Cap for percent coupons, 0 = none
+	FirstPurchaseOnly   bool                 `bson:"first_purchase_only,omitempty" json:"first_purchase_only,omitempty"`     // Only for customers who never bought
+	AllowedUserIDs      []primitive.ObjectID `bson:"allowed_user_ids,omitempty" json:"allowed_user_ids,omitempty"`           // With AllowedEmailDomains: empty = everyone
+	AllowedEmailDomains []string             `bson:"allowed_email_domains,omitempty" json:"allowed_email_domains,omitempty"` // e.g. "university.edu"
+
+	// Stacking
+	StacksWithEarlyBird bool `bson:"stacks_with_early_bird,omitempty" json:"stacks_with_early_bird,omitempty"` // May be used while the early-bird price applies
+	StacksWithCoupons   bool `bson:"stacks_with_coupons,omitempty" json:"stacks_with_coupons,omitempty"`       // May be combined with other stacking coupons
+
+	// Subscriptions
+	Duration         CouponDuration `bson:"duration,omitempty" json:"duration,omitempty"`                     // empty = once
+	DurationInMonths int            `bson:"duration_in_months,omitempty" json:"duration_in_months,omitempty"` // Repeating coupons only
+
+	// Gateway Sync (created on first use with a subscription)
+	StripeCouponID        string `bson:"stripe_coupon_id,omitempty" json:"stripe_coupon_id,omitempty"`
+	StripePromotionCodeID string `bson:"stripe_promotion_code_id,omitempty" json:"stripe_promotion_code_id,omitempty"`
+	StripeCurrency        string `bson:"stripe_currency,omitempty" json:"stripe_currency,omitempty"` // Currency of fixed-amount gateway coupons
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// IsRestrictedToCustomers reports whether only listed users or email domains may use the coupon
+func (c *Coupon) IsRestrictedToCustomers() bool {
+	return len(c.AllowedUserIDs) > 0 || len(c.AllowedEmailDomains) > 0
+}
+
+// HasGatewayCoupon reports whether the discount terms were already synced to the gateway, which cannot change them
+func (c *Coupon) HasGatewayCoupon() bool {
+	return c.StripeCouponID != ""
+}
+
+// EffectiveDuration treats coupons created before durations existed as single-use
+func (c *Coupon) EffectiveDuration() CouponDuration {
+	if c.Duration == "" {
+		return CouponDurationOnce
+	}
+	return c.Duration
+}
+
+// CouponCheck describes the order a coupon is being validated against
+type CouponCheck struct {
+	PlanID      string
+	UserID      string   // Empty for anonymous previews; customer rules then fail
+	OrderAmount float64  // Amount before this coupon; 0 = the plan's base price
+	Factor      float64  // Converts the coupon's fixed amounts from the plan's base currency to the order's; 0 = 1
+	EarlyBird   bool     // The early-bird price is applied to the order
+	OtherCodes  []string // Other coupons on the same order
+}
+
+type RedemptionStatus string
+
+const (
+	RedemptionReserved  RedemptionStatus = "reserved"  // Held at checkout, counts towards limits
+	RedemptionConfirmed RedemptionStatus = "confirmed" // Payment succeeded
+	RedemptionReleased  RedemptionStatus = "released"  // Payment failed or the hold expired
+)
+
+// CouponRedemption: One use of a coupon by a customer
+type CouponRedemption struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	CouponID       primitive.ObjectID  `bson:"coupon_id" json:"coupon_id"`
+	CampaignID     *primitive.ObjectID `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"`
+	Code           string              `bson:"code" json:"code"`
+	UserID         primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	PlanID         primitive.ObjectID  `bson:"plan_id" json:"plan_id"`
+	DiscountAmount float64             `bson:"discount_amount" json:"discount_amount"`
+	Currency       string              `bson:"currency,omitempty" json:"currency,omitempty"` // Of the discount; unset on redemptions in DefaultCurrency
+	Status         RedemptionStatus    `bson:"status" json:"status"`
+	PaymentRef     string              `bson:"payment_ref,omitempty" json:"payment_ref,omitempty"` // Gateway payment or order the discount was used on
+	ReleaseReason  string              `bson:"release_reason,omitempty" json:"release_reason,omitempty"`
+	ExpiresAt      time.Time           `bson:"expires_at" json:"expires_at"` // Reservation is released after this unless confirmed
+	ConfirmedAt    *time.Time          `bson:"confirmed_at,omitempty" json:"confirmed_at,omitempty"`
+	ReleasedAt     *time.Time          `bson:"released_at,omitempty" json:"released_at,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// MaxCampaignCodes caps how many codes a single campaign generates
+const MaxCampaignCodes = 10000
+
+// CouponCampaign: A batch of unique codes generated from a pattern, sharing the same rules
+type CouponCampaign struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CreatorID     primitive.ObjectID `bson:"creator_id" json:"creator_id"`
+	Name          string             `bson:"name" json:"name"`
+	Pattern       string             `bson:"pattern" json:"pattern"` // '?' is replaced by a random character, e.g. "SPRING-????-????"
+	CodeCount     int                `bson:"code_count" json:"code_count"`
+	Rules         Coupon             `bson:"rules" json:"rules"` // Template for every generated code; max_uses 0 = single-use
+	IsActive      bool               `bson:"is_active" json:"is_active"`
+	DeactivatedAt *time.Time         `bson:"deactivated_at,omitempty" json:"deactivated_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// CouponCampaignStats: Redemption totals across every code of a campaign
+type CouponCampaignStats struct {
+	CampaignID     primitive.ObjectID `json:"campaign_id"`
+	CodeCount      int64              `json:"code_count"`
+	CodesUsed      int64              `json:"codes_used"`      // Codes with at least one reserved or confirmed use
+	Reserved       int64              `json:"reserved"`        // Checkouts still holding a code
+	Confirmed      int64              `json:"confirmed"`       // Paid redemptions
+	Released       int64              `json:"released"`        // Failed or abandoned checkouts
+	TotalDiscount  float64            `json:"total_discount"`  // Sum over confirmed redemptions, in Currency
+	RedemptionRate float64            `json:"redemption_rate"` // Percentage of codes used
+
+	Currency           string             `json:"currency"`
+	DiscountByCurrency map[string]float64 `json:"discount_by_currency"` // Confirmed discounts as given
 }
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"math"
+	"strings"
+)
+
+// DefaultCurrency prices plans that do not name a currency and opens new wallets
+const DefaultCurrency = "USD"
+
+// zeroDecimalCurrencies have no minor unit, e.g. 500 JPY is charged as 500, not 50000
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// countryCurrencies: The currency buyers in a country are presented by default
+var countryCurrencies = map[string]string{
+	// Euro area
+	"AT": "EUR", "BE": "EUR", "BG": "EUR", "HR": "EUR", "CY": "EUR", "EE": "EUR", "FI": "EUR", "FR": "EUR",
+	"DE": "EUR", "GR": "EUR", "IE": "EUR", "IT": "EUR", "LV": "EUR", "LT": "EUR", "LU": "EUR", "MT": "EUR",
+	"NL": "EUR", "PT": "EUR", "SK": "EUR", "SI": "EUR", "ES": "EUR",
+
+	"US": "USD", "GB": "GBP", "CH": "CHF", "NO": "NOK", "SE": "SEK", "DK": "DKK", "PL": "PLN", "CZ": "CZK",
+	"HU": "HUF", "RO": "RON", "CA": "CAD", "MX": "MXN", "BR": "BRL", "AU": "AUD", "NZ": "NZD", "JP": "JPY",
+	"SG": "SGD", "IN": "INR", "KR": "KRW", "HK": "HKD", "ZA": "ZAR", "AE": "AED",
+}
+
+// NormalizeCurrency returns the upper-case ISO code; gateways and older records use lower case
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// CurrencyForCountry returns the local currency of a country, or "" if unknown
+func CurrencyForCountry(country string) string {
+	return countryCurrencies[strings.ToUpper(country)]
+}
+
+// IsZeroDecimal reports whether the currency has no minor unit
+func IsZeroDecimal(currency string) bool {
+	return zeroDecimalCurrencies[NormalizeCurrency(currency)]
+}
+
+// RoundAmount rounds to the smallest unit the currency can be charged in
+func RoundAmount(amount float64, currency string) float64 {
+	if IsZeroDecimal(currency) {
+		return math.Round(amount)
+	}
+	return math.Round(amount*100) / 100
+}
+
+// MinorUnits converts an amount to the integer unit gateways charge in (cents, or yen)
+func MinorUnits(amount float64, currency string) int64 {
+	if IsZeroDecimal(currency) {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinorUnits converts an amount reported by a gateway in minor units back to a decimal amount
+func FromMinorUnits(amount int64, currency string) float64 {
+	if IsZeroDecimal(currency) {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
@@ -0,0 +1,85 @@
+package domain
+
+import "time"
+
+// GatewayOrder: A one-off charge to create at a payment gateway
+type GatewayOrder struct {
+	OrderID     string // Our payment ID; the gateway reports it back in webhooks
+	Amount      float64
+	Currency    string
+	Description string
+	Metadata    map[string]string
+
+	// Connect destination charge: the seller's account and the platform's fee (Stripe only)
+	DestinationAccountID string
+	ApplicationFee       float64
+
+	// Where redirect-based gateways send the buyer after approving or cancelling
+	ReturnURL string
+	CancelURL string
+}
+
+// GatewayCheckout: What the buyer needs to complete a payment at the gateway
+type GatewayCheckout struct {
+	Gateway      PaymentGateway `json:"gateway"`
+	OrderID      string         `json:"order_id"`                // Our payment ID
+	Ref          string         `json:"-"`                       // Gateway order, e.g. a PaymentIntent or PayPal order ID
+	ClientSecret string         `json:"client_secret,omitempty"` // Stripe: confirmed in the browser
+	ApprovalURL  string         `json:"approval_url,omitempty"`  // PayPal: where the buyer approves the payment
+
+	// Manual: the code to quote with the transfer and how to pay
+	Reference    string     `json:"reference,omitempty"`
+	Instructions string     `json:"instructions,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // Unpaid orders expire after this
+}
+
+// GatewaySubscription: A subscription opened at the gateway and the payment of its first invoice
+type GatewaySubscription struct {
+	Ref          string // Gateway subscription ID
+	PaymentRef   string // Payment of the first invoice, e.g. a PaymentIntent; empty when nothing is due now
+	ClientSecret string // Stripe: confirmed in the browser
+}
+
+// GatewayCapture: The result of collecting an approved order
+type GatewayCapture struct {
+	Ref       string // Captured transaction, the target of refunds
+	OrderRef  string
+	OrderID   string // Our payment ID, if the gateway carried it
+	Completed bool   // false while the gateway still processes or declined it
+	Amount    float64
+	Currency  string
+}
+
+// GatewayRefund: A refund issued at the gateway
+type GatewayRefund struct {
+	Ref    string
+	Amount float64
+	Status string // Gateway status, e.g. "succeeded", "COMPLETED", "PENDING"
+}
+
+type GatewayEventType string
+
+const (
+	GatewayEventApproved  GatewayEventType = "payment.approved" // Buyer approved; the order still has to be captured
+	GatewayEventSucceeded GatewayEventType = "payment.succeeded"
+	GatewayEventFailed    GatewayEventType = "payment.failed"
+	GatewayEventRefunded  GatewayEventType = "payment.refunded"
+	GatewayEventRenewed   GatewayEventType = "subscription.renewed" // A renewal invoice of a subscription was paid
+)
+
+// GatewayEvent: A verified webhook, translated from the gateway's vocabulary
+type GatewayEvent struct {
+	ID        string // Gateway event ID
+	Type      GatewayEventType
+	OrderID   string // Our payment ID, when the gateway carries it back
+	Ref       string // Gateway order or captured transaction the event is about
+	RefundRef string // Refunds only
+	Amount    float64
+	Currency  string
+	Metadata  map[string]string
+
+	// Renewals only: Ref is the paid invoice
+	SubscriptionRef string
+	PeriodStart     time.Time
+	PeriodEnd       time.Time
+}
@@ -15,27 +15,119 @@ const (
 )
 
 type Invoice struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
-	TransactionID primitive.ObjectID `bson:"transaction_id" json:"transaction_id"` // Link to WalletTransaction or Payment
-	InvoiceNumber string             `bson:"invoice_number" json:"invoice_number"` // e.g. INV-2024-001
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID  `bson:"user_id" json:"user_id"`               // Buyer
+	SellerID       primitive.ObjectID  `bson:"seller_id,omitempty" json:"seller_id"` // Creator; empty = platform sale
+	TransactionID  primitive.ObjectID  `bson:"transaction_id" json:"transaction_id"` // Link to WalletTransaction or Payment
+	SubscriptionID *primitive.ObjectID `bson:"subscription_id,omitempty" json:"subscription_id,omitempty"`
+	InvoiceNumber  string              `bson:"invoice_number" json:"invoice_number"` // e.g. INV-3F9A2C-000001
+	Sequence       int64               `bson:"sequence" json:"sequence"`             // Gap-free per seller
 
-	Items       []InvoiceItem `bson:"items" json:"items"`
-	SubTotal    float64       `bson:"sub_total" json:"sub_total"`
-	TaxAmount   float64       `bson:"tax_amount" json:"tax_amount"`
-	TotalAmount float64       `bson:"total_amount" json:"total_amount"`
-	Currency    string        `bson:"currency" json:"currency"`
+	Seller InvoiceParty `bson:"seller" json:"seller"`
+	Buyer  InvoiceParty `bson:"buyer" json:"buyer"`
+
+	Items          []InvoiceItem     `bson:"items" json:"items"`
+	Discounts      []AppliedDiscount `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	SubTotal       float64           `bson:"sub_total" json:"sub_total"`
+	DiscountAmount float64           `bson:"discount_amount" json:"discount_amount"`
+	TaxAmount      float64           `bson:"tax_amount" json:"tax_amount"`
+	TotalAmount    float64           `bson:"total_amount" json:"total_amount"`
+	Currency       string            `bson:"currency" json:"currency"`
+	PlatformFee    float64           `bson:"platform_fee" json:"platform_fee"` // Seller's cost, not charged to the buyer
+
+	// Tax: with TaxInclusive, SubTotal and discounts already contain TaxAmount
+	TaxLines      []TaxLine `bson:"tax_lines,omitempty" json:"tax_lines,omitempty"`
+	TaxInclusive  bool      `bson:"tax_inclusive" json:"tax_inclusive"`
+	ReverseCharge bool      `bson:"reverse_charge" json:"reverse_charge"` // B2B: the buyer accounts for the tax
 
 	Status       InvoiceStatus `bson:"status" json:"status"`
 	GeneratedURL string        `bson:"generated_url,omitempty" json:"generated_url,omitempty"` // If stored in S3
 
+	// Voiding: a void invoice keeps its number; corrections are issued as a new invoice
+	VoidedAt            *time.Time          `bson:"voided_at,omitempty" json:"voided_at,omitempty"`
+	VoidReason          string              `bson:"void_reason,omitempty" json:"void_reason,omitempty"`
+	ReplacesInvoiceID   *primitive.ObjectID `bson:"replaces_invoice_id,omitempty" json:"replaces_invoice_id,omitempty"`
+	ReplacedByInvoiceID *primitive.ObjectID `bson:"replaced_by_invoice_id,omitempty" json:"replaced_by_invoice_id,omitempty"`
+
 	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
 	PaidAt    *time.Time `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
 }
 
+// InvoiceView selects whose invoices a listing returns
+type InvoiceView string
+
+const (
+	InvoiceViewPurchases InvoiceView = "purchases" // Invoices the user received as buyer
+	InvoiceViewSales     InvoiceView = "sales"     // Invoices the user issued as seller
+	InvoiceViewAll       InvoiceView = "all"       // Every invoice (admins only)
+)
+
+// InvoiceFilter narrows an invoice listing; zero values match everything
+type InvoiceFilter struct {
+	UserID   *primitive.ObjectID
+	SellerID *primitive.ObjectID
+	Status   InvoiceStatus
+	From     *time.Time // Inclusive
+	To       *time.Time // Exclusive
+}
+
+// InvoiceParty: Name and contact printed for the seller or the buyer
+type InvoiceParty struct {
+	Name    string   `bson:"name" json:"name"`
+	Email   string   `bson:"email,omitempty" json:"email,omitempty"`
+	Address []string `bson:"address,omitempty" json:"address,omitempty"`
+	TaxID   string   `bson:"tax_id,omitempty" json:"tax_id,omitempty"`
+}
+
 type InvoiceItem struct {
 	Description string  `bson:"description" json:"description"`
 	Quantity    int     `bson:"quantity" json:"quantity"`
 	UnitPrice   float64 `bson:"unit_price" json:"unit_price"`
 	Total       float64 `bson:"total" json:"total"`
 }
+
+type CreditNoteReason string
+
+const (
+	CreditNoteRefund     CreditNoteReason = "refund"     // Issued automatically for a full or partial refund
+	CreditNoteAdjustment CreditNoteReason = "adjustment" // Goodwill credit issued by an admin
+)
+
+// CreditNote: Reduces the amount owed on an issued invoice, which itself is never edited
+type CreditNote struct {
+	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	InvoiceID        primitive.ObjectID  `bson:"invoice_id" json:"invoice_id"`
+	InvoiceNumber    string              `bson:"invoice_number" json:"invoice_number"` // Of the credited invoice
+	UserID           primitive.ObjectID  `bson:"user_id" json:"user_id"`               // Buyer
+	SellerID         primitive.ObjectID  `bson:"seller_id,omitempty" json:"seller_id"` // empty = platform sale
+	TransactionID    primitive.ObjectID  `bson:"transaction_id" json:"transaction_id"` // Payment of the invoice
+	CreditNoteNumber string              `bson:"credit_note_number" json:"credit_note_number"`
+	Sequence         int64               `bson:"sequence" json:"sequence"` // Own gap-free series per seller
+	Reason           CreditNoteReason    `bson:"reason" json:"reason"`
+	Note             string              `bson:"note,omitempty" json:"note,omitempty"`
+	RefundRef        string              `bson:"refund_ref,omitempty" json:"refund_ref,omitempty"` // Gateway refund, for webhook retries
+	IssuedBy         *primitive.ObjectID `bson:"issued_by,omitempty" json:"issued_by,omitempty"`   // Admin of an adjustment
+
+	Seller InvoiceParty `bson:"seller" json:"seller"`
+	Buyer  InvoiceParty `bson:"buyer" json:"buyer"`
+
+	Items       []InvoiceItem `bson:"items" json:"items"`
+	SubTotal    float64       `bson:"sub_total" json:"sub_total"`   // Net credit
+	TaxAmount   float64       `bson:"tax_amount" json:"tax_amount"` // Tax reversed
+	TotalAmount float64       `bson:"total_amount" json:"total_amount"`
+	Currency    string        `bson:"currency" json:"currency"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// TaxRate is the effective tax rate of the invoice, used to reverse tax proportionally
+func (inv *Invoice) TaxRate() float64 {
+	net := inv.SubTotal - inv.DiscountAmount
+	if inv.TaxInclusive {
+		net -= inv.TaxAmount
+	}
+	if net <= 0 {
+		return 0
+	}
+	return inv.TaxAmount / net
+}
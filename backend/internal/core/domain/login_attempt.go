@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// LoginAttempt tracks consecutive failed logins for a throttling key
+// (e.g. "account:jane@example.com" or "ip:203.0.113.7")
+type LoginAttempt struct {
+	Key           string     `bson:"_id" json:"key"`
+	Failures      int        `bson:"failures" json:"failures"`
+	LastFailureAt time.Time  `bson:"last_failure_at" json:"last_failure_at"`
+	LockedUntil   *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether the key is locked out at the given time
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// ExternalIdentity is what a login provider tells us about the user after a successful exchange
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OAuthState is the server-side half of an in-flight authorization request.
+// It is looked up by the opaque state value and deleted on first use.
+type OAuthState struct {
+	State        string    `bson:"_id" json:"state"`
+	Provider     string    `bson:"provider" json:"provider"`
+	UserID       string    `bson:"user_id,omitempty" json:"user_id,omitempty"` // Initiating user, for account-linking flows
+	Nonce        string    `bson:"nonce,omitempty" json:"-"`
+	CodeVerifier string    `bson:"code_verifier,omitempty" json:"-"` // PKCE
+	ExpiresAt    time.Time `bson:"expires_at" json:"expires_at"`
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
+}
@@ -14,6 +14,7 @@ const (
 	PaymentStatusFailed            PaymentStatus = "failed"
 	PaymentStatusRefunded          PaymentStatus = "refunded"
 	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusExpired           PaymentStatus = "expired" // Not paid before the order's deadline, e.g. a bank transfer that never arrived
 )
 
 type PaymentGateway string
@@ -32,23 +33,113 @@ type Payment struct {
 	PricingPlanID primitive.ObjectID `bson:"pricing_plan_id" json:"pricing_plan_id"`
 	MembershipID  primitive.ObjectID `bson:"membership_id" json:"membership_id"`
 
-	Amount   float64        `bson:"amount" json:"amount"`
+	Amount   float64        `bson:"amount" json:"amount"` // Charged to the buyer
 	Currency string         `bson:"currency" json:"currency"`
 	Status   PaymentStatus  `bson:"status" json:"status"`
 	Gateway  PaymentGateway `bson:"gateway" json:"gateway"`
 
+	// Price Breakdown: Amount = ListAmount - DiscountAmount + TaxAmount,
+	// or ListAmount - DiscountAmount if the plan's prices include tax
+	Description    string            `bson:"description" json:"description"` // Plan name
+	Quantity       int               `bson:"quantity" json:"quantity"`
+	UnitPrice      float64           `bson:"unit_price" json:"unit_price"`
+	ListAmount     float64           `bson:"list_amount" json:"list_amount"`
+	Discounts      []AppliedDiscount `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	DiscountAmount float64           `bson:"discount_amount" json:"discount_amount"`
+	TaxAmount      float64           `bson:"tax_amount" json:"tax_amount"`
+	PlatformFee    float64           `bson:"platform_fee" json:"platform_fee"` // Kept by the platform from the seller's share
+	Tax            *TaxResult        `bson:"tax,omitempty" json:"tax,omitempty"`
+	RefundedAmount float64           `bson:"refunded_amount,omitempty" json:"refunded_amount,omitempty"`
+	Refunds        []PaymentRefund   `bson:"refunds,omitempty" json:"refunds,omitempty"`
+
+	SubscriptionID *primitive.ObjectID `bson:"subscription_id,omitempty" json:"subscription_id,omitempty"`
+	InvoiceID      *primitive.ObjectID `bson:"invoice_id,omitempty" json:"invoice_id,omitempty"`
+
 	// Gateway specific details
-	TransactionID string            `bson:"transaction_id" json:"transaction_id"` // e.g. Stripe PaymentIntent ID
-	Metadata      map[string]string `bson:"metadata" json:"metadata"`
+	GatewayOrderRef string            `bson:"gateway_order_ref,omitempty" json:"gateway_order_ref,omitempty"` // Order created at checkout, e.g. PayPal order ID
+	TransactionID   string            `bson:"transaction_id" json:"transaction_id"`                           // Captured payment, e.g. Stripe PaymentIntent ID
+	Metadata        map[string]string `bson:"metadata" json:"metadata"`
+
+	// Payments made outside a gateway: what the buyer was asked to do, until when, and who confirmed receipt
+	Instructions string               `bson:"instructions,omitempty" json:"instructions,omitempty"`
+	ExpiresAt    *time.Time           `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Confirmation *PaymentConfirmation `bson:"confirmation,omitempty" json:"confirmation,omitempty"`
 
 	// Affiliate Tracking
 	AffiliateID *primitive.ObjectID `bson:"affiliate_id,omitempty" json:"affiliate_id,omitempty"`
 	Commission  float64             `bson:"commission,omitempty" json:"commission,omitempty"`
 
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	PaidAt    *time.Time `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// PaymentConfirmation: An admin's record that an offline payment was received
+type PaymentConfirmation struct {
+	ConfirmedBy primitive.ObjectID `bson:"confirmed_by" json:"confirmed_by"`
+	Notes       string             `bson:"notes" json:"notes"` // Proof of payment, e.g. the bank statement line
+	ConfirmedAt time.Time          `bson:"confirmed_at" json:"confirmed_at"`
+}
+
+// AddDiscount records a price reduction; zero discounts are skipped
+func (p *Payment) AddDiscount(code string, description string, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	p.Discounts = append(p.Discounts, AppliedDiscount{Code: code, Description: description, Amount: amount})
+	p.DiscountAmount += amount
+}
+
+// PriceQuote: What a checkout would charge, without reserving anything
+type PriceQuote struct {
+	PlanID         string            `json:"plan_id"`
+	Currency       string            `json:"currency"`
+	Quantity       int               `json:"quantity"`
+	ListAmount     float64           `json:"list_amount"`
+	Discounts      []AppliedDiscount `json:"discounts,omitempty"`
+	DiscountAmount float64           `json:"discount_amount"`
+	Tax            *TaxResult        `json:"tax,omitempty"`
+	TaxAmount      float64           `json:"tax_amount"`
+	Total          float64           `json:"total"`
+	FXRate         float64           `json:"fx_rate,omitempty"` // Set when converted from the plan's base currency
+}
+
+// PaymentRefund: One full or partial refund reported by the gateway
+type PaymentRefund struct {
+	Ref        string    `bson:"ref,omitempty" json:"ref,omitempty"` // Gateway refund ID
+	Amount     float64   `bson:"amount" json:"amount"`
+	Reason     string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	RefundedAt time.Time `bson:"refunded_at" json:"refunded_at"`
+}
+
+// HasRefund reports whether the gateway refund was already recorded
+func (p *Payment) HasRefund(ref string) bool {
+	if ref == "" {
+		return false
+	}
+	for _, r := range p.Refunds {
+		if r.Ref == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliedDiscount: One price reduction on a payment, e.g. a coupon or the early-bird price
+type AppliedDiscount struct {
+	Code        string  `bson:"code,omitempty" json:"code,omitempty"` // Coupon code, empty for other discounts
+	Description string  `bson:"description" json:"description"`
+	Amount      float64 `bson:"amount" json:"amount"`
 }
 
+const (
+	SubscriptionStatusIncomplete = "incomplete" // Waiting for the first payment
+	SubscriptionStatusActive     = "active"
+	SubscriptionStatusTrialing   = "trialing"
+	SubscriptionStatusPastDue    = "past_due"
+	SubscriptionStatusCanceled   = "canceled"
+)
+
 // Subscription represents a recurring billing agreement
 type Subscription struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -56,12 +147,102 @@ type Subscription struct {
 	PricingPlanID primitive.ObjectID `bson:"pricing_plan_id" json:"pricing_plan_id"`
 
 	StripeSubID string `bson:"stripe_subscription_id" json:"stripe_subscription_id"`
-	Status      string `bson:"status" json:"status"` // active, past_due, canceled
+	Status      string `bson:"status" json:"status"` // active, trialing, past_due, canceled
+
+	// Price per cycle in the currency the buyer chose; empty for subscriptions in the plan's base currency
+	Currency string  `bson:"currency,omitempty" json:"currency,omitempty"`
+	Price    float64 `bson:"price,omitempty" json:"price,omitempty"`
 
 	CurrentPeriodStart time.Time `bson:"current_period_start" json:"current_period_start"`
 	CurrentPeriodEnd   time.Time `bson:"current_period_end" json:"current_period_end"`
 	CancelAtPeriodEnd  bool      `bson:"cancel_at_period_end" json:"cancel_at_period_end"`
 
+	// Coupon attached at checkout, if any
+	Discount *SubscriptionDiscount `bson:"discount,omitempty" json:"discount,omitempty"`
+	// Tax charged by the gateway on every invoice, as decided at checkout
+	Tax *TaxResult `bson:"tax,omitempty" json:"tax,omitempty"`
+
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
+
+// SubscriptionDiscount: Snapshot of the coupon a subscription was started with,
+// so later edits to the coupon do not change what existing subscribers pay
+type SubscriptionDiscount struct {
+	CouponID         primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`
+	Code             string             `bson:"code" json:"code"`
+	RedemptionID     primitive.ObjectID `bson:"redemption_id" json:"redemption_id"`
+	DiscountType     DiscountType       `bson:"discount_type" json:"discount_type"`
+	DiscountAmount   float64            `bson:"discount_amount" json:"discount_amount"`
+	Duration         CouponDuration     `bson:"duration" json:"duration"`
+	DurationInMonths int                `bson:"duration_in_months,omitempty" json:"duration_in_months,omitempty"`
+	StartsAt         time.Time          `bson:"starts_at" json:"starts_at"`
+	EndsAt           *time.Time         `bson:"ends_at,omitempty" json:"ends_at,omitempty"` // Repeating coupons only
+}
+
+// NewSubscriptionDiscount snapshots a coupon applied to a subscription starting at `start`
+func NewSubscriptionDiscount(coupon *Coupon, redemptionID primitive.ObjectID, start time.Time) *SubscriptionDiscount {
+	d := &SubscriptionDiscount{
+		CouponID:         coupon.ID,
+		Code:             coupon.Code,
+		RedemptionID:     redemptionID,
+		DiscountType:     coupon.DiscountType,
+		DiscountAmount:   coupon.DiscountAmount,
+		Duration:         coupon.EffectiveDuration(),
+		DurationInMonths: coupon.DurationInMonths,
+		StartsAt:         start,
+	}
+	if d.Duration == CouponDurationRepeating {
+		end := start.AddDate(0, d.DurationInMonths, 0)
+		d.EndsAt = &end
+	}
+	return d
+}
+
+// AppliesTo reports whether the billing cycle starting at periodStart is discounted
+func (d *SubscriptionDiscount) AppliesTo(cycle int, periodStart time.Time) bool {
+	switch d.Duration {
+	case CouponDurationForever:
+		return true
+	case CouponDurationRepeating:
+		return d.EndsAt != nil && periodStart.Before(*d.EndsAt)
+	default:
+		return cycle == 1
+	}
+}
+
+// Amount is the discount on a cycle billed at `price`
+func (d *SubscriptionDiscount) Amount(price float64) float64 {
+	discount := d.DiscountAmount
+	if d.DiscountType == DiscountTypePercent {
+		discount = price * d.DiscountAmount / 100
+	}
+	if discount > price {
+		discount = price
+	}
+	return discount
+}
+
+// SubscriptionCycle: One billing cycle of a subscription, as invoiced by the gateway
+type SubscriptionCycle struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID  `bson:"subscription_id" json:"subscription_id"`
+	UserID         primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	PricingPlanID  primitive.ObjectID  `bson:"pricing_plan_id" json:"pricing_plan_id"`
+	Cycle          int                 `bson:"cycle" json:"cycle"` // 1 = first payment, 2+ = renewals
+	InvoiceRef     string              `bson:"invoice_ref" json:"invoice_ref"`
+	PeriodStart    time.Time           `bson:"period_start" json:"period_start"`
+	PeriodEnd      time.Time           `bson:"period_end" json:"period_end"`
+	ListAmount     float64             `bson:"list_amount" json:"list_amount"`
+	DiscountAmount float64             `bson:"discount_amount" json:"discount_amount"`
+	AmountPaid     float64             `bson:"amount_paid" json:"amount_paid"`
+	Currency       string              `bson:"currency" json:"currency"`
+	CouponID       *primitive.ObjectID `bson:"coupon_id,omitempty" json:"coupon_id,omitempty"`
+	CouponCode     string              `bson:"coupon_code,omitempty" json:"coupon_code,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// BlocksAccountDeletion reports whether the subscription is still going to bill the user
+func (s *Subscription) BlocksAccountDeletion() bool {
+	return (s.Status == SubscriptionStatusActive || s.Status == SubscriptionStatusTrialing) && !s.CancelAtPeriodEnd
+}
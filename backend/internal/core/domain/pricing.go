@@ -28,6 +28,20 @@ const (
 	IntervalDaily   RecurringInterval = "day"
 )
 
+// After returns the end of a billing period starting at t
+func (i RecurringInterval) After(t time.Time) time.Time {
+	switch i {
+	case IntervalYearly:
+		return t.AddDate(1, 0, 0)
+	case IntervalWeekly:
+		return t.AddDate(0, 0, 7)
+	case IntervalDaily:
+		return t.AddDate(0, 0, 1)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
 // PricingPlan represents a polymorphic pricing configuration for a product
 type PricingPlan struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -41,11 +55,16 @@ type PricingPlan struct {
 	AllowCoupons bool               `bson:"allow_coupons" json:"allow_coupons"`
 	IsFree       bool               `bson:"is_free" json:"is_free"`
 	IsPrivate    bool               `bson:"is_private" json:"is_private"`
+	TaxInclusive bool               `bson:"tax_inclusive" json:"tax_inclusive"` // Prices already include the buyer's VAT/GST
 
 	// Stripe Sync
 	StripeProductID string `bson:"stripe_product_id,omitempty" json:"stripe_product_id,omitempty"`
 	StripePriceID   string `bson:"stripe_price_id,omitempty" json:"stripe_price_id,omitempty"`
 
+	// Explicit prices in other currencies than the base one. Other currencies are derived
+	// from the base price through exchange rates.
+	CurrencyPrices []CurrencyPrice `bson:"currency_prices,omitempty" json:"currency_prices,omitempty"`
+
 	// Type-Specific Configurations (Polymorphic)
 	OneTimeConfig      *OneTimeConfig      `bson:"one_time_config,omitempty" json:"one_time_config,omitempty"`
 	SubscriptionConfig *SubscriptionConfig `bson:"subscription_config,omitempty" json:"subscription_config,omitempty"`
@@ -64,6 +83,78 @@ type PricingPlan struct {
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// BaseCurrency is the currency the plan's configuration is priced in
+func (p *PricingPlan) BaseCurrency() string {
+	var currency string
+	switch {
+	case p.Type == PricingTypeOneTime && p.OneTimeConfig != nil:
+		currency = p.OneTimeConfig.Currency
+	case p.Type == PricingTypeSubscription && p.SubscriptionConfig != nil:
+		currency = p.SubscriptionConfig.Currency
+	case p.Type == PricingTypeSplit && p.SplitConfig != nil:
+		currency = p.SplitConfig.Currency
+	case p.Type == PricingTypeDonation && p.DonationConfig != nil:
+		currency = p.DonationConfig.Currency
+	case p.Type == PricingTypeTiered && p.TieredConfig != nil:
+		currency = p.TieredConfig.Currency
+	case p.Type == PricingTypeBundle && p.BundleConfig != nil:
+		currency = p.BundleConfig.Currency
+	}
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return NormalizeCurrency(currency)
+}
+
+// BasePrice is the headline price in the base currency: the one-time, subscription or
+// bundle price, or the split total. Tiered and donation plans have none.
+func (p *PricingPlan) BasePrice() float64 {
+	switch {
+	case p.Type == PricingTypeOneTime && p.OneTimeConfig != nil:
+		return p.OneTimeConfig.Price
+	case p.Type == PricingTypeSubscription && p.SubscriptionConfig != nil:
+		return p.SubscriptionConfig.Price
+	case p.Type == PricingTypeSplit && p.SplitConfig != nil:
+		return p.SplitConfig.TotalAmount
+	case p.Type == PricingTypeBundle && p.BundleConfig != nil:
+		return p.BundleConfig.Price
+	}
+	return 0
+}
+
+// ExplicitPrice returns the price set for the currency, if any
+func (p *PricingPlan) ExplicitPrice(currency string) *CurrencyPrice {
+	currency = NormalizeCurrency(currency)
+	for i := range p.CurrencyPrices {
+		if NormalizeCurrency(p.CurrencyPrices[i].Currency) == currency {
+			return &p.CurrencyPrices[i]
+		}
+	}
+	return nil
+}
+
+// CurrencyPrice: A headline price set by hand for one currency
+type CurrencyPrice struct {
+	Currency      string  `bson:"currency" json:"currency"`
+	Amount        float64 `bson:"amount" json:"amount"`
+	StripePriceID string  `bson:"stripe_price_id,omitempty" json:"stripe_price_id,omitempty"`
+}
+
+// PlanPrice: A plan priced in the buyer's currency
+type PlanPrice struct {
+	Currency      string  `json:"currency"`
+	Amount        float64 `json:"amount"`            // Headline price, see BasePrice
+	Factor        float64 `json:"factor"`            // Converts other base-currency amounts (tiers, early-bird, minimums)
+	Explicit      bool    `json:"explicit"`          // Set by the creator rather than converted
+	FXRate        float64 `json:"fx_rate,omitempty"` // Base to buyer currency, for derived prices
+	StripePriceID string  `json:"-"`                 // Recurring price in this currency, if synced
+}
+
+// Convert turns another base-currency amount of the plan into the buyer's currency
+func (p *PlanPrice) Convert(amount float64) float64 {
+	return RoundAmount(amount*p.Factor, p.Currency)
+}
+
 // --- Specific Configurations ---
 
 type OneTimeConfig struct {
@@ -92,7 +183,8 @@ type SplitConfig struct {
 }
 
 type TieredConfig struct {
-	Tiers []TierItem `bson:"tiers" json:"tiers"`
+	Tiers    []TierItem `bson:"tiers" json:"tiers"`
+	Currency string     `bson:"currency,omitempty" json:"currency,omitempty"` // empty = DefaultCurrency
 }
 
 type TierItem struct {
@@ -112,6 +204,7 @@ type BundleConfig struct {
 	Price              float64              `bson:"price" json:"price"`
 	OriginalPrice      float64              `bson:"original_price,omitempty" json:"original_price,omitempty"`
 	IncludedProductIDs []primitive.ObjectID `bson:"included_product_ids" json:"included_product_ids"`
+	Currency           string               `bson:"currency,omitempty" json:"currency,omitempty"` // empty = DefaultCurrency
 }
 
 type UpsellConfig struct {
@@ -130,6 +223,11 @@ type EarlyBirdConfig struct {
 	Deadline       time.Time `bson:"deadline" json:"deadline"`
 }
 
+// AppliesAt reports whether the early-bird discount is still available
+func (e *EarlyBirdConfig) AppliesAt(t time.Time) bool {
+	return e != nil && e.DiscountAmount > 0 && t.Before(e.Deadline)
+}
+
 type AccessConfig struct {
 	DurationDays int `bson:"duration_days" json:"duration_days"`
 }
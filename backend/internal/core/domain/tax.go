@@ -0,0 +1,78 @@
+package domain
+
+import "strings"
+
+// BillingAddress: Where the buyer is established; decides which tax applies
+type BillingAddress struct {
+	CompanyName string `bson:"company_name,omitempty" json:"company_name,omitempty"`
+	Line1       string `bson:"line1" json:"line1"`
+	Line2       string `bson:"line2,omitempty" json:"line2,omitempty"`
+	City        string `bson:"city" json:"city"`
+	PostalCode  string `bson:"postal_code,omitempty" json:"postal_code,omitempty"`
+	State       string `bson:"state,omitempty" json:"state,omitempty"`
+	Country     string `bson:"country" json:"country"`                   // ISO 3166-1 alpha-2, e.g. "DE"
+	TaxID       string `bson:"tax_id,omitempty" json:"tax_id,omitempty"` // VAT/GST number of business buyers
+}
+
+// IsBusiness reports whether the buyer gave a tax ID, i.e. buys as a business (B2B)
+func (a *BillingAddress) IsBusiness() bool {
+	return a != nil && a.TaxID != ""
+}
+
+// Lines formats the address for documents
+func (a *BillingAddress) Lines() []string {
+	if a == nil {
+		return nil
+	}
+	var lines []string
+	for _, l := range []string{a.CompanyName, a.Line1, a.Line2, strings.TrimSpace(a.PostalCode + " " + a.City), a.State, a.Country} {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// TaxRequest: An amount to tax for a buyer
+type TaxRequest struct {
+	Amount    float64 // After discounts
+	Currency  string
+	Inclusive bool            // Amount already includes tax
+	Buyer     *BillingAddress // nil = unknown location, nothing is charged
+}
+
+// TaxResult: The tax of one charge. GrossAmount is what the buyer pays.
+type TaxResult struct {
+	Country       string  `bson:"country,omitempty" json:"country,omitempty"` // Jurisdiction
+	Name          string  `bson:"name,omitempty" json:"name,omitempty"`       // e.g. "VAT", "GST"
+	Rate          float64 `bson:"rate" json:"rate"`                           // Fraction, 0.19 = 19%
+	Inclusive     bool    `bson:"inclusive" json:"inclusive"`
+	ReverseCharge bool    `bson:"reverse_charge" json:"reverse_charge"` // B2B: the buyer accounts for the tax
+	BuyerTaxID    string  `bson:"buyer_tax_id,omitempty" json:"buyer_tax_id,omitempty"`
+	NetAmount     float64 `bson:"net_amount" json:"net_amount"`
+	TaxAmount     float64 `bson:"tax_amount" json:"tax_amount"`
+	GrossAmount   float64 `bson:"gross_amount" json:"gross_amount"`
+}
+
+// Lines turns the result into the tax lines of an invoice
+func (r *TaxResult) Lines() []TaxLine {
+	if r == nil || r.Name == "" {
+		return nil
+	}
+	return []TaxLine{{
+		Name:          r.Name,
+		Country:       r.Country,
+		Rate:          r.Rate,
+		TaxableAmount: r.NetAmount,
+		Amount:        r.TaxAmount,
+	}}
+}
+
+// TaxLine: One tax charged on an invoice
+type TaxLine struct {
+	Name          string  `bson:"name" json:"name"`
+	Country       string  `bson:"country" json:"country"`
+	Rate          float64 `bson:"rate" json:"rate"`
+	TaxableAmount float64 `bson:"taxable_amount" json:"taxable_amount"`
+	Amount        float64 `bson:"amount" json:"amount"`
+}
@@ -6,17 +6,87 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+type UserRole string
+
+const (
+	RoleUser    UserRole = "user"
+	RoleCreator UserRole = "creator"
+	RoleAdmin   UserRole = "admin"
+)
+
 type User struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Email               string             `bson:"email" json:"email"`
 	Password            string             `bson:"password" json:"-"` // Never return password in JSON
 	FullName            string             `bson:"full_name" json:"full_name"`
+	Role                UserRole           `bson:"role,omitempty" json:"role,omitempty"` // empty = RoleUser
 	IsEmailVerified     bool               `bson:"is_email_verified" json:"is_email_verified"`
 	StripeCustomerID    string             `bson:"stripe_customer_id,omitempty" json:"stripe_customer_id,omitempty"`
 	StripeConnectID     string             `bson:"stripe_connect_id,omitempty" json:"stripe_connect_id,omitempty"`
-	StripeConnectStatus string             `bson:"stripe_connect_status,omitempty" json:"stripe_connect_status,omitempty"` // "pending", "active", "disabled"
-	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+	StripeConnectStatus string             `bson:"stripe_connect_status,omitempty" json:"stripe_connect_status,omitempty"` // ConnectStatus*, derived from StripeConnectAccount
+	Identities          []ProviderIdentity `bson:"identities,omitempty" json:"identities,omitempty"`                       // Linked social logins
+
+	// Capabilities and requirements of the connected account, kept in sync by account.updated events
+	StripeConnectAccount *ConnectAccount `bson:"stripe_connect_account,omitempty" json:"stripe_connect_account,omitempty"`
+
+	// Affiliate referral touches, used to attribute purchases
+	AffiliateAttribution *AffiliateAttribution `bson:"affiliate_attribution,omitempty" json:"-"`
+	// Affiliate who recruited this user as a sub-affiliate (earns tier-2 commissions)
+	AffiliateParentID *primitive.ObjectID `bson:"affiliate_parent_id,omitempty" json:"affiliate_parent_id,omitempty"`
+
+	// Decides the tax charged at checkout; none is charged without it
+	BillingAddress *BillingAddress `bson:"billing_address,omitempty" json:"billing_address,omitempty"`
+	// Currency prices are shown and charged in; empty = the billing country's currency
+	PreferredCurrency string `bson:"preferred_currency,omitempty" json:"preferred_currency,omitempty"`
+
+	// Set on the first successful payment; drives first-purchase coupons
+	FirstPurchaseAt *time.Time `bson:"first_purchase_at,omitempty" json:"first_purchase_at,omitempty"`
+
+	// Sessions: bumping TokenVersion invalidates every previously issued token
+	TokenVersion int `bson:"token_version" json:"-"`
+
+	// Pending email change, applied once the token sent to the new address is confirmed
+	PendingEmail         string     `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	EmailChangeTokenHash string     `bson:"email_change_token_hash,omitempty" json:"-"`
+	EmailChangeExpiresAt *time.Time `bson:"email_change_expires_at,omitempty" json:"-"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // Set when the account is anonymized
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ProviderIdentity links a user to an external login provider account
+type ProviderIdentity struct {
+	Provider string    `bson:"provider" json:"provider"` // "google", "github"
+	Subject  string    `bson:"subject" json:"subject"`   // Provider's stable user ID
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
 }
 
 // Helper methods for User can go here (e.g., domain logic)
+
+// HasRole reports whether the user has one of the given roles.
+// Users without an explicit role are treated as RoleUser.
+func (u *User) HasRole(roles ...UserRole) bool {
+	role := u.Role
+	if role == "" {
+		role = RoleUser
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIdentity reports whether the provider account is already linked
+func (u *User) HasIdentity(provider, subject string) bool {
+	for _, id := range u.Identities {
+		if id.Provider == provider && id.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Wallet represents a user's balance in the platform
+// Wallet represents a user's balance in one currency; a user has one wallet per currency earned in
 type Wallet struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"` // Creator or Affiliate
@@ -29,6 +29,7 @@ type WalletTransaction struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	WalletID    primitive.ObjectID `bson:"wallet_id" json:"wallet_id"`
 	Amount      float64            `bson:"amount" json:"amount"` // Positive = Credit, Negative = Debit
+	Currency    string             `bson:"currency,omitempty" json:"currency,omitempty"`
 	Type        TransactionType    `bson:"type" json:"type"`
 	ReferenceID primitive.ObjectID `bson:"reference_id" json:"reference_id"` // OrderID or PayoutID
 	Description string             `bson:"description" json:"description"`
@@ -55,10 +56,15 @@ const (
 type PayoutRequest struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Amount      float64            `bson:"amount" json:"amount"`
-	Currency    string             `bson:"currency" json:"currency"`
+	Amount      float64            `bson:"amount" json:"amount"`     // Debited from the wallet
+	Currency    string             `bson:"currency" json:"currency"` // Of the wallet
 	Status      PayoutStatus       `bson:"status" json:"status"`
 	Method      PayoutMethod       `bson:"method" json:"method"`
 	ProcessedAt *time.Time         `bson:"processed_at,omitempty" json:"processed_at,omitempty"`
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+
+	// Paid out in another currency than the wallet's: the converted amount and the rate used
+	PayoutCurrency string  `bson:"payout_currency,omitempty" json:"payout_currency,omitempty"`
+	PayoutAmount   float64 `bson:"payout_amount,omitempty" json:"payout_amount,omitempty"`
+	FXRate         float64 `bson:"fx_rate,omitempty" json:"fx_rate,omitempty"`
 }
@@ -0,0 +1,23 @@
+package ports
+
+import (
+	"context"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// AccountService covers self-service profile changes for the signed-in user
+type AccountService interface {
+	UpdateProfile(ctx context.Context, userID string, fullName string) (*domain.User, error)
+	// UpdateBillingAddress sets the address checkout taxes by; nil removes it
+	UpdateBillingAddress(ctx context.Context, userID string, address *domain.BillingAddress) (*domain.User, error)
+	// UpdatePreferredCurrency sets the currency plans are priced in for the user; "" uses their billing country's
+	UpdatePreferredCurrency(ctx context.Context, userID string, currency string) (*domain.User, error)
+	// RequestEmailChange sends a confirmation token to the new address; the email changes on confirmation
+	RequestEmailChange(ctx context.Context, userID string, newEmail string, password string) error
+	ConfirmEmailChange(ctx context.Context, userID string, token string) (*domain.User, error)
+	// ChangePassword revokes all other sessions and returns fresh tokens for the caller
+	ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string) (string, string, error)
+	// DeleteAccount anonymizes the user's PII while keeping financial records
+	DeleteAccount(ctx context.Context, userID string, password string) error
+}
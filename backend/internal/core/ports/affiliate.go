@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"auth-payment-backend/internal/core/domain"
 
@@ -12,24 +13,98 @@ type AffiliateRepository interface {
 	// Program
 	CreateProgram(ctx context.Context, program *domain.AffiliateProgram) error
 	GetProgram(ctx context.Context, id primitive.ObjectID) (*domain.AffiliateProgram, error)
+	GetProgramsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.AffiliateProgram, error)
+	UpdateProgram(ctx context.Context, program *domain.AffiliateProgram) error
+	// GetGlobalProgram and GetProductProgram return the active program, or nil if there is none
 	GetGlobalProgram(ctx context.Context, creatorID primitive.ObjectID) (*domain.AffiliateProgram, error)
+	GetProductProgram(ctx context.Context, creatorID primitive.ObjectID, productID primitive.ObjectID) (*domain.AffiliateProgram, error)
+
+	// Enrollments
+	CreateEnrollment(ctx context.Context, enrollment *domain.AffiliateEnrollment) error
+	GetEnrollment(ctx context.Context, id primitive.ObjectID) (*domain.AffiliateEnrollment, error)
+	// GetUserEnrollment returns nil if the user has not applied to the program
+	GetUserEnrollment(ctx context.Context, programID primitive.ObjectID, userID primitive.ObjectID) (*domain.AffiliateEnrollment, error)
+	GetEnrollmentsByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AffiliateEnrollment, error)
+	// GetEnrollmentsByProgram lists applicants; an empty status lists all
+	GetEnrollmentsByProgram(ctx context.Context, programID primitive.ObjectID, status domain.EnrollmentStatus) ([]*domain.AffiliateEnrollment, error)
+	UpdateEnrollment(ctx context.Context, enrollment *domain.AffiliateEnrollment) error
 
 	// Links
 	CreateLink(ctx context.Context, link *domain.AffiliateLink) error
 	GetLinkByCode(ctx context.Context, code string) (*domain.AffiliateLink, error)
 	GetLinksByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AffiliateLink, error)
 	RecordClick(ctx context.Context, linkID primitive.ObjectID) error
+	// DisableLinksByUser stops all of the user's links from attributing sales
+	DisableLinksByUser(ctx context.Context, userID primitive.ObjectID, at time.Time) error
+
+	RecordConversion(ctx context.Context, linkID primitive.ObjectID) error
+
+	// Click and conversion events
+	CreateClick(ctx context.Context, click *domain.AffiliateClick) error
+	CreateConversion(ctx context.Context, conv *domain.AffiliateConversion) error
+
+	// Analytics over [from, to)
+	GetAffiliateActivity(ctx context.Context, affiliateID primitive.ObjectID, from, to time.Time) ([]*domain.AffiliateActivityRow, error)
+	// GetProgramLeaderboard returns raw counters per affiliate with a link in the program
+	GetProgramLeaderboard(ctx context.Context, programID primitive.ObjectID, from, to time.Time) ([]*domain.AffiliateLeaderboardEntry, error)
 
 	// Commissions
 	CreateCommission(ctx context.Context, comm *domain.Commission) error
+	GetCommission(ctx context.Context, id primitive.ObjectID) (*domain.Commission, error)
 	GetCommissionsByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Commission, error)
+	GetCommissionsByOrder(ctx context.Context, orderID primitive.ObjectID) ([]*domain.Commission, error)
+	GetChildCommissions(ctx context.Context, parentID primitive.ObjectID) ([]*domain.Commission, error)
+	// GetCommissionsByCreator lists commissions on the creator's programs; an empty status lists all
+	GetCommissionsByCreator(ctx context.Context, creatorID primitive.ObjectID, status domain.CommissionStatus) ([]*domain.Commission, error)
+	GetMaturedCommissions(ctx context.Context, now time.Time, limit int64) ([]*domain.Commission, error)
+	// TransitionCommission saves comm's status fields only if it is still in status `from`.
+	// It reports whether the transition happened.
+	TransitionCommission(ctx context.Context, comm *domain.Commission, from domain.CommissionStatus) (bool, error)
 }
 
 type AffiliateService interface {
-	CreateProgram(ctx context.Context, creatorID string, productID *string, rate float64) (*domain.AffiliateProgram, error)
-	GenerateLink(ctx context.Context, userID string, programID string, code string) (*domain.AffiliateLink, error)
-	TrackClick(ctx context.Context, code string) error
-	ProcessCommission(ctx context.Context, orderID string, amount float64, code string) (*domain.Commission, error)
+	// Programs: creatorID must own the program for every call but GetProgram
+	CreateProgram(ctx context.Context, creatorID string, program *domain.AffiliateProgram) (*domain.AffiliateProgram, error)
+	GetProgram(ctx context.Context, programID string) (*domain.AffiliateProgram, error)
+	ListPrograms(ctx context.Context, creatorID string) ([]*domain.AffiliateProgram, error)
+	// UpdateProgram replaces the program's terms; status is changed with SetProgramStatus
+	UpdateProgram(ctx context.Context, creatorID string, programID string, terms *domain.AffiliateProgram) (*domain.AffiliateProgram, error)
+	// SetProgramStatus pauses, resumes or closes a program. Closing is final.
+	SetProgramStatus(ctx context.Context, creatorID string, programID string, status domain.ProgramStatus) (*domain.AffiliateProgram, error)
+
+	// Enrollment
+	// Enroll applies userID to a program; open programs approve immediately
+	Enroll(ctx context.Context, userID string, programID string, message string) (*domain.AffiliateEnrollment, error)
+	ListMyEnrollments(ctx context.Context, userID string) ([]*domain.AffiliateEnrollment, error)
+	ListEnrollments(ctx context.Context, creatorID string, programID string, status domain.EnrollmentStatus) ([]*domain.AffiliateEnrollment, error)
+	DecideEnrollment(ctx context.Context, creatorID string, enrollmentID string, approve bool, reason string) (*domain.AffiliateEnrollment, error)
+
+	// GenerateLink creates a referral link for an approved affiliate of the program. recruiterCode,
+	// if set, is the code of the affiliate who recruited userID; they earn tier-2 commissions on the link's sales.
+	GenerateLink(ctx context.Context, userID string, programID string, code string, recruiterCode string) (*domain.AffiliateLink, error)
+	// TrackClick records a referral visit and returns the link and its program (for the cookie lifetime)
+	TrackClick(ctx context.Context, code string, ip string, userAgent string, referrer string) (*domain.AffiliateLink, *domain.AffiliateProgram, error)
+	// ResolveAttribution merges the visitor's referral state into the user record and returns the
+	// affiliate code that should be credited for a purchase, or "" if none qualifies.
+	// An explicit code entered at checkout counts as a click at checkout time.
+	ResolveAttribution(ctx context.Context, userID string, explicitCode string, visitor *domain.AffiliateAttribution) (string, error)
+	// ProcessCommission records a pending commission for the affiliate behind code on a purchase of
+	// plan by buyerID, plus a linked tier-2 commission for their recruiter when the program pays one.
+	// Commissions are earned in the currency of the sale.
+	// It returns the tier-1 commission, or nil when the purchase is not eligible.
+	ProcessCommission(ctx context.Context, orderID string, amount float64, currency string, code string, plan *domain.PricingPlan, buyerID string) (*domain.Commission, error)
+	// CancelOrderCommissions cancels the commissions on a refunded or cancelled purchase,
+	// clawing back any that were already credited
+	CancelOrderCommissions(ctx context.Context, orderID string, reason string) error
+	// MatureCommissions approves pending commissions whose refund window has passed
+	MatureCommissions(ctx context.Context) (int, error)
+
+	// Creator review
+	ListCreatorCommissions(ctx context.Context, creatorID string, status domain.CommissionStatus) ([]*domain.Commission, error)
+	ApproveCommission(ctx context.Context, creatorID string, commissionID string) (*domain.Commission, error)
+	RejectCommission(ctx context.Context, creatorID string, commissionID string, reason string) (*domain.Commission, error)
 
-	GetMyStats(ctx context.Context, userID string) (map[string]interface{}, error)
+	// Analytics over [from, to)
+	GetMyStats(ctx context.Context, userID string, from, to time.Time) (*domain.AffiliateStats, error)
+	GetLeaderboard(ctx context.Context, creatorID string, programID string, from, to time.Time, limit int) ([]*domain.AffiliateLeaderboardEntry, error)
 }
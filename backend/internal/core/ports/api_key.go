@@ -0,0 +1,28 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByHash(ctx context.Context, hash string) (*domain.APIKey, error) // nil if not found
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) error
+	RevokeAllByUser(ctx context.Context, userID primitive.ObjectID) error
+	TouchLastUsed(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+type APIKeyService interface {
+	// CreateKey returns the stored key and the plaintext secret, which is never retrievable again
+	CreateKey(ctx context.Context, userID string, name string, scopes []domain.APIKeyScope, expiresAt *time.Time) (*domain.APIKey, string, error)
+	ListKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	RevokeKey(ctx context.Context, userID string, keyID string) error
+	// Authenticate resolves a plaintext key to its issuing user
+	Authenticate(ctx context.Context, rawKey string) (*domain.User, *domain.APIKey, error)
+}
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, event *domain.AuditEvent) error
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.AuditEvent, error)
+}
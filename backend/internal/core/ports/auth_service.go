@@ -7,8 +7,9 @@ import (
 
 type AuthService interface {
 	Register(ctx context.Context, email, password, fullName string) (*domain.User, error)
-	Login(ctx context.Context, email, password string) (string, string, error) // Returns accessToken, refreshToken, error
+	Login(ctx context.Context, email, password, clientIP string) (string, string, error) // Returns accessToken, refreshToken, error
 	RefreshToken(ctx context.Context, refreshToken string) (string, string, error)
 	ValidateToken(tokenString string) (*domain.User, error)
+	UnlockAccount(ctx context.Context, adminID string, userID string) error
 	// Add ForgotPassword, ResetPassword, etc. later
 }
@@ -0,0 +1,41 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// ConnectGateway manages the sellers' connected accounts at the gateway (Stripe Connect)
+type ConnectGateway interface {
+	// CreateAccount opens a connected account of the given type ("express", "standard") and returns its ID and state
+	CreateAccount(ctx context.Context, accountType string, email string, metadata map[string]string) (string, *domain.ConnectAccount, error)
+	GetAccount(ctx context.Context, accountID string) (*domain.ConnectAccount, error)
+	// CreateOnboardingLink returns a single-use URL where the seller fills in the account's details
+	CreateOnboardingLink(ctx context.Context, accountID string, refreshURL string, returnURL string) (string, error)
+	// CreateLoginLink returns a single-use URL to the account's Express dashboard
+	CreateLoginLink(ctx context.Context, accountID string) (string, error)
+
+	// OAuth linking of existing accounts
+	OAuthURL(state string, redirectURI string) string
+	ExchangeOAuthCode(ctx context.Context, code string) (string, error) // Returns the account ID
+	Deauthorize(ctx context.Context, accountID string) error
+
+	// ParseWebhook verifies a webhook delivery and translates it; nil means the event is not relevant
+	ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.ConnectEvent, error)
+}
+
+type ConnectService interface {
+	StartOnboarding(ctx context.Context, userID string, accountType string) (string, error) // Returns the onboarding URL
+	GenerateOAuthURL(ctx context.Context, userID string) (string, error)
+	ConsumeOAuthState(ctx context.Context, state string) (string, error)  // Returns the initiating user ID
+	HandleOAuthCallback(ctx context.Context, code string) (string, error) // Returns the connected account ID
+	ConnectUser(ctx context.Context, userID string, connectID string) error
+	GetConnectionStatus(ctx context.Context, userID string, refresh bool) (map[string]interface{}, error)
+	// SyncAccount reads the user's connected account from the gateway and stores its state
+	SyncAccount(ctx context.Context, userID string) (*domain.User, error)
+	CreateDashboardLoginLink(ctx context.Context, connectID string) (string, error)
+	DisconnectUser(ctx context.Context, userID string) error
+	HandleWebhook(ctx context.Context, payload []byte, headers http.Header) error
+}
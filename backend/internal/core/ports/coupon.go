@@ -3,6 +3,7 @@ package ports
 import (
 	"auth-payment-backend/internal/core/domain"
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -12,15 +13,70 @@ type CouponRepository interface {
 	GetCouponByCode(ctx context.Context, code string) (*domain.Coupon, error)
 	GetCouponByID(ctx context.Context, id primitive.ObjectID) (*domain.Coupon, error)
 	ListCoupons(ctx context.Context) ([]*domain.Coupon, error)
+	ListCouponsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.Coupon, error)
 	IncrementUsage(ctx context.Context, code string) error
+	// ReserveUsage increments used_count only while it is below max_uses. It reports whether a use was reserved.
+	ReserveUsage(ctx context.Context, couponID primitive.ObjectID) (bool, error)
+	ReleaseUsage(ctx context.Context, couponID primitive.ObjectID) error
+
+	// Redemption ledger
+	CreateRedemption(ctx context.Context, redemption *domain.CouponRedemption) error
+	GetRedemption(ctx context.Context, id primitive.ObjectID) (*domain.CouponRedemption, error)
+	GetRedemptionsByCoupon(ctx context.Context, couponID primitive.ObjectID) ([]*domain.CouponRedemption, error)
+	GetExpiredReservations(ctx context.Context, couponID primitive.ObjectID, now time.Time) ([]*domain.CouponRedemption, error)
+	// TransitionRedemption saves the redemption's status fields only if it is still in status `from`
+	TransitionRedemption(ctx context.Context, redemption *domain.CouponRedemption, from domain.RedemptionStatus) (bool, error)
+	// CountUserRedemptions counts reserved and confirmed uses
+	CountUserRedemptions(ctx context.Context, couponID primitive.ObjectID, userID primitive.ObjectID) (int64, error)
 	UpdateCoupon(ctx context.Context, coupon *domain.Coupon) error
+	// SetGatewayIDs stores the gateway coupon and promotion code created for a coupon
+	SetGatewayIDs(ctx context.Context, coupon *domain.Coupon) error
 	DeleteCoupon(ctx context.Context, id primitive.ObjectID) error
+
+	// Campaigns
+	CreateCoupons(ctx context.Context, coupons []*domain.Coupon) error
+	// ExistingCodes returns which of the codes are already taken
+	ExistingCodes(ctx context.Context, codes []string) ([]string, error)
+	GetCouponsByCampaign(ctx context.Context, campaignID primitive.ObjectID) ([]*domain.Coupon, error)
+	DeactivateCampaignCoupons(ctx context.Context, campaignID primitive.ObjectID) error
+	CreateCampaign(ctx context.Context, campaign *domain.CouponCampaign) error
+	GetCampaign(ctx context.Context, id primitive.ObjectID) (*domain.CouponCampaign, error)
+	GetCampaignsByCreator(ctx context.Context, creatorID primitive.ObjectID) ([]*domain.CouponCampaign, error)
+	UpdateCampaign(ctx context.Context, campaign *domain.CouponCampaign) error
+	// DeleteCampaign removes the campaign together with its codes
+	DeleteCampaign(ctx context.Context, id primitive.ObjectID) error
+	GetCampaignStats(ctx context.Context, campaignID primitive.ObjectID) (*domain.CouponCampaignStats, error)
 }
 
 type CouponService interface {
 	CreateCoupon(ctx context.Context, coupon *domain.Coupon) error
 	GetCoupon(ctx context.Context, id string) (*domain.Coupon, error)
-	ListCoupons(ctx context.Context) ([]*domain.Coupon, error)
-	ValidateCoupon(ctx context.Context, code string, planID string) (*domain.Coupon, float64, error) // Returns coupon and discount amount
-	ApplyCoupon(ctx context.Context, code string) error                                              // Increments usage
+	// ListCoupons lists the user's own coupons, or every coupon for admins
+	ListCoupons(ctx context.Context, userID string) ([]*domain.Coupon, error)
+	// ValidateCoupon enforces every rule of the coupon against the order and returns the discount amount
+	ValidateCoupon(ctx context.Context, code string, check *domain.CouponCheck) (*domain.Coupon, float64, error)
+	// ReserveCoupon holds one use of a validated coupon for a checkout; it fails if the limit was reached meanwhile.
+	// discount is in the checkout's currency.
+	ReserveCoupon(ctx context.Context, coupon *domain.Coupon, userID string, planID string, discount float64, currency string) (*domain.CouponRedemption, error)
+	// ConfirmRedemption marks a reservation as used once the payment succeeds
+	ConfirmRedemption(ctx context.Context, redemptionID string, paymentRef string) error
+	// ReleaseRedemption gives a reserved use back, e.g. when the payment fails
+	ReleaseRedemption(ctx context.Context, redemptionID string, reason string) error
+	// ListRedemptions reports the ledger of a coupon the user created (or any coupon for admins)
+	ListRedemptions(ctx context.Context, userID string, couponID string) ([]*domain.CouponRedemption, error)
+	// SyncGatewayCoupon creates the gateway coupon and promotion code a subscription in `currency` is discounted with
+	SyncGatewayCoupon(ctx context.Context, coupon *domain.Coupon, currency string) error
+
+	// Management by the coupon's creator (or an admin)
+	UpdateCoupon(ctx context.Context, userID string, id string, update *domain.Coupon) (*domain.Coupon, error)
+	DeleteCoupon(ctx context.Context, userID string, id string) error
+
+	// Campaigns
+	CreateCampaign(ctx context.Context, creatorID string, campaign *domain.CouponCampaign) error
+	ListCampaigns(ctx context.Context, creatorID string) ([]*domain.CouponCampaign, error)
+	GetCampaign(ctx context.Context, userID string, id string) (*domain.CouponCampaign, error)
+	// GetCampaignCodes returns every coupon generated by the campaign, e.g. for CSV export
+	GetCampaignCodes(ctx context.Context, userID string, id string) ([]*domain.Coupon, error)
+	GetCampaignStats(ctx context.Context, userID string, id string) (*domain.CouponCampaignStats, error)
+	DeactivateCampaign(ctx context.Context, userID string, id string) (*domain.CouponCampaign, error)
 }
@@ -0,0 +1,9 @@
+package ports
+
+import "context"
+
+// FXRateProvider supplies exchange rates for deriving prices and converting payouts
+type FXRateProvider interface {
+	// Rate returns how many units of `to` one unit of `from` buys
+	Rate(ctx context.Context, from string, to string) (float64, error)
+}
@@ -2,19 +2,64 @@ package ports
 
 import (
 	"context"
+	"errors"
 
 	"auth-payment-backend/internal/core/domain"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrInvoiceExists is returned by CreateInvoice when the payment already has a non-void invoice
+var ErrInvoiceExists = errors.New("payment already has an invoice")
+
 type InvoiceRepository interface {
 	CreateInvoice(ctx context.Context, invoice *domain.Invoice) error
 	GetInvoice(ctx context.Context, invoiceID primitive.ObjectID) (*domain.Invoice, error)
 	GetInvoicesByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Invoice, error)
+	// GetInvoiceByTransaction returns the payment's current, non-void invoice
+	GetInvoiceByTransaction(ctx context.Context, txID primitive.ObjectID) (*domain.Invoice, error)
+	ListInvoices(ctx context.Context, filter domain.InvoiceFilter) ([]*domain.Invoice, error)
+	// TransitionInvoice saves the invoice only if it is still in status `from`
+	TransitionInvoice(ctx context.Context, invoice *domain.Invoice, from domain.InvoiceStatus) (bool, error)
+
+	// Numbering: an atomic per-seller counter
+	NextInvoiceSequence(ctx context.Context, sellerKey string) (int64, error)
+	// ReleaseInvoiceSequence hands `seq` back if it is still the last number issued. It reports whether it did.
+	ReleaseInvoiceSequence(ctx context.Context, sellerKey string, seq int64) (bool, error)
+
+	// Credit notes (numbered through the same counters under their own keys)
+	CreateCreditNote(ctx context.Context, note *domain.CreditNote) error
+	GetCreditNote(ctx context.Context, id primitive.ObjectID) (*domain.CreditNote, error)
+	GetCreditNotesByInvoice(ctx context.Context, invoiceID primitive.ObjectID) ([]*domain.CreditNote, error)
+	GetCreditNoteByRefund(ctx context.Context, refundRef string) (*domain.CreditNote, error)
 }
 
 type InvoiceService interface {
 	GenerateInvoiceForTransaction(ctx context.Context, txID string) (*domain.Invoice, error)
-	GetInvoicePDF(ctx context.Context, invoiceID string) ([]byte, error) // Returns PDF bytes
+	// GenerateInvoiceForPayment issues the invoice of a succeeded payment, once
+	GenerateInvoiceForPayment(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error)
+
+	// Access is limited to the invoice's buyer, its seller and admins
+	GetInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, error)
+	GetInvoicePDF(ctx context.Context, userID string, invoiceID string) ([]byte, error) // Returns PDF bytes
+	ListInvoices(ctx context.Context, userID string, view domain.InvoiceView, filter domain.InvoiceFilter) ([]*domain.Invoice, error)
+
+	// VoidInvoice cancels an issued invoice; only its seller or an admin may do so
+	VoidInvoice(ctx context.Context, userID string, invoiceID string, reason string) (*domain.Invoice, error)
+	// ReissueInvoice voids the invoice and issues a replacement with a new number from the current payment data
+	ReissueInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, error)
+
+	// IssueRefundCreditNote credits `amount` (tax included) of the payment's invoice after a refund
+	IssueRefundCreditNote(ctx context.Context, payment *domain.Payment, amount float64, refundRef string, note string) (*domain.CreditNote, error)
+	// IssueAdjustmentCreditNote credits the net amounts of `items` plus reversed tax (admins only)
+	IssueAdjustmentCreditNote(ctx context.Context, adminID string, invoiceID string, note string, items []domain.InvoiceItem) (*domain.CreditNote, error)
+	GetCreditNote(ctx context.Context, userID string, creditNoteID string) (*domain.CreditNote, error)
+	GetCreditNotePDF(ctx context.Context, userID string, creditNoteID string) ([]byte, error)
+	ListCreditNotes(ctx context.Context, userID string, invoiceID string) ([]*domain.CreditNote, error)
+}
+
+// InvoiceRenderer turns an invoice into a printable document
+type InvoiceRenderer interface {
+	RenderPDF(invoice *domain.Invoice) ([]byte, error)
+	RenderCreditNotePDF(note *domain.CreditNote) ([]byte, error)
 }
@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// LoginAttemptStore persists failed-login counters. Implementations must make
+// IncrementFailure atomic so concurrent attempts are all counted.
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (*domain.LoginAttempt, error) // nil if no record
+	// IncrementFailure bumps the counter for key. Failures older than windowStart are forgotten first.
+	IncrementFailure(ctx context.Context, key string, at time.Time, windowStart time.Time) (*domain.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"context"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// OAuthProvider is an external identity provider (Google via OIDC, GitHub via OAuth2)
+type OAuthProvider interface {
+	Name() string
+	// AuthCodeURL builds the authorization redirect. codeChallenge is the S256 PKCE challenge.
+	AuthCodeURL(state, nonce, codeChallenge string) string
+	// Exchange trades the code for tokens and returns the verified identity.
+	// OIDC providers must check the ID token nonce against the given value.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.ExternalIdentity, error)
+}
+
+// OAuthProviders is keyed by provider name
+type OAuthProviders map[string]OAuthProvider
+
+type OAuthStateRepository interface {
+	Save(ctx context.Context, state *domain.OAuthState) error
+	// Consume returns and deletes an unexpired state, or nil if it is unknown, used or expired
+	Consume(ctx context.Context, state string) (*domain.OAuthState, error)
+}
+
+type SocialAuthService interface {
+	StartLogin(ctx context.Context, provider string) (string, string, error)                 // Returns authorization URL, state
+	CompleteLogin(ctx context.Context, provider, code, state string) (string, string, error) // Returns accessToken, refreshToken
+}
@@ -0,0 +1,28 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PaymentRepository interface {
+	// CreatePayment keeps a preset ID, so a checkout can reference the payment before it is stored
+	CreatePayment(ctx context.Context, payment *domain.Payment) error
+	GetPayment(ctx context.Context, id primitive.ObjectID) (*domain.Payment, error)
+	// GetPaymentByGatewayRef finds a payment by its gateway order or captured transaction ID
+	GetPaymentByGatewayRef(ctx context.Context, gateway domain.PaymentGateway, ref string) (*domain.Payment, error)
+	UpdatePayment(ctx context.Context, payment *domain.Payment) error
+	// SetInvoice and SetCommission only write their own fields, so they never undo a concurrent transition
+	SetInvoice(ctx context.Context, id primitive.ObjectID, invoiceID primitive.ObjectID) error
+	SetCommission(ctx context.Context, id primitive.ObjectID, affiliateID primitive.ObjectID, commission float64) error
+	// ListPaymentsByGateway returns a gateway's payments, newest first; empty status matches all
+	ListPaymentsByGateway(ctx context.Context, gateway domain.PaymentGateway, status domain.PaymentStatus) ([]*domain.Payment, error)
+	// ListExpiredPayments returns pending payments whose deadline passed before `now`
+	ListExpiredPayments(ctx context.Context, now time.Time) ([]*domain.Payment, error)
+	// TransitionPayment saves the payment only if it is still in status `from`
+	TransitionPayment(ctx context.Context, payment *domain.Payment, from domain.PaymentStatus) (bool, error)
+}
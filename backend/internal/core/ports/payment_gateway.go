@@ -2,12 +2,31 @@ package ports
 
 import (
 	"context"
+	"net/http"
+
+	"auth-payment-backend/internal/core/domain"
 )
 
+// CheckoutGateway collects one-off payments: an order is created at checkout, approved by the
+// buyer, captured, and possibly refunded. The outcome is reported back through webhooks.
+type CheckoutGateway interface {
+	Name() domain.PaymentGateway
+	CreateOrder(ctx context.Context, order domain.GatewayOrder) (*domain.GatewayCheckout, error)
+	// CaptureOrder collects an approved order; capturing a completed order again is not an error
+	CaptureOrder(ctx context.Context, orderRef string) (*domain.GatewayCapture, error)
+	// RefundCapture refunds amount (in the capture's currency) of a captured payment
+	RefundCapture(ctx context.Context, captureRef string, amount float64, currency string, metadata map[string]string) (*domain.GatewayRefund, error)
+	// ParseWebhook verifies a webhook delivery and translates it; nil means the event is not relevant
+	ParseWebhook(ctx context.Context, payload []byte, headers http.Header) (*domain.GatewayEvent, error)
+}
+
+// CheckoutGateways is keyed by gateway name
+type CheckoutGateways map[domain.PaymentGateway]CheckoutGateway
+
+// PaymentGateway is the primary gateway: besides checkouts it holds the product catalog,
+// customers, subscriptions and coupons
 type PaymentGateway interface {
-	// core payments
-	CreatePaymentIntent(ctx context.Context, amount float64, currency string, metadata map[string]string, destinationAccountID string, applicationFeeAmount int64) (string, error)
-	ConfirmPayment(ctx context.Context, paymentID string) error
+	CheckoutGateway
 
 	// products & prices (sync)
 	CreateProduct(ctx context.Context, name string, description string) (string, error)
@@ -18,6 +37,14 @@ type PaymentGateway interface {
 
 	// subscriptions
 	CreateCustomer(ctx context.Context, email string, name string) (string, error)
-	CreateSubscription(ctx context.Context, customerID string, priceID string, metadata map[string]string, destinationAccountID string, applicationFeePercent float64) (string, error)
+	DeleteCustomer(ctx context.Context, customerID string) error
+	// CreateSubscription returns the gateway subscription with the payment of its first invoice. Webhooks
+	// about that payment carry its ref, not the subscription's metadata.
+	// A non-nil tax is added to (or, if inclusive, shown within) every invoice of the subscription.
+	CreateSubscription(ctx context.Context, customerID string, priceID string, promotionCodeID string, tax *domain.TaxResult, metadata map[string]string, destinationAccountID string, applicationFeePercent float64) (*domain.GatewaySubscription, error)
 	CancelSubscription(ctx context.Context, subID string) error
+
+	// coupons (sync); fixed-amount coupons are created in `currency`
+	CreateCoupon(ctx context.Context, coupon *domain.Coupon, currency string) (string, error)
+	CreatePromotionCode(ctx context.Context, couponID string, code string) (string, error)
 }
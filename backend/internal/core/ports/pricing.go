@@ -23,6 +23,10 @@ type PricingService interface {
 
 	// Complex Logic
 	CalculateFinalPrice(ctx context.Context, planID string, couponCode string) (float64, error)
+	// PriceIn prices the plan in a currency: its explicit price there, or the base price converted at the current rate
+	PriceIn(ctx context.Context, plan *domain.PricingPlan, currency string) (*domain.PlanPrice, error)
+	// SetCurrencyPrice sets the explicit price in a currency; nil removes it. Only the plan's creator or an admin may.
+	SetCurrencyPrice(ctx context.Context, userID string, id string, currency string, amount *float64) (*domain.PricingPlan, error)
 
 	UpdatePlan(ctx context.Context, id string, name string, description string, price *float64, interval *string) error
 	DeletePlan(ctx context.Context, id string) error
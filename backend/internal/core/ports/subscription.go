@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"context"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SubscriptionRepository interface {
+	CreateSubscription(ctx context.Context, sub *domain.Subscription) error
+	GetByStripeID(ctx context.Context, stripeSubID string) (*domain.Subscription, error)
+	GetByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Subscription, error)
+	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Subscription, error)
+	UpdateSubscription(ctx context.Context, sub *domain.Subscription) error
+
+	// Billing cycle ledger
+	CreateCycle(ctx context.Context, cycle *domain.SubscriptionCycle) error
+	GetCycleByInvoice(ctx context.Context, subscriptionID primitive.ObjectID, invoiceRef string) (*domain.SubscriptionCycle, error)
+	GetCycles(ctx context.Context, subscriptionID primitive.ObjectID) ([]*domain.SubscriptionCycle, error)
+}
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// TaxCalculator decides the tax of a charge from the buyer's billing address
+type TaxCalculator interface {
+	Calculate(ctx context.Context, req domain.TaxRequest) (*domain.TaxResult, error)
+	// NormalizeTaxID checks the format of a VAT/GST number for the country and returns it canonicalized
+	NormalizeTaxID(country string, taxID string) (string, error)
+}
@@ -9,6 +9,10 @@ type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetByID(ctx context.Context, id string) (*domain.User, error)
+	GetByProviderIdentity(ctx context.Context, provider, subject string) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
-	UpdateStripeConnect(ctx context.Context, userID string, connectID string, status string) error
+	// GetByStripeConnectID returns nil if no user has the connected account
+	GetByStripeConnectID(ctx context.Context, connectID string) (*domain.User, error)
+	// UpdateStripeConnect links (or with an empty connectID, unlinks) a connected account; a nil account clears its state
+	UpdateStripeConnect(ctx context.Context, userID string, connectID string, status string, account *domain.ConnectAccount) error
 }
@@ -11,13 +11,14 @@ import (
 type WalletRepository interface {
 	// Wallet
 	CreateWallet(ctx context.Context, wallet *domain.Wallet) error
-	GetWalletByUserID(ctx context.Context, userID primitive.ObjectID) (*domain.Wallet, error)
+	GetWallet(ctx context.Context, userID primitive.ObjectID, currency string) (*domain.Wallet, error)
+	GetWalletsByUserID(ctx context.Context, userID primitive.ObjectID) ([]*domain.Wallet, error)
 	GetWalletByID(ctx context.Context, walletID primitive.ObjectID) (*domain.Wallet, error)
 	UpdateBalance(ctx context.Context, walletID primitive.ObjectID, newBalance float64) error
 
 	// Ledger / Transactions
 	CreateTransaction(ctx context.Context, tx *domain.WalletTransaction) error
-	GetTransactions(ctx context.Context, walletID primitive.ObjectID) ([]*domain.WalletTransaction, error)
+	GetTransactions(ctx context.Context, walletIDs []primitive.ObjectID) ([]*domain.WalletTransaction, error)
 
 	// Payouts
 	CreatePayoutRequest(ctx context.Context, req *domain.PayoutRequest) error
@@ -27,13 +28,16 @@ type WalletRepository interface {
 }
 
 type WalletService interface {
-	GetBalance(ctx context.Context, userID string) (*domain.Wallet, error)
-	GetTransactions(ctx context.Context, userID string) ([]*domain.WalletTransaction, error)
+	// GetBalance returns the user's wallet in every currency they hold
+	GetBalance(ctx context.Context, userID string) ([]*domain.Wallet, error)
+	// GetTransactions lists the ledger of one currency, or of all with an empty currency
+	GetTransactions(ctx context.Context, userID string, currency string) ([]*domain.WalletTransaction, error)
 
 	// Core Logic
-	CreditWallet(ctx context.Context, userID string, amount float64, txType domain.TransactionType, refID string, desc string) error
-	DebitWallet(ctx context.Context, userID string, amount float64, txType domain.TransactionType, refID string, desc string) error
+	CreditWallet(ctx context.Context, userID string, amount float64, currency string, txType domain.TransactionType, refID string, desc string) error
+	DebitWallet(ctx context.Context, userID string, amount float64, currency string, txType domain.TransactionType, refID string, desc string) error
 
-	// Payouts
-	RequestPayout(ctx context.Context, userID string, amount float64, method domain.PayoutMethod) error
+	// Payouts. The amount is debited from the wallet in currency and paid out in payoutCurrency
+	// (empty = the same), converted at the current rate.
+	RequestPayout(ctx context.Context, userID string, amount float64, currency string, payoutCurrency string, method domain.PayoutMethod) (*domain.PayoutRequest, error)
 }
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTTL = 24 * time.Hour
+
+type AccountServiceImpl struct {
+	userRepo      ports.UserRepository
+	walletRepo    ports.WalletRepository
+	subRepo       ports.SubscriptionRepository
+	apiKeyRepo    ports.APIKeyRepository
+	affiliateRepo ports.AffiliateRepository
+	gateway       ports.PaymentGateway
+	connectSvc    ports.ConnectService
+	mailer        ports.Mailer
+	tax           ports.TaxCalculator
+	tokenService  *TokenService
+	config        *config.Config
+}
+
+func NewAccountService(userRepo ports.UserRepository, walletRepo ports.WalletRepository, subRepo ports.SubscriptionRepository, apiKeyRepo ports.APIKeyRepository, affiliateRepo ports.AffiliateRepository, gateway ports.PaymentGateway, connectSvc ports.ConnectService, mailer ports.Mailer, tax ports.TaxCalculator, tokenService *TokenService, cfg *config.Config) ports.AccountService {
+	return &AccountServiceImpl{
+		userRepo:      userRepo,
+		walletRepo:    walletRepo,
+		subRepo:       subRepo,
+		apiKeyRepo:    apiKeyRepo,
+		affiliateRepo: affiliateRepo,
+		gateway:       gateway,
+		connectSvc:    connectSvc,
+		mailer:        mailer,
+		tax:           tax,
+		tokenService:  tokenService,
+		config:        cfg,
+	}
+}
+
+func (s *AccountServiceImpl) getActiveUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user.DeletedAt != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// checkPassword verifies the current password. Accounts created through social
+// login have no password and skip the check.
+func checkPassword(user *domain.User, password string) error {
+	if user.Password == "" {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return errors.New("invalid password")
+	}
+	return nil
+}
+
+func (s *AccountServiceImpl) UpdateProfile(ctx context.Context, userID string, fullName string) (*domain.User, error) {
+	fullName = strings.TrimSpace(fullName)
+	if fullName == "" {
+		return nil, errors.New("full name is required")
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.FullName = fullName
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AccountServiceImpl) UpdateBillingAddress(ctx context.Context, userID string, address *domain.BillingAddress) (*domain.User, error) {
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if address != nil {
+		address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+		if len(address.Country) != 2 {
+			return nil, errors.New("country must be a two-letter ISO code")
+		}
+		if strings.TrimSpace(address.Line1) == "" || strings.TrimSpace(address.City) == "" {
+			return nil, errors.New("address line and city are required")
+		}
+		if address.TaxID != "" {
+			taxID, err := s.tax.NormalizeTaxID(address.Country, address.TaxID)
+			if err != nil {
+				return nil, err
+			}
+			address.TaxID = taxID
+		}
+	}
+
+	user.BillingAddress = address
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AccountServiceImpl) UpdatePreferredCurrency(ctx context.Context, userID string, currency string) (*domain.User, error) {
+	currency = domain.NormalizeCurrency(currency)
+	if currency != "" && !isCurrencyCode(currency) {
+		return nil, errors.New("currency must be a three-letter ISO code")
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PreferredCurrency = currency
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func isCurrencyCode(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *AccountServiceImpl) RequestEmailChange(ctx context.Context, userID string, newEmail string, password string) error {
+	newEmail = normalizeEmail(newEmail)
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return err
+	}
+	if newEmail == user.Email {
+		return errors.New("new email is the same as the current one")
+	}
+
+	existing, err := s.userRepo.GetByEmail(ctx, newEmail)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New("email already exists")
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(emailChangeTTL)
+
+	user.PendingEmail = newEmail
+	user.EmailChangeTokenHash = hashToken(token)
+	user.EmailChangeExpiresAt = &expiresAt
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Confirm your new email address by opening:\n%s/settings/account/confirm-email?token=%s\n\nThe link expires in 24 hours.",
+		strings.TrimRight(s.config.FrontendURL, "/"), token)
+	return s.mailer.Send(ctx, newEmail, "Confirm your new email address", body)
+}
+
+func (s *AccountServiceImpl) ConfirmEmailChange(ctx context.Context, userID string, token string) (*domain.User, error) {
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PendingEmail == "" || user.EmailChangeExpiresAt == nil || user.EmailChangeExpiresAt.Before(time.Now()) {
+		return nil, errors.New("no pending email change")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(user.EmailChangeTokenHash)) != 1 {
+		return nil, errors.New("invalid confirmation token")
+	}
+
+	// The address may have been claimed since the request was made
+	existing, err := s.userRepo.GetByEmail(ctx, user.PendingEmail)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("email already exists")
+	}
+
+	user.Email = user.PendingEmail
+	user.IsEmailVerified = true
+	user.PendingEmail = ""
+	user.EmailChangeTokenHash = ""
+	user.EmailChangeExpiresAt = nil
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AccountServiceImpl) ChangePassword(ctx context.Context, userID string, currentPassword string, newPassword string) (string, string, error) {
+	if len(newPassword) < 6 {
+		return "", "", errors.New("password must be at least 6 characters")
+	}
+
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkPassword(user, currentPassword); err != nil {
+		return "", "", err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	user.Password = string(hashed)
+	user.TokenVersion++ // Revokes every other session
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return "", "", err
+	}
+
+	return s.tokenService.GenerateTokens(user)
+}
+
+func (s *AccountServiceImpl) DeleteAccount(ctx context.Context, userID string, password string) error {
+	user, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return err
+	}
+
+	// 1. Block while money or billing is still in flight
+	wallets, err := s.walletRepo.GetWalletsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, wallet := range wallets {
+		if wallet.Balance > 0 {
+			return errors.New("withdraw your wallet balance before deleting your account")
+		}
+	}
+
+	payouts, err := s.walletRepo.GetPayoutsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payouts {
+		if p.Status == domain.PayoutStatusPending {
+			return errors.New("account has open payout requests")
+		}
+	}
+
+	// Commissions still pending would be approved into a wallet nobody can withdraw from
+	commissions, err := s.affiliateRepo.GetCommissionsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, comm := range commissions {
+		if comm.Status == domain.CommissionStatusPending {
+			return errors.New("account has pending affiliate commissions")
+		}
+	}
+
+	subs, err := s.subRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if sub.BlocksAccountDeletion() {
+			return errors.New("cancel your active subscriptions before deleting your account")
+		}
+	}
+
+	// 2. Tear down gateway state. Subscriptions that are only winding down
+	// (cancel at period end, past due) are cancelled immediately.
+	for _, sub := range subs {
+		if sub.Status == domain.SubscriptionStatusCanceled || sub.StripeSubID == "" {
+			continue
+		}
+		if err := s.gateway.CancelSubscription(ctx, sub.StripeSubID); err != nil {
+			return fmt.Errorf("failed to cancel subscription: %w", err)
+		}
+		sub.Status = domain.SubscriptionStatusCanceled
+		if err := s.subRepo.UpdateSubscription(ctx, sub); err != nil {
+			log.Printf("Failed to mark subscription %s canceled: %v", sub.ID.Hex(), err)
+		}
+	}
+	if user.StripeCustomerID != "" {
+		if err := s.gateway.DeleteCustomer(ctx, user.StripeCustomerID); err != nil {
+			return fmt.Errorf("failed to delete payment customer: %w", err)
+		}
+	}
+
+	if user.StripeConnectID != "" {
+		if err := s.connectSvc.DisconnectUser(ctx, userID); err != nil {
+			return fmt.Errorf("failed to disconnect payout account: %w", err)
+		}
+		// Keep the final save below from writing the link back
+		user.StripeConnectID = ""
+		user.StripeConnectStatus = domain.ConnectStatusDisconnected
+		user.StripeConnectAccount = nil
+	}
+
+	// Stop the user's referral links and program memberships from earning
+	if err := s.affiliateRepo.DisableLinksByUser(ctx, user.ID, time.Now()); err != nil {
+		return fmt.Errorf("failed to disable affiliate links: %w", err)
+	}
+	enrollments, err := s.affiliateRepo.GetEnrollmentsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, enrollment := range enrollments {
+		if enrollment.Status == domain.EnrollmentWithdrawn {
+			continue
+		}
+		enrollment.Status = domain.EnrollmentWithdrawn
+		enrollment.UpdatedAt = time.Now()
+		if err := s.affiliateRepo.UpdateEnrollment(ctx, enrollment); err != nil {
+			return fmt.Errorf("failed to withdraw affiliate enrollment: %w", err)
+		}
+	}
+
+	// API keys authenticate without a session, so the token version does not revoke them
+	if err := s.apiKeyRepo.RevokeAllByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke api keys: %w", err)
+	}
+
+	// 3. Anonymize. The user ID stays so payments, invoices and ledger entries remain intact.
+	now := time.Now()
+	user.Email = fmt.Sprintf("deleted-%s@deleted.invalid", user.ID.Hex())
+	user.FullName = "Deleted User"
+	user.Password = ""
+	user.IsEmailVerified = false
+	user.Identities = nil
+	user.BillingAddress = nil
+	user.StripeCustomerID = ""
+	user.PendingEmail = ""
+	user.EmailChangeTokenHash = ""
+	user.EmailChangeExpiresAt = nil
+	user.TokenVersion++
+	user.DeletedAt = &now
+
+	return s.userRepo.Update(ctx, user)
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maturationBatchSize bounds how many commissions one scheduler run approves
+const maturationBatchSize = 200
+
+func (s *AffiliateServiceImpl) MatureCommissions(ctx context.Context) (int, error) {
+	due, err := s.repo.GetMaturedCommissions(ctx, time.Now(), maturationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	approved := 0
+	for _, comm := range due {
+		ok, err := s.approve(ctx, comm, "refund window passed")
+		if err != nil {
+			log.Printf("Failed to approve commission %s: %v", comm.ID.Hex(), err)
+			continue
+		}
+		if ok {
+			approved++
+		}
+	}
+	return approved, nil
+}
+
+func (s *AffiliateServiceImpl) ListCreatorCommissions(ctx context.Context, creatorID string, status domain.CommissionStatus) ([]*domain.Commission, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetCommissionsByCreator(ctx, cOID, status)
+}
+
+func (s *AffiliateServiceImpl) ApproveCommission(ctx context.Context, creatorID string, commissionID string) (*domain.Commission, error) {
+	comm, err := s.creatorCommission(ctx, creatorID, commissionID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.approve(ctx, comm, "approved by creator")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("only pending commissions can be approved")
+	}
+
+	// Tier-2 commissions follow the review of the sale they derive from
+	s.forEachPendingChild(ctx, comm, func(child *domain.Commission) error {
+		_, err := s.approve(ctx, child, "parent commission approved")
+		return err
+	})
+	return comm, nil
+}
+
+func (s *AffiliateServiceImpl) RejectCommission(ctx context.Context, creatorID string, commissionID string, reason string) (*domain.Commission, error) {
+	comm, err := s.creatorCommission(ctx, creatorID, commissionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.reject(ctx, comm, reason)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("only pending commissions can be rejected")
+	}
+
+	s.forEachPendingChild(ctx, comm, func(child *domain.Commission) error {
+		_, err := s.reject(ctx, child, "parent commission rejected")
+		return err
+	})
+	return comm, nil
+}
+
+func (s *AffiliateServiceImpl) reject(ctx context.Context, comm *domain.Commission, reason string) (bool, error) {
+	now := time.Now()
+	comm.Status = domain.CommissionStatusRejected
+	comm.RejectedAt = &now
+	comm.StatusReason = reason
+	comm.UpdatedAt = now
+	return s.repo.TransitionCommission(ctx, comm, domain.CommissionStatusPending)
+}
+
+// forEachPendingChild applies fn to the pending tier-2 commissions of parent, logging failures
+func (s *AffiliateServiceImpl) forEachPendingChild(ctx context.Context, parent *domain.Commission, fn func(*domain.Commission) error) {
+	children, err := s.repo.GetChildCommissions(ctx, parent.ID)
+	if err != nil {
+		log.Printf("Failed to load tier-2 commissions of %s: %v", parent.ID.Hex(), err)
+		return
+	}
+	for _, child := range children {
+		if child.Status != domain.CommissionStatusPending {
+			continue
+		}
+		if err := fn(child); err != nil {
+			log.Printf("Failed to update tier-2 commission %s: %v", child.ID.Hex(), err)
+		}
+	}
+}
+
+func (s *AffiliateServiceImpl) CancelOrderCommissions(ctx context.Context, orderID string, reason string) error {
+	oOID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return err
+	}
+	comms, err := s.repo.GetCommissionsByOrder(ctx, oOID)
+	if err != nil {
+		return err
+	}
+
+	for _, comm := range comms {
+		from := comm.Status
+		if from != domain.CommissionStatusPending && from != domain.CommissionStatusApproved {
+			continue
+		}
+
+		now := time.Now()
+		comm.Status = domain.CommissionStatusCancelled
+		comm.CancelledAt = &now
+		comm.StatusReason = reason
+		comm.UpdatedAt = now
+
+		ok, err := s.repo.TransitionCommission(ctx, comm, from)
+		if err != nil {
+			return err
+		}
+		if !ok || from != domain.CommissionStatusApproved {
+			continue
+		}
+
+		// Already credited: take it back
+		err = s.walletSvc.DebitWallet(ctx, comm.AffiliateID.Hex(), comm.EarnedAmount, comm.Currency, domain.TransactionTypeRefund, comm.ID.Hex(), fmt.Sprintf("Commission reversal for Order %s", orderID))
+		if err != nil {
+			s.revert(ctx, comm, from)
+			return err
+		}
+	}
+	return nil
+}
+
+// approve moves a pending commission to approved and credits the affiliate.
+// It reports false if the commission was no longer pending.
+func (s *AffiliateServiceImpl) approve(ctx context.Context, comm *domain.Commission, reason string) (bool, error) {
+	now := time.Now()
+	comm.Status = domain.CommissionStatusApproved
+	comm.ApprovedAt = &now
+	comm.StatusReason = reason
+	comm.UpdatedAt = now
+
+	ok, err := s.repo.TransitionCommission(ctx, comm, domain.CommissionStatusPending)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	err = s.walletSvc.CreditWallet(
+		ctx,
+		comm.AffiliateID.Hex(),
+		comm.EarnedAmount,
+		comm.Currency,
+		domain.TransactionTypeCommission,
+		comm.ID.Hex(),
+		fmt.Sprintf("Commission for Order %s", comm.OrderID.Hex()),
+	)
+	if err != nil {
+		s.revert(ctx, comm, domain.CommissionStatusPending)
+		return false, err
+	}
+	return true, nil
+}
+
+// revert puts a commission back into `to` after a failed wallet operation
+func (s *AffiliateServiceImpl) revert(ctx context.Context, comm *domain.Commission, to domain.CommissionStatus) {
+	from := comm.Status
+	comm.Status = to
+	comm.UpdatedAt = time.Now()
+	switch from {
+	case domain.CommissionStatusApproved:
+		comm.ApprovedAt = nil
+	case domain.CommissionStatusCancelled:
+		comm.CancelledAt = nil
+	}
+	if _, err := s.repo.TransitionCommission(ctx, comm, from); err != nil {
+		log.Printf("Failed to revert commission %s to %s: %v", comm.ID.Hex(), to, err)
+	}
+}
+
+// creatorCommission loads a commission the creator is allowed to review
+func (s *AffiliateServiceImpl) creatorCommission(ctx context.Context, creatorID string, commissionID string) (*domain.Commission, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := primitive.ObjectIDFromHex(commissionID)
+	if err != nil {
+		return nil, errors.New("invalid commission ID")
+	}
+	comm, err := s.repo.GetCommission(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if comm == nil || comm.CreatorID != cOID {
+		return nil, errors.New("commission not found")
+	}
+	return comm, nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *AffiliateServiceImpl) CreateProgram(ctx context.Context, creatorID string, program *domain.AffiliateProgram) (*domain.AffiliateProgram, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProgram(program); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	program.ID = primitive.NilObjectID
+	program.CreatorID = cOID
+	program.SetStatus(domain.ProgramStatusActive)
+	program.CreatedAt = now
+	program.UpdatedAt = now
+
+	if err := s.repo.CreateProgram(ctx, program); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (s *AffiliateServiceImpl) GetProgram(ctx context.Context, programID string) (*domain.AffiliateProgram, error) {
+	pOID, err := primitive.ObjectIDFromHex(programID)
+	if err != nil {
+		return nil, errors.New("invalid program ID")
+	}
+	program, err := s.repo.GetProgram(ctx, pOID)
+	if err != nil {
+		return nil, errors.New("program not found")
+	}
+	return program, nil
+}
+
+func (s *AffiliateServiceImpl) ListPrograms(ctx context.Context, creatorID string) ([]*domain.AffiliateProgram, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetProgramsByCreator(ctx, cOID)
+}
+
+func (s *AffiliateServiceImpl) UpdateProgram(ctx context.Context, creatorID string, programID string, terms *domain.AffiliateProgram) (*domain.AffiliateProgram, error) {
+	program, err := s.ownedProgram(ctx, creatorID, programID)
+	if err != nil {
+		return nil, err
+	}
+	if program.Status == domain.ProgramStatusClosed {
+		return nil, errors.New("closed programs cannot be changed")
+	}
+	if err := validateProgram(terms); err != nil {
+		return nil, err
+	}
+
+	program.Name = terms.Name
+	program.Terms = terms.Terms
+	program.ProductID = terms.ProductID
+	program.CommissionRate = terms.CommissionRate
+	program.EnrollmentMode = terms.EnrollmentMode
+	program.AttributionModel = terms.AttributionModel
+	program.AttributionWindowDays = terms.AttributionWindowDays
+	program.RefundWindowDays = terms.RefundWindowDays
+	program.Tier2Rate = terms.Tier2Rate
+	program.Tier2MaxAmount = terms.Tier2MaxAmount
+	program.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateProgram(ctx, program); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (s *AffiliateServiceImpl) SetProgramStatus(ctx context.Context, creatorID string, programID string, status domain.ProgramStatus) (*domain.AffiliateProgram, error) {
+	program, err := s.ownedProgram(ctx, creatorID, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case domain.ProgramStatusActive, domain.ProgramStatusPaused, domain.ProgramStatusClosed:
+	default:
+		return nil, errors.New("invalid program status")
+	}
+	if program.Status == domain.ProgramStatusClosed {
+		return nil, errors.New("program is closed")
+	}
+
+	program.SetStatus(status)
+	program.UpdatedAt = time.Now()
+	if err := s.repo.UpdateProgram(ctx, program); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (s *AffiliateServiceImpl) Enroll(ctx context.Context, userID string, programID string, message string) (*domain.AffiliateEnrollment, error) {
+	uOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	program, err := s.GetProgram(ctx, programID)
+	if err != nil {
+		return nil, err
+	}
+	if !program.IsActive {
+		return nil, errors.New("program is not accepting affiliates")
+	}
+	if program.CreatorID == uOID {
+		return nil, errors.New("you cannot join your own program")
+	}
+
+	existing, err := s.repo.GetUserEnrollment(ctx, program.ID, uOID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("you have already applied to this program")
+	}
+
+	now := time.Now()
+	enrollment := &domain.AffiliateEnrollment{
+		ProgramID: program.ID,
+		CreatorID: program.CreatorID,
+		UserID:    uOID,
+		Status:    domain.EnrollmentPending,
+		Message:   message,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !program.RequiresApproval() {
+		enrollment.Status = domain.EnrollmentApproved
+		enrollment.DecidedAt = &now
+	}
+
+	if err := s.repo.CreateEnrollment(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (s *AffiliateServiceImpl) ListMyEnrollments(ctx context.Context, userID string) ([]*domain.AffiliateEnrollment, error) {
+	uOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetEnrollmentsByUser(ctx, uOID)
+}
+
+func (s *AffiliateServiceImpl) ListEnrollments(ctx context.Context, creatorID string, programID string, status domain.EnrollmentStatus) ([]*domain.AffiliateEnrollment, error) {
+	program, err := s.ownedProgram(ctx, creatorID, programID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetEnrollmentsByProgram(ctx, program.ID, status)
+}
+
+func (s *AffiliateServiceImpl) DecideEnrollment(ctx context.Context, creatorID string, enrollmentID string, approve bool, reason string) (*domain.AffiliateEnrollment, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	eOID, err := primitive.ObjectIDFromHex(enrollmentID)
+	if err != nil {
+		return nil, errors.New("invalid enrollment ID")
+	}
+	enrollment, err := s.repo.GetEnrollment(ctx, eOID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil || enrollment.CreatorID != cOID {
+		return nil, errors.New("enrollment not found")
+	}
+	if enrollment.Status != domain.EnrollmentPending {
+		return nil, errors.New("enrollment has already been decided")
+	}
+
+	now := time.Now()
+	enrollment.Status = domain.EnrollmentRejected
+	if approve {
+		enrollment.Status = domain.EnrollmentApproved
+	}
+	enrollment.DecisionReason = reason
+	enrollment.DecidedAt = &now
+	enrollment.UpdatedAt = now
+
+	if err := s.repo.UpdateEnrollment(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// ownedProgram loads a program and checks that creatorID owns it
+func (s *AffiliateServiceImpl) ownedProgram(ctx context.Context, creatorID string, programID string) (*domain.AffiliateProgram, error) {
+	cOID, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, err
+	}
+	program, err := s.GetProgram(ctx, programID)
+	if err != nil {
+		return nil, err
+	}
+	if program.CreatorID != cOID {
+		return nil, errors.New("program not found")
+	}
+	return program, nil
+}
+
+func validateProgram(p *domain.AffiliateProgram) error {
+	if p.CommissionRate < 0 || p.CommissionRate > 100 {
+		return errors.New("commission rate must be between 0 and 100")
+	}
+	if p.EnrollmentMode == "" {
+		p.EnrollmentMode = domain.EnrollmentOpen
+	}
+	if p.EnrollmentMode != domain.EnrollmentOpen && p.EnrollmentMode != domain.EnrollmentApproval {
+		return errors.New("enrollment mode must be open or approval")
+	}
+	if p.AttributionModel != "" && p.AttributionModel != domain.AttributionFirstClick && p.AttributionModel != domain.AttributionLastClick {
+		return errors.New("attribution model must be first_click or last_click")
+	}
+	if p.AttributionWindowDays < 0 {
+		return errors.New("attribution window cannot be negative")
+	}
+	if p.RefundWindowDays < 0 {
+		return errors.New("refund window cannot be negative")
+	}
+	// A recruiter never earns more than the affiliate who made the sale
+	if p.Tier2Rate < 0 || p.Tier2Rate > p.CommissionRate {
+		return errors.New("tier-2 rate must be between 0 and the commission rate")
+	}
+	if p.CommissionRate+p.Tier2Rate > 100 {
+		return errors.New("combined commission rates cannot exceed 100")
+	}
+	if p.Tier2MaxAmount < 0 {
+		return errors.New("tier-2 cap cannot be negative")
+	}
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
 	"time"
 
+	"auth-payment-backend/internal/adapters/config"
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
@@ -13,149 +17,383 @@ import (
 )
 
 type AffiliateServiceImpl struct {
-	repo      ports.AffiliateRepository
-	walletSvc ports.WalletService
+	repo       ports.AffiliateRepository
+	walletSvc  ports.WalletService
+	userRepo   ports.UserRepository
+	fx         ports.FXRateProvider
+	backendURL string
 }
 
-func NewAffiliateService(repo ports.AffiliateRepository, walletSvc ports.WalletService) ports.AffiliateService {
+func NewAffiliateService(repo ports.AffiliateRepository, walletSvc ports.WalletService, userRepo ports.UserRepository, fx ports.FXRateProvider, cfg *config.Config) ports.AffiliateService {
 	return &AffiliateServiceImpl{
-		repo:      repo,
-		walletSvc: walletSvc,
+		repo:       repo,
+		walletSvc:  walletSvc,
+		userRepo:   userRepo,
+		fx:         fx,
+		backendURL: strings.TrimRight(cfg.BackendURL, "/"),
 	}
 }
 
-func (s *AffiliateServiceImpl) CreateProgram(ctx context.Context, creatorID string, productID *string, rate float64) (*domain.AffiliateProgram, error) {
-	cOID, err := primitive.ObjectIDFromHex(creatorID)
+func (s *AffiliateServiceImpl) GenerateLink(ctx context.Context, userID string, programID string, code string, recruiterCode string) (*domain.AffiliateLink, error) {
+	uOID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, err
 	}
-
-	var pOID *primitive.ObjectID
-	if productID != nil {
-		oid, err := primitive.ObjectIDFromHex(*productID)
-		if err != nil {
-			return nil, err
-		}
-		pOID = &oid
-	}
-
-	program := &domain.AffiliateProgram{
-		CreatorID:      cOID,
-		ProductID:      pOID,
-		CommissionRate: rate,
-		IsActive:       true,
-		CreatedAt:      time.Now(),
-	}
-
-	if err := s.repo.CreateProgram(ctx, program); err != nil {
+	pOID, err := primitive.ObjectIDFromHex(programID)
+	if err != nil {
 		return nil, err
 	}
-	return program, nil
-}
 
-func (s *AffiliateServiceImpl) GenerateLink(ctx context.Context, userID string, programID string, code string) (*domain.AffiliateLink, error) {
-	uOID, err := primitive.ObjectIDFromHex(userID)
+	// Only approved affiliates of a running program get links
+	program, err := s.repo.GetProgram(ctx, pOID)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("program not found")
 	}
-	pOID, err := primitive.ObjectIDFromHex(programID)
+	if !program.IsActive {
+		return nil, errors.New("program is not accepting new links")
+	}
+	enrollment, err := s.repo.GetUserEnrollment(ctx, pOID, uOID)
 	if err != nil {
 		return nil, err
 	}
+	if enrollment == nil || enrollment.Status != domain.EnrollmentApproved {
+		return nil, errors.New("you are not an approved affiliate of this program")
+	}
 
 	// Check if code exists
 	if _, err := s.repo.GetLinkByCode(ctx, code); err == nil {
 		return nil, errors.New("affiliate code already taken")
 	}
 
+	var parentID *primitive.ObjectID
+	if recruiterCode != "" {
+		parentID, err = s.recruiterFor(ctx, uOID, recruiterCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Links hit the backend's /ref/:code, which records the click and sets the attribution cookie
 	link := &domain.AffiliateLink{
 		UserID:    uOID,
 		ProgramID: pOID,
 		Code:      code,
-		Url:       fmt.Sprintf("http://localhost:5173/ref/%s", code), // TODO: Config domain
+		Url:       fmt.Sprintf("%s/ref/%s", s.backendURL, url.PathEscape(code)),
+		ParentID:  parentID,
 		CreatedAt: time.Now(),
 	}
 
 	if err := s.repo.CreateLink(ctx, link); err != nil {
 		return nil, err
 	}
+
+	// The first recruiter also becomes the affiliate's default parent for later links
+	if parentID != nil {
+		if user, err := s.userRepo.GetByID(ctx, userID); err == nil && user.AffiliateParentID == nil {
+			user.AffiliateParentID = parentID
+			if err := s.userRepo.Update(ctx, user); err != nil {
+				log.Printf("Failed to set affiliate parent for user %s: %v", userID, err)
+			}
+		}
+	}
 	return link, nil
 }
 
-func (s *AffiliateServiceImpl) TrackClick(ctx context.Context, code string) error {
+// recruiterFor resolves the owner of recruiterCode as the parent of userID,
+// refusing self-recruitment and chains that would loop back to userID.
+func (s *AffiliateServiceImpl) recruiterFor(ctx context.Context, userID primitive.ObjectID, recruiterCode string) (*primitive.ObjectID, error) {
+	recruiterLink, err := s.repo.GetLinkByCode(ctx, recruiterCode)
+	if err != nil {
+		return nil, errors.New("recruiter code not found")
+	}
+	parentID := recruiterLink.UserID
+	if parentID == userID {
+		return nil, errors.New("affiliates cannot recruit themselves")
+	}
+
+	current := parentID
+	for depth := 0; depth < domain.MaxAffiliateChainDepth; depth++ {
+		ancestor, err := s.userRepo.GetByID(ctx, current.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if ancestor == nil || ancestor.AffiliateParentID == nil {
+			return &parentID, nil
+		}
+		if *ancestor.AffiliateParentID == userID {
+			return nil, errors.New("recruiter chain would form a cycle")
+		}
+		current = *ancestor.AffiliateParentID
+	}
+	return nil, errors.New("recruiter chain is too deep")
+}
+
+func (s *AffiliateServiceImpl) TrackClick(ctx context.Context, code string, ip string, userAgent string, referrer string) (*domain.AffiliateLink, *domain.AffiliateProgram, error) {
 	link, err := s.repo.GetLinkByCode(ctx, code)
+	if err != nil || !link.IsActive() {
+		return nil, nil, errors.New("affiliate link not found")
+	}
+
+	program, err := s.repo.GetProgram(ctx, link.ProgramID)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	click := &domain.AffiliateClick{
+		LinkID:      link.ID,
+		ProgramID:   link.ProgramID,
+		AffiliateID: link.UserID,
+		IP:          ip,
+		UserAgent:   userAgent,
+		Referrer:    referrer,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateClick(ctx, click); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.repo.RecordClick(ctx, link.ID); err != nil {
+		return nil, nil, err
+	}
+	return link, program, nil
+}
+
+func (s *AffiliateServiceImpl) ResolveAttribution(ctx context.Context, userID string, explicitCode string, visitor *domain.AffiliateAttribution) (string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// 1. Merge cookie and explicit code into the durable, per-user attribution
+	attribution := user.AffiliateAttribution
+	if attribution == nil {
+		attribution = &domain.AffiliateAttribution{}
+	}
+	before := *attribution
+	attribution.Merge(visitor)
+	if explicitCode != "" {
+		// An unknown code must not replace a valid touch; it would attribute nothing from then on
+		if link, err := s.repo.GetLinkByCode(ctx, explicitCode); err == nil && link.IsActive() {
+			attribution.Record(domain.AffiliateTouch{Code: link.Code, ClickedAt: time.Now()})
+		} else {
+			log.Printf("Ignoring unknown affiliate code %q at checkout of user %s", explicitCode, userID)
+		}
+	}
+
+	if attribution.IsEmpty() {
+		return "", nil
+	}
+	if before != *attribution {
+		user.AffiliateAttribution = attribution
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			log.Printf("Failed to persist affiliate attribution for user %s: %v", userID, err)
+		}
+	}
+
+	// 2. Apply the model of the program behind the most recent touch
+	now := time.Now()
+	lastLink, lastProgram, err := s.touchProgram(ctx, attribution.Last)
+	if err != nil {
+		return "", nil // Unknown code, nothing to attribute
+	}
+
+	if lastProgram.Model() == domain.AttributionFirstClick && attribution.First != nil {
+		firstLink, firstProgram, err := s.touchProgram(ctx, attribution.First)
+		if err == nil && now.Sub(attribution.First.ClickedAt) <= firstProgram.Window() {
+			return firstLink.Code, nil
+		}
+	}
+
+	if now.Sub(attribution.Last.ClickedAt) <= lastProgram.Window() {
+		return lastLink.Code, nil
+	}
+	return "", nil
+}
+
+func (s *AffiliateServiceImpl) touchProgram(ctx context.Context, touch *domain.AffiliateTouch) (*domain.AffiliateLink, *domain.AffiliateProgram, error) {
+	if touch == nil {
+		return nil, nil, errors.New("no touch")
+	}
+	link, err := s.repo.GetLinkByCode(ctx, touch.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !link.IsActive() {
+		return nil, nil, errors.New("affiliate link disabled")
+	}
+	program, err := s.repo.GetProgram(ctx, link.ProgramID)
+	if err != nil {
+		return nil, nil, err
 	}
-	return s.repo.RecordClick(ctx, link.ID)
+	return link, program, nil
 }
 
-func (s *AffiliateServiceImpl) ProcessCommission(ctx context.Context, orderID string, amount float64, code string) (*domain.Commission, error) {
+func (s *AffiliateServiceImpl) ProcessCommission(ctx context.Context, orderID string, amount float64, currency string, code string, plan *domain.PricingPlan, buyerID string) (*domain.Commission, error) {
 	// 1. Get Link
 	link, err := s.repo.GetLinkByCode(ctx, code)
 	if err != nil {
 		return nil, err // Invalid code, ignore commission
 	}
+	if !link.IsActive() {
+		log.Printf("Skipping commission for code %s on order %s: link disabled", code, orderID)
+		return nil, nil
+	}
 
-	// 2. Get Program to find Rate
-	program, err := s.repo.GetProgram(ctx, link.ProgramID)
+	// 2. Check eligibility and find the program whose rate applies
+	program, reason, err := s.commissionProgram(ctx, link, plan, buyerID)
 	if err != nil {
 		return nil, err
 	}
+	if program == nil {
+		log.Printf("Skipping commission for code %s on order %s: %s", code, orderID, reason)
+		return nil, nil
+	}
+
+	// A repeated webhook for the same order must not pay the affiliate (and their recruiter) twice
+	oOID, _ := primitive.ObjectIDFromHex(orderID)
+	if !oOID.IsZero() {
+		existing, err := s.repo.GetCommissionsByOrder(ctx, oOID)
+		if err != nil {
+			return nil, err
+		}
+		for _, comm := range existing {
+			if comm.Tier == 1 && comm.LinkID == link.ID {
+				return comm, nil
+			}
+		}
+	}
 
 	// 3. Calculate
 	commissionAmount := (amount * program.CommissionRate) / 100.0
 
-	// 4. Create Commission Record
-	oOID, _ := primitive.ObjectIDFromHex(orderID)
+	// 4. Create Commission Record. It is credited once the refund window has passed.
+	now := time.Now()
 	comm := &domain.Commission{
 		AffiliateID:  link.UserID,
 		LinkID:       link.ID,
+		ProgramID:    program.ID,
+		CreatorID:    program.CreatorID,
 		OrderID:      oOID,
 		TotalAmount:  amount,
 		EarnedAmount: commissionAmount,
-		Status:       "paid", // Auto-paying to wallet for simplicity
-		CreatedAt:    time.Now(),
+		Currency:     domain.NormalizeCurrency(currency),
+		Status:       domain.CommissionStatusPending,
+		Tier:         1,
+		MaturesAt:    now.Add(program.MaturationPeriod()),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 
 	if err := s.repo.CreateCommission(ctx, comm); err != nil {
 		return nil, err
 	}
 
-	// 5. Credit Wallet
-	err = s.walletSvc.CreditWallet(
-		ctx,
-		link.UserID.Hex(),
-		commissionAmount,
-		domain.TransactionTypeCommission,
-		comm.ID.Hex(),
-		fmt.Sprintf("Commission for Order %s", orderID),
-	)
-	if err != nil {
-		return nil, err
+	// 5. Record the conversion for analytics
+	conv := &domain.AffiliateConversion{
+		LinkID:       link.ID,
+		ProgramID:    link.ProgramID,
+		AffiliateID:  link.UserID,
+		OrderID:      oOID,
+		CommissionID: comm.ID,
+		SaleAmount:   amount,
+		Currency:     comm.Currency,
+		CreatedAt:    now,
+	}
+	if err := s.repo.CreateConversion(ctx, conv); err != nil {
+		log.Printf("Failed to record conversion for order %s: %v", orderID, err)
+	}
+	if err := s.repo.RecordConversion(ctx, link.ID); err != nil {
+		log.Printf("Failed to count conversion on link %s: %v", link.Code, err)
 	}
 
+	// 6. Tier 2: reward whoever recruited the affiliate
+	if err := s.createTier2Commission(ctx, comm, link, program, buyerID); err != nil {
+		log.Printf("Failed to create tier-2 commission for order %s: %v", orderID, err)
+	}
 	return comm, nil
 }
 
-func (s *AffiliateServiceImpl) GetMyStats(ctx context.Context, userID string) (map[string]interface{}, error) {
-	uOID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return nil, err
+// createTier2Commission records the recruiter's share of a sale, linked to the tier-1 commission
+func (s *AffiliateServiceImpl) createTier2Commission(ctx context.Context, parent *domain.Commission, link *domain.AffiliateLink, program *domain.AffiliateProgram, buyerID string) error {
+	if program.Tier2Rate <= 0 {
+		return nil
 	}
 
-	links, err := s.repo.GetLinksByUser(ctx, uOID)
+	recruiterID := link.ParentID
+	if recruiterID == nil {
+		affiliate, err := s.userRepo.GetByID(ctx, link.UserID.Hex())
+		if err != nil {
+			return err
+		}
+		if affiliate == nil {
+			return nil
+		}
+		recruiterID = affiliate.AffiliateParentID
+	}
+	// Nobody earns twice on the same sale or on their own purchase
+	if recruiterID == nil || *recruiterID == link.UserID || recruiterID.Hex() == buyerID {
+		return nil
+	}
+	recruiter, err := s.userRepo.GetByID(ctx, recruiterID.Hex())
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if recruiter == nil || recruiter.DeletedAt != nil {
+		return nil
 	}
 
-	comms, err := s.repo.GetCommissionsByUser(ctx, uOID)
+	amount := program.Tier2Amount(parent.TotalAmount)
+	if amount <= 0 {
+		return nil
+	}
+
+	comm := &domain.Commission{
+		AffiliateID:        *recruiterID,
+		LinkID:             link.ID,
+		ProgramID:          parent.ProgramID,
+		CreatorID:          parent.CreatorID,
+		OrderID:            parent.OrderID,
+		TotalAmount:        parent.TotalAmount,
+		EarnedAmount:       amount,
+		Currency:           parent.Currency,
+		Status:             domain.CommissionStatusPending,
+		Tier:               2,
+		ParentCommissionID: &parent.ID,
+		MaturesAt:          parent.MaturesAt,
+		CreatedAt:          parent.CreatedAt,
+		UpdatedAt:          parent.UpdatedAt,
+	}
+	return s.repo.CreateCommission(ctx, comm)
+}
+
+// commissionProgram returns the program that governs a commission for a purchase
+// through link, or nil and the reason the purchase is not eligible.
+// A product-specific program takes precedence over the creator's global one.
+func (s *AffiliateServiceImpl) commissionProgram(ctx context.Context, link *domain.AffiliateLink, plan *domain.PricingPlan, buyerID string) (*domain.AffiliateProgram, string, error) {
+	if plan == nil {
+		return nil, "unknown plan", nil
+	}
+	if buyerID != "" && buyerID == link.UserID.Hex() {
+		return nil, "self-referral", nil
+	}
+
+	linked, err := s.repo.GetProgram(ctx, link.ProgramID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if !linked.Covers(plan) {
+		return nil, "program does not cover the purchased plan", nil
+	}
+	if linked.ProductID != nil {
+		return linked, "", nil
 	}
 
-	return map[string]interface{}{
-		"links":       links,
-		"commissions": comms,
-	}, nil
+	specific, err := s.repo.GetProductProgram(ctx, plan.CreatorID, plan.ProductID)
+	if err != nil {
+		return nil, "", err
+	}
+	if specific != nil {
+		return specific, "", nil
+	}
+	return linked, "", nil
 }
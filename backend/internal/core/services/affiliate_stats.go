@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const defaultLeaderboardLimit = 20
+
+func (s *AffiliateServiceImpl) GetMyStats(ctx context.Context, userID string, from, to time.Time) (*domain.AffiliateStats, error) {
+	uOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, errors.New("invalid date range")
+	}
+
+	links, err := s.repo.GetLinksByUser(ctx, uOID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.GetAffiliateActivity(ctx, uOID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &domain.AffiliateStats{
+		From:               from,
+		To:                 to,
+		Links:              make([]domain.AffiliateLinkStats, 0, len(links)),
+		Currency:           reportingCurrency,
+		EarningsByCurrency: map[string]float64{},
+	}
+
+	// Every link is listed, even without activity in the range
+	byLink := make(map[primitive.ObjectID]*domain.AffiliateLinkStats, len(links))
+	for _, link := range links {
+		stats.Links = append(stats.Links, domain.AffiliateLinkStats{LinkID: link.ID, Code: link.Code})
+	}
+	for i := range stats.Links {
+		byLink[stats.Links[i].LinkID] = &stats.Links[i]
+	}
+
+	// One entry per day so charts have no gaps
+	byDay := map[string]*domain.AffiliateDailyStats{}
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		stats.Daily = append(stats.Daily, domain.AffiliateDailyStats{Date: day.Format("2006-01-02")})
+	}
+	for i := range stats.Daily {
+		byDay[stats.Daily[i].Date] = &stats.Daily[i]
+	}
+
+	// Commissions are earned in the sale's currency; metrics add them up in the reporting currency
+	convert := newReportingConverter(ctx, s.fx)
+	for _, row := range rows {
+		tier1, tier2 := convert.Total(row.Earnings), convert.Total(row.Tier2Earnings)
+		earnings := tier1 + tier2
+		stats.Totals.Add(row.Clicks, row.Conversions, earnings)
+		stats.Tier2Earnings += tier2
+		for currency, amount := range row.Earnings {
+			stats.EarningsByCurrency[currency] += amount
+		}
+		for currency, amount := range row.Tier2Earnings {
+			stats.EarningsByCurrency[currency] += amount
+		}
+
+		// Tier-2 earnings come from other affiliates' links, so only count them in totals and per day
+		if link, ok := byLink[row.LinkID]; ok {
+			link.Add(row.Clicks, row.Conversions, tier1)
+		}
+		if day, ok := byDay[row.Day]; ok {
+			day.Add(row.Clicks, row.Conversions, earnings)
+		}
+	}
+
+	stats.Totals.Finalize()
+	for i := range stats.Links {
+		stats.Links[i].Finalize()
+	}
+	for i := range stats.Daily {
+		stats.Daily[i].Finalize()
+	}
+	return stats, nil
+}
+
+func (s *AffiliateServiceImpl) GetLeaderboard(ctx context.Context, creatorID string, programID string, from, to time.Time, limit int) ([]*domain.AffiliateLeaderboardEntry, error) {
+	if !from.Before(to) {
+		return nil, errors.New("invalid date range")
+	}
+	if limit <= 0 {
+		limit = defaultLeaderboardLimit
+	}
+
+	program, err := s.ownedProgram(ctx, creatorID, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.GetProgramLeaderboard(ctx, program.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rank on comparable amounts: sales in several currencies are converted first
+	convert := newReportingConverter(ctx, s.fx)
+	for _, e := range entries {
+		e.Currency = reportingCurrency
+		e.Sales = convert.Total(e.SalesByCurrency)
+		e.Earnings = convert.Total(e.EarningsByCurrency)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Earnings != entries[j].Earnings {
+			return entries[i].Earnings > entries[j].Earnings
+		}
+		return entries[i].Conversions > entries[j].Conversions
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	for _, e := range entries {
+		e.Finalize()
+		if user, err := s.userRepo.GetByID(ctx, e.AffiliateID.Hex()); err == nil {
+			e.Name = user.FullName
+		}
+	}
+	return entries, nil
+}
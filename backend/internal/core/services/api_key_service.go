@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	apiKeyPrefix = "apk_"
+	// lastUsedResolution limits last_used_at writes to one per key per minute
+	lastUsedResolution = time.Minute
+)
+
+type APIKeyServiceImpl struct {
+	repo     ports.APIKeyRepository
+	userRepo ports.UserRepository
+}
+
+func NewAPIKeyService(repo ports.APIKeyRepository, userRepo ports.UserRepository) ports.APIKeyService {
+	return &APIKeyServiceImpl{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *APIKeyServiceImpl) CreateKey(ctx context.Context, userID string, name string, scopes []domain.APIKeyScope, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", errors.New("user not found")
+	}
+	if !user.HasRole(domain.RoleCreator, domain.RoleAdmin) {
+		return nil, "", errors.New("only creators and admins can issue API keys")
+	}
+
+	if name == "" {
+		return nil, "", errors.New("key name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, v := range domain.ValidAPIKeyScopes {
+			if scope == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, "", errors.New("unknown scope: " + string(scope))
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, "", errors.New("expiry must be in the future")
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	rawKey := apiKeyPrefix + secret
+
+	key := &domain.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    rawKey[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *APIKeyServiceImpl) ListKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.repo.ListByUser(ctx, oid)
+}
+
+func (s *APIKeyServiceImpl) RevokeKey(ctx context.Context, userID string, keyID string) error {
+	uOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+	kOID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return errors.New("invalid key ID")
+	}
+	return s.repo.Revoke(ctx, kOID, uOID)
+}
+
+func (s *APIKeyServiceImpl) Authenticate(ctx context.Context, rawKey string) (*domain.User, *domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	key, err := s.repo.GetByHash(ctx, hashToken(rawKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key == nil || !key.IsUsable(now) {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, key.UserID.Hex())
+	if err != nil || user.DeletedAt != nil {
+		return nil, nil, errors.New("invalid api key")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("Failed to update api key last_used_at: %v", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return user, key, nil
+}
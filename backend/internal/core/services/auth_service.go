@@ -3,26 +3,38 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
+	"strings"
 
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
 	repo         ports.UserRepository
 	tokenService *TokenService
+	loginGuard   *LoginGuard
 }
 
-func NewAuthService(repo ports.UserRepository, tokenService *TokenService) ports.AuthService {
+func NewAuthService(repo ports.UserRepository, tokenService *TokenService, loginGuard *LoginGuard) ports.AuthService {
 	return &AuthService{
 		repo:         repo,
 		tokenService: tokenService,
+		loginGuard:   loginGuard,
 	}
 }
 
+// normalizeEmail is the single form emails are stored and looked up in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func (s *AuthService) Register(ctx context.Context, email, password, fullName string) (*domain.User, error) {
+	email = normalizeEmail(email)
+
 	existingUser, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, err
@@ -40,6 +52,7 @@ func (s *AuthService) Register(ctx context.Context, email, password, fullName st
 		Email:    email,
 		Password: string(hashedPassword),
 		FullName: fullName,
+		Role:     domain.RoleUser,
 	}
 
 	if err := s.repo.Create(ctx, user); err != nil {
@@ -49,23 +62,56 @@ func (s *AuthService) Register(ctx context.Context, email, password, fullName st
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, email, password string) (string, string, error) {
+func (s *AuthService) Login(ctx context.Context, email, password, clientIP string) (string, string, error) {
+	email = normalizeEmail(email)
+
+	// Reject early while locked so attackers can't keep probing passwords
+	if err := s.loginGuard.Check(ctx, email, clientIP); err != nil {
+		return "", "", err
+	}
+
 	user, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
 		return "", "", err
 	}
 	if user == nil {
+		// Count unknown emails too, otherwise lockouts leak which accounts exist
+		if err := s.loginGuard.RegisterFailure(ctx, email, clientIP, nil); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
 		return "", "", errors.New("invalid credentials")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
+		if err := s.loginGuard.RegisterFailure(ctx, email, clientIP, &user.ID); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
 		return "", "", errors.New("invalid credentials")
 	}
 
+	if err := s.loginGuard.RegisterSuccess(ctx, email); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
 	return s.tokenService.GenerateTokens(user)
 }
 
+// UnlockAccount clears a brute-force lockout on behalf of an admin
+func (s *AuthService) UnlockAccount(ctx context.Context, adminID string, userID string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	actorOID, err := primitive.ObjectIDFromHex(adminID)
+	if err != nil {
+		return errors.New("invalid admin ID")
+	}
+
+	return s.loginGuard.Unlock(ctx, user.Email, user.ID, actorOID)
+}
+
 func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
 	claims, err := s.tokenService.ValidateToken(refreshToken)
 	if err != nil {
@@ -84,8 +130,10 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (st
 		return "", "", errors.New("user not found")
 	}
 
-	// In a real implementation, we should check if the refresh token is revoked or reused.
-	// For this MVP, we just issue new tokens.
+	// Tokens issued before a password change or account deletion are revoked
+	if user.DeletedAt != nil || claims.Version != user.TokenVersion {
+		return "", "", errors.New("token revoked")
+	}
 
 	return s.tokenService.GenerateTokens(user)
 }
@@ -97,5 +145,12 @@ func (s *AuthService) ValidateToken(tokenString string) (*domain.User, error) {
 	}
 
 	// Use background context as a fallback because the interface method doesn't accept context
-	return s.repo.GetByID(context.Background(), claims.UserID)
+	user, err := s.repo.GetByID(context.Background(), claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil || claims.Version != user.TokenVersion {
+		return nil, errors.New("token revoked")
+	}
+	return user, nil
 }
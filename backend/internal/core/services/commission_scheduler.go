@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// CommissionScheduler periodically approves commissions whose refund window has passed
+type CommissionScheduler struct {
+	affiliateSvc ports.AffiliateService
+	interval     time.Duration
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func NewCommissionScheduler(affiliateSvc ports.AffiliateService, cfg *config.Config) *CommissionScheduler {
+	return &CommissionScheduler{
+		affiliateSvc: affiliateSvc,
+		interval:     cfg.CommissionMaturationInterval,
+	}
+}
+
+func (cs *CommissionScheduler) Start() {
+	cs.stop = make(chan struct{})
+	cs.done = make(chan struct{})
+
+	go func() {
+		defer close(cs.done)
+		ticker := time.NewTicker(cs.interval)
+		defer ticker.Stop()
+
+		cs.run()
+		for {
+			select {
+			case <-ticker.C:
+				cs.run()
+			case <-cs.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (cs *CommissionScheduler) Stop(ctx context.Context) error {
+	if cs.stop == nil {
+		return nil
+	}
+	close(cs.stop)
+	select {
+	case <-cs.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cs *CommissionScheduler) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), cs.interval)
+	defer cancel()
+
+	n, err := cs.affiliateSvc.MatureCommissions(ctx)
+	if err != nil {
+		log.Printf("Commission maturation failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Approved %d matured commissions", n)
+	}
+}
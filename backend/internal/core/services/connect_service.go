@@ -2,77 +2,148 @@ package services
 
 import (
 	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 	"context"
+	"errors"
 	"fmt"
-	"net/url"
+	"log"
+	"net/http"
+	"time"
+)
 
-	"github.com/stripe/stripe-go/v76"
-	"github.com/stripe/stripe-go/v76/account"
-	"github.com/stripe/stripe-go/v76/loginlink"
-	"github.com/stripe/stripe-go/v76/oauth"
+const (
+	connectStateProvider = "stripe_connect"
+	connectStateTTL      = 15 * time.Minute
 )
 
-type ConnectService struct {
-	config   *config.Config
-	userRepo ports.UserRepository
+type ConnectServiceImpl struct {
+	config    *config.Config
+	gateway   ports.ConnectGateway
+	userRepo  ports.UserRepository
+	stateRepo ports.OAuthStateRepository
 }
 
-func NewConnectService(cfg *config.Config, userRepo ports.UserRepository) *ConnectService {
-	return &ConnectService{
-		config:   cfg,
-		userRepo: userRepo,
+func NewConnectService(cfg *config.Config, gateway ports.ConnectGateway, userRepo ports.UserRepository, stateRepo ports.OAuthStateRepository) ports.ConnectService {
+	return &ConnectServiceImpl{
+		config:    cfg,
+		gateway:   gateway,
+		userRepo:  userRepo,
+		stateRepo: stateRepo,
 	}
 }
 
-// GenerateOAuthURL builds the Stripe OAuth authorization URL for Express accounts
-func (s *ConnectService) GenerateOAuthURL(userID string) string {
-	clientID := s.config.StripeConnectClientID
-	// backendCallbackURL := fmt.Sprintf("%s/stripe/connect/callback", s.config.FrontendURL) // Typically config.BackendURL if it existed
-	backendCallbackURL := "http://localhost:8080/stripe/connect/callback" // TODO: Make configurable via config
+// StartOnboarding creates the seller's connected account (Express unless accountType is
+// "standard") and returns the Account Link that collects its details on Stripe. A seller
+// who already has an account gets a fresh link to it, e.g. to complete missing requirements.
+func (s *ConnectServiceImpl) StartOnboarding(ctx context.Context, userID string, accountType string) (string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
 
-	params := url.Values{}
-	params.Add("response_type", "code")
-	params.Add("client_id", clientID)
-	params.Add("scope", "read_write")
-	params.Add("state", userID) // Using userID as state for simplicity (CSRF protection recommended in prod)
-	params.Add("redirect_uri", backendCallbackURL)
+	connectID := user.StripeConnectID
+	if connectID == "" {
+		if accountType == "" {
+			accountType = domain.ConnectAccountExpress
+		}
+		if accountType != domain.ConnectAccountExpress && accountType != domain.ConnectAccountStandard {
+			return "", errors.New("account type must be express or standard")
+		}
+
+		id, state, err := s.gateway.CreateAccount(ctx, accountType, user.Email, map[string]string{"user_id": userID})
+		if err != nil {
+			return "", err
+		}
+		state.Managed = true
+		if err := s.userRepo.UpdateStripeConnect(ctx, userID, id, state.Status(), state); err != nil {
+			return "", err
+		}
+		connectID = id
+	} else if user.StripeConnectAccount == nil || !user.StripeConnectAccount.Managed {
+		// Accounts linked before onboarding existed were all linked with OAuth
+		return "", errors.New("accounts linked with OAuth are managed on Stripe")
+	}
 
-	return fmt.Sprintf("https://connect.stripe.com/oauth/authorize?%s", params.Encode())
+	return s.gateway.CreateOnboardingLink(ctx, connectID, s.config.StripeConnectRefreshURL, s.config.StripeConnectReturnURL)
 }
 
-// HandleOAuthCallback exchanges the authorization code for a connected account ID
-func (s *ConnectService) HandleOAuthCallback(ctx context.Context, code string) (string, error) {
-	params := &stripe.OAuthTokenParams{
-		GrantType: stripe.String("authorization_code"),
-		Code:      stripe.String(code),
+// GenerateOAuthURL builds the Stripe OAuth authorization URL, to link an existing Standard account.
+// The state is a random single-use nonce stored server-side and bound to userID.
+func (s *ConnectServiceImpl) GenerateOAuthURL(ctx context.Context, userID string) (string, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return "", err
 	}
 
-	token, err := oauth.New(params)
+	now := time.Now()
+	err = s.stateRepo.Save(ctx, &domain.OAuthState{
+		State:     state,
+		Provider:  connectStateProvider,
+		UserID:    userID,
+		ExpiresAt: now.Add(connectStateTTL),
+		CreatedAt: now,
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return token.StripeUserID, nil
+	return s.gateway.OAuthURL(state, s.config.StripeConnectRedirect), nil
 }
 
-// ConnectUser updates the user record with the connected account ID
-func (s *ConnectService) ConnectUser(ctx context.Context, userID string, connectID string) error {
-	return s.userRepo.UpdateStripeConnect(ctx, userID, connectID, "active")
+// ConsumeOAuthState validates the callback state and returns the user who started the flow.
+// A state can only be used once.
+func (s *ConnectServiceImpl) ConsumeOAuthState(ctx context.Context, state string) (string, error) {
+	if state == "" {
+		return "", errors.New("missing state")
+	}
+
+	saved, err := s.stateRepo.Consume(ctx, state)
+	if err != nil {
+		return "", err
+	}
+	if saved == nil || saved.Provider != connectStateProvider || saved.UserID == "" {
+		return "", errors.New("invalid or expired state")
+	}
+	return saved.UserID, nil
 }
 
-// GetConnectionStatus checks if the user has a connected account
-func (s *ConnectService) GetConnectionStatus(ctx context.Context, userID string) (map[string]interface{}, error) {
-	user, err := s.userRepo.GetByID(ctx, userID)
+// HandleOAuthCallback exchanges the authorization code for a connected account ID
+func (s *ConnectServiceImpl) HandleOAuthCallback(ctx context.Context, code string) (string, error) {
+	return s.gateway.ExchangeOAuthCode(ctx, code)
+}
+
+// ConnectUser links the connected account from the OAuth callback to the user. Its status
+// comes from the account itself: it may not be able to accept charges yet.
+func (s *ConnectServiceImpl) ConnectUser(ctx context.Context, userID string, connectID string) error {
+	state, err := s.gateway.GetAccount(ctx, connectID)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.UpdateStripeConnect(ctx, userID, connectID, state.Status(), state)
+}
+
+// GetConnectionStatus checks if the user has a connected account. With refresh, the account
+// is read from Stripe first, e.g. when the seller returns from onboarding before the webhook.
+func (s *ConnectServiceImpl) GetConnectionStatus(ctx context.Context, userID string, refresh bool) (map[string]interface{}, error) {
+	var user *domain.User
+	var err error
+	if refresh {
+		user, err = s.SyncAccount(ctx, userID)
+	} else {
+		user, err = s.userRepo.GetByID(ctx, userID)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	connected := user.StripeConnectID != ""
 	return map[string]interface{}{
-		"connected":         boundBool(connected),
-		"stripe_connect_id": user.StripeConnectID,
-		"status":            user.StripeConnectStatus,
+		"connected":            boundBool(connected),
+		"stripe_connect_id":    user.StripeConnectID,
+		"status":               user.StripeConnectStatus,
+		"account":              user.StripeConnectAccount,
+		"can_receive_payments": user.CanReceiveDestinationCharges(),
 	}, nil
 }
 
@@ -80,34 +151,87 @@ func boundBool(b bool) bool {
 	return b
 }
 
-// CreateDashboardLoginLink generates a single-use login link for the Express dashboard
-func (s *ConnectService) CreateDashboardLoginLink(ctx context.Context, connectID string) (string, error) {
-	// First, fetch the account to check its type
-	acc, err := account.GetByID(connectID, nil)
+// SyncAccount refreshes the state of the user's connected account from Stripe. Sellers linked
+// before the state was stored have none until it is synced.
+func (s *ConnectServiceImpl) SyncAccount(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if user.StripeConnectID == "" {
+		return user, nil
 	}
 
-	// Standard accounts cannot use login links. They log in directly to Stripe.
-	if acc.Type == stripe.AccountTypeStandard {
-		return "https://dashboard.stripe.com/", nil
+	state, err := s.gateway.GetAccount(ctx, user.StripeConnectID)
+	if err != nil {
+		return nil, err
 	}
+	if err := s.syncAccount(ctx, user, state); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
-	// For Express/Custom accounts, use Login Link
-	params := &stripe.LoginLinkParams{
-		Account: stripe.String(connectID),
+// HandleWebhook applies events of connected accounts: account updates refresh the seller's
+// status, and an account that removed the platform's access is unlinked.
+func (s *ConnectServiceImpl) HandleWebhook(ctx context.Context, payload []byte, headers http.Header) error {
+	event, err := s.gateway.ParseWebhook(ctx, payload, headers)
+	if err != nil || event == nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByStripeConnectID(ctx, event.AccountID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("Ignoring connect event %s: no user for account %s", event.ID, event.AccountID)
+		return nil
+	}
+
+	switch event.Type {
+	case domain.ConnectEventAccountUpdated:
+		return s.syncAccount(ctx, user, event.Account)
+	case domain.ConnectEventDeauthorized:
+		log.Printf("Connected account %s of user %s left the platform", event.AccountID, user.ID.Hex())
+		return s.userRepo.UpdateStripeConnect(ctx, user.ID.Hex(), "", domain.ConnectStatusDisconnected, nil)
 	}
+	return nil
+}
+
+// syncAccount stores the account's latest state and derived status on its user
+func (s *ConnectServiceImpl) syncAccount(ctx context.Context, user *domain.User, state *domain.ConnectAccount) error {
+	state.Managed = user.StripeConnectAccount != nil && user.StripeConnectAccount.Managed
+	if err := s.userRepo.UpdateStripeConnect(ctx, user.ID.Hex(), user.StripeConnectID, state.Status(), state); err != nil {
+		return err
+	}
+	user.StripeConnectStatus = state.Status()
+	user.StripeConnectAccount = state
+	return nil
+}
 
-	link, err := loginlink.New(params)
+// CreateDashboardLoginLink generates a single-use login link for the Express dashboard
+func (s *ConnectServiceImpl) CreateDashboardLoginLink(ctx context.Context, connectID string) (string, error) {
+	// First, fetch the account to check its type
+	acc, err := s.gateway.GetAccount(ctx, connectID)
 	if err != nil {
 		return "", err
 	}
 
-	return link.URL, nil
+	// Standard accounts cannot use login links. They log in directly to Stripe.
+	if acc.Type == domain.ConnectAccountStandard {
+		return "https://dashboard.stripe.com/", nil
+	}
+	if !acc.DetailsSubmitted {
+		return "", errors.New("finish onboarding to open the dashboard")
+	}
+
+	// For Express/Custom accounts, use Login Link
+	return s.gateway.CreateLoginLink(ctx, connectID)
 }
 
 // DisconnectUser deauthorizes the connected account and removes it from the user record
-func (s *ConnectService) DisconnectUser(ctx context.Context, userID string) error {
+func (s *ConnectServiceImpl) DisconnectUser(ctx context.Context, userID string) error {
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return err
@@ -117,18 +241,14 @@ func (s *ConnectService) DisconnectUser(ctx context.Context, userID string) erro
 		return fmt.Errorf("user not connected")
 	}
 
-	// Deauthorize from Stripe
-	params := &stripe.DeauthorizeParams{
-		ClientID:     stripe.String(s.config.StripeConnectClientID),
-		StripeUserID: stripe.String(user.StripeConnectID),
-	}
-
-	_, err = oauth.Del(params)
-	// We log error but proceed to remove from DB to keep state consistent if token is already invalid
-	if err != nil {
-		fmt.Printf("Error deauthorizing account: %v\n", err)
+	// Deauthorize accounts linked with OAuth. Accounts the platform created stay on it and are only unlinked.
+	if user.StripeConnectAccount == nil || !user.StripeConnectAccount.Managed {
+		// We log error but proceed to remove from DB to keep state consistent if token is already invalid
+		if err := s.gateway.Deauthorize(ctx, user.StripeConnectID); err != nil {
+			fmt.Printf("Error deauthorizing account: %v\n", err)
+		}
 	}
 
 	// Remove from DB
-	return s.userRepo.UpdateStripeConnect(ctx, userID, "", "disconnected")
+	return s.userRepo.UpdateStripeConnect(ctx, userID, "", domain.ConnectStatusDisconnected, nil)
 }
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/adapters/payment/fake"
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memOAuthStateRepo struct {
+	mu     sync.Mutex
+	states map[string]domain.OAuthState
+}
+
+func (r *memOAuthStateRepo) Save(ctx context.Context, state *domain.OAuthState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.State] = *state
+	return nil
+}
+
+func (r *memOAuthStateRepo) Consume(ctx context.Context, state string) (*domain.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved, ok := r.states[state]
+	if !ok || time.Now().After(saved.ExpiresAt) {
+		return nil, nil
+	}
+	delete(r.states, state)
+	return &saved, nil
+}
+
+type connectFlow struct {
+	t       *testing.T
+	ctx     context.Context
+	svc     *ConnectServiceImpl
+	gateway *fake.FakeConnectGateway
+	users   *memUserRepo
+	cfg     *config.Config
+	userID  string
+}
+
+func newConnectFlow(t *testing.T) *connectFlow {
+	t.Helper()
+	user := domain.User{ID: primitive.NewObjectID(), Email: "seller@example.com", FullName: "Seller", Role: domain.RoleCreator}
+	cfg := &config.Config{
+		StripeConnectRedirect:   "http://localhost:8080/stripe/connect/callback",
+		StripeConnectRefreshURL: "http://localhost:5173/settings/stripe-connect?onboarding=refresh",
+		StripeConnectReturnURL:  "http://localhost:5173/settings/stripe-connect?onboarding=return",
+	}
+	f := &connectFlow{
+		t:       t,
+		ctx:     context.Background(),
+		gateway: fake.NewFakeConnectGateway(),
+		users:   &memUserRepo{users: map[string]domain.User{user.ID.Hex(): user}},
+		cfg:     cfg,
+		userID:  user.ID.Hex(),
+	}
+	f.svc = NewConnectService(cfg, f.gateway, f.users, &memOAuthStateRepo{states: map[string]domain.OAuthState{}}).(*ConnectServiceImpl)
+	return f
+}
+
+func (f *connectFlow) user() *domain.User {
+	f.t.Helper()
+	user, err := f.users.GetByID(f.ctx, f.userID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return user
+}
+
+// deliver passes every pending webhook of the gateway to the service, as the webhook endpoint would
+func (f *connectFlow) deliver() int {
+	f.t.Helper()
+	webhooks := f.gateway.Webhooks()
+	for _, w := range webhooks {
+		if err := f.svc.HandleWebhook(f.ctx, w.Payload, w.Headers); err != nil {
+			f.t.Fatalf("webhook: %v", err)
+		}
+	}
+	return len(webhooks)
+}
+
+// onboard creates the user's Express account and returns its ID
+func (f *connectFlow) onboard() string {
+	f.t.Helper()
+	if _, err := f.svc.StartOnboarding(f.ctx, f.userID, ""); err != nil {
+		f.t.Fatalf("onboarding: %v", err)
+	}
+	return f.user().StripeConnectID
+}
+
+// linkOAuth links an existing Standard account through the OAuth flow, as the callback handler does
+func (f *connectFlow) linkOAuth() string {
+	f.t.Helper()
+	authURL, err := f.svc.GenerateOAuthURL(f.ctx, f.userID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	userID, err := f.svc.ConsumeOAuthState(f.ctx, u.Query().Get("state"))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	connectID, err := f.svc.HandleOAuthCallback(f.ctx, f.gateway.AuthorizeOAuth("seller@example.com"))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if err := f.svc.ConnectUser(f.ctx, userID, connectID); err != nil {
+		f.t.Fatal(err)
+	}
+	return connectID
+}
+
+func TestConnectOnboarding(t *testing.T) {
+	f := newConnectFlow(t)
+
+	link, err := f.svc.StartOnboarding(f.ctx, f.userID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(link, url.QueryEscape(f.cfg.StripeConnectReturnURL)) || !strings.Contains(link, url.QueryEscape(f.cfg.StripeConnectRefreshURL)) {
+		t.Fatalf("onboarding link %s lacks the refresh/return URLs", link)
+	}
+
+	user := f.user()
+	acc, ok := f.gateway.Account(user.StripeConnectID)
+	if !ok || acc.State.Type != domain.ConnectAccountExpress || acc.Metadata["user_id"] != f.userID {
+		t.Fatalf("gateway account: %+v", acc)
+	}
+	if user.StripeConnectStatus != domain.ConnectStatusPending || !user.StripeConnectAccount.Managed {
+		t.Fatalf("after account creation: status %s, account %+v", user.StripeConnectStatus, user.StripeConnectAccount)
+	}
+	if user.CanReceiveDestinationCharges() {
+		t.Fatal("a pending account can receive destination charges")
+	}
+
+	// An expired link is replaced by a new one for the same account
+	if _, err := f.svc.StartOnboarding(f.ctx, f.userID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if f.gateway.Accounts() != 1 || len(f.gateway.Links()) != 2 {
+		t.Fatalf("%d accounts and %d links, want 1 and 2", f.gateway.Accounts(), len(f.gateway.Links()))
+	}
+
+	if err := f.gateway.CompleteOnboarding(user.StripeConnectID); err != nil {
+		t.Fatal(err)
+	}
+	f.deliver()
+	if user := f.user(); user.StripeConnectStatus != domain.ConnectStatusActive || !user.CanReceiveDestinationCharges() {
+		t.Fatalf("after onboarding: status %s", user.StripeConnectStatus)
+	}
+}
+
+func TestConnectOnboardingAccountTypes(t *testing.T) {
+	tests := []struct {
+		accountType string
+		wantErr     bool
+	}{
+		{domain.ConnectAccountExpress, false},
+		{domain.ConnectAccountStandard, false},
+		{"custom", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accountType, func(t *testing.T) {
+			f := newConnectFlow(t)
+			_, err := f.svc.StartOnboarding(f.ctx, f.userID, tt.accountType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if f.gateway.Accounts() != 0 {
+					t.Fatal("an account was created")
+				}
+				return
+			}
+			if acc := f.user().StripeConnectAccount; acc == nil || acc.Type != tt.accountType {
+				t.Fatalf("account %+v, want type %s", acc, tt.accountType)
+			}
+		})
+	}
+}
+
+func TestConnectStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		simulate  func(g *fake.FakeConnectGateway, id string) error
+		want      string
+		canCharge bool
+	}{
+		{
+			name:     "onboarding not submitted",
+			simulate: func(g *fake.FakeConnectGateway, id string) error { return nil },
+			want:     domain.ConnectStatusPending,
+		},
+		{
+			name:      "onboarded",
+			simulate:  func(g *fake.FakeConnectGateway, id string) error { return nil },
+			want:      domain.ConnectStatusActive,
+			canCharge: true,
+		},
+		{
+			name: "information due later",
+			simulate: func(g *fake.FakeConnectGateway, id string) error {
+				return g.RequireInformation(id, false, "individual.id_number")
+			},
+			want:      domain.ConnectStatusActive,
+			canCharge: true,
+		},
+		{
+			name: "information past due",
+			simulate: func(g *fake.FakeConnectGateway, id string) error {
+				return g.RequireInformation(id, true, "individual.verification.document")
+			},
+			want: domain.ConnectStatusRestricted,
+		},
+		{
+			name:     "rejected",
+			simulate: func(g *fake.FakeConnectGateway, id string) error { return g.Reject(id) },
+			want:     domain.ConnectStatusDisabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newConnectFlow(t)
+			id := f.onboard()
+			if tt.name != "onboarding not submitted" {
+				if err := f.gateway.CompleteOnboarding(id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.simulate(f.gateway, id); err != nil {
+				t.Fatal(err)
+			}
+			f.deliver()
+
+			status, err := f.svc.GetConnectionStatus(f.ctx, f.userID, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status["connected"] != true || status["stripe_connect_id"] != id {
+				t.Fatalf("status %v", status)
+			}
+			if status["status"] != tt.want || status["can_receive_payments"] != tt.canCharge {
+				t.Fatalf("status %v, can receive payments %v; want %s, %v", status["status"], status["can_receive_payments"], tt.want, tt.canCharge)
+			}
+		})
+	}
+}
+
+func TestConnectStatusRefresh(t *testing.T) {
+	f := newConnectFlow(t)
+	id := f.onboard()
+
+	// The seller is back from onboarding before the webhook arrives
+	if err := f.gateway.CompleteOnboarding(id); err != nil {
+		t.Fatal(err)
+	}
+	status, err := f.svc.GetConnectionStatus(f.ctx, f.userID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["status"] != domain.ConnectStatusPending {
+		t.Fatalf("stored status %v, want pending", status["status"])
+	}
+	status, err = f.svc.GetConnectionStatus(f.ctx, f.userID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["status"] != domain.ConnectStatusActive || f.user().StripeConnectStatus != domain.ConnectStatusActive {
+		t.Fatalf("refreshed status %v, want active", status["status"])
+	}
+
+	// Not connected
+	f.users.UpdateStripeConnect(f.ctx, f.userID, "", domain.ConnectStatusDisconnected, nil)
+	status, err = f.svc.GetConnectionStatus(f.ctx, f.userID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["connected"] != false || status["can_receive_payments"] != false {
+		t.Fatalf("status %v", status)
+	}
+}
+
+func TestConnectWebhooks(t *testing.T) {
+	f := newConnectFlow(t)
+	id := f.onboard()
+	if err := f.gateway.CompleteOnboarding(id); err != nil {
+		t.Fatal(err)
+	}
+	w := f.gateway.Webhooks()[0]
+
+	forged := []byte(strings.Replace(string(w.Payload), `"charges_enabled":true`, `"charges_enabled":false`, 1))
+	if err := f.svc.HandleWebhook(f.ctx, forged, w.Headers); err == nil {
+		t.Fatal("forged webhook was accepted")
+	}
+
+	// Events of accounts that belong to no user are ignored
+	other := newConnectFlow(t)
+	otherID := other.onboard()
+	if err := other.gateway.CompleteOnboarding(otherID); err != nil {
+		t.Fatal(err)
+	}
+	other.users.UpdateStripeConnect(other.ctx, other.userID, "", domain.ConnectStatusDisconnected, nil)
+	if n := other.deliver(); n != 1 {
+		t.Fatalf("delivered %d webhooks, want 1", n)
+	}
+
+	// Managed status survives account updates
+	if err := f.svc.HandleWebhook(f.ctx, w.Payload, w.Headers); err != nil {
+		t.Fatal(err)
+	}
+	if acc := f.user().StripeConnectAccount; !acc.Managed || !acc.ChargesEnabled {
+		t.Fatalf("account after update: %+v", acc)
+	}
+}
+
+func TestConnectOAuth(t *testing.T) {
+	f := newConnectFlow(t)
+
+	authURL, err := f.svc.GenerateOAuthURL(f.ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	state := u.Query().Get("state")
+	if state == "" || u.Query().Get("redirect_uri") != f.cfg.StripeConnectRedirect {
+		t.Fatalf("authorization URL %s", authURL)
+	}
+
+	userID, err := f.svc.ConsumeOAuthState(f.ctx, state)
+	if err != nil || userID != f.userID {
+		t.Fatalf("state resolved to %q, %v", userID, err)
+	}
+	if _, err := f.svc.ConsumeOAuthState(f.ctx, state); err == nil {
+		t.Fatal("state was accepted twice")
+	}
+	if _, err := f.svc.ConsumeOAuthState(f.ctx, "forged"); err == nil {
+		t.Fatal("unknown state was accepted")
+	}
+
+	code := f.gateway.AuthorizeOAuth("seller@example.com")
+	connectID, err := f.svc.HandleOAuthCallback(f.ctx, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.HandleOAuthCallback(f.ctx, code); err == nil {
+		t.Fatal("authorization code was exchanged twice")
+	}
+	if err := f.svc.ConnectUser(f.ctx, userID, connectID); err != nil {
+		t.Fatal(err)
+	}
+
+	user := f.user()
+	if user.StripeConnectID != connectID || user.StripeConnectStatus != domain.ConnectStatusActive || user.StripeConnectAccount.Managed {
+		t.Fatalf("after OAuth: %s %s %+v", user.StripeConnectID, user.StripeConnectStatus, user.StripeConnectAccount)
+	}
+
+	// Linked accounts are managed on Stripe, not onboarded by the platform
+	if _, err := f.svc.StartOnboarding(f.ctx, f.userID, ""); err == nil {
+		t.Fatal("onboarding link created for an account linked with OAuth")
+	}
+}
+
+func TestConnectDashboardLink(t *testing.T) {
+	t.Run("express", func(t *testing.T) {
+		f := newConnectFlow(t)
+		id := f.onboard()
+
+		if _, err := f.svc.CreateDashboardLoginLink(f.ctx, id); err == nil {
+			t.Fatal("dashboard link created before onboarding")
+		}
+		if err := f.gateway.CompleteOnboarding(id); err != nil {
+			t.Fatal(err)
+		}
+		link, err := f.svc.CreateDashboardLoginLink(f.ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(link, "https://connect.fake/express/"+id+"/") {
+			t.Fatalf("login link %s", link)
+		}
+	})
+
+	t.Run("standard", func(t *testing.T) {
+		f := newConnectFlow(t)
+		id := f.linkOAuth()
+		link, err := f.svc.CreateDashboardLoginLink(f.ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link != "https://dashboard.stripe.com/" {
+			t.Fatalf("link %s, want the Stripe dashboard", link)
+		}
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		f := newConnectFlow(t)
+		if _, err := f.svc.CreateDashboardLoginLink(f.ctx, "acct_unknown"); err == nil {
+			t.Fatal("dashboard link created for an unknown account")
+		}
+	})
+}
+
+func TestConnectDisconnect(t *testing.T) {
+	t.Run("created by the platform", func(t *testing.T) {
+		f := newConnectFlow(t)
+		id := f.onboard()
+		if err := f.svc.DisconnectUser(f.ctx, f.userID); err != nil {
+			t.Fatal(err)
+		}
+		user := f.user()
+		if user.StripeConnectID != "" || user.StripeConnectStatus != domain.ConnectStatusDisconnected || user.StripeConnectAccount != nil {
+			t.Fatalf("after disconnect: %s %s %+v", user.StripeConnectID, user.StripeConnectStatus, user.StripeConnectAccount)
+		}
+		// The account stays on the platform
+		if acc, _ := f.gateway.Account(id); acc.Deauthorized {
+			t.Fatal("platform account was deauthorized")
+		}
+	})
+
+	t.Run("linked with OAuth", func(t *testing.T) {
+		f := newConnectFlow(t)
+		id := f.linkOAuth()
+		if err := f.svc.DisconnectUser(f.ctx, f.userID); err != nil {
+			t.Fatal(err)
+		}
+		if acc, _ := f.gateway.Account(id); !acc.Deauthorized {
+			t.Fatal("linked account was not deauthorized")
+		}
+		if f.user().StripeConnectID != "" {
+			t.Fatal("account still linked")
+		}
+	})
+
+	t.Run("not connected", func(t *testing.T) {
+		f := newConnectFlow(t)
+		if err := f.svc.DisconnectUser(f.ctx, f.userID); err == nil {
+			t.Fatal("disconnected a user without an account")
+		}
+	})
+
+	t.Run("revoked by the seller", func(t *testing.T) {
+		f := newConnectFlow(t)
+		id := f.linkOAuth()
+		if err := f.gateway.LeavePlatform(id); err != nil {
+			t.Fatal(err)
+		}
+		f.deliver()
+		user := f.user()
+		if user.StripeConnectID != "" || user.StripeConnectStatus != domain.ConnectStatusDisconnected {
+			t.Fatalf("after deauthorization: %s %s", user.StripeConnectID, user.StripeConnectStatus)
+		}
+	})
+}
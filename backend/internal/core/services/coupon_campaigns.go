@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// campaignCodeAlphabet leaves out 0/O and 1/I, which customers mistype
+const campaignCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// defaultCampaignPattern is used when a campaign has no pattern
+const defaultCampaignPattern = "????-????"
+
+func (s *CouponServiceImpl) CreateCampaign(ctx context.Context, creatorID string, campaign *domain.CouponCampaign) error {
+	user, err := s.userRepo.GetByID(ctx, creatorID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if strings.TrimSpace(campaign.Name) == "" {
+		return errors.New("campaign name is required")
+	}
+	if campaign.CodeCount <= 0 || campaign.CodeCount > domain.MaxCampaignCodes {
+		return fmt.Errorf("code count must be between 1 and %d", domain.MaxCampaignCodes)
+	}
+	campaign.Pattern = strings.ToUpper(strings.TrimSpace(campaign.Pattern))
+	if campaign.Pattern == "" {
+		campaign.Pattern = defaultCampaignPattern
+	}
+	if err := validateCampaignPattern(campaign.Pattern, campaign.CodeCount); err != nil {
+		return err
+	}
+	if campaign.Rules.MaxUses == 0 {
+		campaign.Rules.MaxUses = 1
+	}
+	if err := validateCouponRules(&campaign.Rules); err != nil {
+		return err
+	}
+
+	codes, err := s.generateCampaignCodes(ctx, campaign.Pattern, campaign.CodeCount)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	campaign.CreatorID = user.ID
+	campaign.IsActive = true
+	campaign.DeactivatedAt = nil
+	campaign.CreatedAt = now
+	if err := s.repo.CreateCampaign(ctx, campaign); err != nil {
+		return err
+	}
+
+	coupons := make([]*domain.Coupon, len(codes))
+	for i, code := range codes {
+		coupon := campaign.Rules
+		coupon.ID = primitive.NilObjectID
+		coupon.Code = code
+		coupon.CreatorID = user.ID
+		coupon.CampaignID = &campaign.ID
+		coupon.UsedCount = 0
+		coupon.IsActive = true
+		coupon.StripeCouponID = ""
+		coupon.StripePromotionCodeID = ""
+		coupon.StripeCurrency = ""
+		coupon.CreatedAt = now
+		coupons[i] = &coupon
+	}
+	if err := s.repo.CreateCoupons(ctx, coupons); err != nil {
+		if delErr := s.repo.DeleteCampaign(ctx, campaign.ID); delErr != nil {
+			log.Printf("Failed to roll back campaign %s: %v", campaign.ID.Hex(), delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *CouponServiceImpl) ListCampaigns(ctx context.Context, creatorID string) ([]*domain.CouponCampaign, error) {
+	oid, err := primitive.ObjectIDFromHex(creatorID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.repo.GetCampaignsByCreator(ctx, oid)
+}
+
+func (s *CouponServiceImpl) GetCampaign(ctx context.Context, userID string, id string) (*domain.CouponCampaign, error) {
+	return s.ownedCampaign(ctx, userID, id)
+}
+
+func (s *CouponServiceImpl) GetCampaignCodes(ctx context.Context, userID string, id string) ([]*domain.Coupon, error) {
+	campaign, err := s.ownedCampaign(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetCouponsByCampaign(ctx, campaign.ID)
+}
+
+func (s *CouponServiceImpl) GetCampaignStats(ctx context.Context, userID string, id string) (*domain.CouponCampaignStats, error) {
+	campaign, err := s.ownedCampaign(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repo.GetCampaignStats(ctx, campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Codes may be redeemed in several currencies; the total is converted to one
+	stats.Currency = reportingCurrency
+	stats.TotalDiscount = newReportingConverter(ctx, s.fx).Total(stats.DiscountByCurrency)
+	return stats, nil
+}
+
+// DeactivateCampaign switches off every code of the campaign. Checkouts already holding a code may still complete.
+func (s *CouponServiceImpl) DeactivateCampaign(ctx context.Context, userID string, id string) (*domain.CouponCampaign, error) {
+	campaign, err := s.ownedCampaign(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaign.IsActive {
+		return campaign, nil
+	}
+
+	if err := s.repo.DeactivateCampaignCoupons(ctx, campaign.ID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	campaign.IsActive = false
+	campaign.DeactivatedAt = &now
+	if err := s.repo.UpdateCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// ownedCampaign loads a campaign the user may manage: their own, or any campaign for admins
+func (s *CouponServiceImpl) ownedCampaign(ctx context.Context, userID string, id string) (*domain.CouponCampaign, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid campaign ID")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	campaign, err := s.repo.GetCampaign(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil || (campaign.CreatorID != user.ID && !user.HasRole(domain.RoleAdmin)) {
+		return nil, errors.New("campaign not found")
+	}
+	return campaign, nil
+}
+
+// validateCampaignPattern makes sure the pattern yields valid codes with room for `count` of them.
+// The space must be far larger than the count so random codes rarely collide and stay hard to guess.
+func validateCampaignPattern(pattern string, count int) error {
+	placeholders := 0
+	for _, r := range pattern {
+		switch {
+		case r == '?':
+			placeholders++
+		case r == '-', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			return errors.New("pattern may only contain letters, digits, '-' and '?'")
+		}
+	}
+	if placeholders < 4 {
+		return errors.New("pattern needs at least 4 '?' placeholders")
+	}
+
+	space := new(big.Int).Exp(big.NewInt(int64(len(campaignCodeAlphabet))), big.NewInt(int64(placeholders)), nil)
+	needed := big.NewInt(int64(count) * 1000)
+	if space.Cmp(needed) < 0 {
+		return errors.New("pattern has too few '?' placeholders for this many codes")
+	}
+	return nil
+}
+
+// generateCampaignCodes draws `count` distinct codes that no existing coupon uses
+func (s *CouponServiceImpl) generateCampaignCodes(ctx context.Context, pattern string, count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	seen := make(map[string]bool, count)
+
+	for attempt := 0; attempt < 5 && len(codes) < count; attempt++ {
+		var batch []string
+		for len(codes)+len(batch) < count {
+			code, err := randomCode(pattern)
+			if err != nil {
+				return nil, err
+			}
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			batch = append(batch, code)
+		}
+
+		taken, err := s.repo.ExistingCodes(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		takenSet := make(map[string]bool, len(taken))
+		for _, code := range taken {
+			takenSet[code] = true
+		}
+		for _, code := range batch {
+			if !takenSet[code] {
+				codes = append(codes, code)
+			}
+		}
+	}
+	if len(codes) < count {
+		return nil, errors.New("could not generate enough unique codes; use a longer pattern")
+	}
+	return codes, nil
+}
+
+func randomCode(pattern string) (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(campaignCodeAlphabet)))
+	for _, r := range pattern {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(campaignCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
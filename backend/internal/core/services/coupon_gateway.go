@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"auth-payment-backend/internal/core/domain"
+)
+
+// SyncGatewayCoupon mirrors the coupon on the gateway the first time it discounts a
+// subscription. Limits and customer rules stay local: the promotion code is only
+// attached by checkout after ValidateCoupon and ReserveCoupon succeeded.
+func (s *CouponServiceImpl) SyncGatewayCoupon(ctx context.Context, coupon *domain.Coupon, currency string) error {
+	currency = strings.ToLower(currency)
+
+	// The gateway cannot cap a percentage, so the recurring discount would differ from ours
+	if coupon.DiscountType == domain.DiscountTypePercent && coupon.MaxDiscountAmount > 0 {
+		return errors.New("coupons with a maximum discount cannot be used on subscriptions")
+	}
+	if coupon.StripeCouponID != "" {
+		if coupon.DiscountType == domain.DiscountTypeFixed && coupon.StripeCurrency != currency {
+			return errors.New("coupon is not available in " + strings.ToUpper(currency))
+		}
+		if coupon.StripePromotionCodeID != "" {
+			return nil
+		}
+	}
+
+	if coupon.StripeCouponID == "" {
+		id, err := s.gateway.CreateCoupon(ctx, coupon, currency)
+		if err != nil {
+			return err
+		}
+		coupon.StripeCouponID = id
+		if coupon.DiscountType == domain.DiscountTypeFixed {
+			coupon.StripeCurrency = currency
+		}
+	}
+	promoID, err := s.gateway.CreatePromotionCode(ctx, coupon.StripeCouponID, coupon.Code)
+	if err != nil {
+		// Keep the coupon ID so a retry only creates the promotion code
+		if saveErr := s.repo.SetGatewayIDs(ctx, coupon); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+	coupon.StripePromotionCodeID = promoID
+
+	return s.repo.SetGatewayIDs(ctx, coupon)
+}
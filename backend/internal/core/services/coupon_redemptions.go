@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// couponReservationTTL is how long a checkout may hold a coupon use before paying
+const couponReservationTTL = time.Hour
+
+func (s *CouponServiceImpl) ReserveCoupon(ctx context.Context, coupon *domain.Coupon, userID string, planID string, discount float64, currency string) (*domain.CouponRedemption, error) {
+	uOID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	pOID, err := primitive.ObjectIDFromHex(planID)
+	if err != nil {
+		return nil, errors.New("invalid plan ID")
+	}
+
+	ok, err := s.repo.ReserveUsage(ctx, coupon.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("coupon usage limit reached")
+	}
+
+	now := time.Now()
+	redemption := &domain.CouponRedemption{
+		CouponID:       coupon.ID,
+		CampaignID:     coupon.CampaignID,
+		Code:           coupon.Code,
+		UserID:         uOID,
+		PlanID:         pOID,
+		DiscountAmount: discount,
+		Currency:       domain.NormalizeCurrency(currency),
+		Status:         domain.RedemptionReserved,
+		ExpiresAt:      now.Add(couponReservationTTL),
+		CreatedAt:      now,
+	}
+	if err := s.repo.CreateRedemption(ctx, redemption); err != nil {
+		if relErr := s.repo.ReleaseUsage(ctx, coupon.ID); relErr != nil {
+			log.Printf("Failed to release coupon %s usage: %v", coupon.Code, relErr)
+		}
+		return nil, err
+	}
+	return redemption, nil
+}
+
+func (s *CouponServiceImpl) ConfirmRedemption(ctx context.Context, redemptionID string, paymentRef string) error {
+	redemption, err := s.getRedemption(ctx, redemptionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from := redemption.Status
+	switch from {
+	case domain.RedemptionConfirmed:
+		return nil // Webhook retry
+	case domain.RedemptionReserved, domain.RedemptionReleased:
+	default:
+		return errors.New("unknown redemption status")
+	}
+
+	redemption.Status = domain.RedemptionConfirmed
+	redemption.PaymentRef = paymentRef
+	redemption.ConfirmedAt = &now
+	ok, err := s.repo.TransitionRedemption(ctx, redemption, from)
+	if err != nil || !ok {
+		return err
+	}
+
+	// The hold expired before the payment landed. The customer paid the
+	// discounted price, so the use counts again even if it exceeds the limit.
+	if from == domain.RedemptionReleased {
+		return s.repo.IncrementUsage(ctx, redemption.Code)
+	}
+	return nil
+}
+
+func (s *CouponServiceImpl) ReleaseRedemption(ctx context.Context, redemptionID string, reason string) error {
+	redemption, err := s.getRedemption(ctx, redemptionID)
+	if err != nil {
+		return err
+	}
+	if redemption.Status != domain.RedemptionReserved {
+		return nil // Already confirmed or released
+	}
+	return s.release(ctx, redemption, reason)
+}
+
+func (s *CouponServiceImpl) ListRedemptions(ctx context.Context, userID string, couponID string) ([]*domain.CouponRedemption, error) {
+	coupon, err := s.ownedCoupon(ctx, userID, couponID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRedemptionsByCoupon(ctx, coupon.ID)
+}
+
+// release moves a reservation to released and gives the use back to the coupon
+func (s *CouponServiceImpl) release(ctx context.Context, redemption *domain.CouponRedemption, reason string) error {
+	now := time.Now()
+	redemption.Status = domain.RedemptionReleased
+	redemption.ReleaseReason = reason
+	redemption.ReleasedAt = &now
+
+	ok, err := s.repo.TransitionRedemption(ctx, redemption, domain.RedemptionReserved)
+	if err != nil || !ok {
+		return err
+	}
+	return s.repo.ReleaseUsage(ctx, redemption.CouponID)
+}
+
+// releaseExpiredReservations frees uses held by abandoned checkouts. It runs
+// lazily whenever the coupon is validated, so no background job is needed.
+func (s *CouponServiceImpl) releaseExpiredReservations(ctx context.Context, coupon *domain.Coupon) {
+	expired, err := s.repo.GetExpiredReservations(ctx, coupon.ID, time.Now())
+	if err != nil {
+		log.Printf("Failed to load expired reservations of coupon %s: %v", coupon.Code, err)
+		return
+	}
+	for _, redemption := range expired {
+		if err := s.release(ctx, redemption, "reservation expired"); err != nil {
+			log.Printf("Failed to release redemption %s: %v", redemption.ID.Hex(), err)
+			continue
+		}
+		if coupon.UsedCount > 0 {
+			coupon.UsedCount--
+		}
+	}
+}
+
+func (s *CouponServiceImpl) getRedemption(ctx context.Context, redemptionID string) (*domain.CouponRedemption, error) {
+	oid, err := primitive.ObjectIDFromHex(redemptionID)
+	if err != nil {
+		return nil, errors.New("invalid redemption ID")
+	}
+	redemption, err := s.repo.GetRedemption(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if redemption == nil {
+		return nil, errors.New("redemption not found")
+	}
+	return redemption, nil
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"auth-payment-backend/internal/core/domain"
@@ -14,12 +16,18 @@ import (
 type CouponServiceImpl struct {
 	repo        ports.CouponRepository
 	pricingRepo ports.PricingRepository
+	userRepo    ports.UserRepository
+	gateway     ports.PaymentGateway
+	fx          ports.FXRateProvider
 }
 
-func NewCouponService(repo ports.CouponRepository, pricingRepo ports.PricingRepository) ports.CouponService {
+func NewCouponService(repo ports.CouponRepository, pricingRepo ports.PricingRepository, userRepo ports.UserRepository, gateway ports.PaymentGateway, fx ports.FXRateProvider) ports.CouponService {
 	return &CouponServiceImpl{
 		repo:        repo,
 		pricingRepo: pricingRepo,
+		userRepo:    userRepo,
+		gateway:     gateway,
+		fx:          fx,
 	}
 }
 
@@ -27,20 +35,59 @@ func (s *CouponServiceImpl) CreateCoupon(ctx context.Context, coupon *domain.Cou
 	if coupon.Code == "" {
 		return errors.New("coupon code is required")
 	}
-	if coupon.DiscountAmount <= 0 {
-		return errors.New("discount amount must be greater than 0")
+	if strings.Contains(coupon.Code, ",") {
+		return errors.New("coupon code cannot contain commas")
 	}
-	if coupon.DiscountType == domain.DiscountTypePercent && coupon.DiscountAmount > 100 {
-		return errors.New("percentage discount cannot exceed 100%")
+	if err := validateCouponRules(coupon); err != nil {
+		return err
 	}
 
 	coupon.CreatedAt = time.Now()
 	coupon.IsActive = true
 	coupon.UsedCount = 0
+	coupon.CampaignID = nil
+	coupon.StripeCouponID = ""
+	coupon.StripePromotionCodeID = ""
+	coupon.StripeCurrency = ""
 
 	return s.repo.CreateCoupon(ctx, coupon)
 }
 
+// validateCouponRules checks the discount and eligibility rules shared by single coupons and campaigns
+func validateCouponRules(coupon *domain.Coupon) error {
+	if coupon.DiscountAmount <= 0 {
+		return errors.New("discount amount must be greater than 0")
+	}
+	if coupon.DiscountType != domain.DiscountTypeFixed && coupon.DiscountType != domain.DiscountTypePercent {
+		return errors.New("invalid discount type")
+	}
+	if coupon.DiscountType == domain.DiscountTypePercent && coupon.DiscountAmount > 100 {
+		return errors.New("percentage discount cannot exceed 100%")
+	}
+	if coupon.MaxUses < 0 || coupon.MaxUsesPerUser < 0 || coupon.MinOrderAmount < 0 || coupon.MaxDiscountAmount < 0 {
+		return errors.New("coupon limits cannot be negative")
+	}
+	if coupon.StartDate != nil && coupon.ExpiryDate != nil && !coupon.StartDate.Before(*coupon.ExpiryDate) {
+		return errors.New("start date must be before the expiry date")
+	}
+	switch coupon.Duration {
+	case "", domain.CouponDurationOnce, domain.CouponDurationForever:
+		if coupon.DurationInMonths != 0 {
+			return errors.New("duration in months is only allowed for repeating coupons")
+		}
+	case domain.CouponDurationRepeating:
+		if coupon.DurationInMonths <= 0 {
+			return errors.New("repeating coupons need a duration in months")
+		}
+	default:
+		return errors.New("invalid coupon duration")
+	}
+	for i, d := range coupon.AllowedEmailDomains {
+		coupon.AllowedEmailDomains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+	}
+	return nil
+}
+
 func (s *CouponServiceImpl) GetCoupon(ctx context.Context, id string) (*domain.Coupon, error) {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -49,35 +96,135 @@ func (s *CouponServiceImpl) GetCoupon(ctx context.Context, id string) (*domain.C
 	return s.repo.GetCouponByID(ctx, oid)
 }
 
-func (s *CouponServiceImpl) ListCoupons(ctx context.Context) ([]*domain.Coupon, error) {
-	return s.repo.ListCoupons(ctx)
+func (s *CouponServiceImpl) ListCoupons(ctx context.Context, userID string) ([]*domain.Coupon, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.HasRole(domain.RoleAdmin) {
+		return s.repo.ListCoupons(ctx)
+	}
+	return s.repo.ListCouponsByCreator(ctx, user.ID)
+}
+
+// UpdateCoupon replaces the coupon's rules. The code, owner and usage counters cannot change.
+func (s *CouponServiceImpl) UpdateCoupon(ctx context.Context, userID string, id string, update *domain.Coupon) (*domain.Coupon, error) {
+	coupon, err := s.ownedCoupon(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCouponRules(update); err != nil {
+		return nil, err
+	}
+
+	// Subscriptions already carry the gateway coupon; it cannot be edited
+	termsChanged := update.DiscountType != coupon.DiscountType ||
+		update.DiscountAmount != coupon.DiscountAmount ||
+		update.EffectiveDuration() != coupon.EffectiveDuration() ||
+		update.DurationInMonths != coupon.DurationInMonths ||
+		update.MaxDiscountAmount != coupon.MaxDiscountAmount
+	if coupon.HasGatewayCoupon() && termsChanged {
+		return nil, errors.New("discount terms of a coupon used on subscriptions cannot be changed")
+	}
+
+	coupon.DiscountType = update.DiscountType
+	coupon.DiscountAmount = update.DiscountAmount
+	coupon.ApplicablePlanIDs = update.ApplicablePlanIDs
+	coupon.MaxUses = update.MaxUses
+	coupon.ExpiryDate = update.ExpiryDate
+	coupon.IsActive = update.IsActive
+	coupon.StartDate = update.StartDate
+	coupon.MaxUsesPerUser = update.MaxUsesPerUser
+	coupon.MinOrderAmount = update.MinOrderAmount
+	coupon.MaxDiscountAmount = update.MaxDiscountAmount
+	coupon.FirstPurchaseOnly = update.FirstPurchaseOnly
+	coupon.AllowedUserIDs = update.AllowedUserIDs
+	coupon.AllowedEmailDomains = update.AllowedEmailDomains
+	coupon.StacksWithEarlyBird = update.StacksWithEarlyBird
+	coupon.StacksWithCoupons = update.StacksWithCoupons
+	coupon.Duration = update.Duration
+	coupon.DurationInMonths = update.DurationInMonths
+
+	if err := s.repo.UpdateCoupon(ctx, coupon); err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// DeleteCoupon removes a coupon that was never redeemed; used coupons must be deactivated instead
+func (s *CouponServiceImpl) DeleteCoupon(ctx context.Context, userID string, id string) error {
+	coupon, err := s.ownedCoupon(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	redemptions, err := s.repo.GetRedemptionsByCoupon(ctx, coupon.ID)
+	if err != nil {
+		return err
+	}
+	if len(redemptions) > 0 || coupon.UsedCount > 0 {
+		return errors.New("coupon has been used; deactivate it instead")
+	}
+	return s.repo.DeleteCoupon(ctx, coupon.ID)
+}
+
+// ownedCoupon loads a coupon the user may manage: their own, or any coupon for admins
+func (s *CouponServiceImpl) ownedCoupon(ctx context.Context, userID string, id string) (*domain.Coupon, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid coupon ID")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	coupon, err := s.repo.GetCouponByID(ctx, oid)
+	if err != nil {
+		return nil, errors.New("coupon not found")
+	}
+	if coupon.CreatorID != user.ID && !user.HasRole(domain.RoleAdmin) {
+		return nil, errors.New("coupon not found")
+	}
+	return coupon, nil
 }
 
-func (s *CouponServiceImpl) ValidateCoupon(ctx context.Context, code string, planID string) (*domain.Coupon, float64, error) {
+// isPlatformCoupon reports whether a coupon applies to every seller's plans: coupons from
+// before coupons had owners, and coupons created by admins
+func (s *CouponServiceImpl) isPlatformCoupon(ctx context.Context, coupon *domain.Coupon) bool {
+	if coupon.CreatorID.IsZero() {
+		return true
+	}
+	creator, err := s.userRepo.GetByID(ctx, coupon.CreatorID.Hex())
+	return err == nil && creator.HasRole(domain.RoleAdmin)
+}
+
+func (s *CouponServiceImpl) ValidateCoupon(ctx context.Context, code string, check *domain.CouponCheck) (*domain.Coupon, float64, error) {
 	coupon, err := s.repo.GetCouponByCode(ctx, code)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	// 1. Availability
+	now := time.Now()
+	s.releaseExpiredReservations(ctx, coupon)
 	if !coupon.IsActive {
 		return nil, 0, errors.New("coupon is inactive")
 	}
-
-	if coupon.ExpiryDate != nil && coupon.ExpiryDate.Before(time.Now()) {
-		return nil, 0, errors.New("coupon established")
+	if coupon.StartDate != nil && now.Before(*coupon.StartDate) {
+		return nil, 0, errors.New("coupon is not active yet")
+	}
+	if coupon.ExpiryDate != nil && coupon.ExpiryDate.Before(now) {
+		return nil, 0, errors.New("coupon has expired")
 	}
-
 	if coupon.MaxUses > 0 && coupon.UsedCount >= coupon.MaxUses {
 		return nil, 0, errors.New("coupon usage limit reached")
 	}
 
-	// Check if applicable to plan
+	// 2. Plan
+	planOID, err := primitive.ObjectIDFromHex(check.PlanID)
+	if err != nil {
+		return nil, 0, errors.New("invalid plan ID")
+	}
 	if len(coupon.ApplicablePlanIDs) > 0 {
-		planOID, err := primitive.ObjectIDFromHex(planID)
-		if err != nil {
-			return nil, 0, errors.New("invalid plan ID")
-		}
-
 		isApplicable := false
 		for _, id := range coupon.ApplicablePlanIDs {
 			if id == planOID {
@@ -89,43 +236,54 @@ func (s *CouponServiceImpl) ValidateCoupon(ctx context.Context, code string, pla
 			return nil, 0, errors.New("coupon not applicable to this plan")
 		}
 	}
-
-	// Calculate discount amount
-	var discount float64
-	// fetching plan to calculate percentage based discount
-	// For actual implementation we need the plan Price.
-	// To keep it clean, we might just return the coupon and let caller calculate,
-	// OR fetch plan here. Let's fetch plan here for robustness.
-
-	planOID, _ := primitive.ObjectIDFromHex(planID) // Error handled above/irrelevant if not checking applicability?
-	// Actually we should always validate plan existence
 	plan, err := s.pricingRepo.GetPlanByID(ctx, planOID)
 	if err != nil {
 		return nil, 0, errors.New("plan not found")
 	}
+	if coupon.CreatorID != plan.CreatorID && !s.isPlatformCoupon(ctx, coupon) {
+		return nil, 0, errors.New("coupon not applicable to this plan")
+	}
 
-	var basePrice float64
-	switch plan.Type {
-	case domain.PricingTypeOneTime:
-		basePrice = plan.OneTimeConfig.Price
-	case domain.PricingTypeSubscription:
-		basePrice = plan.SubscriptionConfig.Price
-	case domain.PricingTypeBundle:
-		basePrice = plan.BundleConfig.Price
-	case domain.PricingTypeSplit:
-		basePrice = plan.SplitConfig.UpfrontPayment // Usually split applies to upfront? Or absolute total? Let's say upfront for now or Total.
-		// Simpler to just say we don't support split coupons yet or apply to total.
-		// Let's assume total amount for simplicity of calculation, but applying to installment is complex.
-		basePrice = plan.SplitConfig.TotalAmount
-	default:
-		// Tiered/Donation might be tricky.
-		basePrice = 0
+	// 3. Stacking
+	if check.EarlyBird && !coupon.StacksWithEarlyBird {
+		return nil, 0, errors.New("coupon cannot be combined with the early-bird price")
+	}
+	for _, other := range check.OtherCodes {
+		if other == code {
+			return nil, 0, errors.New("coupon can only be used once per order")
+		}
+	}
+	if len(check.OtherCodes) > 0 && !coupon.StacksWithCoupons {
+		return nil, 0, errors.New("coupon cannot be combined with other coupons")
+	}
+
+	// 4. Customer
+	if err := s.checkCustomer(ctx, coupon, check.UserID); err != nil {
+		return nil, 0, err
 	}
 
+	// 5. Order amount. The coupon's amounts are in the plan's base currency.
+	factor := check.Factor
+	if factor <= 0 {
+		factor = 1
+	}
+	basePrice := check.OrderAmount
+	if basePrice <= 0 {
+		basePrice = planBasePrice(plan) * factor
+	}
+	if minOrder := coupon.MinOrderAmount * factor; minOrder > 0 && basePrice < minOrder {
+		return nil, 0, fmt.Errorf("order must be at least %.2f to use this coupon", minOrder)
+	}
+
+	// 6. Calculate discount amount
+	var discount float64
 	if coupon.DiscountType == domain.DiscountTypeFixed {
-		discount = coupon.DiscountAmount
+		discount = coupon.DiscountAmount * factor
 	} else {
 		discount = (basePrice * coupon.DiscountAmount) / 100
+		if maxDiscount := coupon.MaxDiscountAmount * factor; maxDiscount > 0 && discount > maxDiscount {
+			discount = maxDiscount
+		}
 	}
 
 	if discount > basePrice {
@@ -135,6 +293,74 @@ func (s *CouponServiceImpl) ValidateCoupon(ctx context.Context, code string, pla
 	return coupon, discount, nil
 }
 
-func (s *CouponServiceImpl) ApplyCoupon(ctx context.Context, code string) error {
-	return s.repo.IncrementUsage(ctx, code)
+// checkCustomer enforces the per-customer rules of a coupon
+func (s *CouponServiceImpl) checkCustomer(ctx context.Context, coupon *domain.Coupon, userID string) error {
+	needsUser := coupon.IsRestrictedToCustomers() || coupon.FirstPurchaseOnly || coupon.MaxUsesPerUser > 0
+	if !needsUser {
+		return nil
+	}
+	if userID == "" {
+		return errors.New("sign in to use this coupon")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if coupon.IsRestrictedToCustomers() && !couponAllowsUser(coupon, user) {
+		return errors.New("coupon is not available for your account")
+	}
+	if coupon.FirstPurchaseOnly && user.FirstPurchaseAt != nil {
+		return errors.New("coupon is only valid on your first purchase")
+	}
+	if coupon.MaxUsesPerUser > 0 {
+		used, err := s.repo.CountUserRedemptions(ctx, coupon.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.MaxUsesPerUser) {
+			return errors.New("you have already used this coupon the maximum number of times")
+		}
+	}
+	return nil
+}
+
+func couponAllowsUser(coupon *domain.Coupon, user *domain.User) bool {
+	for _, id := range coupon.AllowedUserIDs {
+		if id == user.ID {
+			return true
+		}
+	}
+	// Only verified addresses prove membership of a domain
+	if !user.IsEmailVerified {
+		return false
+	}
+	_, domainPart, ok := strings.Cut(strings.ToLower(user.Email), "@")
+	if !ok {
+		return false
+	}
+	for _, d := range coupon.AllowedEmailDomains {
+		if domainPart == d {
+			return true
+		}
+	}
+	return false
+}
+
+// planBasePrice is the list price a coupon applies to when the caller has no order amount
+func planBasePrice(plan *domain.PricingPlan) float64 {
+	switch plan.Type {
+	case domain.PricingTypeOneTime:
+		return plan.OneTimeConfig.Price
+	case domain.PricingTypeSubscription:
+		return plan.SubscriptionConfig.Price
+	case domain.PricingTypeBundle:
+		return plan.BundleConfig.Price
+	case domain.PricingTypeSplit:
+		// Applying to a single installment is complex; use the total amount
+		return plan.SplitConfig.TotalAmount
+	default:
+		// Tiered/Donation depend on the order
+		return 0
+	}
 }
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// creditNoteSeriesPrefix keeps credit notes in their own numbering series
+const creditNoteSeriesPrefix = "credit:"
+
+func (s *InvoiceServiceImpl) IssueRefundCreditNote(ctx context.Context, payment *domain.Payment, amount float64, refundRef string, note string) (*domain.CreditNote, error) {
+	// Gateways retry webhooks
+	if refundRef != "" {
+		existing, err := s.repo.GetCreditNoteByRefund(ctx, refundRef)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	inv, err := s.paymentInvoice(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.creditableAmount(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	if remaining <= 0 {
+		return nil, errors.New("invoice is already fully credited")
+	}
+	if amount <= 0 || amount > remaining {
+		amount = remaining
+	}
+
+	// The refund includes tax; split it at the invoice's rate
+	total := domain.RoundAmount(amount, inv.Currency)
+	net := domain.RoundAmount(total/(1+inv.TaxRate()), inv.Currency)
+	description := "Refund"
+	if len(inv.Items) == 1 {
+		description = "Refund: " + inv.Items[0].Description
+	}
+
+	cn := s.buildCreditNote(inv, domain.CreditNoteRefund, note)
+	cn.RefundRef = refundRef
+	cn.Items = []domain.InvoiceItem{{Description: description, Quantity: 1, UnitPrice: net, Total: net}}
+	cn.SubTotal = net
+	cn.TaxAmount = domain.RoundAmount(total-net, inv.Currency)
+	cn.TotalAmount = total
+
+	if err := s.createNumberedCreditNote(ctx, cn); err != nil {
+		return nil, err
+	}
+	return cn, nil
+}
+
+func (s *InvoiceServiceImpl) IssueAdjustmentCreditNote(ctx context.Context, adminID string, invoiceID string, note string, items []domain.InvoiceItem) (*domain.CreditNote, error) {
+	inv, user, err := s.accessibleInvoice(ctx, adminID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasRole(domain.RoleAdmin) {
+		return nil, errors.New("only admins can issue adjustment credit notes")
+	}
+	if inv.Status == domain.InvoiceStatusVoid {
+		return nil, errors.New("void invoices cannot be credited")
+	}
+	if strings.TrimSpace(note) == "" {
+		return nil, errors.New("a note explaining the adjustment is required")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("at least one item is required")
+	}
+
+	var net float64
+	lines := make([]domain.InvoiceItem, 0, len(items))
+	for _, item := range items {
+		if strings.TrimSpace(item.Description) == "" {
+			return nil, errors.New("item description is required")
+		}
+		if item.Quantity <= 0 {
+			item.Quantity = 1
+		}
+		if item.UnitPrice <= 0 {
+			return nil, errors.New("item unit price must be positive")
+		}
+		item.Total = domain.RoundAmount(item.UnitPrice*float64(item.Quantity), inv.Currency)
+		net += item.Total
+		lines = append(lines, item)
+	}
+	net = domain.RoundAmount(net, inv.Currency)
+	tax := domain.RoundAmount(net*inv.TaxRate(), inv.Currency)
+
+	remaining, err := s.creditableAmount(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	if net+tax > remaining+0.005 {
+		return nil, fmt.Errorf("credit exceeds the %.2f %s left on the invoice", remaining, inv.Currency)
+	}
+
+	cn := s.buildCreditNote(inv, domain.CreditNoteAdjustment, note)
+	cn.IssuedBy = &user.ID
+	cn.Items = lines
+	cn.SubTotal = net
+	cn.TaxAmount = tax
+	cn.TotalAmount = domain.RoundAmount(net+tax, inv.Currency)
+
+	if err := s.createNumberedCreditNote(ctx, cn); err != nil {
+		return nil, err
+	}
+	return cn, nil
+}
+
+func (s *InvoiceServiceImpl) GetCreditNote(ctx context.Context, userID string, creditNoteID string) (*domain.CreditNote, error) {
+	cOID, err := primitive.ObjectIDFromHex(creditNoteID)
+	if err != nil {
+		return nil, errors.New("invalid credit note ID")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	cn, err := s.repo.GetCreditNote(ctx, cOID)
+	if err != nil {
+		return nil, err
+	}
+	// Same visibility as the credited invoice
+	if cn == nil || (cn.UserID != user.ID && cn.SellerID != user.ID && !user.HasRole(domain.RoleAdmin)) {
+		return nil, errors.New("credit note not found")
+	}
+	return cn, nil
+}
+
+func (s *InvoiceServiceImpl) GetCreditNotePDF(ctx context.Context, userID string, creditNoteID string) ([]byte, error) {
+	cn, err := s.GetCreditNote(ctx, userID, creditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.renderer.RenderCreditNotePDF(cn)
+}
+
+func (s *InvoiceServiceImpl) ListCreditNotes(ctx context.Context, userID string, invoiceID string) ([]*domain.CreditNote, error) {
+	inv, _, err := s.accessibleInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetCreditNotesByInvoice(ctx, inv.ID)
+}
+
+// paymentInvoice returns the current invoice of a payment, issuing it if it is missing
+func (s *InvoiceServiceImpl) paymentInvoice(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	if payment.InvoiceID != nil {
+		inv, err := s.repo.GetInvoice(ctx, *payment.InvoiceID)
+		if err != nil {
+			return nil, err
+		}
+		if inv != nil {
+			if inv.Status == domain.InvoiceStatusVoid {
+				return nil, errors.New("void invoices cannot be credited")
+			}
+			return inv, nil
+		}
+	}
+	return s.GenerateInvoiceForPayment(ctx, payment)
+}
+
+// creditableAmount is what is left of the invoice total after earlier credit notes
+func (s *InvoiceServiceImpl) creditableAmount(ctx context.Context, inv *domain.Invoice) (float64, error) {
+	notes, err := s.repo.GetCreditNotesByInvoice(ctx, inv.ID)
+	if err != nil {
+		return 0, err
+	}
+	remaining := inv.TotalAmount
+	for _, n := range notes {
+		remaining -= n.TotalAmount
+	}
+	return domain.RoundAmount(remaining, inv.Currency), nil
+}
+
+// buildCreditNote snapshots the parties of the credited invoice
+func (s *InvoiceServiceImpl) buildCreditNote(inv *domain.Invoice, reason domain.CreditNoteReason, note string) *domain.CreditNote {
+	return &domain.CreditNote{
+		InvoiceID:     inv.ID,
+		InvoiceNumber: inv.InvoiceNumber,
+		UserID:        inv.UserID,
+		SellerID:      inv.SellerID,
+		TransactionID: inv.TransactionID,
+		Reason:        reason,
+		Note:          strings.TrimSpace(note),
+		Seller:        inv.Seller,
+		Buyer:         inv.Buyer,
+		Currency:      inv.Currency,
+		CreatedAt:     time.Now(),
+	}
+}
+
+func (s *InvoiceServiceImpl) createNumberedCreditNote(ctx context.Context, cn *domain.CreditNote) error {
+	key, prefix := sellerSeries(cn.SellerID, "CN")
+	return s.issueNumber(ctx, creditNoteSeriesPrefix+key, func(seq int64) error {
+		cn.Sequence = seq
+		cn.CreditNoteNumber = fmt.Sprintf("%s-%06d", prefix, seq)
+		return s.repo.CreateCreditNote(ctx, cn)
+	})
+}
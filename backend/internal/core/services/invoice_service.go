@@ -1,83 +1,355 @@
 package services
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"auth-payment-backend/internal/adapters/config"
 	"auth-payment-backend/internal/core/domain"
 	"auth-payment-backend/internal/core/ports"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// platformSellerKey numbers the invoices of sales without a creator
+const platformSellerKey = "platform"
+
 type InvoiceServiceImpl struct {
-	repo       ports.InvoiceRepository
-	walletRepo ports.WalletRepository // To fetch transaction details
+	repo        ports.InvoiceRepository
+	paymentRepo ports.PaymentRepository
+	userRepo    ports.UserRepository
+	renderer    ports.InvoiceRenderer
+	config      *config.Config
 }
 
-func NewInvoiceService(repo ports.InvoiceRepository, walletRepo ports.WalletRepository) ports.InvoiceService {
+func NewInvoiceService(repo ports.InvoiceRepository, paymentRepo ports.PaymentRepository, userRepo ports.UserRepository, renderer ports.InvoiceRenderer, cfg *config.Config) ports.InvoiceService {
 	return &InvoiceServiceImpl{
-		repo:       repo,
-		walletRepo: walletRepo,
+		repo:        repo,
+		paymentRepo: paymentRepo,
+		userRepo:    userRepo,
+		renderer:    renderer,
+		config:      cfg,
 	}
 }
 
 func (s *InvoiceServiceImpl) GenerateInvoiceForTransaction(ctx context.Context, txID string) (*domain.Invoice, error) {
-	// 1. Get Transaction
-	// In a real app we'd fetch the TX. For now, we mock or assume it exists.
-	// Since walletRepo doesn't have GetTransactionByID exposed in interface explicitly yet,
-	// we will assume we can fetch it or just create a mock invoice for this phase.
+	tOID, err := primitive.ObjectIDFromHex(txID)
+	if err != nil {
+		return nil, errors.New("invalid transaction ID")
+	}
+	payment, err := s.paymentRepo.GetPayment(ctx, tOID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.New("payment not found")
+	}
+	return s.GenerateInvoiceForPayment(ctx, payment)
+}
 
-	// Let's create a real-ish invoice object
-	tOID, _ := primitive.ObjectIDFromHex(txID)
+func (s *InvoiceServiceImpl) GenerateInvoiceForPayment(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	switch payment.Status {
+	case domain.PaymentStatusSucceeded, domain.PaymentStatusRefunded, domain.PaymentStatusPartiallyRefunded:
+	default:
+		return nil, errors.New("payment has not succeeded")
+	}
 
-	inv := &domain.Invoice{
-		UserID:        primitive.NewObjectID(), // Should come from TX
-		TransactionID: tOID,
-		InvoiceNumber: fmt.Sprintf("INV-%d", time.Now().Unix()),
-		Status:        domain.InvoiceStatusPaid,
-		Currency:      "USD",
-		Items: []domain.InvoiceItem{
-			{Description: "Service Fee", Quantity: 1, UnitPrice: 100.00, Total: 100.00},
-		},
-		SubTotal:    100.00,
-		TotalAmount: 100.00,
-		CreatedAt:   time.Now(),
+	// One invoice per payment
+	existing, err := s.repo.GetInvoiceByTransaction(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
 	}
 
-	if err := s.repo.CreateInvoice(ctx, inv); err != nil {
+	inv := s.buildInvoice(ctx, payment)
+	if err := s.createNumbered(ctx, inv); err != nil {
+		if errors.Is(err, ports.ErrInvoiceExists) {
+			// Another request invoiced the payment between the check and the insert
+			return s.repo.GetInvoiceByTransaction(ctx, payment.ID)
+		}
 		return nil, err
 	}
 
+	payment.InvoiceID = &inv.ID
+	if err := s.paymentRepo.SetInvoice(ctx, payment.ID, inv.ID); err != nil {
+		log.Printf("Failed to link invoice %s to payment %s: %v", inv.InvoiceNumber, payment.ID.Hex(), err)
+	}
 	return inv, nil
 }
 
-func (s *InvoiceServiceImpl) GetInvoicePDF(ctx context.Context, invoiceID string) ([]byte, error) {
-	// Simple text-based PDF generation mockup
-	// In production, use `jung-kurt/gofpdf`
+func (s *InvoiceServiceImpl) GetInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, error) {
+	inv, _, err := s.accessibleInvoice(ctx, userID, invoiceID)
+	return inv, err
+}
 
-	iOID, err := primitive.ObjectIDFromHex(invoiceID)
+func (s *InvoiceServiceImpl) GetInvoicePDF(ctx context.Context, userID string, invoiceID string) ([]byte, error) {
+	inv, _, err := s.accessibleInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.renderer.RenderPDF(inv)
+}
+
+func (s *InvoiceServiceImpl) ListInvoices(ctx context.Context, userID string, view domain.InvoiceView, filter domain.InvoiceFilter) ([]*domain.Invoice, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	filter.UserID, filter.SellerID = nil, nil
+	switch view {
+	case "", domain.InvoiceViewPurchases:
+		filter.UserID = &user.ID
+	case domain.InvoiceViewSales:
+		filter.SellerID = &user.ID
+	case domain.InvoiceViewAll:
+		if !user.HasRole(domain.RoleAdmin) {
+			return nil, errors.New("only admins can list all invoices")
+		}
+	default:
+		return nil, errors.New("invalid invoice view")
+	}
+	return s.repo.ListInvoices(ctx, filter)
+}
+
+func (s *InvoiceServiceImpl) VoidInvoice(ctx context.Context, userID string, invoiceID string, reason string) (*domain.Invoice, error) {
+	inv, err := s.manageableInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status == domain.InvoiceStatusVoid {
+		return nil, errors.New("invoice is already void")
+	}
+	if reason == "" {
+		reason = "voided"
+	}
+	if err := s.void(ctx, inv, reason); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (s *InvoiceServiceImpl) ReissueInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, error) {
+	original, err := s.manageableInvoice(ctx, userID, invoiceID)
 	if err != nil {
 		return nil, err
 	}
+	if original.ReplacedByInvoiceID != nil {
+		return nil, errors.New("invoice was already reissued")
+	}
+	payment, err := s.paymentRepo.GetPayment(ctx, original.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.New("invoice has no payment to reissue from")
+	}
+
+	if original.Status != domain.InvoiceStatusVoid {
+		if err := s.void(ctx, original, "reissued"); err != nil {
+			return nil, err
+		}
+	}
+
+	replacement := s.buildInvoice(ctx, payment)
+	replacement.ReplacesInvoiceID = &original.ID
+	if err := s.createNumbered(ctx, replacement); err != nil {
+		return nil, err
+	}
+
+	original.ReplacedByInvoiceID = &replacement.ID
+	if _, err := s.repo.TransitionInvoice(ctx, original, domain.InvoiceStatusVoid); err != nil {
+		log.Printf("Failed to link invoice %s to its replacement: %v", original.InvoiceNumber, err)
+	}
+	if err := s.paymentRepo.SetInvoice(ctx, payment.ID, replacement.ID); err != nil {
+		log.Printf("Failed to link invoice %s to payment %s: %v", replacement.InvoiceNumber, payment.ID.Hex(), err)
+	}
+	return replacement, nil
+}
+
+// void marks the invoice as void. It keeps its number so the sequence has no gaps.
+func (s *InvoiceServiceImpl) void(ctx context.Context, inv *domain.Invoice, reason string) error {
+	from := inv.Status
+	now := time.Now()
+	inv.Status = domain.InvoiceStatusVoid
+	inv.VoidedAt = &now
+	inv.VoidReason = reason
+
+	ok, err := s.repo.TransitionInvoice(ctx, inv, from)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invoice was changed meanwhile, try again")
+	}
+	return nil
+}
 
+// accessibleInvoice loads an invoice the user bought, sold, or may see as admin
+func (s *InvoiceServiceImpl) accessibleInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, *domain.User, error) {
+	iOID, err := primitive.ObjectIDFromHex(invoiceID)
+	if err != nil {
+		return nil, nil, errors.New("invalid invoice ID")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.New("user not found")
+	}
 	inv, err := s.repo.GetInvoice(ctx, iOID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Invoices of other users look exactly like missing ones
+	if inv == nil || (inv.UserID != user.ID && inv.SellerID != user.ID && !user.HasRole(domain.RoleAdmin)) {
+		return nil, nil, errors.New("invoice not found")
+	}
+	return inv, user, nil
+}
+
+// manageableInvoice loads an invoice the user may void or reissue: as its seller or an admin
+func (s *InvoiceServiceImpl) manageableInvoice(ctx context.Context, userID string, invoiceID string) (*domain.Invoice, error) {
+	inv, user, err := s.accessibleInvoice(ctx, userID, invoiceID)
 	if err != nil {
 		return nil, err
 	}
+	if inv.SellerID != user.ID && !user.HasRole(domain.RoleAdmin) {
+		return nil, errors.New("only the seller can change this invoice")
+	}
+	return inv, nil
+}
+
+// buildInvoice copies the price breakdown of the payment and snapshots both parties,
+// so later profile changes do not alter an issued invoice
+func (s *InvoiceServiceImpl) buildInvoice(ctx context.Context, payment *domain.Payment) *domain.Invoice {
+	quantity := payment.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	unitPrice := payment.UnitPrice
+	if unitPrice <= 0 {
+		unitPrice = payment.ListAmount / float64(quantity)
+	}
+	description := payment.Description
+	if description == "" {
+		description = "Payment"
+	}
+
+	now := time.Now()
+	inv := &domain.Invoice{
+		UserID:         payment.UserID,
+		SellerID:       payment.CreatorID,
+		TransactionID:  payment.ID,
+		SubscriptionID: payment.SubscriptionID,
+		Seller:         s.sellerParty(ctx, payment.CreatorID),
+		Buyer:          s.buyerParty(ctx, payment),
+		Items: []domain.InvoiceItem{
+			{Description: description, Quantity: quantity, UnitPrice: unitPrice, Total: payment.ListAmount},
+		},
+		Discounts:      payment.Discounts,
+		SubTotal:       payment.ListAmount,
+		DiscountAmount: payment.DiscountAmount,
+		TaxAmount:      payment.TaxAmount,
+		TotalAmount:    payment.Amount,
+		Currency:       strings.ToUpper(payment.Currency),
+		PlatformFee:    payment.PlatformFee,
+		Status:         domain.InvoiceStatusPaid,
+		CreatedAt:      now,
+		PaidAt:         payment.PaidAt,
+	}
+	if payment.Tax != nil {
+		inv.TaxLines = payment.Tax.Lines()
+		inv.TaxInclusive = payment.Tax.Inclusive
+		inv.ReverseCharge = payment.Tax.ReverseCharge
+	}
+	return inv
+}
+
+// createNumbered stores the invoice under the seller's next number
+func (s *InvoiceServiceImpl) createNumbered(ctx context.Context, inv *domain.Invoice) error {
+	key, prefix := sellerSeries(inv.SellerID, "INV")
+	return s.issueNumber(ctx, key, func(seq int64) error {
+		inv.Sequence = seq
+		inv.InvoiceNumber = fmt.Sprintf("%s-%06d", prefix, seq)
+		return s.repo.CreateInvoice(ctx, inv)
+	})
+}
+
+// sellerSeries names the counter and number prefix of a seller's documents
+func sellerSeries(sellerID primitive.ObjectID, kind string) (key string, prefix string) {
+	if sellerID.IsZero() {
+		return platformSellerKey, kind + "-PLAT"
+	}
+	hex := sellerID.Hex()
+	return hex, kind + "-" + strings.ToUpper(hex[len(hex)-6:])
+}
+
+// issueNumber stores a document under the next number of the series. A number whose
+// document could not be stored is handed back, so the sequence stays gap-free.
+func (s *InvoiceServiceImpl) issueNumber(ctx context.Context, key string, create func(seq int64) error) error {
+	seq, err := s.repo.NextInvoiceSequence(ctx, key)
+	if err != nil {
+		return err
+	}
 
-	// Create a simple text buffer acting as a file content
-	var b bytes.Buffer
-	b.WriteString(fmt.Sprintf("INVOICE #%s\n", inv.InvoiceNumber))
-	b.WriteString(fmt.Sprintf("Date: %s\n", inv.CreatedAt.Format(time.RFC3339)))
-	b.WriteString("--------------------------------\n")
-	for _, item := range inv.Items {
-		b.WriteString(fmt.Sprintf("%s x%d : $%.2f\n", item.Description, item.Quantity, item.Total))
+	err = create(seq)
+	if err == nil {
+		return nil
 	}
-	b.WriteString("--------------------------------\n")
-	b.WriteString(fmt.Sprintf("TOTAL: $%.2f %s\n", inv.TotalAmount, inv.Currency))
+	released, relErr := s.repo.ReleaseInvoiceSequence(ctx, key, seq)
+	if relErr == nil && released {
+		return err
+	}
+	if errors.Is(err, ports.ErrInvoiceExists) {
+		// The document is a duplicate and won't store under any number
+		log.Printf("Document number %d of series %s was skipped: %v", seq, key, err)
+		return err
+	}
+	// Later numbers were issued meanwhile; retry once so this one is not skipped
+	if retryErr := create(seq); retryErr != nil {
+		log.Printf("Document number %d of series %s was skipped: %v", seq, key, retryErr)
+		return retryErr
+	}
+	return nil
+}
+
+func (s *InvoiceServiceImpl) sellerParty(ctx context.Context, creatorID primitive.ObjectID) domain.InvoiceParty {
+	if creatorID.IsZero() {
+		return domain.InvoiceParty{Name: s.config.PlatformName}
+	}
+	return s.userParty(ctx, creatorID, s.config.PlatformName)
+}
 
-	return b.Bytes(), nil
+// buyerParty adds the billing address and tax ID the payment was taxed by
+func (s *InvoiceServiceImpl) buyerParty(ctx context.Context, payment *domain.Payment) domain.InvoiceParty {
+	party := domain.InvoiceParty{Name: "Customer"}
+	if !payment.UserID.IsZero() {
+		if user, err := s.userRepo.GetByID(ctx, payment.UserID.Hex()); err == nil && user != nil {
+			party = domain.InvoiceParty{Name: user.FullName, Email: user.Email}
+			if user.BillingAddress != nil {
+				party.Address = user.BillingAddress.Lines()
+				party.TaxID = user.BillingAddress.TaxID
+			}
+		}
+	}
+	if payment.Tax != nil && payment.Tax.BuyerTaxID != "" {
+		party.TaxID = payment.Tax.BuyerTaxID
+	}
+	return party
+}
+
+func (s *InvoiceServiceImpl) userParty(ctx context.Context, userID primitive.ObjectID, fallback string) domain.InvoiceParty {
+	if userID.IsZero() {
+		return domain.InvoiceParty{Name: fallback}
+	}
+	user, err := s.userRepo.GetByID(ctx, userID.Hex())
+	if err != nil || user == nil {
+		return domain.InvoiceParty{Name: fallback}
+	}
+	return domain.InvoiceParty{Name: user.FullName, Email: user.Email}
 }
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LockoutError is returned when an account or client IP is temporarily locked
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// LoginGuard tracks failed logins per account and per IP and applies
+// exponential-backoff lockouts once the configured thresholds are exceeded.
+type LoginGuard struct {
+	store              ports.LoginAttemptStore
+	auditRepo          ports.AuditRepository
+	maxAccountAttempts int
+	maxIPAttempts      int
+	window             time.Duration
+	baseLockout        time.Duration
+	maxLockout         time.Duration
+	now                func() time.Time
+}
+
+func NewLoginGuard(store ports.LoginAttemptStore, auditRepo ports.AuditRepository, cfg *config.Config) *LoginGuard {
+	return &LoginGuard{
+		store:              store,
+		auditRepo:          auditRepo,
+		maxAccountAttempts: cfg.LoginMaxAttempts,
+		maxIPAttempts:      cfg.LoginIPMaxAttempts,
+		window:             cfg.LoginAttemptWindow,
+		baseLockout:        cfg.LoginLockoutBase,
+		maxLockout:         cfg.LoginLockoutMax,
+		now:                time.Now,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a *LockoutError if either the account or the IP is currently locked
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+
+	now := g.now()
+	for _, key := range keys {
+		attempt, err := g.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if attempt != nil && attempt.IsLocked(now) {
+			return &LockoutError{RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// RegisterFailure counts a failed attempt. userID is nil when the email is unknown.
+func (g *LoginGuard) RegisterFailure(ctx context.Context, email, ip string, userID *primitive.ObjectID) error {
+	now := g.now()
+	windowStart := now.Add(-g.window)
+
+	attempt, err := g.store.IncrementFailure(ctx, accountKey(email), now, windowStart)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures >= g.maxAccountAttempts {
+		until := now.Add(g.lockoutFor(attempt.Failures - g.maxAccountAttempts))
+		if err := g.store.Lock(ctx, attempt.Key, until); err != nil {
+			return err
+		}
+		g.audit(ctx, domain.AuditEventAccountLocked, userID, ip, map[string]string{
+			"email":        email,
+			"failures":     fmt.Sprintf("%d", attempt.Failures),
+			"locked_until": until.Format(time.RFC3339),
+		})
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	attempt, err = g.store.IncrementFailure(ctx, ipKey(ip), now, windowStart)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures >= g.maxIPAttempts {
+		until := now.Add(g.lockoutFor(attempt.Failures - g.maxIPAttempts))
+		if err := g.store.Lock(ctx, attempt.Key, until); err != nil {
+			return err
+		}
+		g.audit(ctx, domain.AuditEventIPLocked, nil, ip, map[string]string{
+			"failures":     fmt.Sprintf("%d", attempt.Failures),
+			"locked_until": until.Format(time.RFC3339),
+		})
+	}
+	return nil
+}
+
+// RegisterSuccess clears the account counter. The IP counter is left alone so a
+// single valid login can't be used to reset a credential-stuffing run.
+func (g *LoginGuard) RegisterSuccess(ctx context.Context, email string) error {
+	return g.store.Reset(ctx, accountKey(email))
+}
+
+// Unlock clears any lockout on the account
+func (g *LoginGuard) Unlock(ctx context.Context, email string, userID primitive.ObjectID, actorID primitive.ObjectID) error {
+	if err := g.store.Reset(ctx, accountKey(email)); err != nil {
+		return err
+	}
+	event := &domain.AuditEvent{
+		Type:      domain.AuditEventAccountUnlocked,
+		UserID:    &userID,
+		ActorID:   &actorID,
+		Details:   map[string]string{"email": email},
+		CreatedAt: g.now(),
+	}
+	return g.auditRepo.Record(ctx, event)
+}
+
+// lockoutFor doubles the base lockout for every failure past the threshold
+func (g *LoginGuard) lockoutFor(excess int) time.Duration {
+	d := g.baseLockout
+	for i := 0; i < excess; i++ {
+		d *= 2
+		if d >= g.maxLockout {
+			return g.maxLockout
+		}
+	}
+	if d > g.maxLockout {
+		return g.maxLockout
+	}
+	return d
+}
+
+func (g *LoginGuard) audit(ctx context.Context, eventType domain.AuditEventType, userID *primitive.ObjectID, ip string, details map[string]string) {
+	event := &domain.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		IP:        ip,
+		Details:   details,
+		CreatedAt: g.now(),
+	}
+	if err := g.auditRepo.Record(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", eventType, err)
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetPayment returns one of the buyer's payments, e.g. to show the instructions of a bank transfer again
+func (s *PaymentServiceImpl) GetPayment(ctx context.Context, userID string, orderID string) (*domain.Payment, error) {
+	payment, err := s.loadPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.UserID.Hex() != userID {
+		return nil, errors.New("payment not found")
+	}
+	return payment, nil
+}
+
+// ListManualPayments lists offline payments for admins to reconcile; empty status lists all
+func (s *PaymentServiceImpl) ListManualPayments(ctx context.Context, status string) ([]*domain.Payment, error) {
+	return s.paymentRepo.ListPaymentsByGateway(ctx, domain.GatewayManual, domain.PaymentStatus(status))
+}
+
+// ConfirmManualPayment records an admin's proof that an offline payment was received and
+// settles it like a gateway webhook would: commissions, coupon redemptions and invoice.
+func (s *PaymentServiceImpl) ConfirmManualPayment(ctx context.Context, adminID string, orderID string, notes string) (*domain.Payment, error) {
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		return nil, errors.New("proof of payment notes are required")
+	}
+	adminOID, err := primitive.ObjectIDFromHex(adminID)
+	if err != nil {
+		return nil, errors.New("invalid admin id")
+	}
+
+	payment, err := s.loadPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Gateway != domain.GatewayManual {
+		return nil, errors.New("only manual payments can be confirmed")
+	}
+	switch payment.Status {
+	case domain.PaymentStatusPending:
+	case domain.PaymentStatusExpired:
+		return nil, errors.New("payment has expired; the buyer has to check out again")
+	default:
+		return nil, errors.New("payment is not awaiting confirmation")
+	}
+
+	payment.Confirmation = &domain.PaymentConfirmation{
+		ConfirmedBy: adminOID,
+		Notes:       notes,
+		ConfirmedAt: time.Now(),
+	}
+	ok, err := s.paymentRepo.TransitionPayment(ctx, payment, domain.PaymentStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("payment was changed meanwhile, try again")
+	}
+
+	if err := s.ProcessPaymentSuccess(ctx, payment.Amount, payment.Currency, gatewayMetadata(payment, nil, payment.GatewayOrderRef)); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.GetPayment(ctx, payment.ID)
+}
+
+// ExpirePayments closes pending orders whose deadline passed and gives their reserved coupon uses back.
+// It returns how many orders expired.
+func (s *PaymentServiceImpl) ExpirePayments(ctx context.Context) (int, error) {
+	payments, err := s.paymentRepo.ListExpiredPayments(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, payment := range payments {
+		payment.Status = domain.PaymentStatusExpired
+		ok, err := s.paymentRepo.TransitionPayment(ctx, payment, domain.PaymentStatusPending)
+		if err != nil {
+			log.Printf("Failed to expire payment %s: %v", payment.ID.Hex(), err)
+			continue
+		}
+		if !ok {
+			continue // Confirmed or paid meanwhile
+		}
+		s.releaseRedemptions(ctx, metadataList(payment.Metadata, "coupon_redemptions"), "payment expired")
+		expired++
+	}
+	return expired, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+)
+
+// PaymentExpiryScheduler periodically expires orders that were not paid in time
+type PaymentExpiryScheduler struct {
+	paymentSvc *PaymentServiceImpl
+	interval   time.Duration
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewPaymentExpiryScheduler(paymentSvc *PaymentServiceImpl, cfg *config.Config) *PaymentExpiryScheduler {
+	return &PaymentExpiryScheduler{
+		paymentSvc: paymentSvc,
+		interval:   cfg.PaymentExpiryInterval,
+	}
+}
+
+func (ps *PaymentExpiryScheduler) Start() {
+	ps.stop = make(chan struct{})
+	ps.done = make(chan struct{})
+
+	go func() {
+		defer close(ps.done)
+		ticker := time.NewTicker(ps.interval)
+		defer ticker.Stop()
+
+		ps.run()
+		for {
+			select {
+			case <-ticker.C:
+				ps.run()
+			case <-ps.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (ps *PaymentExpiryScheduler) Stop(ctx context.Context) error {
+	if ps.stop == nil {
+		return nil
+	}
+	close(ps.stop)
+	select {
+	case <-ps.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ps *PaymentExpiryScheduler) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), ps.interval)
+	defer cancel()
+
+	n, err := ps.paymentSvc.ExpirePayments(ctx)
+	if err != nil {
+		log.Printf("Payment expiry failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Expired %d unpaid orders", n)
+	}
+}
@@ -0,0 +1,786 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-payment-backend/internal/adapters/config"
+	"auth-payment-backend/internal/adapters/payment/fake"
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ---- In-memory adapters ----
+
+type memPaymentRepo struct {
+	mu       sync.Mutex
+	payments map[primitive.ObjectID]domain.Payment
+}
+
+func (r *memPaymentRepo) CreatePayment(ctx context.Context, payment *domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if payment.ID.IsZero() {
+		payment.ID = primitive.NewObjectID()
+	}
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memPaymentRepo) GetPayment(ctx context.Context, id primitive.ObjectID) (*domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, nil
+	}
+	return &payment, nil
+}
+
+func (r *memPaymentRepo) GetPaymentByGatewayRef(ctx context.Context, gateway domain.PaymentGateway, ref string) (*domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, payment := range r.payments {
+		if payment.Gateway == gateway && (payment.GatewayOrderRef == ref || payment.TransactionID == ref) {
+			return &payment, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memPaymentRepo) UpdatePayment(ctx context.Context, payment *domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memPaymentRepo) SetInvoice(ctx context.Context, id primitive.ObjectID, invoiceID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payment := r.payments[id]
+	payment.InvoiceID = &invoiceID
+	r.payments[id] = payment
+	return nil
+}
+
+func (r *memPaymentRepo) SetCommission(ctx context.Context, id primitive.ObjectID, affiliateID primitive.ObjectID, commission float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payment := r.payments[id]
+	payment.AffiliateID = &affiliateID
+	payment.Commission = commission
+	r.payments[id] = payment
+	return nil
+}
+
+func (r *memPaymentRepo) ListPaymentsByGateway(ctx context.Context, gateway domain.PaymentGateway, status domain.PaymentStatus) ([]*domain.Payment, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *memPaymentRepo) ListExpiredPayments(ctx context.Context, now time.Time) ([]*domain.Payment, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *memPaymentRepo) TransitionPayment(ctx context.Context, payment *domain.Payment, from domain.PaymentStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.payments[payment.ID]
+	if !ok || stored.Status != from {
+		return false, nil
+	}
+	r.payments[payment.ID] = *payment
+	return true, nil
+}
+
+func (r *memPaymentRepo) byUser(userID primitive.ObjectID) []domain.Payment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Payment
+	for _, payment := range r.payments {
+		if payment.UserID == userID {
+			out = append(out, payment)
+		}
+	}
+	return out
+}
+
+type memSubscriptionRepo struct {
+	mu     sync.Mutex
+	subs   map[primitive.ObjectID]domain.Subscription
+	cycles []domain.SubscriptionCycle
+}
+
+func (r *memSubscriptionRepo) CreateSubscription(ctx context.Context, sub *domain.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub.ID = primitive.NewObjectID()
+	r.subs[sub.ID] = *sub
+	return nil
+}
+
+func (r *memSubscriptionRepo) GetByStripeID(ctx context.Context, stripeSubID string) (*domain.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sub := range r.subs {
+		if sub.StripeSubID == stripeSubID {
+			return &sub, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memSubscriptionRepo) GetByUser(ctx context.Context, userID primitive.ObjectID) ([]*domain.Subscription, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *memSubscriptionRepo) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sub, nil
+}
+
+func (r *memSubscriptionRepo) UpdateSubscription(ctx context.Context, sub *domain.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs[sub.ID] = *sub
+	return nil
+}
+
+func (r *memSubscriptionRepo) CreateCycle(ctx context.Context, cycle *domain.SubscriptionCycle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cycle.ID = primitive.NewObjectID()
+	r.cycles = append(r.cycles, *cycle)
+	return nil
+}
+
+func (r *memSubscriptionRepo) GetCycleByInvoice(ctx context.Context, subscriptionID primitive.ObjectID, invoiceRef string) (*domain.SubscriptionCycle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cycle := range r.cycles {
+		if cycle.SubscriptionID == subscriptionID && cycle.InvoiceRef == invoiceRef {
+			return &cycle, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memSubscriptionRepo) GetCycles(ctx context.Context, subscriptionID primitive.ObjectID) ([]*domain.SubscriptionCycle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*domain.SubscriptionCycle
+	for i := range r.cycles {
+		if r.cycles[i].SubscriptionID == subscriptionID {
+			cycle := r.cycles[i]
+			out = append(out, &cycle)
+		}
+	}
+	return out, nil
+}
+
+type memUserRepo struct {
+	ports.UserRepository
+	mu    sync.Mutex
+	users map[string]domain.User
+}
+
+func (r *memUserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &user, nil
+}
+
+func (r *memUserRepo) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID.Hex()] = *user
+	return nil
+}
+
+func (r *memUserRepo) GetByStripeConnectID(ctx context.Context, connectID string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.StripeConnectID == connectID {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memUserRepo) UpdateStripeConnect(ctx context.Context, userID string, connectID string, status string, account *domain.ConnectAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+	user.StripeConnectID = connectID
+	user.StripeConnectStatus = status
+	user.StripeConnectAccount = account
+	r.users[userID] = user
+	return nil
+}
+
+// ---- Service stubs ----
+
+type stubPricing struct {
+	ports.PricingService
+	plans map[string]*domain.PricingPlan
+}
+
+func (s *stubPricing) GetPlan(ctx context.Context, id string) (*domain.PricingPlan, error) {
+	plan, ok := s.plans[id]
+	if !ok {
+		return nil, errors.New("plan not found")
+	}
+	return plan, nil
+}
+
+func (s *stubPricing) PriceIn(ctx context.Context, plan *domain.PricingPlan, currency string) (*domain.PlanPrice, error) {
+	price := &domain.PlanPrice{Currency: "USD", Factor: 1, StripePriceID: plan.StripePriceID}
+	switch {
+	case plan.OneTimeConfig != nil:
+		price.Amount = plan.OneTimeConfig.Price
+	case plan.SubscriptionConfig != nil:
+		price.Amount = plan.SubscriptionConfig.Price
+	}
+	return price, nil
+}
+
+type stubAffiliates struct {
+	ports.AffiliateService
+}
+
+func (s *stubAffiliates) ResolveAttribution(ctx context.Context, userID string, explicitCode string, visitor *domain.AffiliateAttribution) (string, error) {
+	return explicitCode, nil
+}
+
+func (s *stubAffiliates) CancelOrderCommissions(ctx context.Context, orderID string, reason string) error {
+	return nil
+}
+
+type stubCoupons struct {
+	ports.CouponService
+}
+
+type stubInvoices struct {
+	ports.InvoiceService
+	mu          sync.Mutex
+	invoices    int
+	creditNotes int
+}
+
+func (s *stubInvoices) GenerateInvoiceForPayment(ctx context.Context, payment *domain.Payment) (*domain.Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invoices++
+	return &domain.Invoice{}, nil
+}
+
+func (s *stubInvoices) IssueRefundCreditNote(ctx context.Context, payment *domain.Payment, amount float64, refundRef string, note string) (*domain.CreditNote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creditNotes++
+	return &domain.CreditNote{}, nil
+}
+
+type noTax struct{}
+
+func (noTax) Calculate(ctx context.Context, req domain.TaxRequest) (*domain.TaxResult, error) {
+	return &domain.TaxResult{NetAmount: req.Amount, GrossAmount: req.Amount, Inclusive: req.Inclusive}, nil
+}
+
+func (noTax) NormalizeTaxID(country string, taxID string) (string, error) {
+	return taxID, nil
+}
+
+// ---- Harness ----
+
+type paymentFlow struct {
+	t        *testing.T
+	ctx      context.Context
+	svc      *PaymentServiceImpl
+	gateway  *fake.FakeGateway
+	connect  *fake.FakeConnectGateway
+	users    *memUserRepo
+	payments *memPaymentRepo
+	subs     *memSubscriptionRepo
+	invoices *stubInvoices
+	user     domain.User
+	oneTime  *domain.PricingPlan
+	monthly  *domain.PricingPlan
+}
+
+func newPaymentFlow(t *testing.T) *paymentFlow {
+	t.Helper()
+	ctx := context.Background()
+	gateway := fake.NewFakeGateway(domain.GatewayStripe)
+
+	productRef, err := gateway.CreateProduct(ctx, "Course", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priceRef, err := gateway.CreatePrice(ctx, productRef, 20, "USD", "month")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := domain.User{ID: primitive.NewObjectID(), Email: "buyer@example.com", FullName: "Buyer"}
+	oneTime := &domain.PricingPlan{
+		ID:            primitive.NewObjectID(),
+		Name:          "Course",
+		Type:          domain.PricingTypeOneTime,
+		IsActive:      true,
+		OneTimeConfig: &domain.OneTimeConfig{Price: 100, Currency: "USD"},
+	}
+	monthly := &domain.PricingPlan{
+		ID:                 primitive.NewObjectID(),
+		Name:               "Membership",
+		Type:               domain.PricingTypeSubscription,
+		IsActive:           true,
+		StripeProductID:    productRef,
+		StripePriceID:      priceRef,
+		SubscriptionConfig: &domain.SubscriptionConfig{Price: 20, Currency: "USD", Interval: domain.IntervalMonthly},
+	}
+
+	f := &paymentFlow{
+		t:        t,
+		ctx:      ctx,
+		gateway:  gateway,
+		connect:  fake.NewFakeConnectGateway(),
+		users:    &memUserRepo{users: map[string]domain.User{user.ID.Hex(): user}},
+		payments: &memPaymentRepo{payments: map[primitive.ObjectID]domain.Payment{}},
+		subs:     &memSubscriptionRepo{subs: map[primitive.ObjectID]domain.Subscription{}},
+		invoices: &stubInvoices{},
+		user:     user,
+		oneTime:  oneTime,
+		monthly:  monthly,
+	}
+	f.svc = NewPaymentService(
+		gateway,
+		ports.CheckoutGateways{gateway.Name(): gateway},
+		&stubPricing{plans: map[string]*domain.PricingPlan{oneTime.ID.Hex(): oneTime, monthly.ID.Hex(): monthly}},
+		&stubAffiliates{},
+		&stubCoupons{},
+		NewConnectService(&config.Config{}, f.connect, f.users, &memOAuthStateRepo{states: map[string]domain.OAuthState{}}),
+		f.users,
+		f.subs,
+		f.payments,
+		f.invoices,
+		noTax{},
+		&config.Config{FrontendURL: "http://localhost:3000"},
+	)
+	return f
+}
+
+func (f *paymentFlow) checkout(plan *domain.PricingPlan) *domain.GatewayCheckout {
+	f.t.Helper()
+	checkout, err := f.svc.InitiateCheckout(f.ctx, f.user.ID.Hex(), plan.ID.Hex(), "", nil, nil, 0, 1, "", "")
+	if err != nil {
+		f.t.Fatalf("checkout: %v", err)
+	}
+	return checkout
+}
+
+// deliver passes every pending webhook of the gateway to the service, as the webhook endpoint would
+func (f *paymentFlow) deliver() int {
+	f.t.Helper()
+	webhooks := f.gateway.Webhooks()
+	for _, w := range webhooks {
+		if err := f.svc.HandleWebhook(f.ctx, string(f.gateway.Name()), w.Payload, w.Headers); err != nil {
+			f.t.Fatalf("webhook: %v", err)
+		}
+	}
+	return len(webhooks)
+}
+
+func (f *paymentFlow) payment(orderID string) *domain.Payment {
+	f.t.Helper()
+	payment, err := f.svc.GetPayment(f.ctx, f.user.ID.Hex(), orderID)
+	if err != nil {
+		f.t.Fatalf("payment %s: %v", orderID, err)
+	}
+	return payment
+}
+
+func (f *paymentFlow) pay(plan *domain.PricingPlan) *domain.Payment {
+	f.t.Helper()
+	checkout := f.checkout(plan)
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		f.t.Fatal(err)
+	}
+	f.deliver()
+	return f.payment(checkout.OrderID)
+}
+
+// ---- Tests ----
+
+func TestPaymentFlowSucceeds(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.oneTime)
+
+	if p := f.payment(checkout.OrderID); p.Status != domain.PaymentStatusPending || p.GatewayOrderRef != checkout.Ref {
+		t.Fatalf("after checkout: status %s, order ref %q", p.Status, p.GatewayOrderRef)
+	}
+
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.deliver(); n != 1 {
+		t.Fatalf("delivered %d webhooks, want 1", n)
+	}
+
+	p := f.payment(checkout.OrderID)
+	if p.Status != domain.PaymentStatusSucceeded || p.Amount != 100 || p.TransactionID != checkout.Ref || p.PaidAt == nil {
+		t.Fatalf("after payment: %+v", p)
+	}
+	if f.invoices.invoices != 1 {
+		t.Fatalf("issued %d invoices, want 1", f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowIgnoresRepeatedWebhooks(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.oneTime)
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	webhooks := f.gateway.Webhooks()
+	for i := 0; i < 3; i++ {
+		for _, w := range webhooks {
+			if err := f.svc.HandleWebhook(f.ctx, string(f.gateway.Name()), w.Payload, w.Headers); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if f.invoices.invoices != 1 {
+		t.Fatalf("issued %d invoices, want 1", f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowRecordsOrderlessPaymentsOnce(t *testing.T) {
+	f := newPaymentFlow(t)
+	// A payment made outside checkout: the webhook has no order ID
+	metadata := map[string]string{"payment_id": "pi_outside", "plan_id": f.oneTime.ID.Hex(), "user_id": f.user.ID.Hex()}
+	for i := 0; i < 3; i++ {
+		if err := f.svc.ProcessPaymentSuccess(f.ctx, 100, "USD", metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(f.payments.byUser(f.user.ID)); n != 1 || f.invoices.invoices != 1 {
+		t.Fatalf("recorded %d payments and %d invoices, want 1 each", n, f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowRejectsForgedWebhooks(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.oneTime)
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	w := f.gateway.Webhooks()[0]
+	forged := []byte(strings.Replace(string(w.Payload), `"payment.succeeded"`, `"payment.failed"`, 1))
+
+	if err := f.svc.HandleWebhook(f.ctx, string(f.gateway.Name()), forged, w.Headers); err == nil {
+		t.Fatal("forged webhook was accepted")
+	}
+	if err := f.svc.HandleWebhook(f.ctx, "paypal", w.Payload, w.Headers); err == nil {
+		t.Fatal("webhook for an unconfigured gateway was accepted")
+	}
+	if p := f.payment(checkout.OrderID); p.Status != domain.PaymentStatusPending {
+		t.Fatalf("status %s, want pending", p.Status)
+	}
+}
+
+func TestPaymentFlowDeclineThenRetry(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.oneTime)
+
+	event, err := f.gateway.Confirm(checkout.Ref, fake.CardDeclined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != domain.GatewayEventFailed {
+		t.Fatalf("event %s, want %s", event.Type, domain.GatewayEventFailed)
+	}
+	f.deliver()
+	if p := f.payment(checkout.OrderID); p.Status != domain.PaymentStatusFailed {
+		t.Fatalf("after decline: status %s, want failed", p.Status)
+	}
+
+	// The buyer tries another card on the same order
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	f.deliver()
+	if p := f.payment(checkout.OrderID); p.Status != domain.PaymentStatusSucceeded {
+		t.Fatalf("after retry: status %s, want succeeded", p.Status)
+	}
+}
+
+func TestPaymentFlowRequires3DS(t *testing.T) {
+	tests := []struct {
+		name    string
+		approve bool
+		want    domain.PaymentStatus
+	}{
+		{"authenticated", true, domain.PaymentStatusSucceeded},
+		{"challenge failed", false, domain.PaymentStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newPaymentFlow(t)
+			checkout := f.checkout(f.oneTime)
+
+			event, err := f.gateway.Confirm(checkout.Ref, fake.CardRequires3DS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event != nil || f.deliver() != 0 {
+				t.Fatal("an event was sent before authentication")
+			}
+			if order, _ := f.gateway.Order(checkout.Ref); order.Status != fake.StatusRequiresAction {
+				t.Fatalf("order status %s, want %s", order.Status, fake.StatusRequiresAction)
+			}
+
+			// Capturing from the return page does not complete an unauthenticated order
+			p, err := f.svc.CapturePayment(f.ctx, f.user.ID.Hex(), checkout.OrderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != domain.PaymentStatusPending {
+				t.Fatalf("before authentication: status %s, want pending", p.Status)
+			}
+
+			if _, err := f.gateway.Authenticate(checkout.Ref, tt.approve); err != nil {
+				t.Fatal(err)
+			}
+			f.deliver()
+			if p := f.payment(checkout.OrderID); p.Status != tt.want {
+				t.Fatalf("status %s, want %s", p.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentFlowCaptureBeforeWebhook(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.oneTime)
+	if _, err := f.gateway.Confirm(checkout.Ref, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+
+	// The buyer is back on the return page before the webhook arrives
+	p, err := f.svc.CapturePayment(f.ctx, f.user.ID.Hex(), checkout.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != domain.PaymentStatusSucceeded {
+		t.Fatalf("status %s, want succeeded", p.Status)
+	}
+
+	f.deliver()
+	if f.invoices.invoices != 1 {
+		t.Fatalf("issued %d invoices, want 1", f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowRefunds(t *testing.T) {
+	f := newPaymentFlow(t)
+	paid := f.pay(f.oneTime)
+	orderID := paid.ID.Hex()
+
+	p, err := f.svc.RefundPayment(f.ctx, orderID, 40, "requested by customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != domain.PaymentStatusPartiallyRefunded || p.RefundedAmount != 40 {
+		t.Fatalf("after partial refund: status %s, refunded %.2f", p.Status, p.RefundedAmount)
+	}
+
+	// The gateway's webhook for the same refund is a repeat
+	if n := f.deliver(); n != 1 {
+		t.Fatalf("delivered %d webhooks, want 1", n)
+	}
+	if p := f.payment(orderID); p.RefundedAmount != 40 || len(p.Refunds) != 1 {
+		t.Fatalf("after webhook: refunded %.2f in %d refunds", p.RefundedAmount, len(p.Refunds))
+	}
+
+	if _, err := f.svc.RefundPayment(f.ctx, orderID, 80, ""); err == nil {
+		t.Fatal("refund above the amount left was accepted")
+	}
+
+	// amount 0 refunds the rest
+	p, err = f.svc.RefundPayment(f.ctx, orderID, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.deliver()
+	if p.Status != domain.PaymentStatusRefunded || p.RefundedAmount != 100 {
+		t.Fatalf("after full refund: status %s, refunded %.2f", p.Status, p.RefundedAmount)
+	}
+	if order, _ := f.gateway.Order(paid.GatewayOrderRef); order.Refunded != 100 {
+		t.Fatalf("gateway refunded %.2f, want 100", order.Refunded)
+	}
+	if f.invoices.creditNotes != 2 {
+		t.Fatalf("issued %d credit notes, want 2", f.invoices.creditNotes)
+	}
+}
+
+func TestPaymentFlowManualPaymentNeedsConfirmation(t *testing.T) {
+	f := newPaymentFlow(t)
+	payment := newPendingPayment(f.user.ID, f.oneTime, "USD", 100, 1)
+	payment.Gateway = domain.GatewayManual
+	if err := f.payments.CreatePayment(f.ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+	orderID := payment.ID.Hex()
+
+	// A success report, e.g. from the mock webhook, settles nothing without an admin
+	if err := f.svc.ProcessPaymentSuccess(f.ctx, 1, "USD", map[string]string{"order_id": orderID, "user_id": f.user.ID.Hex()}); err == nil {
+		t.Fatal("manual payment settled without confirmation")
+	}
+	if p := f.payment(orderID); p.Status != domain.PaymentStatusPending || p.Amount != 100 || f.invoices.invoices != 0 {
+		t.Fatalf("after success report: status %s, amount %.2f, %d invoices", p.Status, p.Amount, f.invoices.invoices)
+	}
+
+	p, err := f.svc.ConfirmManualPayment(f.ctx, primitive.NewObjectID().Hex(), orderID, "bank transfer REF-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != domain.PaymentStatusSucceeded || p.Amount != 100 || p.Confirmation == nil || f.invoices.invoices != 1 {
+		t.Fatalf("after confirmation: status %s, amount %.2f, %d invoices", p.Status, p.Amount, f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowSyncsLegacyConnectedSeller(t *testing.T) {
+	f := newPaymentFlow(t)
+
+	// A seller linked with OAuth before the account's state was stored
+	accountID, err := f.connect.ExchangeOAuthCode(f.ctx, f.connect.AuthorizeOAuth("seller@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seller := domain.User{ID: primitive.NewObjectID(), Email: "seller@example.com", StripeConnectID: accountID, StripeConnectStatus: domain.ConnectStatusActive}
+	f.users.users[seller.ID.Hex()] = seller
+	plan := *f.oneTime
+	plan.ID = primitive.NewObjectID()
+	plan.CreatorID = seller.ID
+	f.svc.pricingSvc.(*stubPricing).plans[plan.ID.Hex()] = &plan
+
+	checkout := f.checkout(&plan)
+	if order, _ := f.gateway.Order(checkout.Ref); order.DestinationAccountID != accountID {
+		t.Fatalf("charge routed to %q, want the seller's account %s", order.DestinationAccountID, accountID)
+	}
+	if synced, _ := f.users.GetByID(f.ctx, seller.ID.Hex()); synced.StripeConnectAccount == nil || synced.StripeConnectAccount.Managed {
+		t.Fatalf("account state after checkout: %+v", synced.StripeConnectAccount)
+	}
+}
+
+func TestPaymentFlowSubscriptionRenewals(t *testing.T) {
+	f := newPaymentFlow(t)
+	checkout := f.checkout(f.monthly)
+
+	// The first invoice is paid like any checkout, though its webhook carries no order ID
+	orderRef := strings.TrimSuffix(checkout.ClientSecret, "_secret")
+	if order, _ := f.gateway.Order(orderRef); order.Metadata["order_id"] != "" {
+		t.Fatalf("first invoice's payment carries the order ID, Stripe's does not: %v", order.Metadata)
+	}
+	if _, err := f.gateway.Confirm(orderRef, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	f.deliver()
+
+	if p := f.payment(checkout.OrderID); p.Status != domain.PaymentStatusSucceeded || p.GatewayOrderRef != orderRef || f.invoices.invoices != 1 {
+		t.Fatalf("first invoice: status %s, order ref %q, %d invoices", p.Status, p.GatewayOrderRef, f.invoices.invoices)
+	}
+	sub, err := f.subs.GetByStripeID(f.ctx, checkout.Ref)
+	if err != nil || sub == nil {
+		t.Fatalf("subscription %s not found: %v", checkout.Ref, err)
+	}
+	if sub.Status != domain.SubscriptionStatusActive {
+		t.Fatalf("subscription status %s, want active", sub.Status)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.gateway.Renew(checkout.Ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	webhooks := f.gateway.Webhooks()
+	for _, w := range append(webhooks, webhooks...) { // Retried deliveries are recorded once
+		if err := f.svc.HandleWebhook(f.ctx, string(f.gateway.Name()), w.Payload, w.Headers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cycles, err := f.subs.GetCycles(f.ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 3 {
+		t.Fatalf("recorded %d cycles, want 3", len(cycles))
+	}
+	gatewaySub, _ := f.gateway.Subscription(checkout.Ref)
+	last := cycles[2]
+	if last.Cycle != 3 || last.AmountPaid != 20 || !last.PeriodEnd.Equal(gatewaySub.PeriodEnd) {
+		t.Fatalf("last cycle: %+v", last)
+	}
+	if !last.PeriodStart.Equal(cycles[1].PeriodEnd) {
+		t.Fatalf("cycle 3 starts %v, cycle 2 ends %v", last.PeriodStart, cycles[1].PeriodEnd)
+	}
+
+	// Each renewal is a payment with its own invoice
+	if n := len(f.payments.byUser(f.user.ID)); n != 3 {
+		t.Fatalf("recorded %d payments, want 3", n)
+	}
+	if f.invoices.invoices != 3 {
+		t.Fatalf("issued %d invoices, want 3", f.invoices.invoices)
+	}
+}
+
+func TestPaymentFlowIgnoresUnknownRenewals(t *testing.T) {
+	f := newPaymentFlow(t)
+	ctx := f.ctx
+
+	// A subscription created at the gateway but never through checkout
+	cus, _ := f.gateway.CreateCustomer(ctx, "other@example.com", "Other")
+	gatewaySub, err := f.gateway.CreateSubscription(ctx, cus, f.monthly.StripePriceID, "", nil, map[string]string{}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subRef := gatewaySub.Ref
+	if _, err := f.gateway.Renew(subRef); err == nil {
+		t.Fatal("an unpaid subscription was renewed")
+	}
+	if _, err := f.gateway.Confirm(gatewaySub.PaymentRef, fake.CardSucceeds); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.gateway.Renew(subRef); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := f.deliver(); n != 2 {
+		t.Fatalf("delivered %d webhooks, want 2", n)
+	}
+	if len(f.payments.payments) != 0 || len(f.subs.cycles) != 0 {
+		t.Fatalf("recorded %d payments and %d cycles for an unknown subscription", len(f.payments.payments), len(f.subs.cycles))
+	}
+}
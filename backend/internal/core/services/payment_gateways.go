@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkoutGateway returns a configured gateway; empty selects the primary one
+func (s *PaymentServiceImpl) checkoutGateway(name domain.PaymentGateway) (ports.CheckoutGateway, error) {
+	if name == "" {
+		return s.gateway, nil
+	}
+	gateway, ok := s.gateways[name]
+	if !ok {
+		return nil, fmt.Errorf("payment gateway %s is not available", name)
+	}
+	return gateway, nil
+}
+
+// CapturePayment collects the buyer's approved order, e.g. when PayPal sends them back to
+// the return URL. Captures the gateway has not completed yet are settled by its webhook.
+func (s *PaymentServiceImpl) CapturePayment(ctx context.Context, userID string, orderID string) (*domain.Payment, error) {
+	payment, err := s.loadPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.UserID.Hex() != userID {
+		return nil, errors.New("payment not found")
+	}
+	switch payment.Status {
+	case domain.PaymentStatusSucceeded:
+		return payment, nil // Already captured, e.g. by the webhook
+	case domain.PaymentStatusPending:
+	default:
+		return nil, errors.New("payment is not awaiting capture")
+	}
+
+	gateway, err := s.checkoutGateway(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	return s.capturePayment(ctx, gateway, payment)
+}
+
+func (s *PaymentServiceImpl) capturePayment(ctx context.Context, gateway ports.CheckoutGateway, payment *domain.Payment) (*domain.Payment, error) {
+	if payment.GatewayOrderRef == "" {
+		return nil, errors.New("payment has no gateway order to capture")
+	}
+	capture, err := gateway.CaptureOrder(ctx, payment.GatewayOrderRef)
+	if err != nil {
+		return nil, err
+	}
+	if !capture.Completed {
+		return payment, nil
+	}
+
+	amount, currency := capture.Amount, capture.Currency
+	if amount <= 0 {
+		amount, currency = payment.Amount, payment.Currency
+	}
+	if err := s.ProcessPaymentSuccess(ctx, amount, currency, gatewayMetadata(payment, nil, capture.Ref)); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.GetPayment(ctx, payment.ID)
+}
+
+// HandleWebhook verifies a gateway webhook and feeds it into the same pipeline as the
+// checkout: captures, settlement, failures, refunds and subscription renewals. Events
+// about payments that were not made through checkout are ignored.
+func (s *PaymentServiceImpl) HandleWebhook(ctx context.Context, gatewayName string, payload []byte, headers http.Header) error {
+	gateway, ok := s.gateways[domain.PaymentGateway(gatewayName)]
+	if !ok {
+		return fmt.Errorf("payment gateway %s is not available", gatewayName)
+	}
+	event, err := gateway.ParseWebhook(ctx, payload, headers)
+	if err != nil || event == nil {
+		return err
+	}
+	if event.Type == domain.GatewayEventRenewed {
+		return s.handleRenewal(ctx, gateway.Name(), event)
+	}
+
+	payment, err := s.eventPayment(ctx, gateway.Name(), event)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		log.Printf("Ignoring %s event %s: no payment for %s", gateway.Name(), event.ID, event.Ref)
+		return nil
+	}
+
+	switch event.Type {
+	case domain.GatewayEventApproved:
+		if payment.Status != domain.PaymentStatusPending {
+			return nil
+		}
+		_, err := s.capturePayment(ctx, gateway, payment)
+		return err
+	case domain.GatewayEventSucceeded:
+		amount, currency := event.Amount, event.Currency
+		if amount <= 0 {
+			amount, currency = payment.Amount, payment.Currency
+		}
+		return s.ProcessPaymentSuccess(ctx, amount, currency, gatewayMetadata(payment, event.Metadata, event.Ref))
+	case domain.GatewayEventFailed:
+		return s.ProcessPaymentFailure(ctx, gatewayMetadata(payment, event.Metadata, event.Ref))
+	case domain.GatewayEventRefunded:
+		return s.ProcessPaymentCancellation(ctx, payment.ID.Hex(), event.RefundRef, event.Amount, "")
+	}
+	return nil
+}
+
+// handleRenewal records a paid renewal invoice of a subscription started at checkout
+func (s *PaymentServiceImpl) handleRenewal(ctx context.Context, gateway domain.PaymentGateway, event *domain.GatewayEvent) error {
+	sub, err := s.subRepo.GetByStripeID(ctx, event.SubscriptionRef)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		log.Printf("Ignoring %s event %s: no subscription for %s", gateway, event.ID, event.SubscriptionRef)
+		return nil
+	}
+	_, err = s.ProcessSubscriptionInvoice(ctx, event.SubscriptionRef, event.Ref, event.Amount, event.PeriodStart, event.PeriodEnd)
+	return err
+}
+
+// eventPayment finds the payment a webhook is about, by our order ID or else by the gateway's reference
+func (s *PaymentServiceImpl) eventPayment(ctx context.Context, gateway domain.PaymentGateway, event *domain.GatewayEvent) (*domain.Payment, error) {
+	if oid, err := primitive.ObjectIDFromHex(event.OrderID); err == nil {
+		return s.paymentRepo.GetPayment(ctx, oid)
+	}
+	if event.Ref == "" {
+		return nil, nil
+	}
+	return s.paymentRepo.GetPaymentByGatewayRef(ctx, gateway, event.Ref)
+}
+
+// gatewayMetadata is the checkout metadata of a payment, completed with the gateway's
+// transaction reference. Metadata echoed by the gateway fills in for older payments.
+func gatewayMetadata(payment *domain.Payment, echoed map[string]string, transactionRef string) map[string]string {
+	metadata := map[string]string{}
+	for k, v := range echoed {
+		metadata[k] = v
+	}
+	for k, v := range payment.Metadata {
+		metadata[k] = v
+	}
+	metadata["order_id"] = payment.ID.Hex()
+	if transactionRef != "" {
+		metadata["payment_id"] = transactionRef
+	}
+	return metadata
+}
+
+// RefundPayment refunds a paid order at its gateway (admin). amount 0 refunds the rest.
+// The refund is recorded right away; the gateway's refund webhook is then recognised as a repeat.
+func (s *PaymentServiceImpl) RefundPayment(ctx context.Context, orderID string, amount float64, reason string) (*domain.Payment, error) {
+	payment, err := s.loadPayment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	switch payment.Status {
+	case domain.PaymentStatusSucceeded, domain.PaymentStatusPartiallyRefunded:
+	default:
+		return nil, errors.New("only paid payments can be refunded")
+	}
+	if payment.TransactionID == "" {
+		return nil, errors.New("payment has no gateway transaction to refund")
+	}
+
+	left := domain.RoundAmount(payment.Amount-payment.RefundedAmount, payment.Currency)
+	if amount <= 0 {
+		amount = left
+	}
+	if amount > left {
+		return nil, fmt.Errorf("refund exceeds the %.2f %s left on the payment", left, payment.Currency)
+	}
+
+	gateway, err := s.checkoutGateway(payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	refund, err := gateway.RefundCapture(ctx, payment.TransactionID, amount, payment.Currency, map[string]string{
+		"order_id": orderID,
+		"reason":   reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ProcessPaymentCancellation(ctx, orderID, refund.Ref, refund.Amount, reason); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.GetPayment(ctx, payment.ID)
+}
+
+func (s *PaymentServiceImpl) loadPayment(ctx context.Context, orderID string) (*domain.Payment, error) {
+	oid, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return nil, errors.New("invalid payment id")
+	}
+	payment, err := s.paymentRepo.GetPayment(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.New("payment not found")
+	}
+	return payment, nil
+}
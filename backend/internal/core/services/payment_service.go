@@ -3,7 +3,11 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"strings"
+	"time"
 
 	"auth-payment-backend/internal/adapters/config" // Added
 	"auth-payment-backend/internal/core/domain"
@@ -13,40 +17,77 @@ import (
 )
 
 type PaymentServiceImpl struct {
-	gateway      ports.PaymentGateway
+	gateway      ports.PaymentGateway // Primary gateway: subscriptions and Connect destination charges
+	gateways     ports.CheckoutGateways
 	pricingSvc   ports.PricingService
 	affiliateSvc ports.AffiliateService
 	couponSvc    ports.CouponService
+	connectSvc   ports.ConnectService
 	userRepo     ports.UserRepository
+	subRepo      ports.SubscriptionRepository
+	paymentRepo  ports.PaymentRepository
+	invoiceSvc   ports.InvoiceService
+	tax          ports.TaxCalculator
 	config       *config.Config // Added
 }
 
-func NewPaymentService(gateway ports.PaymentGateway, pricingSvc ports.PricingService, affiliateSvc ports.AffiliateService, couponSvc ports.CouponService, userRepo ports.UserRepository, cfg *config.Config) *PaymentServiceImpl {
+func NewPaymentService(gateway ports.PaymentGateway, gateways ports.CheckoutGateways, pricingSvc ports.PricingService, affiliateSvc ports.AffiliateService, couponSvc ports.CouponService, connectSvc ports.ConnectService, userRepo ports.UserRepository, subRepo ports.SubscriptionRepository, paymentRepo ports.PaymentRepository, invoiceSvc ports.InvoiceService, tax ports.TaxCalculator, cfg *config.Config) *PaymentServiceImpl {
 	return &PaymentServiceImpl{
 		gateway:      gateway,
+		gateways:     gateways,
 		pricingSvc:   pricingSvc,
 		affiliateSvc: affiliateSvc,
 		couponSvc:    couponSvc,
+		connectSvc:   connectSvc,
 		userRepo:     userRepo,
+		subRepo:      subRepo,
+		paymentRepo:  paymentRepo,
+		invoiceSvc:   invoiceSvc,
+		tax:          tax,
 		config:       cfg,
 	}
 }
 
-// InitiateCheckout creates a PaymentIntent for a specific Plan
-func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string, planID string, affiliateCode string, couponCode string, inputAmount float64, quantity int) (string, error) {
+// InitiateCheckout creates an order for a specific Plan at the chosen gateway (the primary
+// one if empty) and returns what the buyer needs to pay it, with the ID of the pending
+// payment (the order ID the webhooks refer to).
+// The credited affiliate is resolved from the explicit code and the visitor's referral cookie.
+// The plan is charged in currency, or else in the buyer's preferred or local currency.
+func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string, planID string, affiliateCode string, referral *domain.AffiliateAttribution, couponCodes []string, inputAmount float64, quantity int, currency string, gatewayName string) (*domain.GatewayCheckout, error) {
+	gateway, err := s.checkoutGateway(domain.PaymentGateway(gatewayName))
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. Get Plan Details
 	plan, err := s.pricingSvc.GetPlan(ctx, planID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	recurring := plan.Type == domain.PricingTypeSubscription && plan.StripePriceID != ""
+	if recurring && gateway.Name() != s.gateway.Name() {
+		return nil, fmt.Errorf("subscriptions cannot be paid with %s", gateway.Name())
+	}
+	price, err := s.planPrice(ctx, plan, userID, currency, recurring)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve Affiliate Attribution
+	affiliateCode, err = s.affiliateSvc.ResolveAttribution(ctx, userID, affiliateCode, referral)
+	if err != nil {
+		log.Printf("Warning: Failed to resolve affiliate attribution for user %s: %v", userID, err)
+		affiliateCode = ""
 	}
 
 	// [New] Determine Destination (Creator) and Application Fee
 	var destinationAccountID string
-	var applicationFeeAmount int64
 	var applicationFeePercent float64
 
-	// Lookup Creator
-	if plan.CreatorID.IsZero() {
+	// Lookup Creator. Destination charges exist on the primary gateway only; other gateways collect for the platform.
+	if gateway.Name() != s.gateway.Name() {
+		log.Printf("Plan %s is paid with %s. Proceeding as platform-only sale.", planID, gateway.Name())
+	} else if plan.CreatorID.IsZero() {
 		log.Printf("Warning: Plan %s has no CreatorID. Proceeding as platform-only sale.", planID)
 	} else {
 		log.Printf("Looking up creator for PlanID: %s, CreatorID: %s", planID, plan.CreatorID.Hex())
@@ -57,8 +98,17 @@ func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string
 		} else {
 			log.Printf("Found Creator: %s, ConnectID: %s, Status: %s", creator.FullName, creator.StripeConnectID, creator.StripeConnectStatus)
 
-			// Check if creator is connected and active
-			if creator.StripeConnectID != "" && creator.StripeConnectStatus == "active" {
+			// Sellers linked before account state was stored are synced on their first sale
+			if creator.StripeConnectID != "" && creator.StripeConnectAccount == nil {
+				if synced, err := s.connectSvc.SyncAccount(ctx, creator.ID.Hex()); err != nil {
+					log.Printf("Warning: Failed to sync connected account %s: %v", creator.StripeConnectID, err)
+				} else {
+					creator = synced
+				}
+			}
+
+			// Route the charge to the creator only if their account can accept it
+			if creator.CanReceiveDestinationCharges() {
 				destinationAccountID = creator.StripeConnectID
 
 				// Calculate Fee
@@ -69,24 +119,26 @@ func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string
 
 				// For subscription, we simply pass the percent
 				applicationFeePercent = feePercent
+			} else if creator.StripeConnectID != "" {
+				log.Printf("Warning: Connected account %s cannot accept charges (status %s). Proceeding as platform-only sale.", creator.StripeConnectID, creator.StripeConnectStatus)
 			}
 		}
 	}
 
 	// 2. Handle Subscription Logic
 	// If it's a subscription AND has a Stripe Price ID, we use the Subscription flow.
-	if plan.Type == domain.PricingTypeSubscription && plan.StripePriceID != "" {
+	if recurring {
 		// A. Get User
 		user, err := s.userRepo.GetByID(ctx, userID)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		// B. Ensure Stripe Customer Exists
 		if user.StripeCustomerID == "" {
 			cusID, err := s.gateway.CreateCustomer(ctx, user.Email, user.FullName)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			user.StripeCustomerID = cusID
 			if err := s.userRepo.Update(ctx, user); err != nil {
@@ -94,7 +146,7 @@ func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string
 			}
 		}
 
-		// C. Create Subscription
+		// C. Attach Coupon. The gateway discounts the invoices its duration covers.
 		metadata := map[string]string{
 			"plan_id": planID,
 			"user_id": userID,
@@ -102,48 +154,271 @@ func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string
 		if affiliateCode != "" {
 			metadata["affiliate_code"] = affiliateCode
 		}
-		if couponCode != "" {
-			metadata["coupon_code"] = couponCode
+		discount, promotionCodeID, err := s.subscriptionCoupon(ctx, plan, price, userID, couponCodes)
+		if err != nil {
+			return nil, errors.New("invalid coupon: " + err.Error())
+		}
+		var redemptionIDs []string
+		if discount != nil {
+			metadata["coupon_codes"] = discount.Code
+			metadata["coupon_redemptions"] = discount.RedemptionID.Hex()
+			redemptionIDs = []string{discount.RedemptionID.Hex()}
+		}
+
+		// D. Record the first payment; the gateway reports it back with this order ID
+		payment, err := s.priceSubscription(ctx, plan, price, user, discount)
+		if err != nil {
+			s.releaseRedemptions(ctx, redemptionIDs, "checkout rejected")
+			return nil, err
+		}
+		payment.Gateway = s.gateway.Name()
+		payment.PlatformFee = domain.RoundAmount((payment.Amount-payment.TaxAmount)*applicationFeePercent/100, payment.Currency)
+		metadata["order_id"] = payment.ID.Hex()
+		payment.Metadata = metadata
+		if err := s.paymentRepo.CreatePayment(ctx, payment); err != nil {
+			s.releaseRedemptions(ctx, redemptionIDs, "checkout could not be saved")
+			return nil, err
+		}
+
+		// E. Create Subscription (Pass Connect args). The gateway adds the tax to every invoice.
+		gatewaySub, err := s.gateway.CreateSubscription(ctx, user.StripeCustomerID, price.StripePriceID, promotionCodeID, payment.Tax, metadata, destinationAccountID, applicationFeePercent)
+		if err != nil {
+			s.releaseRedemptions(ctx, redemptionIDs, "subscription could not be created")
+			s.failPayment(ctx, payment)
+			return nil, err
+		}
+		stripeSubID := gatewaySub.Ref
+		// Webhooks of the first invoice's payment find the order by its ref
+		payment.GatewayOrderRef = gatewaySub.PaymentRef
+
+		// F. Track it locally so renewals can be recorded against it
+		sub := &domain.Subscription{
+			UserID:        user.ID,
+			PricingPlanID: plan.ID,
+			StripeSubID:   stripeSubID,
+			Status:        domain.SubscriptionStatusIncomplete,
+			Discount:      discount,
+			Tax:           payment.Tax,
+		}
+		if price.Currency != plan.BaseCurrency() {
+			sub.Currency = price.Currency
+			sub.Price = price.Amount
+		}
+		if err := s.subRepo.CreateSubscription(ctx, sub); err != nil {
+			log.Printf("Failed to save subscription %s: %v", stripeSubID, err)
+		} else {
+			payment.SubscriptionID = &sub.ID
 		}
+		if err := s.paymentRepo.UpdatePayment(ctx, payment); err != nil {
+			log.Printf("Failed to link payment %s to gateway subscription %s: %v", payment.ID.Hex(), stripeSubID, err)
+		}
+
+		return &domain.GatewayCheckout{
+			Gateway:      s.gateway.Name(),
+			OrderID:      payment.ID.Hex(),
+			Ref:          stripeSubID,
+			ClientSecret: gatewaySub.ClientSecret,
+		}, nil
+	}
+
+	// 3. Fallback to Standard One-Time Payment Logic: price, discounts and tax
+	payment, redemptionIDs, err := s.priceOrder(ctx, plan, price, userID, couponCodes, inputAmount, quantity, true)
+	if err != nil {
+		return nil, err
+	}
+	payment.Gateway = gateway.Name()
+
+	// NEW: Calculate Application Fee Amount for One-Time Payment
+	if destinationAccountID != "" {
+		// Calculate fee on the FINAL amount (after discount), without the tax collected for the authorities
+		feePercent := s.config.PlatformFeePercent
+		payment.PlatformFee = domain.RoundAmount((payment.Amount-payment.TaxAmount)*(feePercent/100), payment.Currency)
+	}
+
+	// 6. Record the pending payment; the gateway reports it back with this order ID.
+	// The metadata is kept with it for gateways that do not echo it in their webhooks.
+	metadata := map[string]string{
+		"plan_id":  planID,
+		"user_id":  userID,
+		"order_id": payment.ID.Hex(),
+	}
+	if affiliateCode != "" {
+		metadata["affiliate_code"] = affiliateCode
+	}
+	if len(couponCodes) > 0 {
+		metadata["coupon_codes"] = strings.Join(couponCodes, ",")
+		metadata["coupon_redemptions"] = strings.Join(redemptionIDs, ",")
+	}
+	payment.Metadata = metadata
+	if err := s.paymentRepo.CreatePayment(ctx, payment); err != nil {
+		s.releaseRedemptions(ctx, redemptionIDs, "checkout could not be saved")
+		return nil, err
+	}
+
+	// 7. Create the gateway order
+	frontendURL := strings.TrimRight(s.config.FrontendURL, "/")
+	checkout, err := gateway.CreateOrder(ctx, domain.GatewayOrder{
+		OrderID:              payment.ID.Hex(),
+		Amount:               payment.Amount,
+		Currency:             payment.Currency,
+		Description:          payment.Description,
+		Metadata:             metadata,
+		DestinationAccountID: destinationAccountID,
+		ApplicationFee:       payment.PlatformFee,
+		ReturnURL:            fmt.Sprintf("%s/checkout/return?order_id=%s", frontendURL, payment.ID.Hex()),
+		CancelURL:            fmt.Sprintf("%s/checkout/cancel?order_id=%s", frontendURL, payment.ID.Hex()),
+	})
+	if err != nil {
+		s.releaseRedemptions(ctx, redemptionIDs, "payment could not be created")
+		s.failPayment(ctx, payment)
+		return nil, err
+	}
+
+	payment.GatewayOrderRef = checkout.Ref
+	payment.Instructions = checkout.Instructions
+	payment.ExpiresAt = checkout.ExpiresAt
+	if err := s.paymentRepo.UpdatePayment(ctx, payment); err != nil {
+		log.Printf("Failed to save gateway order of payment %s: %v", payment.ID.Hex(), err)
+	}
+	return checkout, nil
+}
 
-		// Pass Connect args
-		subID, err := s.gateway.CreateSubscription(ctx, user.StripeCustomerID, plan.StripePriceID, metadata, destinationAccountID, applicationFeePercent)
+// QuoteCheckout prices a checkout like InitiateCheckout, including tax, without
+// reserving coupons or creating anything at the gateway.
+func (s *PaymentServiceImpl) QuoteCheckout(ctx context.Context, userID string, planID string, couponCodes []string, inputAmount float64, quantity int, currency string) (*domain.PriceQuote, error) {
+	plan, err := s.pricingSvc.GetPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	recurring := plan.Type == domain.PricingTypeSubscription && plan.StripePriceID != ""
+	price, err := s.planPrice(ctx, plan, userID, currency, recurring)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *domain.Payment
+	if recurring {
+		user, err := s.userRepo.GetByID(ctx, userID)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if len(couponCodes) > 1 {
+			return nil, errors.New("invalid coupon: only one coupon can be applied to a subscription")
+		}
+		var discount *domain.SubscriptionDiscount
+		if len(couponCodes) == 1 {
+			coupon, _, err := s.couponSvc.ValidateCoupon(ctx, couponCodes[0], &domain.CouponCheck{
+				PlanID:      planID,
+				UserID:      userID,
+				OrderAmount: price.Amount,
+				Factor:      price.Factor,
+			})
+			if err == nil {
+				err = subscriptionCouponCurrency(coupon, plan, price)
+			}
+			if err != nil {
+				return nil, errors.New("invalid coupon: " + err.Error())
+			}
+			discount = domain.NewSubscriptionDiscount(coupon, primitive.NilObjectID, time.Now())
+		}
+		if payment, err = s.priceSubscription(ctx, plan, price, user, discount); err != nil {
+			return nil, err
 		}
+	} else {
+		if payment, _, err = s.priceOrder(ctx, plan, price, userID, couponCodes, inputAmount, quantity, false); err != nil {
+			return nil, err
+		}
+	}
 
-		return subID, nil
+	quote := &domain.PriceQuote{
+		PlanID:         planID,
+		Currency:       payment.Currency,
+		Quantity:       payment.Quantity,
+		ListAmount:     payment.ListAmount,
+		Discounts:      payment.Discounts,
+		DiscountAmount: payment.DiscountAmount,
+		Tax:            payment.Tax,
+		TaxAmount:      payment.TaxAmount,
+		Total:          payment.Amount,
+	}
+	if !price.Explicit {
+		quote.FXRate = price.FXRate
 	}
+	return quote, nil
+}
+
+// planPrice prices the plan in the checkout currency: the one asked for, else the buyer's
+// preferred currency, else the currency of their billing country. Only a currency asked for
+// at checkout is binding; otherwise a plan not sold in it is charged in its base currency.
+// Recurring prices need a gateway price in the currency.
+func (s *PaymentServiceImpl) planPrice(ctx context.Context, plan *domain.PricingPlan, userID string, requested string, recurring bool) (*domain.PlanPrice, error) {
+	currency := domain.NormalizeCurrency(requested)
+	if currency == "" {
+		if user, err := s.userRepo.GetByID(ctx, userID); err == nil && user != nil {
+			currency = user.PreferredCurrency
+			if currency == "" && user.BillingAddress != nil {
+				currency = domain.CurrencyForCountry(user.BillingAddress.Country)
+			}
+		}
+	}
+
+	price, err := s.pricingSvc.PriceIn(ctx, plan, currency)
+	if err == nil && recurring && price.StripePriceID == "" {
+		err = fmt.Errorf("subscription is not available in %s", price.Currency)
+	}
+	if err != nil {
+		if requested != "" {
+			return nil, err
+		}
+		return s.pricingSvc.PriceIn(ctx, plan, "")
+	}
+	return price, nil
+}
+
+// priceSubscription builds the pending payment of a subscription's first invoice
+func (s *PaymentServiceImpl) priceSubscription(ctx context.Context, plan *domain.PricingPlan, price *domain.PlanPrice, user *domain.User, discount *domain.SubscriptionDiscount) (*domain.Payment, error) {
+	payment := newPendingPayment(user.ID, plan, price.Currency, price.Amount, 1)
+	if discount != nil {
+		payment.AddDiscount(discount.Code, "Coupon "+discount.Code, discount.Amount(price.Amount))
+	}
+	if err := s.applyTax(ctx, payment, plan, user.BillingAddress); err != nil {
+		return nil, err
+	}
+	// The gateway charges the plan's price, so it cannot drop the tax out of an inclusive price
+	if payment.Tax != nil && payment.Tax.ReverseCharge && plan.TaxInclusive {
+		return nil, errors.New("tax-inclusive subscription prices cannot be reverse charged")
+	}
+	return payment, nil
+}
+
+// priceOrder prices a one-time checkout in the buyer's currency: list price, early-bird
+// price, coupons and tax. With reserve, a use of each coupon is reserved for the order and
+// the redemption IDs are returned; otherwise coupons are only validated.
+func (s *PaymentServiceImpl) priceOrder(ctx context.Context, plan *domain.PricingPlan, price *domain.PlanPrice, userID string, couponCodes []string, inputAmount float64, quantity int, reserve bool) (*domain.Payment, []string, error) {
+	planID := plan.ID.Hex()
 
-	// 3. Fallback to Standard One-Time Payment Logic (existing code)
 	// Calculate Final Amount
 	amount := 0.0
-	currency := "USD"
+	currency := price.Currency
+	units := 1
 
 	switch plan.Type {
-	case domain.PricingTypeOneTime:
-		amount = plan.OneTimeConfig.Price
-		currency = plan.OneTimeConfig.Currency
+	case domain.PricingTypeOneTime, domain.PricingTypeBundle:
+		amount = price.Amount
 	case domain.PricingTypeSubscription:
 		// Fallback for subscriptions without Stripe Price ID (legacy/local)
-		amount = plan.SubscriptionConfig.Price
-		currency = plan.SubscriptionConfig.Currency
-	case domain.PricingTypeBundle:
-		amount = plan.BundleConfig.Price
-		currency = "USD"
+		amount = price.Amount
 	case domain.PricingTypeSplit:
 		if plan.SplitConfig.UpfrontPayment > 0 {
-			amount = plan.SplitConfig.UpfrontPayment
+			amount = price.Convert(plan.SplitConfig.UpfrontPayment)
 		} else {
-			amount = plan.SplitConfig.TotalAmount / float64(plan.SplitConfig.InstallmentCount)
+			amount = domain.RoundAmount(price.Amount/float64(plan.SplitConfig.InstallmentCount), currency)
 		}
-		currency = plan.SplitConfig.Currency
 	case domain.PricingTypeDonation:
-		if inputAmount < plan.DonationConfig.MinAmount {
-			return "", errors.New("donation amount below minimum")
+		if inputAmount < price.Convert(plan.DonationConfig.MinAmount) {
+			return nil, nil, errors.New("donation amount below minimum")
 		}
 		amount = inputAmount
-		currency = plan.DonationConfig.Currency
 	case domain.PricingTypeTiered:
 		if quantity <= 0 {
 			quantity = 1
@@ -156,80 +431,409 @@ func (s *PaymentServiceImpl) InitiateCheckout(ctx context.Context, userID string
 				max = int(1e9)
 			}
 			if quantity >= tier.MinQty && quantity <= max {
-				unitPrice = tier.UnitPrice
+				unitPrice = price.Convert(tier.UnitPrice)
 				found = true
 				break
 			}
 		}
 		if !found {
-			return "", errors.New("invalid quantity")
+			return nil, nil, errors.New("invalid quantity")
 		}
 		amount = unitPrice * float64(quantity)
-		currency = "USD"
+		units = quantity
 	default:
-		return "", errors.New("unsupported plan type")
+		return nil, nil, errors.New("unsupported plan type")
+	}
+
+	payment := newPendingPayment(primitive.NilObjectID, plan, currency, amount, units)
+	if uOID, err := primitive.ObjectIDFromHex(userID); err == nil {
+		payment.UserID = uOID
 	}
 
-	// 4. Apply Coupon (One-Time Logic)
-	if couponCode != "" {
-		_, discount, err := s.couponSvc.ValidateCoupon(ctx, couponCode, planID)
+	// 4. Apply Early-Bird Price (fixed-price plans only)
+	earlyBird := plan.Type != domain.PricingTypeDonation && plan.EarlyBird.AppliesAt(time.Now())
+	if earlyBird {
+		earlyBirdDiscount := price.Convert(plan.EarlyBird.DiscountAmount)
+		payment.AddDiscount("", "Early-bird price", math.Min(earlyBirdDiscount, amount))
+		amount -= earlyBirdDiscount
+		if amount < 0 {
+			amount = 0
+		}
+	}
+
+	// 5. Apply Coupons (One-Time Logic). Each coupon applies to the amount left by the previous ones
+	// and a use is reserved for this checkout, to be confirmed or released by the payment outcome.
+	var redemptionIDs []string
+	for i, code := range couponCodes {
+		if amount <= 0 {
+			break
+		}
+		others := make([]string, 0, len(couponCodes)-1)
+		others = append(others, couponCodes[:i]...)
+		others = append(others, couponCodes[i+1:]...)
+
+		coupon, discount, err := s.couponSvc.ValidateCoupon(ctx, code, &domain.CouponCheck{
+			PlanID:      planID,
+			UserID:      userID,
+			OrderAmount: amount,
+			Factor:      price.Factor,
+			EarlyBird:   earlyBird,
+			OtherCodes:  others,
+		})
 		if err != nil {
-			return "", errors.New("invalid coupon: " + err.Error())
+			s.releaseRedemptions(ctx, redemptionIDs, "checkout rejected")
+			return nil, nil, errors.New("invalid coupon: " + err.Error())
+		}
+		discount = domain.RoundAmount(discount, currency)
+		if reserve {
+			redemption, err := s.couponSvc.ReserveCoupon(ctx, coupon, userID, planID, discount, currency)
+			if err != nil {
+				s.releaseRedemptions(ctx, redemptionIDs, "checkout rejected")
+				return nil, nil, errors.New("invalid coupon: " + err.Error())
+			}
+			redemptionIDs = append(redemptionIDs, redemption.ID.Hex())
 		}
+		payment.AddDiscount(coupon.Code, "Coupon "+coupon.Code, discount)
 		amount -= discount
 		if amount < 0 {
 			amount = 0
 		}
 	}
 
-	// NEW: Calculate Application Fee Amount for One-Time Payment
-	if destinationAccountID != "" {
-		// Calculate fee on the FINAL amount (after discount)
-		feePercent := s.config.PlatformFeePercent
-		applicationFeeAmount = int64(amount * (feePercent / 100) * 100) // cents
+	// 6. Add the buyer's tax
+	var buyer *domain.BillingAddress
+	if user, err := s.userRepo.GetByID(ctx, userID); err == nil {
+		buyer = user.BillingAddress
 	}
-
-	// 5. Create PaymentIntent
-	metadata := map[string]string{
-		"plan_id": planID,
-		"user_id": userID,
+	if err := s.applyTax(ctx, payment, plan, buyer); err != nil {
+		s.releaseRedemptions(ctx, redemptionIDs, "checkout rejected")
+		return nil, nil, err
 	}
-	if affiliateCode != "" {
-		metadata["affiliate_code"] = affiliateCode
+	return payment, redemptionIDs, nil
+}
+
+// applyTax computes the tax of the discounted price and sets the amount to charge
+func (s *PaymentServiceImpl) applyTax(ctx context.Context, payment *domain.Payment, plan *domain.PricingPlan, buyer *domain.BillingAddress) error {
+	taxable := math.Max(payment.ListAmount-payment.DiscountAmount, 0)
+	result, err := s.tax.Calculate(ctx, domain.TaxRequest{
+		Amount:    taxable,
+		Currency:  payment.Currency,
+		Inclusive: plan.TaxInclusive,
+		Buyer:     buyer,
+	})
+	if err != nil {
+		return err
 	}
-	if couponCode != "" {
-		metadata["coupon_code"] = couponCode
+
+	payment.Amount = result.GrossAmount
+	payment.TaxAmount = result.TaxAmount
+	if result.Name == "" {
+		return nil
 	}
 
-	return s.gateway.CreatePaymentIntent(ctx, amount, currency, metadata, destinationAccountID, applicationFeeAmount)
+	// A reverse-charged buyer pays the price without the included tax, so the breakdown is restated net
+	if result.Inclusive && result.ReverseCharge {
+		factor := 1 / (1 + result.Rate)
+		payment.UnitPrice = domain.RoundAmount(payment.UnitPrice*factor, payment.Currency)
+		if len(payment.Discounts) == 0 {
+			payment.ListAmount = result.NetAmount
+		} else {
+			payment.ListAmount = domain.RoundAmount(payment.ListAmount*factor, payment.Currency)
+			payment.DiscountAmount = domain.RoundAmount(payment.ListAmount-result.NetAmount, payment.Currency)
+			var restated float64
+			for i := range payment.Discounts {
+				payment.Discounts[i].Amount = domain.RoundAmount(payment.Discounts[i].Amount*factor, payment.Currency)
+				restated += payment.Discounts[i].Amount
+			}
+			// Rounding differences go to the last discount
+			last := &payment.Discounts[len(payment.Discounts)-1]
+			last.Amount = domain.RoundAmount(last.Amount+payment.DiscountAmount-restated, payment.Currency)
+		}
+		result.Inclusive = false
+	}
+	payment.Tax = result
+	return nil
 }
 
 // ProcessPaymentSuccess handles the post-payment logic (Webhooks)
 func (s *PaymentServiceImpl) ProcessPaymentSuccess(ctx context.Context, amount float64, currency string, metadata map[string]string) error {
-	// 1. Mark Payment as Paid in DB (TODO)
+	// 1. Mark Payment as Paid in DB
+	payment, err := s.settlePayment(ctx, amount, currency, metadata)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return nil // Webhook retry, already processed
+	}
+	orderID := payment.ID.Hex()
 
-	// 2. Handle Affiliate Commission
-	// 2. Handle Affiliate Commission
+	// 2. Handle Affiliate Commission, linked to the order so a later refund can cancel it
 	if code, ok := metadata["affiliate_code"]; ok && code != "" {
-		// We need an Order ID to link the commission to.
-		// For now, we use a mock one or the PaymentIntentID if passed in metadata.
-		mockOrderID := primitive.NewObjectID().Hex()
+		// The plan decides which creator/product the sale belongs to
+		plan, err := s.pricingSvc.GetPlan(ctx, metadata["plan_id"])
+		if err != nil {
+			plan = nil
+		}
 
-		_, err := s.affiliateSvc.ProcessCommission(ctx, mockOrderID, amount, code)
+		// Tax is collected for the authorities, not earned
+		commission, err := s.affiliateSvc.ProcessCommission(ctx, orderID, payment.Amount-payment.TaxAmount, payment.Currency, code, plan, metadata["user_id"])
 		if err != nil {
 			// Log error but don't fail the whole payment success processing
-			// log.Printf("Failed to process commission: %v", err)
-			return err
+			log.Printf("Failed to process commission for order %s: %v", orderID, err)
+		} else if commission != nil {
+			payment.AffiliateID = &commission.AffiliateID
+			payment.Commission = commission.EarnedAmount
+			if err := s.paymentRepo.SetCommission(ctx, payment.ID, commission.AffiliateID, commission.EarnedAmount); err != nil {
+				log.Printf("Failed to record commission on payment %s: %v", orderID, err)
+			}
 		}
 	}
 
-	// 3. Handle Coupon Usage
-	if code, ok := metadata["coupon_code"]; ok && code != "" {
-		if err := s.couponSvc.ApplyCoupon(ctx, code); err != nil {
-			// Log error
-			// return err
+	// 3. Confirm Coupon Redemptions reserved at checkout
+	paymentRef := metadata["payment_id"]
+	if paymentRef == "" {
+		paymentRef = orderID
+	}
+	for _, id := range metadataList(metadata, "coupon_redemptions") {
+		if err := s.couponSvc.ConfirmRedemption(ctx, id, paymentRef); err != nil {
+			log.Printf("Failed to confirm coupon redemption %s: %v", id, err)
+		}
+	}
+
+	// 4. Record the billing cycle of a subscription's first invoice
+	if sub := s.paymentSubscription(ctx, payment, metadata); sub != nil {
+		if _, _, err := s.recordCycle(ctx, sub, paymentRef, payment.Amount, time.Time{}, time.Time{}); err != nil {
+			log.Printf("Failed to record invoice of subscription %s: %v", sub.StripeSubID, err)
+		}
+	}
+
+	// 5. Remember the customer's first purchase
+	if user, err := s.userRepo.GetByID(ctx, metadata["user_id"]); err == nil && user.FirstPurchaseAt == nil {
+		now := time.Now()
+		user.FirstPurchaseAt = &now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			log.Printf("Failed to record first purchase for user %s: %v", user.ID.Hex(), err)
+		}
+	}
+
+	// 6. Issue the Invoice
+	if _, err := s.invoiceSvc.GenerateInvoiceForPayment(ctx, payment); err != nil {
+		log.Printf("Failed to generate invoice for payment %s: %v", orderID, err)
+	}
+
+	return nil
+}
+
+// settlePayment marks the checkout's payment as succeeded. Payments the webhook has no
+// order for (e.g. created outside checkout) are recorded from the webhook data.
+// It returns nil when the payment was already settled.
+func (s *PaymentServiceImpl) settlePayment(ctx context.Context, amount float64, currency string, metadata map[string]string) (*domain.Payment, error) {
+	now := time.Now()
+	orderOID, orderErr := primitive.ObjectIDFromHex(metadata["order_id"])
+
+	var payment *domain.Payment
+	var err error
+	if orderErr == nil {
+		if payment, err = s.paymentRepo.GetPayment(ctx, orderOID); err != nil {
+			return nil, err
+		}
+	} else if ref := metadata["payment_id"]; ref != "" {
+		// Without an order ID, a repeated webhook finds the payment it recorded the first time
+		if payment, err = s.paymentRepo.GetPaymentByGatewayRef(ctx, domain.GatewayStripe, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if payment == nil {
+		plan, err := s.pricingSvc.GetPlan(ctx, metadata["plan_id"])
+		if err != nil {
+			plan = &domain.PricingPlan{}
+		}
+		payment = newPendingPayment(primitive.NilObjectID, plan, currency, amount, 1)
+		if orderErr == nil {
+			payment.ID = orderOID
+		}
+		if uOID, err := primitive.ObjectIDFromHex(metadata["user_id"]); err == nil {
+			payment.UserID = uOID
+		}
+		payment.Amount = amount
+		payment.Status = domain.PaymentStatusSucceeded
+		payment.TransactionID = metadata["payment_id"]
+		payment.Metadata = metadata
+		payment.PaidAt = &now
+		if err := s.paymentRepo.CreatePayment(ctx, payment); err != nil {
+			return nil, err
+		}
+		return payment, nil
+	}
+
+	// Nothing proves an offline payment was received but an admin's confirmation
+	if payment.Gateway == domain.GatewayManual && payment.Confirmation == nil {
+		return nil, errors.New("manual payments are settled by admin confirmation")
+	}
+
+	from := payment.Status
+	switch from {
+	case domain.PaymentStatusPending, domain.PaymentStatusFailed:
+	default:
+		return nil, nil
+	}
+	// The order was priced at checkout; what the caller reports is only checked against it
+	if amount != payment.Amount || (currency != "" && currency != payment.Currency) {
+		log.Printf("Warning: order %s was charged %.2f %s, expected %.2f %s", payment.ID.Hex(), amount, currency, payment.Amount, payment.Currency)
+	}
+	payment.Status = domain.PaymentStatusSucceeded
+	payment.TransactionID = metadata["payment_id"]
+	payment.Metadata = metadata
+	payment.PaidAt = &now
+	ok, err := s.paymentRepo.TransitionPayment(ctx, payment, from)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// paymentSubscription finds the subscription a payment was the first invoice of
+func (s *PaymentServiceImpl) paymentSubscription(ctx context.Context, payment *domain.Payment, metadata map[string]string) *domain.Subscription {
+	var sub *domain.Subscription
+	var err error
+	if payment.SubscriptionID != nil {
+		sub, err = s.subRepo.GetByID(ctx, *payment.SubscriptionID)
+	} else if stripeSubID := metadata["subscription_id"]; stripeSubID != "" {
+		sub, err = s.subRepo.GetByStripeID(ctx, stripeSubID)
+	}
+	if err != nil {
+		log.Printf("Failed to load subscription of payment %s: %v", payment.ID.Hex(), err)
+	}
+	return sub
+}
+
+// ProcessPaymentFailure handles a failed or abandoned payment (Webhooks)
+func (s *PaymentServiceImpl) ProcessPaymentFailure(ctx context.Context, metadata map[string]string) error {
+	// Give reserved coupon uses back
+	for _, id := range metadataList(metadata, "coupon_redemptions") {
+		if err := s.couponSvc.ReleaseRedemption(ctx, id, "payment failed"); err != nil {
+			return err
 		}
 	}
 
+	if oid, err := primitive.ObjectIDFromHex(metadata["order_id"]); err == nil {
+		payment, err := s.paymentRepo.GetPayment(ctx, oid)
+		if err != nil {
+			return err
+		}
+		if payment != nil && payment.Status == domain.PaymentStatusPending {
+			s.failPayment(ctx, payment)
+		}
+	}
 	return nil
 }
+
+// metadataList reads a comma-separated metadata value
+func metadataList(metadata map[string]string, key string) []string {
+	raw := metadata[key]
+	if raw == "" {
+		return nil
+	}
+	return strings.Split(raw, ",")
+}
+
+// ProcessPaymentCancellation handles a full or partial refund of a purchase (Webhooks).
+// amount 0 refunds whatever is left; refundRef deduplicates retried webhooks.
+func (s *PaymentServiceImpl) ProcessPaymentCancellation(ctx context.Context, orderID string, refundRef string, amount float64, reason string) error {
+	if reason == "" {
+		reason = "purchase refunded"
+	}
+	fullRefund := true
+	if oid, err := primitive.ObjectIDFromHex(orderID); err == nil {
+		payment, err := s.paymentRepo.GetPayment(ctx, oid)
+		if err != nil {
+			return err
+		}
+		if payment != nil {
+			if fullRefund, err = s.refundPayment(ctx, payment, refundRef, amount, reason); err != nil {
+				return err
+			}
+		}
+	}
+	// Commissions only lapse once nothing of the purchase is kept
+	if !fullRefund {
+		return nil
+	}
+	return s.affiliateSvc.CancelOrderCommissions(ctx, orderID, reason)
+}
+
+// refundPayment records the refund on the payment and credits its invoice.
+// It reports whether the payment is now fully refunded.
+func (s *PaymentServiceImpl) refundPayment(ctx context.Context, payment *domain.Payment, refundRef string, amount float64, reason string) (bool, error) {
+	from := payment.Status
+	switch from {
+	case domain.PaymentStatusSucceeded, domain.PaymentStatusPartiallyRefunded:
+	default:
+		// Already refunded, or cancelled before it was paid
+		return true, nil
+	}
+	if payment.HasRefund(refundRef) {
+		return false, nil
+	}
+
+	left := domain.RoundAmount(payment.Amount-payment.RefundedAmount, payment.Currency)
+	if amount <= 0 || amount > left {
+		amount = left
+	}
+	payment.RefundedAmount = domain.RoundAmount(payment.RefundedAmount+amount, payment.Currency)
+	payment.Refunds = append(payment.Refunds, domain.PaymentRefund{Ref: refundRef, Amount: amount, Reason: reason, RefundedAt: time.Now()})
+	payment.Status = domain.PaymentStatusPartiallyRefunded
+	if payment.RefundedAmount >= payment.Amount {
+		payment.Status = domain.PaymentStatusRefunded
+	}
+
+	ok, err := s.paymentRepo.TransitionPayment(ctx, payment, from)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, errors.New("payment was changed meanwhile, try again")
+	}
+
+	if _, err := s.invoiceSvc.IssueRefundCreditNote(ctx, payment, amount, refundRef, reason); err != nil {
+		log.Printf("Failed to issue credit note for payment %s: %v", payment.ID.Hex(), err)
+	}
+	return payment.Status == domain.PaymentStatusRefunded, nil
+}
+
+// newPendingPayment starts the local record of a checkout before any discount
+func newPendingPayment(userID primitive.ObjectID, plan *domain.PricingPlan, currency string, listAmount float64, quantity int) *domain.Payment {
+	payment := &domain.Payment{
+		ID:            primitive.NewObjectID(),
+		UserID:        userID,
+		CreatorID:     plan.CreatorID,
+		PricingPlanID: plan.ID,
+		Currency:      currency,
+		Status:        domain.PaymentStatusPending,
+		Gateway:       domain.GatewayStripe,
+		Description:   plan.Name,
+		Quantity:      quantity,
+		ListAmount:    listAmount,
+		Amount:        listAmount,
+	}
+	if quantity > 0 {
+		payment.UnitPrice = listAmount / float64(quantity)
+	}
+	return payment
+}
+
+func (s *PaymentServiceImpl) failPayment(ctx context.Context, payment *domain.Payment) {
+	payment.Status = domain.PaymentStatusFailed
+	if _, err := s.paymentRepo.TransitionPayment(ctx, payment, domain.PaymentStatusPending); err != nil {
+		log.Printf("Failed to mark payment %s as failed: %v", payment.ID.Hex(), err)
+	}
+}
+
+func (s *PaymentServiceImpl) releaseRedemptions(ctx context.Context, redemptionIDs []string, reason string) {
+	for _, id := range redemptionIDs {
+		if err := s.couponSvc.ReleaseRedemption(ctx, id, reason); err != nil {
+			log.Printf("Failed to release coupon redemption %s: %v", id, err)
+		}
+	}
+}
@@ -13,12 +13,14 @@ import (
 )
 
 type PricingServiceImpl struct {
-	repo    ports.PricingRepository
-	gateway ports.PaymentGateway
+	repo     ports.PricingRepository
+	gateway  ports.PaymentGateway
+	fx       ports.FXRateProvider
+	userRepo ports.UserRepository
 }
 
-func NewPricingService(repo ports.PricingRepository, gateway ports.PaymentGateway) ports.PricingService {
-	return &PricingServiceImpl{repo: repo, gateway: gateway}
+func NewPricingService(repo ports.PricingRepository, gateway ports.PaymentGateway, fx ports.FXRateProvider, userRepo ports.UserRepository) ports.PricingService {
+	return &PricingServiceImpl{repo: repo, gateway: gateway, fx: fx, userRepo: userRepo}
 }
 
 func (s *PricingServiceImpl) CreatePlan(ctx context.Context, plan *domain.PricingPlan) error {
@@ -66,10 +68,13 @@ func (s *PricingServiceImpl) CreatePlan(ctx context.Context, plan *domain.Pricin
 			return errors.New("invalid bundle config (must have price and included products)")
 		}
 		amount = plan.BundleConfig.Price
-		currency = "USD"
+		currency = plan.BaseCurrency()
 	default:
 		return errors.New("unknown pricing type")
 	}
+	if err := validateCurrencyPrices(plan); err != nil {
+		return err
+	}
 
 	// 3. Constraints Validation
 	if plan.LimitedSell != nil {
@@ -106,6 +111,17 @@ func (s *PricingServiceImpl) CreatePlan(ctx context.Context, plan *domain.Pricin
 			} else {
 				fmt.Printf("❌ Failed to create Stripe Price: %v\n", err)
 			}
+
+			// One Stripe price per explicitly priced currency
+			for i := range plan.CurrencyPrices {
+				cp := &plan.CurrencyPrices[i]
+				priceID, err := s.gateway.CreatePrice(ctx, prodID, cp.Amount, cp.Currency, interval)
+				if err != nil {
+					fmt.Printf("❌ Failed to create Stripe Price in %s: %v\n", cp.Currency, err)
+					continue
+				}
+				cp.StripePriceID = priceID
+			}
 		} else {
 			fmt.Printf("❌ Failed to create Stripe Product: %v\n", err)
 		}
@@ -140,6 +156,131 @@ func (s *PricingServiceImpl) ListPlans(ctx context.Context, productID *string) (
 	return s.repo.GetPlans(ctx, pOID)
 }
 
+func (s *PricingServiceImpl) PriceIn(ctx context.Context, plan *domain.PricingPlan, currency string) (*domain.PlanPrice, error) {
+	base := plan.BaseCurrency()
+	currency = domain.NormalizeCurrency(currency)
+	if currency == "" || currency == base {
+		return &domain.PlanPrice{Currency: base, Amount: plan.BasePrice(), Factor: 1, Explicit: true, StripePriceID: plan.StripePriceID}, nil
+	}
+
+	if cp := plan.ExplicitPrice(currency); cp != nil {
+		price := &domain.PlanPrice{Currency: currency, Amount: cp.Amount, Explicit: true, StripePriceID: cp.StripePriceID}
+		if basePrice := plan.BasePrice(); basePrice > 0 {
+			price.Factor = cp.Amount / basePrice
+		} else if price.Factor, _ = s.fx.Rate(ctx, base, currency); price.Factor == 0 {
+			price.Factor = 1
+		}
+		return price, nil
+	}
+
+	rate, err := s.fx.Rate(ctx, base, currency)
+	if err != nil {
+		return nil, fmt.Errorf("plan is not available in %s", currency)
+	}
+	return &domain.PlanPrice{
+		Currency: currency,
+		Amount:   domain.RoundAmount(plan.BasePrice()*rate, currency),
+		Factor:   rate,
+		FXRate:   rate,
+	}, nil
+}
+
+func (s *PricingServiceImpl) SetCurrencyPrice(ctx context.Context, userID string, id string, currency string, amount *float64) (*domain.PricingPlan, error) {
+	plan, err := s.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Only the plan's creator (or an admin) prices it
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if plan.CreatorID != user.ID && !user.HasRole(domain.RoleAdmin) {
+		return nil, errors.New("plan not found")
+	}
+	currency = domain.NormalizeCurrency(currency)
+	if currency == plan.BaseCurrency() {
+		return nil, errors.New("use the plan price for its base currency")
+	}
+
+	old := plan.ExplicitPrice(currency)
+	if old == nil && amount == nil {
+		return nil, errors.New("plan has no price in " + currency)
+	}
+	var retired string
+	if old != nil {
+		retired = old.StripePriceID
+	}
+
+	// Replace (or remove) the explicit price
+	prices := make([]domain.CurrencyPrice, 0, len(plan.CurrencyPrices)+1)
+	for _, cp := range plan.CurrencyPrices {
+		if domain.NormalizeCurrency(cp.Currency) != currency {
+			prices = append(prices, cp)
+		}
+	}
+	if amount != nil {
+		prices = append(prices, domain.CurrencyPrice{Currency: currency, Amount: *amount})
+	}
+	plan.CurrencyPrices = prices
+	if err := validateCurrencyPrices(plan); err != nil {
+		return nil, err
+	}
+
+	// Sync with Stripe: a new price for the amount, the old one archived
+	if amount != nil && plan.StripeProductID != "" {
+		interval := ""
+		if plan.Type == domain.PricingTypeSubscription {
+			interval = "month" // Same default as CreatePlan
+			if plan.SubscriptionConfig.Interval != "" {
+				interval = string(plan.SubscriptionConfig.Interval)
+			}
+		}
+		priceID, err := s.gateway.CreatePrice(ctx, plan.StripeProductID, *amount, currency, interval)
+		if err != nil {
+			return nil, err
+		}
+		plan.CurrencyPrices[len(plan.CurrencyPrices)-1].StripePriceID = priceID
+	}
+	if retired != "" {
+		_ = s.gateway.ArchivePrice(ctx, retired)
+	}
+
+	plan.UpdatedAt = time.Now()
+	if err := s.repo.UpdatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// validateCurrencyPrices checks the explicit prices: one positive price per ISO currency,
+// only for plans with a headline price
+func validateCurrencyPrices(plan *domain.PricingPlan) error {
+	if len(plan.CurrencyPrices) == 0 {
+		return nil
+	}
+	switch plan.Type {
+	case domain.PricingTypeTiered, domain.PricingTypeDonation:
+		return errors.New("tiered and donation plans are converted from their base currency only")
+	}
+	seen := map[string]bool{plan.BaseCurrency(): true}
+	for i := range plan.CurrencyPrices {
+		cp := &plan.CurrencyPrices[i]
+		cp.Currency = domain.NormalizeCurrency(cp.Currency)
+		if !isCurrencyCode(cp.Currency) {
+			return errors.New("currency must be a three-letter ISO code")
+		}
+		if seen[cp.Currency] {
+			return errors.New("duplicate price for " + cp.Currency)
+		}
+		if cp.Amount <= 0 {
+			return errors.New("price for " + cp.Currency + " must be positive")
+		}
+		seen[cp.Currency] = true
+	}
+	return nil
+}
+
 func (s *PricingServiceImpl) CalculateFinalPrice(ctx context.Context, planID string, couponCode string) (float64, error) {
 	// Placeholder for now. Will contain Logic for coupons, early bird, etc.
 	plan, err := s.GetPlan(ctx, planID)
@@ -219,19 +360,27 @@ func (s *PricingServiceImpl) UpdatePlan(ctx context.Context, id string, name str
 			_ = s.gateway.ArchivePrice(ctx, plan.StripePriceID)
 
 			// B. Create New Price
-			// We need currency. Assuming USD or existing config currency.
-			currency := "USD"
-			if plan.OneTimeConfig != nil {
-				currency = plan.OneTimeConfig.Currency
-			}
-			if plan.SubscriptionConfig != nil {
-				currency = plan.SubscriptionConfig.Currency
-			}
+			currency := plan.BaseCurrency()
 
 			newStripePriceID, err := s.gateway.CreatePrice(ctx, plan.StripeProductID, newPrice, currency, newInterval)
 			if err == nil {
 				plan.StripePriceID = newStripePriceID
 			}
+
+			// Explicit prices in other currencies keep their amount but follow the interval
+			if newInterval != currentInterval {
+				for i := range plan.CurrencyPrices {
+					cp := &plan.CurrencyPrices[i]
+					if cp.StripePriceID == "" {
+						continue
+					}
+					_ = s.gateway.ArchivePrice(ctx, cp.StripePriceID)
+					cp.StripePriceID = ""
+					if priceID, err := s.gateway.CreatePrice(ctx, plan.StripeProductID, cp.Amount, cp.Currency, newInterval); err == nil {
+						cp.StripePriceID = priceID
+					}
+				}
+			}
 		}
 
 		// Update Local Configs
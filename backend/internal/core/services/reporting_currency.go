@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+// reportingCurrency is what stats spanning several currencies are totalled in
+const reportingCurrency = domain.DefaultCurrency
+
+// reportingConverter totals per-currency amounts in the reporting currency,
+// looking each exchange rate up once per report
+type reportingConverter struct {
+	ctx   context.Context
+	fx    ports.FXRateProvider
+	rates map[string]float64
+}
+
+func newReportingConverter(ctx context.Context, fx ports.FXRateProvider) *reportingConverter {
+	return &reportingConverter{ctx: ctx, fx: fx, rates: map[string]float64{}}
+}
+
+// Total converts and sums amounts. Currencies without a rate are left out of the
+// total; they still show in the per-currency breakdown.
+func (c *reportingConverter) Total(amounts map[string]float64) float64 {
+	var total float64
+	for currency, amount := range amounts {
+		rate, ok := c.rates[currency]
+		if !ok {
+			var err error
+			if rate, err = c.fx.Rate(c.ctx, currency, reportingCurrency); err != nil {
+				log.Printf("Leaving %s out of %s stats totals: %v", currency, reportingCurrency, err)
+			}
+			c.rates[currency] = rate
+		}
+		total += amount * rate
+	}
+	return domain.RoundAmount(total, reportingCurrency)
+}
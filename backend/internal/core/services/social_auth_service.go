@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+	"auth-payment-backend/internal/core/ports"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+type SocialAuthServiceImpl struct {
+	providers    ports.OAuthProviders
+	stateRepo    ports.OAuthStateRepository
+	userRepo     ports.UserRepository
+	tokenService *TokenService
+}
+
+func NewSocialAuthService(providers ports.OAuthProviders, stateRepo ports.OAuthStateRepository, userRepo ports.UserRepository, tokenService *TokenService) ports.SocialAuthService {
+	return &SocialAuthServiceImpl{
+		providers:    providers,
+		stateRepo:    stateRepo,
+		userRepo:     userRepo,
+		tokenService: tokenService,
+	}
+}
+
+// StartLogin stores state, nonce and PKCE verifier server-side and returns the
+// provider URL and the state, which the caller must bind to the browser
+func (s *SocialAuthServiceImpl) StartLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", errors.New("unsupported login provider")
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	err = s.stateRepo.Save(ctx, &domain.OAuthState{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(oauthStateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return provider.AuthCodeURL(state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:])), state, nil
+}
+
+// CompleteLogin validates the callback, resolves (or creates) the local user and issues our tokens
+func (s *SocialAuthServiceImpl) CompleteLogin(ctx context.Context, providerName, code, state string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", errors.New("unsupported login provider")
+	}
+	if code == "" || state == "" {
+		return "", "", errors.New("missing code or state")
+	}
+
+	saved, err := s.stateRepo.Consume(ctx, state)
+	if err != nil {
+		return "", "", err
+	}
+	if saved == nil || saved.Provider != providerName {
+		return "", "", errors.New("invalid or expired login state")
+	}
+
+	identity, err := provider.Exchange(ctx, code, saved.CodeVerifier, saved.Nonce)
+	if err != nil {
+		return "", "", err
+	}
+
+	user, err := s.resolveUser(ctx, identity)
+	if err != nil {
+		return "", "", err
+	}
+
+	return s.tokenService.GenerateTokens(user)
+}
+
+// resolveUser finds the user by linked identity, then by verified email, and
+// otherwise registers a new account.
+func (s *SocialAuthServiceImpl) resolveUser(ctx context.Context, identity *domain.ExternalIdentity) (*domain.User, error) {
+	user, err := s.userRepo.GetByProviderIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	// Linking or creating by email is only safe when the provider vouches for it
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("provider account has no verified email")
+	}
+	email := normalizeEmail(identity.Email)
+
+	link := domain.ProviderIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	user, err = s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		if !user.IsEmailVerified && user.Password != "" {
+			// Nobody proved ownership of this mailbox, so whoever set the password may
+			// not be its owner. Linking would hand them the account; refuse instead.
+			return nil, errors.New("an account with this email already exists; sign in with your password")
+		}
+		user.IsEmailVerified = true
+		user.Identities = append(user.Identities, link)
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	user = &domain.User{
+		Email:           email,
+		FullName:        identity.Name,
+		Role:            domain.RoleUser,
+		IsEmailVerified: true,
+		Identities:      []domain.ProviderIdentity{link},
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"auth-payment-backend/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// subscriptionCoupon validates, syncs and reserves the coupon a new subscription starts with.
+// It returns the discount snapshot and the gateway promotion code to attach.
+func (s *PaymentServiceImpl) subscriptionCoupon(ctx context.Context, plan *domain.PricingPlan, price *domain.PlanPrice, userID string, couponCodes []string) (*domain.SubscriptionDiscount, string, error) {
+	if len(couponCodes) == 0 {
+		return nil, "", nil
+	}
+	// The gateway takes a single promotion code per subscription
+	if len(couponCodes) > 1 {
+		return nil, "", errors.New("only one coupon can be applied to a subscription")
+	}
+
+	coupon, amount, err := s.couponSvc.ValidateCoupon(ctx, couponCodes[0], &domain.CouponCheck{
+		PlanID:      plan.ID.Hex(),
+		UserID:      userID,
+		OrderAmount: price.Amount,
+		Factor:      price.Factor,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := subscriptionCouponCurrency(coupon, plan, price); err != nil {
+		return nil, "", err
+	}
+	if err := s.couponSvc.SyncGatewayCoupon(ctx, coupon, price.Currency); err != nil {
+		return nil, "", err
+	}
+	redemption, err := s.couponSvc.ReserveCoupon(ctx, coupon, userID, plan.ID.Hex(), amount, price.Currency)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return domain.NewSubscriptionDiscount(coupon, redemption.ID, time.Now()), coupon.StripePromotionCodeID, nil
+}
+
+// subscriptionCouponCurrency rejects fixed-amount coupons on subscriptions billed in another
+// currency than the plan's: the gateway would take the coupon's amount off in that currency
+func subscriptionCouponCurrency(coupon *domain.Coupon, plan *domain.PricingPlan, price *domain.PlanPrice) error {
+	if coupon.DiscountType == domain.DiscountTypeFixed && price.Currency != plan.BaseCurrency() {
+		return errors.New("fixed-amount coupons only apply to subscriptions in " + plan.BaseCurrency())
+	}
+	return nil
+}
+
+// ProcessSubscriptionInvoice records a paid renewal invoice of a subscription (Webhooks).
+// Zero period bounds are derived from the previous cycle and the plan interval.
+func (s *PaymentServiceImpl) ProcessSubscriptionInvoice(ctx context.Context, stripeSubID string, invoiceRef string, amountPaid float64, periodStart, periodEnd time.Time) (*domain.SubscriptionCycle, error) {
+	sub, err := s.subRepo.GetByStripeID(ctx, stripeSubID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, errors.New("subscription not found")
+	}
+
+	cycle, created, err := s.recordCycle(ctx, sub, invoiceRef, amountPaid, periodStart, periodEnd)
+	if err != nil || !created {
+		return cycle, err
+	}
+
+	// The first invoice is settled through checkout; renewals get their own payment and invoice
+	if cycle.Cycle > 1 {
+		s.recordRenewalPayment(ctx, sub, cycle)
+	}
+	return cycle, nil
+}
+
+// recordCycle adds a billing cycle to the ledger unless the invoice was recorded before.
+// It reports whether the cycle is new.
+func (s *PaymentServiceImpl) recordCycle(ctx context.Context, sub *domain.Subscription, invoiceRef string, amountPaid float64, periodStart, periodEnd time.Time) (*domain.SubscriptionCycle, bool, error) {
+	if invoiceRef == "" {
+		return nil, false, errors.New("invoice reference is required")
+	}
+
+	// Webhook retry
+	existing, err := s.subRepo.GetCycleByInvoice(ctx, sub.ID, invoiceRef)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	plan, err := s.pricingSvc.GetPlan(ctx, sub.PricingPlanID.Hex())
+	if err != nil {
+		return nil, false, err
+	}
+	cycles, err := s.subRepo.GetCycles(ctx, sub.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if periodStart.IsZero() {
+		periodStart = time.Now()
+		if len(cycles) > 0 {
+			periodStart = cycles[len(cycles)-1].PeriodEnd
+		}
+	}
+	if !periodEnd.After(periodStart) {
+		periodEnd = plan.SubscriptionConfig.Interval.After(periodStart)
+	}
+
+	cycle := &domain.SubscriptionCycle{
+		SubscriptionID: sub.ID,
+		UserID:         sub.UserID,
+		PricingPlanID:  sub.PricingPlanID,
+		Cycle:          len(cycles) + 1,
+		InvoiceRef:     invoiceRef,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+		ListAmount:     plan.SubscriptionConfig.Price,
+		AmountPaid:     amountPaid,
+		Currency:       plan.SubscriptionConfig.Currency,
+	}
+	if sub.Currency != "" {
+		cycle.ListAmount = sub.Price
+		cycle.Currency = sub.Currency
+	}
+	if sub.Discount != nil && sub.Discount.AppliesTo(cycle.Cycle, periodStart) {
+		couponID := sub.Discount.CouponID
+		cycle.DiscountAmount = sub.Discount.Amount(cycle.ListAmount)
+		cycle.CouponID = &couponID
+		cycle.CouponCode = sub.Discount.Code
+	}
+	if err := s.subRepo.CreateCycle(ctx, cycle); err != nil {
+		return nil, false, err
+	}
+
+	sub.Status = domain.SubscriptionStatusActive
+	sub.CurrentPeriodStart = periodStart
+	sub.CurrentPeriodEnd = periodEnd
+	if err := s.subRepo.UpdateSubscription(ctx, sub); err != nil {
+		return nil, false, err
+	}
+	return cycle, true, nil
+}
+
+// recordRenewalPayment stores a renewal as a succeeded payment and issues its invoice
+func (s *PaymentServiceImpl) recordRenewalPayment(ctx context.Context, sub *domain.Subscription, cycle *domain.SubscriptionCycle) {
+	plan, err := s.pricingSvc.GetPlan(ctx, sub.PricingPlanID.Hex())
+	if err != nil {
+		log.Printf("Failed to load plan of subscription %s: %v", sub.StripeSubID, err)
+		return
+	}
+
+	now := time.Now()
+	payment := newPendingPayment(sub.UserID, plan, cycle.Currency, cycle.ListAmount, 1)
+	payment.Description = fmt.Sprintf("%s (%s to %s)", plan.Name, cycle.PeriodStart.Format("2006-01-02"), cycle.PeriodEnd.Format("2006-01-02"))
+	if cycle.DiscountAmount > 0 {
+		payment.AddDiscount(cycle.CouponCode, "Coupon "+cycle.CouponCode, cycle.DiscountAmount)
+	}
+	payment.Amount = cycle.AmountPaid
+	if sub.Tax != nil {
+		payment.Tax = renewalTax(sub.Tax, cycle.AmountPaid, cycle.Currency)
+		payment.TaxAmount = payment.Tax.TaxAmount
+	}
+	payment.Status = domain.PaymentStatusSucceeded
+	payment.TransactionID = cycle.InvoiceRef
+	payment.SubscriptionID = &sub.ID
+	payment.PaidAt = &now
+	if err := s.paymentRepo.CreatePayment(ctx, payment); err != nil {
+		log.Printf("Failed to record renewal payment of subscription %s: %v", sub.StripeSubID, err)
+		return
+	}
+	if _, err := s.invoiceSvc.GenerateInvoiceForPayment(ctx, payment); err != nil {
+		log.Printf("Failed to generate invoice for renewal of subscription %s: %v", sub.StripeSubID, err)
+	}
+}
+
+// renewalTax splits the amount the gateway charged for a renewal at the subscription's tax rate
+func renewalTax(tax *domain.TaxResult, amountPaid float64, currency string) *domain.TaxResult {
+	renewal := *tax
+	renewal.GrossAmount = domain.RoundAmount(amountPaid, currency)
+	renewal.NetAmount = renewal.GrossAmount
+	renewal.TaxAmount = 0
+	if !tax.ReverseCharge {
+		renewal.NetAmount = domain.RoundAmount(amountPaid/(1+tax.Rate), currency)
+		renewal.TaxAmount = domain.RoundAmount(renewal.GrossAmount-renewal.NetAmount, currency)
+	}
+	return &renewal
+}
+
+func (s *PaymentServiceImpl) ListSubscriptions(ctx context.Context, userID string) ([]*domain.Subscription, error) {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.subRepo.GetByUser(ctx, oid)
+}
+
+// ListSubscriptionCycles reports what each billing cycle of the user's subscription was charged and discounted
+func (s *PaymentServiceImpl) ListSubscriptionCycles(ctx context.Context, userID string, subscriptionID string) ([]*domain.SubscriptionCycle, error) {
+	oid, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, errors.New("invalid subscription ID")
+	}
+	sub, err := s.subRepo.GetByID(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil || sub.UserID.Hex() != userID {
+		return nil, errors.New("subscription not found")
+	}
+	return s.subRepo.GetCycles(ctx, sub.ID)
+}
@@ -1,6 +1,10 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -19,9 +23,10 @@ func NewTokenService(cfg *config.Config) *TokenService {
 }
 
 type MyCustomClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Type   string `json:"type"` // "access" or "refresh"
+	UserID  string `json:"user_id"`
+	Email   string `json:"email"`
+	Type    string `json:"type"` // "access" or "refresh"
+	Version int    `json:"ver"`  // Must match User.TokenVersion
 	jwt.RegisteredClaims
 }
 
@@ -31,6 +36,7 @@ func (s *TokenService) GenerateTokens(user *domain.User) (string, string, error)
 		user.ID.Hex(),
 		user.Email,
 		"access",
+		user.TokenVersion,
 		jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -48,6 +54,7 @@ func (s *TokenService) GenerateTokens(user *domain.User) (string, string, error)
 		user.ID.Hex(),
 		user.Email,
 		"refresh",
+		user.TokenVersion,
 		jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -78,3 +85,18 @@ func (s *TokenService) ValidateToken(tokenString string) (*MyCustomClaims, error
 
 	return nil, errors.New("invalid token")
 }
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a high-entropy secret for storage at rest
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
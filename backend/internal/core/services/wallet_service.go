@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"auth-payment-backend/internal/core/domain"
@@ -13,14 +14,19 @@ import (
 
 type WalletServiceImpl struct {
 	repo ports.WalletRepository
+	fx   ports.FXRateProvider
 }
 
-func NewWalletService(repo ports.WalletRepository) ports.WalletService {
-	return &WalletServiceImpl{repo: repo}
+func NewWalletService(repo ports.WalletRepository, fx ports.FXRateProvider) ports.WalletService {
+	return &WalletServiceImpl{repo: repo, fx: fx}
 }
 
-func (s *WalletServiceImpl) getOrCreateWallet(ctx context.Context, userID primitive.ObjectID) (*domain.Wallet, error) {
-	wallet, err := s.repo.GetWalletByUserID(ctx, userID)
+func (s *WalletServiceImpl) getOrCreateWallet(ctx context.Context, userID primitive.ObjectID, currency string) (*domain.Wallet, error) {
+	currency = domain.NormalizeCurrency(currency)
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	wallet, err := s.repo.GetWallet(ctx, userID, currency)
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +34,7 @@ func (s *WalletServiceImpl) getOrCreateWallet(ctx context.Context, userID primit
 		newWallet := &domain.Wallet{
 			UserID:    userID,
 			Balance:   0,
-			Currency:  "USD", // Default
+			Currency:  currency,
 			UpdatedAt: time.Now(),
 		}
 		if err := s.repo.CreateWallet(ctx, newWallet); err != nil {
@@ -39,29 +45,55 @@ func (s *WalletServiceImpl) getOrCreateWallet(ctx context.Context, userID primit
 	return wallet, nil
 }
 
-func (s *WalletServiceImpl) GetBalance(ctx context.Context, userID string) (*domain.Wallet, error) {
+func (s *WalletServiceImpl) GetBalance(ctx context.Context, userID string) ([]*domain.Wallet, error) {
 	oid, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, errors.New("invalid user ID")
 	}
-	return s.getOrCreateWallet(ctx, oid)
+	wallets, err := s.repo.GetWalletsByUserID(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) == 0 {
+		wallet, err := s.getOrCreateWallet(ctx, oid, domain.DefaultCurrency)
+		if err != nil {
+			return nil, err
+		}
+		wallets = []*domain.Wallet{wallet}
+	}
+	return wallets, nil
 }
 
-func (s *WalletServiceImpl) GetTransactions(ctx context.Context, userID string) ([]*domain.WalletTransaction, error) {
+func (s *WalletServiceImpl) GetTransactions(ctx context.Context, userID string, currency string) ([]*domain.WalletTransaction, error) {
 	oid, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, errors.New("invalid user ID")
 	}
 
-	wallet, err := s.getOrCreateWallet(ctx, oid)
-	if err != nil {
+	var wallets []*domain.Wallet
+	if currency != "" {
+		wallet, err := s.repo.GetWallet(ctx, oid, domain.NormalizeCurrency(currency))
+		if err != nil {
+			return nil, err
+		}
+		if wallet != nil {
+			wallets = append(wallets, wallet)
+		}
+	} else if wallets, err = s.repo.GetWalletsByUserID(ctx, oid); err != nil {
 		return nil, err
 	}
+	if len(wallets) == 0 {
+		return []*domain.WalletTransaction{}, nil
+	}
 
-	return s.repo.GetTransactions(ctx, wallet.ID)
+	ids := make([]primitive.ObjectID, len(wallets))
+	for i, w := range wallets {
+		ids[i] = w.ID
+	}
+	return s.repo.GetTransactions(ctx, ids)
 }
 
-func (s *WalletServiceImpl) CreditWallet(ctx context.Context, userID string, amount float64, txType domain.TransactionType, refID string, desc string) error {
+func (s *WalletServiceImpl) CreditWallet(ctx context.Context, userID string, amount float64, currency string, txType domain.TransactionType, refID string, desc string) error {
 	if amount <= 0 {
 		return errors.New("amount must be positive")
 	}
@@ -74,7 +106,7 @@ func (s *WalletServiceImpl) CreditWallet(ctx context.Context, userID string, amo
 	refOID, _ := primitive.ObjectIDFromHex(refID) // Ignore error if refID is empty/invalid, typically it should be valid
 
 	// 1. Get Wallet
-	wallet, err := s.getOrCreateWallet(ctx, oid)
+	wallet, err := s.getOrCreateWallet(ctx, oid, currency)
 	if err != nil {
 		return err
 	}
@@ -83,6 +115,7 @@ func (s *WalletServiceImpl) CreditWallet(ctx context.Context, userID string, amo
 	tx := &domain.WalletTransaction{
 		WalletID:    wallet.ID,
 		Amount:      amount, // Positive
+		Currency:    wallet.Currency,
 		Type:        txType,
 		ReferenceID: refOID,
 		Description: desc,
@@ -97,7 +130,7 @@ func (s *WalletServiceImpl) CreditWallet(ctx context.Context, userID string, amo
 	return s.repo.UpdateBalance(ctx, wallet.ID, newBalance)
 }
 
-func (s *WalletServiceImpl) DebitWallet(ctx context.Context, userID string, amount float64, txType domain.TransactionType, refID string, desc string) error {
+func (s *WalletServiceImpl) DebitWallet(ctx context.Context, userID string, amount float64, currency string, txType domain.TransactionType, refID string, desc string) error {
 	if amount <= 0 {
 		return errors.New("amount must be positive")
 	}
@@ -109,7 +142,7 @@ func (s *WalletServiceImpl) DebitWallet(ctx context.Context, userID string, amou
 	refOID, _ := primitive.ObjectIDFromHex(refID)
 
 	// 1. Get Wallet
-	wallet, err := s.getOrCreateWallet(ctx, oid)
+	wallet, err := s.getOrCreateWallet(ctx, oid, currency)
 	if err != nil {
 		return err
 	}
@@ -123,6 +156,7 @@ func (s *WalletServiceImpl) DebitWallet(ctx context.Context, userID string, amou
 	tx := &domain.WalletTransaction{
 		WalletID:    wallet.ID,
 		Amount:      -amount, // Negative
+		Currency:    wallet.Currency,
 		Type:        txType,
 		ReferenceID: refOID,
 		Description: desc,
@@ -137,24 +171,48 @@ func (s *WalletServiceImpl) DebitWallet(ctx context.Context, userID string, amou
 	return s.repo.UpdateBalance(ctx, wallet.ID, newBalance)
 }
 
-func (s *WalletServiceImpl) RequestPayout(ctx context.Context, userID string, amount float64, method domain.PayoutMethod) error {
-	// 1. Debit wallet first (lock funds)
-	err := s.DebitWallet(ctx, userID, amount, domain.TransactionTypePayout, "", "Payout Request")
-	if err != nil {
-		return err
+func (s *WalletServiceImpl) RequestPayout(ctx context.Context, userID string, amount float64, currency string, payoutCurrency string, method domain.PayoutMethod) (*domain.PayoutRequest, error) {
+	currency = domain.NormalizeCurrency(currency)
+	if currency == "" {
+		currency = domain.DefaultCurrency
 	}
+	payoutCurrency = domain.NormalizeCurrency(payoutCurrency)
 
-	oid, _ := primitive.ObjectIDFromHex(userID)
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
 
-	// 2. Create Payout Request
 	req := &domain.PayoutRequest{
 		UserID:    oid,
 		Amount:    amount,
-		Currency:  "USD",
+		Currency:  currency,
 		Status:    domain.PayoutStatusPending,
 		Method:    method,
 		CreatedAt: time.Now(),
 	}
+	desc := "Payout Request"
+
+	// 1. Convert before anything is debited, so a missing rate leaves the wallet untouched
+	if payoutCurrency != "" && payoutCurrency != currency {
+		rate, err := s.fx.Rate(ctx, currency, payoutCurrency)
+		if err != nil {
+			return nil, err
+		}
+		req.PayoutCurrency = payoutCurrency
+		req.PayoutAmount = domain.RoundAmount(amount*rate, payoutCurrency)
+		req.FXRate = rate
+		desc = fmt.Sprintf("Payout Request (%.2f %s at %.6f)", req.PayoutAmount, payoutCurrency, rate)
+	}
 
-	return s.repo.CreatePayoutRequest(ctx, req)
+	// 2. Debit wallet first (lock funds)
+	if err := s.DebitWallet(ctx, userID, amount, currency, domain.TransactionTypePayout, "", desc); err != nil {
+		return nil, err
+	}
+
+	// 3. Create Payout Request
+	if err := s.repo.CreatePayoutRequest(ctx, req); err != nil {
+		return nil, err
+	}
+	return req, nil
 }
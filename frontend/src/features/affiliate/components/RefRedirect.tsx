@@ -0,0 +1,15 @@
+import { useEffect } from 'react';
+import { useParams } from '@tanstack/react-router';
+import { API_URL } from '@/lib/api';
+
+// Links issued before they pointed at the API land here; the backend's /ref/:code
+// records the click, sets the attribution cookie and redirects back to us.
+export const RefRedirect = () => {
+    const { code } = useParams({ from: '/ref/$code' });
+
+    useEffect(() => {
+        window.location.replace(`${API_URL}/ref/${encodeURIComponent(code)}${window.location.search}`);
+    }, [code]);
+
+    return <div className="p-4">Redirecting...</div>;
+};
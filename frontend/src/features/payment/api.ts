@@ -1,8 +1,20 @@
 import { api } from '@/lib/api';
 
+// Stripe checkouts carry a client secret; PayPal checkouts an approval URL to redirect to;
+// manual checkouts a reference code and payment instructions
+export interface CheckoutResponse {
+    gateway: 'stripe' | 'paypal' | 'manual';
+    order_id: string;
+    client_secret?: string;
+    approval_url?: string;
+    reference?: string;
+    instructions?: string;
+    expires_at?: string;
+}
+
 export const paymentApi = {
     initiateCheckout: async (planId: string, affiliateCode?: string, couponCode?: string, amount?: number, quantity?: number) => {
-        const response = await api.post<{ client_secret: string }>('/payment/checkout', {
+        const response = await api.post<CheckoutResponse>('/payment/checkout', {
             plan_id: planId,
             affiliate_code: affiliateCode,
             coupon_code: couponCode,
@@ -10,5 +22,10 @@ export const paymentApi = {
             quantity
         });
         return response.data;
+    },
+    // Called when the buyer returns from approving the order at the gateway
+    capturePayment: async (orderId: string) => {
+        const response = await api.post(`/payment/orders/${orderId}/capture`);
+        return response.data;
     }
 };
@@ -105,7 +105,7 @@ export const CheckoutPage = () => {
 
         // Passed undefined for affiliateCode for now (should come from context/cookies)
         paymentApi.initiateCheckout(planId, undefined, appliedCoupon?.code, plan?.type === 'donation' ? donationAmount : undefined, plan?.type === 'tiered' ? quantity : undefined)
-            .then(data => setClientSecret(data.client_secret))
+            .then(data => setClientSecret(data.client_secret ?? ""))
             .catch(err => {
                 console.error(err);
                 if (err.response?.status === 401 || err.response?.status === 403) {
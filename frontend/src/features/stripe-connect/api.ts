@@ -1,18 +1,36 @@
 import { api } from '@/lib/api';
 
+export interface ConnectAccount {
+    type: 'express' | 'standard';
+    managed: boolean;
+    charges_enabled: boolean;
+    payouts_enabled: boolean;
+    details_submitted: boolean;
+    currently_due?: string[];
+    past_due?: string[];
+    disabled_reason?: string;
+    deadline?: string;
+}
+
 export interface ConnectionStatus {
     connected: boolean;
     stripe_connect_id?: string;
-    status?: string;
+    status?: 'pending' | 'restricted' | 'active' | 'disabled' | 'disconnected';
+    account?: ConnectAccount;
+    can_receive_payments: boolean;
 }
 
 export const stripeConnectApi = {
+    startOnboarding: async (type: 'express' | 'standard' = 'express') => {
+        const { data } = await api.post<{ url: string }>('/stripe/connect/onboard', { type });
+        return data;
+    },
     getOAuthUrl: async () => {
         const { data } = await api.get<{ url: string }>('/stripe/connect/oauth');
         return data;
     },
-    getStatus: async () => {
-        const { data } = await api.get<ConnectionStatus>('/stripe/connect/status');
+    getStatus: async (refresh = false) => {
+        const { data } = await api.get<ConnectionStatus>('/stripe/connect/status', { params: refresh ? { refresh: true } : undefined });
         return data;
     },
     getDashboardLink: async () => {
@@ -12,11 +12,13 @@ import {
 } from "@/components/ui/alert-dialog";
 import { useConnectStripe, useStripeConnectStatus, useStripeDashboard, useDisconnectStripe } from '../hooks';
 import { CheckCircle2, AlertCircle, ExternalLink, Loader2 } from 'lucide-react';
-import { useEffect } from 'react';
+import { useEffect, useState } from 'react';
 import { toast } from 'sonner';
 
 export const StripeConnectPage = () => {
-    const { data, isLoading } = useStripeConnectStatus();
+    // Stripe sends the seller back with ?onboarding=return, or ?onboarding=refresh when the link expired
+    const [onboarding] = useState(() => new URLSearchParams(window.location.search).get('onboarding'));
+    const { data, isLoading } = useStripeConnectStatus(onboarding === 'return');
     const connectMutation = useConnectStripe();
     const dashboardMutation = useStripeDashboard();
     const disconnectMutation = useDisconnectStripe();
@@ -36,6 +38,14 @@ export const StripeConnectPage = () => {
             toast.error('Failed to connect: ' + params.get('error'));
             window.history.replaceState({}, '', window.location.pathname);
         }
+        if (onboarding) {
+            window.history.replaceState({}, '', window.location.pathname);
+        }
+        if (onboarding === 'refresh') {
+            // The onboarding link expired: get a fresh one
+            connectMutation.mutate();
+        }
+        // eslint-disable-next-line react-hooks/exhaustive-deps
     }, []);
 
     if (isLoading) {
@@ -47,6 +57,9 @@ export const StripeConnectPage = () => {
     }
 
     const isConnected = data?.connected;
+    const isReady = data?.can_receive_payments;
+    const canContinueOnboarding = isConnected && !isReady && data?.account?.managed && data?.status !== 'disabled';
+    const due = [...(data?.account?.past_due ?? []), ...(data?.account?.currently_due ?? [])];
 
     return (
         <div className="max-w-4xl mx-auto p-8 space-y-8">
@@ -60,8 +73,8 @@ export const StripeConnectPage = () => {
             <div className="bg-white rounded-xl border border-slate-200 shadow-sm overflow-hidden">
                 <div className="p-6 sm:p-8">
                     <div className="flex items-start gap-4">
-                        <div className={`p-3 rounded-lg ${isConnected ? 'bg-green-100' : 'bg-slate-100'}`}>
-                            {isConnected ? (
+                        <div className={`p-3 rounded-lg ${isReady ? 'bg-green-100' : 'bg-slate-100'}`}>
+                            {isReady ? (
                                 <CheckCircle2 className="w-6 h-6 text-green-600" />
                             ) : (
                                 <AlertCircle className="w-6 h-6 text-slate-500" />
@@ -69,14 +82,29 @@ export const StripeConnectPage = () => {
                         </div>
                         <div className="space-y-1 flex-1">
                             <h3 className="font-semibold text-lg text-slate-900">
-                                {isConnected ? 'Payment Account Connected' : 'No Payment Account Connected'}
+                                {isReady
+                                    ? 'Payment Account Connected'
+                                    : isConnected
+                                        ? 'Payment Account Not Ready'
+                                        : 'No Payment Account Connected'}
                             </h3>
                             <p className="text-slate-500">
-                                {isConnected
+                                {isReady
                                     ? 'Your Stripe account is connected and ready to receive payouts.'
-                                    : 'Connect with Stripe to start selling courses and products.'}
+                                    : isConnected
+                                        ? data?.status === 'disabled'
+                                            ? 'Stripe has disabled this account. Sales are collected by the platform.'
+                                            : 'Stripe needs more information before this account can accept payments. Until then, sales are collected by the platform.'
+                                        : 'Connect with Stripe to start selling courses and products.'}
                             </p>
 
+                            {isConnected && !isReady && due.length > 0 && (
+                                <p className="text-sm text-amber-700">
+                                    {due.length} item{due.length > 1 ? 's' : ''} required by Stripe
+                                    {data?.account?.deadline && ` (due ${new Date(data.account.deadline).toLocaleDateString()})`}
+                                </p>
+                            )}
+
                             {isConnected && data?.stripe_connect_id && (
                                 <p className="text-xs font-mono text-slate-400 pt-2">
                                     Account ID: {data.stripe_connect_id} • Status: {data.status}
@@ -86,6 +114,15 @@ export const StripeConnectPage = () => {
 
                         {isConnected ? (
                             <div className="flex flex-col sm:flex-row gap-3">
+                                {canContinueOnboarding && (
+                                    <Button
+                                        onClick={() => connectMutation.mutate()}
+                                        disabled={connectMutation.isPending}
+                                        className="bg-indigo-600 hover:bg-indigo-700 gap-2"
+                                    >
+                                        {connectMutation.isPending ? 'Opening...' : 'Continue Onboarding'}
+                                    </Button>
+                                )}
                                 <Button
                                     variant="outline"
                                     onClick={() => dashboardMutation.mutate()}
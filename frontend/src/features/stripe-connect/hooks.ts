@@ -2,17 +2,18 @@ import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { stripeConnectApi } from './api';
 import { toast } from 'sonner';
 
-export const useStripeConnectStatus = () => {
+// With refresh, the account is read from Stripe (e.g. when coming back from onboarding)
+export const useStripeConnectStatus = (refresh = false) => {
     return useQuery({
         queryKey: ['stripe-connect-status'],
-        queryFn: stripeConnectApi.getStatus,
+        queryFn: () => stripeConnectApi.getStatus(refresh),
         retry: false,
     });
 };
 
 export const useConnectStripe = () => {
     return useMutation({
-        mutationFn: stripeConnectApi.getOAuthUrl,
+        mutationFn: () => stripeConnectApi.startOnboarding(),
         onSuccess: (data) => {
             // Redirect to Stripe
             window.location.href = data.url;
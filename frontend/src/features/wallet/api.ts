@@ -9,6 +9,7 @@ export interface Wallet {
 export interface WalletTransaction {
     id: string;
     amount: number;
+    currency?: string;
     type: 'sale' | 'payout' | 'refund' | 'commission';
     description: string;
     created_at: string;
@@ -16,15 +17,15 @@ export interface WalletTransaction {
 
 export const walletApi = {
     getBalance: async () => {
-        const response = await api.get<Wallet>('/wallet/balance');
+        const response = await api.get<Wallet[]>('/wallet/balance'); // One wallet per currency
         return response.data;
     },
     getTransactions: async () => {
         const response = await api.get<WalletTransaction[]>('/wallet/transactions');
         return response.data;
     },
-    requestPayout: async (amount: number, method: string) => {
-        const response = await api.post('/wallet/payouts', { amount, method });
+    requestPayout: async (amount: number, currency: string, method: string) => {
+        const response = await api.post('/wallet/payouts', { amount, currency, method });
         return response.data;
     }
 };
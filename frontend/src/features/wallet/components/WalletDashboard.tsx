@@ -6,15 +6,17 @@ import { Input } from '@/components/ui/input';
 import { ArrowUpRight, ArrowDownLeft, DollarSign, Wallet as WalletIcon } from 'lucide-react';
 
 export const WalletDashboard = () => {
-    const { data: wallet, isLoading: isWalletLoading } = useWallet();
+    const { data: wallets, isLoading: isWalletLoading } = useWallet();
     const { data: transactions, isLoading: isTxLoading } = useTransactions();
     const payoutMutation = useRequestPayout();
 
     const [payoutAmount, setPayoutAmount] = useState<string>("");
+    const [payoutCurrency, setPayoutCurrency] = useState<string>("");
+    const currency = payoutCurrency || wallets?.[0]?.currency || "USD";
 
     const handlePayout = () => {
         if (!payoutAmount) return;
-        payoutMutation.mutate({ amount: Number(payoutAmount), method: 'stripe' });
+        payoutMutation.mutate({ amount: Number(payoutAmount), currency, method: 'stripe' });
         setPayoutAmount("");
     };
 
@@ -32,11 +34,13 @@ export const WalletDashboard = () => {
                     <CardHeader className="pb-2">
                         <CardTitle className="text-sm font-medium text-slate-400">Total Balance</CardTitle>
                     </CardHeader>
-                    <CardContent>
-                        <div className="text-4xl font-bold flex items-center gap-1">
-                            <span>$</span>
-                            {wallet?.balance.toFixed(2) ?? "0.00"}
-                        </div>
+                    <CardContent className="space-y-1">
+                        {(wallets ?? []).map((wallet) => (
+                            <div key={wallet.currency} className="text-4xl font-bold flex items-baseline gap-2">
+                                {wallet.balance.toFixed(2)}
+                                <span className="text-lg text-slate-400">{wallet.currency}</span>
+                            </div>
+                        ))}
                     </CardContent>
                 </Card>
 
@@ -49,15 +53,22 @@ export const WalletDashboard = () => {
                     <CardContent className="space-y-4">
                         <div className="flex gap-2">
                             <div className="relative flex-1">
-                                <span className="absolute left-3 top-2.5 text-slate-500">$</span>
                                 <Input
                                     type="number"
                                     placeholder="0.00"
-                                    className="pl-7"
                                     value={payoutAmount}
                                     onChange={(e) => setPayoutAmount(e.target.value)}
                                 />
                             </div>
+                            <select
+                                className="rounded-md border border-slate-200 px-2 text-sm"
+                                value={currency}
+                                onChange={(e) => setPayoutCurrency(e.target.value)}
+                            >
+                                {(wallets ?? []).map((wallet) => (
+                                    <option key={wallet.currency} value={wallet.currency}>{wallet.currency}</option>
+                                ))}
+                            </select>
                             <Button onClick={handlePayout} disabled={payoutMutation.isPending}>
                                 {payoutMutation.isPending ? 'Processing...' : 'Withdraw'}
                             </Button>
@@ -87,7 +98,7 @@ export const WalletDashboard = () => {
                                     </div>
                                     <div className="flex items-center gap-4">
                                         <div className={`font-semibold ${tx.amount > 0 ? 'text-green-600' : 'text-slate-900'}`}>
-                                            {tx.amount > 0 ? '+' : ''}{tx.amount.toFixed(2)} USD
+                                            {tx.amount > 0 ? '+' : ''}{tx.amount.toFixed(2)} {tx.currency ?? 'USD'}
                                         </div>
                                         <Button variant="ghost" size="sm" className="hidden group-hover:flex" onClick={() => window.open(`http://localhost:8080/invoices/mock_id/download`, '_blank')}>
                                             File
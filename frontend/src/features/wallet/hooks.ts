@@ -19,8 +19,8 @@ export const useRequestPayout = () => {
     const queryClient = useQueryClient();
 
     return useMutation({
-        mutationFn: ({ amount, method }: { amount: number, method: string }) =>
-            walletApi.requestPayout(amount, method),
+        mutationFn: ({ amount, currency, method }: { amount: number, currency: string, method: string }) =>
+            walletApi.requestPayout(amount, currency, method),
         onSuccess: () => {
             queryClient.invalidateQueries({ queryKey: ['wallet'] });
             queryClient.invalidateQueries({ queryKey: ['transactions'] });
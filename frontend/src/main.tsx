@@ -5,12 +5,14 @@ import { QueryClient, QueryClientProvider } from '@tanstack/react-query'
 import { RouterProvider, createRouter, createRoute, createRootRoute, Outlet, Link } from '@tanstack/react-router'
 import { LoginPage } from './pages/LoginPage'
 import { SignupPage } from './pages/SignupPage'
+import { OAuthCallbackPage } from './pages/OAuthCallbackPage'
 import { useUser, useLogout } from './features/auth/hooks'
 import { Button } from './components/ui/button'
 import { PricingForm } from './features/pricing/components/PricingForm'
 import { CheckoutPage } from './features/payment/components/CheckoutPage'
 import { WalletDashboard } from './features/wallet/components/WalletDashboard'
 import { AffiliateDashboard } from './features/affiliate/components/AffiliateDashboard'
+import { RefRedirect } from './features/affiliate/components/RefRedirect'
 import { LandingPage } from './features/home/components/LandingPage'
 import { PricingPage } from './features/pricing/components/PricingPage'
 import { AdminPlanList } from './features/pricing/pages/AdminPlanList'
@@ -87,6 +89,12 @@ const loginRoute = createRoute({
   component: LoginPage,
 })
 
+const oauthCallbackRoute = createRoute({
+  getParentRoute: () => rootRoute,
+  path: '/oauth/callback',
+  component: OAuthCallbackPage,
+})
+
 const checkoutRoute = createRoute({
   getParentRoute: () => rootRoute,
   path: '/checkout/$planId',
@@ -105,6 +113,12 @@ const affiliateRoute = createRoute({
   component: AffiliateDashboard,
 })
 
+const refRoute = createRoute({
+  getParentRoute: () => rootRoute,
+  path: '/ref/$code',
+  component: RefRedirect,
+})
+
 const signupRoute = createRoute({
   getParentRoute: () => rootRoute,
   path: '/signup',
@@ -151,7 +165,7 @@ const stripeConnectRoute = createRoute({
   component: StripeConnectPage,
 })
 
-const routeTree = rootRoute.addChildren([indexRoute, loginRoute, signupRoute, adminPlanListRoute, adminPricingRoute, adminCouponsRoute, pricingRoute, checkoutRoute, walletRoute, affiliateRoute, paymentSuccessRoute, stripeConnectRoute])
+const routeTree = rootRoute.addChildren([indexRoute, loginRoute, oauthCallbackRoute, signupRoute, adminPlanListRoute, adminPricingRoute, adminCouponsRoute, pricingRoute, checkoutRoute, walletRoute, affiliateRoute, refRoute, paymentSuccessRoute, stripeConnectRoute])
 
 const router = createRouter({
   routeTree,
@@ -5,11 +5,14 @@ import { Input } from '@/components/ui/input';
 import { Card, CardContent, CardDescription, CardHeader, CardTitle, CardFooter } from '@/components/ui/card';
 import { Label } from '@/components/ui/label';
 import { Link } from '@tanstack/react-router';
+import { API_URL } from '@/lib/api';
 
 export function LoginPage() {
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
     const login = useLogin();
+    // Set by the backend when social login fails
+    const oauthError = new URLSearchParams(window.location.search).get('error');
 
     const handleSubmit = (e: React.FormEvent) => {
         e.preventDefault();
@@ -46,6 +49,9 @@ export function LoginPage() {
                                 required
                             />
                         </div>
+                        {oauthError && !login.error && (
+                            <div className="text-sm text-red-500">{oauthError}</div>
+                        )}
                         {login.error && (
                             <div className="text-sm text-red-500">
                                 {login.error instanceof Error ? login.error.message : 'Login failed'}
@@ -56,6 +62,14 @@ export function LoginPage() {
                         <Button type="submit" className="w-full" disabled={login.isPending}>
                             {login.isPending ? 'Signing in...' : 'Sign in'}
                         </Button>
+                        <div className="flex w-full gap-2">
+                            <Button type="button" variant="outline" className="w-full" onClick={() => window.location.assign(`${API_URL}/auth/oauth/google`)}>
+                                Google
+                            </Button>
+                            <Button type="button" variant="outline" className="w-full" onClick={() => window.location.assign(`${API_URL}/auth/oauth/github`)}>
+                                GitHub
+                            </Button>
+                        </div>
                         <div className="text-sm text-center text-gray-500">
                             Don't have an account?{' '}
                             <Link to="/signup" className="text-primary hover:underline">
@@ -0,0 +1,33 @@
+import { useEffect, useRef } from 'react';
+import { useNavigate } from '@tanstack/react-router';
+import { useQueryClient } from '@tanstack/react-query';
+
+// The backend finishes social login by redirecting here with the access token in the
+// fragment (the refresh token is already in its httpOnly cookie).
+export function OAuthCallbackPage() {
+    const navigate = useNavigate();
+    const queryClient = useQueryClient();
+    const handled = useRef(false);
+
+    useEffect(() => {
+        // StrictMode runs effects twice; the fragment is gone after the first run
+        if (handled.current) return;
+        handled.current = true;
+
+        const params = new URLSearchParams(window.location.hash.slice(1));
+        const accessToken = params.get('access_token');
+
+        // Drop the token from the address bar and history
+        window.history.replaceState(null, '', window.location.pathname);
+
+        if (!accessToken) {
+            window.location.replace('/login?error=' + encodeURIComponent('Social login failed'));
+            return;
+        }
+        localStorage.setItem('access_token', accessToken);
+        queryClient.invalidateQueries({ queryKey: ['me'] });
+        navigate({ to: '/' });
+    }, [navigate, queryClient]);
+
+    return <div className="p-4">Signing you in...</div>;
+}
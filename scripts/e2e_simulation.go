@@ -52,23 +52,14 @@ func request(method, url string, body interface{}, dest interface{}) error {
 	return nil
 }
 
-func main() {
-	fmt.Println("🚀 Starting Payment Ecosystem E2E Simulation...")
-
-	// 0. Auth: Register and Login
-	fmt.Print("\n0️⃣  Authenticating... ")
-	email := fmt.Sprintf("e2e_user_%d@example.com", time.Now().Unix())
-	password := "password123"
-
-	// Register
+func registerAndLogin(email, password, name string) string {
 	regReq := map[string]interface{}{
 		"email":     email,
 		"password":  password,
-		"full_name": "E2E User",
+		"full_name": name,
 	}
 	request("POST", "/auth/register", regReq, nil)
 
-	// Login
 	loginReq := map[string]interface{}{
 		"email":    email,
 		"password": password,
@@ -78,8 +69,30 @@ func main() {
 		fmt.Printf("FAILED to Login: %v\n", err)
 		os.Exit(1)
 	}
-	authToken = loginResp["access_token"].(string)
-	fmt.Printf("✅ Authenticated as %s\n", email)
+	return loginResp["access_token"].(string)
+}
+
+func main() {
+	fmt.Println("🚀 Starting Payment Ecosystem E2E Simulation...")
+
+	// Payment success is simulated through the mock webhook, which the server only exposes
+	// with ALLOW_MOCK_WEBHOOKS=true and only to admins
+	adminToken := os.Getenv("E2E_ADMIN_TOKEN")
+	if adminToken == "" {
+		fmt.Println("E2E_ADMIN_TOKEN must hold an admin's access token (server started with ALLOW_MOCK_WEBHOOKS=true)")
+		os.Exit(1)
+	}
+
+	// 0. Auth: Register and Login a creator and an affiliate
+	fmt.Print("\n0️⃣  Authenticating... ")
+	password := "password123"
+	creatorEmail := fmt.Sprintf("e2e_creator_%d@example.com", time.Now().Unix())
+	affiliateEmail := fmt.Sprintf("e2e_affiliate_%d@example.com", time.Now().Unix())
+
+	creatorToken := registerAndLogin(creatorEmail, password, "E2E Creator")
+	affiliateToken := registerAndLogin(affiliateEmail, password, "E2E Affiliate")
+	authToken = creatorToken
+	fmt.Printf("✅ Authenticated as %s and %s\n", creatorEmail, affiliateEmail)
 
 	// 1. Create a Plan
 	fmt.Print("\n1️⃣  Creating Pricing Plan... ")
@@ -100,10 +113,9 @@ func main() {
 	planID := planResp["id"].(string)
 	fmt.Printf("✅ Created Plan: %s\n", planID)
 
-	// 2. Affiliate: Join Program (Generate Link)
+	// 2. Creator: Create Program, Affiliate: Enroll and Generate Link
 	fmt.Print("\n2️⃣  Generating Affiliate Link... ")
-	// Assuming Program exists or we create one. Let's create one first to be safe (dev endpoint)
-	programReq := map[string]interface{}{"rate": 20.0} // 20%
+	programReq := map[string]interface{}{"name": "E2E Program", "rate": 20.0} // 20%, open enrollment
 	var progResp map[string]interface{}
 	if err := request("POST", "/affiliate/programs", programReq, &progResp); err != nil {
 		fmt.Printf("FAILED: %v\n", err)
@@ -112,6 +124,12 @@ func main() {
 	programID := progResp["id"].(string)
 	fmt.Printf("✅ Created Program: %s\n", programID)
 
+	authToken = affiliateToken
+	if err := request("POST", "/affiliate/programs/"+programID+"/enroll", nil, nil); err != nil {
+		fmt.Printf("FAILED to Enroll: %v\n", err)
+		os.Exit(1)
+	}
+
 	affiliateCode := fmt.Sprintf("TEST%d", time.Now().Unix())
 	linkReq := map[string]interface{}{
 		"program_id": programID,
@@ -124,6 +142,9 @@ func main() {
 	}
 	fmt.Printf("✅ Generated Code: %s\n", affiliateCode)
 
+	// The creator buys their own plan; any buyer other than the affiliate works
+	authToken = creatorToken
+
 	// 3. Buyer: Checkout with Code
 	fmt.Print("\n3️⃣  Initiating Checkout... ")
 	checkoutReq := map[string]interface{}{
@@ -143,25 +164,47 @@ func main() {
 		"amount":   100.0,
 		"currency": "USD",
 		"metadata": map[string]string{
+			"order_id":       checkoutResp["order_id"].(string),
 			"plan_id":        planID,
 			"user_id":        "mock_buyer",
 			"affiliate_code": affiliateCode,
 		},
 	}
+	authToken = adminToken
 	if err := request("POST", "/payment/webhook/mock", webhookReq, nil); err != nil {
 		fmt.Printf("FAILED: %v\n", err)
 		os.Exit(1)
 	}
+	authToken = creatorToken
 	fmt.Println("✅ Webhook Processed")
 
-	// 5. Verify Commission (Wallet Balance)
-	fmt.Print("\n5️⃣  Verifying Affiliate Wallet... ")
-	var walletResp map[string]interface{}
+	// 5. Creator approves the pending commission (otherwise it matures after the refund window)
+	fmt.Print("\n5️⃣  Approving Commission... ")
+	var pending []map[string]interface{}
+	if err := request("GET", "/affiliate/commissions?status=pending", nil, &pending); err != nil || len(pending) == 0 {
+		fmt.Printf("FAILED: no pending commission (%v)\n", err)
+		os.Exit(1)
+	}
+	if err := request("POST", "/affiliate/commissions/"+pending[0]["id"].(string)+"/approve", nil, nil); err != nil {
+		fmt.Printf("FAILED: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Commission Approved")
+
+	// Verify Commission (Wallet Balance)
+	fmt.Print("\n   Verifying Affiliate Wallet... ")
+	authToken = affiliateToken
+	var walletResp []map[string]interface{}
 	if err := request("GET", "/wallet/balance", nil, &walletResp); err != nil {
 		fmt.Printf("FAILED: %v\n", err)
 		os.Exit(1)
 	}
-	balance := walletResp["balance"].(float64)
+	balance := 0.0
+	for _, w := range walletResp {
+		if w["currency"] == "USD" {
+			balance = w["balance"].(float64)
+		}
+	}
 	fmt.Printf("✅ Wallet Balance: $%.2f (Expected >= $20.00)\n", balance)
 
 	if balance < 20.0 {
@@ -172,8 +215,9 @@ func main() {
 	// 6. Request Payout
 	fmt.Print("\n6️⃣  Requesting Payout... ")
 	payoutReq := map[string]interface{}{
-		"amount": 20.0,
-		"method": "stripe",
+		"amount":   20.0,
+		"currency": "USD",
+		"method":   "stripe",
 	}
 	if err := request("POST", "/wallet/payouts", payoutReq, nil); err != nil {
 		fmt.Printf("FAILED: %v\n", err)